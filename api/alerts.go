@@ -0,0 +1,120 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"securefingerprint/internal/alerting"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AlertsAPI struct {
+	engine      *alerting.Engine
+	deadLetters alerting.DeadLetterStore
+}
+
+func NewAlertsAPI(engine *alerting.Engine, deadLetters alerting.DeadLetterStore) *AlertsAPI {
+	return &AlertsAPI{
+		engine:      engine,
+		deadLetters: deadLetters,
+	}
+}
+
+// 获取告警规则与Webhook配置
+func (api *AlertsAPI) GetRules(c *gin.Context) {
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"rules":    api.engine.Rules(),
+			"webhooks": api.engine.Notifier().Webhooks(),
+		},
+	})
+}
+
+// 发送测试告警，可通过webhook参数指定目标
+func (api *AlertsAPI) TestWebhook(c *gin.Context) {
+	results, err := api.engine.Notifier().SendTest(c.Request.Context(), c.Query("webhook"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, ConfigResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	success := true
+	for _, result := range results {
+		if result.Error != "" {
+			success = false
+		}
+	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: success,
+		Data:    results,
+	})
+}
+
+// 获取死信列表
+func (api *AlertsAPI) GetDeadLetters(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit < 1 || limit > 1000 {
+		limit = 100
+	}
+
+	letters, err := api.deadLetters.List(c.Request.Context(), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ConfigResponse{
+			Success: false,
+			Error:   "获取死信失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Data:    letters,
+	})
+}
+
+// 重新投递全部死信
+func (api *AlertsAPI) ReplayDeadLetters(c *gin.Context) {
+	letters, err := api.deadLetters.Drain(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ConfigResponse{
+			Success: false,
+			Error:   "读取死信失败: " + err.Error(),
+		})
+		return
+	}
+
+	requeued := 0
+	for _, letter := range letters {
+		if api.engine.Notifier().Redeliver(letter.Webhook, letter.Alert) {
+			requeued++
+			continue
+		}
+		// 无法入队的放回死信
+		api.deadLetters.Push(c.Request.Context(), letter)
+	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"total":    len(letters),
+			"requeued": requeued,
+		},
+	})
+}
+
+// 注册告警API路由
+func (api *AlertsAPI) RegisterRoutes(router *gin.RouterGroup) {
+	alerts := router.Group("/alerts")
+	{
+		alerts.GET("/rules", api.GetRules)
+		alerts.POST("/test", api.TestWebhook)
+		alerts.GET("/dead-letters", api.GetDeadLetters)
+		alerts.POST("/dead-letters/replay", api.ReplayDeadLetters)
+	}
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"securefingerprint/internal/cluster"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ClusterAPI struct {
+	clusterer *cluster.Clusterer
+}
+
+func NewClusterAPI(clusterer *cluster.Clusterer) *ClusterAPI {
+	return &ClusterAPI{clusterer: clusterer}
+}
+
+// 查询指纹所属身份及身份中的全部指纹
+func (api *ClusterAPI) GetIdentity(c *gin.Context) {
+	identity, ok := api.lookup(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"fingerprint": c.Param("fingerprint"),
+			"identity":    identity.ID,
+			"members":     identity.Members,
+			"total":       len(identity.Members),
+		},
+	})
+}
+
+// 封禁指纹所属身份的全部指纹，之后加入该身份的指纹同样被封禁
+func (api *ClusterAPI) BanIdentity(c *gin.Context) {
+	var req struct {
+		Reason   string `json:"reason" binding:"required"`
+		Duration string `json:"duration" binding:"required"` // 如: "1h", "24h"
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Error:   "无效的请求参数: " + err.Error(),
+		})
+		return
+	}
+
+	duration, err := time.ParseDuration(req.Duration)
+	if err != nil || duration <= 0 {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Error:   "无效的持续时间格式",
+		})
+		return
+	}
+	if duration > 7*24*time.Hour {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Error:   "封禁时间不能超过7天",
+		})
+		return
+	}
+
+	identity, ok := api.lookup(c)
+	if !ok {
+		return
+	}
+	count, err := api.clusterer.BanIdentity(identity.ID, duration)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ConfigResponse{
+			Success: false,
+			Error:   "封禁身份失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Message: "身份封禁成功",
+		Data: map[string]interface{}{
+			"identity":     identity.ID,
+			"fingerprints": count,
+			"reason":       req.Reason,
+			"duration":     req.Duration,
+			"expires_at":   time.Now().Add(duration),
+		},
+	})
+}
+
+// 解除指纹所属身份的封禁
+func (api *ClusterAPI) UnbanIdentity(c *gin.Context) {
+	identity, ok := api.lookup(c)
+	if !ok {
+		return
+	}
+	count, err := api.clusterer.UnbanIdentity(identity.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ConfigResponse{
+			Success: false,
+			Error:   "解除身份封禁失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Message: "身份封禁已解除",
+		Data: map[string]interface{}{
+			"identity":     identity.ID,
+			"fingerprints": count,
+		},
+	})
+}
+
+// 查询路径参数中指纹所属的身份，失败时写入错误响应
+func (api *ClusterAPI) lookup(c *gin.Context) (*cluster.Identity, bool) {
+	identity, err := api.clusterer.Identity(c.Param("fingerprint"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ConfigResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+	if identity == nil {
+		c.JSON(http.StatusNotFound, ConfigResponse{
+			Success: false,
+			Error:   "指纹未归入任何身份",
+		})
+		return nil, false
+	}
+	return identity, true
+}
+
+// 注册身份聚类API路由
+func (api *ClusterAPI) RegisterRoutes(router *gin.RouterGroup) {
+	identities := router.Group("/identities")
+	{
+		identities.GET("/:fingerprint", api.GetIdentity)
+		identities.POST("/:fingerprint/ban", api.BanIdentity)
+		identities.DELETE("/:fingerprint/ban", api.UnbanIdentity)
+	}
+}
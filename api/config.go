@@ -197,6 +197,14 @@ func (api *ConfigAPI) UpdateLimiterConfig(c *gin.Context) {
 		return
 	}
 
+	if err := config.Shadow.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
 	// 应用新配置
 	api.limiter.UpdateConfig(config)
 
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"securefingerprint/internal/events"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+type EventsAPI struct {
+	bus      *events.Bus
+	upgrader websocket.Upgrader
+}
+
+func NewEventsAPI(bus *events.Bus) *EventsAPI {
+	return &EventsAPI{
+		bus: bus,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 4096,
+			// 与CORS中间件保持一致，允许跨域的管理界面连接
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+// 解析订阅参数：过滤条件和恢复游标（Last-Event-ID头或cursor参数）
+func (api *EventsAPI) subscribe(c *gin.Context) (*events.Subscription, error) {
+	filter, err := events.FilterFromQuery(c.Request.URL.Query())
+	if err != nil {
+		return nil, err
+	}
+
+	rawCursor := c.GetHeader("Last-Event-ID")
+	if rawCursor == "" {
+		rawCursor = c.Query("cursor")
+	}
+	var cursor events.Cursor
+	if rawCursor != "" {
+		cursor, err = events.ParseCursor(rawCursor)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return api.bus.Subscribe(filter, cursor), nil
+}
+
+// SSE决策事件流
+func (api *EventsAPI) StreamSSE(c *gin.Context) {
+	sub, err := api.subscribe(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	// 长连接不受服务器WriteTimeout限制
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	if sub.Gap {
+		fmt.Fprintf(c.Writer, "event: reset\ndata: {}\n\n")
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(api.bus.Heartbeat())
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprintf(c.Writer, ": heartbeat %d\n\n", time.Now().Unix())
+			c.Writer.Flush()
+		case event, ok := <-sub.C:
+			if !ok {
+				if errors.Is(sub.Err(), events.ErrSlowConsumer) {
+					fmt.Fprintf(c.Writer, "event: overflow\ndata: {\"error\":%q}\n\n", sub.Err().Error())
+					c.Writer.Flush()
+				}
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			// 没有游标的事件不写id行，空id会清除浏览器记录的Last-Event-ID
+			if event.Cursor.ID > 0 {
+				fmt.Fprintf(c.Writer, "id: %s\n", event.Cursor)
+			}
+			fmt.Fprintf(c.Writer, "event: decision\ndata: %s\n\n", data)
+			c.Writer.Flush()
+		}
+	}
+}
+
+// WebSocket消息
+type eventMessage struct {
+	Type  string        `json:"type"` // decision, reset, overflow, heartbeat
+	Event *events.Event `json:"event,omitempty"`
+	Error string        `json:"error,omitempty"`
+	Time  time.Time     `json:"time,omitempty"`
+}
+
+// WebSocket决策事件流
+func (api *EventsAPI) StreamWebSocket(c *gin.Context) {
+	sub, err := api.subscribe(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	defer sub.Close()
+
+	conn, err := api.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	heartbeat := api.bus.Heartbeat()
+	writeTimeout := 10 * time.Second
+
+	// 读循环：处理pong与客户端关闭
+	closed := make(chan struct{})
+	conn.SetReadLimit(1024)
+	conn.SetReadDeadline(time.Now().Add(heartbeat * 3))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(heartbeat * 3))
+	})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	write := func(message eventMessage) error {
+		conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		return conn.WriteJSON(message)
+	}
+
+	if sub.Gap {
+		if err := write(eventMessage{Type: "reset"}); err != nil {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+			if err := write(eventMessage{Type: "heartbeat", Time: time.Now()}); err != nil {
+				return
+			}
+		case event, ok := <-sub.C:
+			if !ok {
+				if errors.Is(sub.Err(), events.ErrSlowConsumer) {
+					write(eventMessage{Type: "overflow", Error: sub.Err().Error()})
+				}
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, ""),
+					time.Now().Add(time.Second))
+				return
+			}
+			if err := write(eventMessage{Type: "decision", Event: &event}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// 注册事件流API路由
+func (api *EventsAPI) RegisterRoutes(router *gin.RouterGroup) {
+	eventsGroup := router.Group("/events")
+	{
+		eventsGroup.GET("/stream", api.StreamSSE)
+		eventsGroup.GET("/ws", api.StreamWebSocket)
+	}
+}
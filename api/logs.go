@@ -1,11 +1,15 @@
 package api
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"securefingerprint/internal/export"
 	"securefingerprint/internal/storage"
 
 	"github.com/gin-gonic/gin"
@@ -14,36 +18,22 @@ import (
 type LogsAPI struct {
 	mysqlClient *storage.MySQLClient
 	redisClient *storage.RedisClient
+	events      *EventsAPI
+	exports     *export.JobManager
 }
 
-func NewLogsAPI(mysqlClient *storage.MySQLClient, redisClient *storage.RedisClient) *LogsAPI {
+func NewLogsAPI(mysqlClient *storage.MySQLClient, redisClient *storage.RedisClient, events *EventsAPI, exports *export.JobManager) *LogsAPI {
 	return &LogsAPI{
 		mysqlClient: mysqlClient,
 		redisClient: redisClient,
+		events:      events,
+		exports:     exports,
 	}
 }
 
-// 查询访问日志
-func (api *LogsAPI) GetAccessLogs(c *gin.Context) {
-	// 构建查询条件
+// 解析访问记录筛选参数
+func parseAccessRecordQuery(c *gin.Context) (*storage.AccessRecordQuery, error) {
 	query := &storage.AccessRecordQuery{}
-	
-	// 分页参数
-	pageStr := c.DefaultQuery("page", "1")
-	sizeStr := c.DefaultQuery("size", "20")
-	
-	page, _ := strconv.Atoi(pageStr)
-	size, _ := strconv.Atoi(sizeStr)
-	
-	if page < 1 {
-		page = 1
-	}
-	if size < 1 || size > 100 {
-		size = 20
-	}
-	
-	query.Limit = size
-	query.Offset = (page - 1) * size
 
 	// 筛选参数
 	query.Fingerprint = c.Query("fingerprint")
@@ -52,14 +42,22 @@ func (api *LogsAPI) GetAccessLogs(c *gin.Context) {
 	query.Path = c.Query("path")
 	query.Method = c.Query("method")
 	query.Action = c.Query("action")
-	
+	query.Country = c.Query("country")
+	if asnStr := c.Query("asn"); asnStr != "" {
+		asn, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(asnStr), "AS"), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("无效的ASN")
+		}
+		query.ASN = uint(asn)
+	}
+
 	// 分数范围
 	if minScoreStr := c.Query("min_score"); minScoreStr != "" {
 		if minScore, err := strconv.Atoi(minScoreStr); err == nil {
 			query.MinScore = &minScore
 		}
 	}
-	
+
 	if maxScoreStr := c.Query("max_score"); maxScoreStr != "" {
 		if maxScore, err := strconv.Atoi(maxScoreStr); err == nil {
 			query.MaxScore = &maxScore
@@ -68,33 +66,67 @@ func (api *LogsAPI) GetAccessLogs(c *gin.Context) {
 
 	// 时间范围
 	if startTimeStr := c.Query("start_time"); startTimeStr != "" {
-		if startTime, err := time.Parse("2006-01-02T15:04:05Z07:00", startTimeStr); err == nil {
-			query.StartTime = startTime
-		} else {
-			c.JSON(http.StatusBadRequest, ConfigResponse{
-				Success: false,
-				Error:   "无效的开始时间格式",
-			})
-			return
+		startTime, err := time.Parse("2006-01-02T15:04:05Z07:00", startTimeStr)
+		if err != nil {
+			return nil, fmt.Errorf("无效的开始时间格式")
 		}
+		query.StartTime = startTime
 	}
 
 	if endTimeStr := c.Query("end_time"); endTimeStr != "" {
-		if endTime, err := time.Parse("2006-01-02T15:04:05Z07:00", endTimeStr); err == nil {
-			query.EndTime = endTime
-		} else {
-			c.JSON(http.StatusBadRequest, ConfigResponse{
-				Success: false,
-				Error:   "无效的结束时间格式",
-			})
-			return
+		endTime, err := time.Parse("2006-01-02T15:04:05Z07:00", endTimeStr)
+		if err != nil {
+			return nil, fmt.Errorf("无效的结束时间格式")
 		}
+		query.EndTime = endTime
 	}
 
 	// 排序参数
 	query.OrderBy = c.DefaultQuery("order_by", "timestamp")
 	query.OrderDir = c.DefaultQuery("order_dir", "DESC")
 
+	return query, nil
+}
+
+// 查询访问日志，传入cursor参数时使用游标分页（cursor=0表示从最新开始）
+func (api *LogsAPI) GetAccessLogs(c *gin.Context) {
+	// 构建查询条件
+	query, err := parseAccessRecordQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// 分页参数
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 20
+	}
+
+	query.Limit = size
+	query.Offset = (page - 1) * size
+
+	if cursorStr, ok := c.GetQuery("cursor"); ok {
+		cursor, err := strconv.ParseInt(cursorStr, 10, 64)
+		if err != nil || cursor < 0 {
+			c.JSON(http.StatusBadRequest, ConfigResponse{
+				Success: false,
+				Error:   "无效的游标参数",
+			})
+			return
+		}
+		query.Cursor = &cursor
+		query.Offset = 0
+	}
+
 	// 查询日志
 	result, err := api.mysqlClient.QueryAccessRecords(query)
 	if err != nil {
@@ -207,92 +239,135 @@ func (api *LogsAPI) getTopIPs() []map[string]interface{} {
 	}
 }
 
-// 导出日志
-func (api *LogsAPI) ExportLogs(c *gin.Context) {
-	format := c.DefaultQuery("format", "json")
-	fingerprint := c.Query("fingerprint")
-	startTimeStr := c.Query("start_time")
-	endTimeStr := c.Query("end_time")
+// 解析导出选项
+func parseExportOptions(c *gin.Context) (export.Options, error) {
+	opts := export.Options{
+		Format: c.DefaultQuery("format", export.FormatJSON),
+		Gzip:   c.Query("gzip") == "true",
+	}
+	return opts, opts.Validate()
+}
 
-	var startTime, endTime time.Time
-	var err error
+// 流式导出日志，支持全部筛选参数，行数不设上限
+func (api *LogsAPI) ExportLogs(c *gin.Context) {
+	query, err := parseAccessRecordQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
 
-	if startTimeStr != "" {
-		startTime, err = time.Parse("2006-01-02T15:04:05Z07:00", startTimeStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, ConfigResponse{
-				Success: false,
-				Error:   "无效的开始时间格式",
-			})
-			return
-		}
+	opts, err := parseExportOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
 	}
 
-	if endTimeStr != "" {
-		endTime, err = time.Parse("2006-01-02T15:04:05Z07:00", endTimeStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, ConfigResponse{
-				Success: false,
-				Error:   "无效的结束时间格式",
-			})
-			return
-		}
+	filename := "access_logs_" + time.Now().Format("20060102_150405") + "." + opts.Extension()
+
+	c.Header("Content-Type", opts.ContentType())
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Status(http.StatusOK)
+
+	// 大量导出耗时可能超过服务器WriteTimeout，不设写超时，避免响应被截断
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	// 响应头已发送，导出中途出错只能记录日志并截断响应
+	rows, err := export.Write(c.Request.Context(), c.Writer, api.mysqlClient, query, opts)
+	if err != nil {
+		log.Printf("导出日志失败(已写入%d行): %v", rows, err)
 	}
+}
 
-	// 查询所有符合条件的日志
-	logs, err := api.mysqlClient.GetAccessLogs(fingerprint, 10000, 0, startTime, endTime)
+// 创建异步导出任务
+func (api *LogsAPI) CreateExportJob(c *gin.Context) {
+	query, err := parseAccessRecordQuery(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ConfigResponse{
+		c.JSON(http.StatusBadRequest, ConfigResponse{
 			Success: false,
-			Error:   "导出日志失败: " + err.Error(),
+			Error:   err.Error(),
 		})
 		return
 	}
 
-	switch format {
-	case "csv":
-		api.exportLogsAsCSV(c, logs)
-	case "json":
-		api.exportLogsAsJSON(c, logs)
-	default:
+	opts, err := parseExportOptions(c)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, ConfigResponse{
 			Success: false,
-			Error:   "不支持的导出格式，支持: json, csv",
+			Error:   err.Error(),
 		})
+		return
+	}
+
+	job, err := api.exports.Submit(*query, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ConfigResponse{
+			Success: false,
+			Error:   "创建导出任务失败: " + err.Error(),
+		})
+		return
 	}
+
+	c.JSON(http.StatusAccepted, ConfigResponse{
+		Success: true,
+		Data:    job,
+		Message: "导出任务已创建",
+	})
 }
 
-// 导出为JSON格式
-func (api *LogsAPI) exportLogsAsJSON(c *gin.Context, logs []storage.AccessRecord) {
-	filename := "access_logs_" + time.Now().Format("20060102_150405") + ".json"
-	
-	c.Header("Content-Type", "application/json")
-	c.Header("Content-Disposition", "attachment; filename="+filename)
-	
-	c.JSON(http.StatusOK, map[string]interface{}{
-		"export_time": time.Now(),
-		"total_count": len(logs),
-		"logs":        logs,
+// 导出任务列表
+func (api *LogsAPI) ListExportJobs(c *gin.Context) {
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Data:    api.exports.List(),
 	})
 }
 
-// 导出为CSV格式
-func (api *LogsAPI) exportLogsAsCSV(c *gin.Context, logs []storage.AccessRecord) {
-	filename := "access_logs_" + time.Now().Format("20060102_150405") + ".csv"
-	
-	c.Header("Content-Type", "text/csv")
-	c.Header("Content-Disposition", "attachment; filename="+filename)
-	
-	// CSV头部
-	c.Writer.WriteString("ID,Fingerprint,IP,UserAgent,Path,Method,Score,Action,Timestamp\n")
-	
-	// 写入数据
-	for _, log := range logs {
-		c.Writer.WriteString(fmt.Sprintf("%d,%s,%s,\"%s\",%s,%s,%d,%s,%s\n",
-			log.ID, log.Fingerprint, log.IP, log.UserAgent, 
-			log.Path, log.Method, log.Score, log.Action, 
-			log.Timestamp.Format("2006-01-02 15:04:05")))
+// 查询导出任务状态
+func (api *LogsAPI) GetExportJob(c *gin.Context) {
+	job, err := api.exports.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, ConfigResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
 	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Data:    job,
+	})
+}
+
+// 下载已完成的导出文件
+func (api *LogsAPI) DownloadExportJob(c *gin.Context) {
+	job, path, err := api.exports.FilePath(c.Param("id"))
+	if errors.Is(err, export.ErrJobNotFound) {
+		c.JSON(http.StatusNotFound, ConfigResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusConflict, ConfigResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// 大文件下载不受服务器WriteTimeout限制
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	c.Header("Content-Type", job.Options.ContentType())
+	c.FileAttachment(path, job.Filename())
 }
 
 // 清理日志
@@ -323,30 +398,9 @@ func (api *LogsAPI) CleanupLogs(c *gin.Context) {
 	})
 }
 
-// 获取实时日志流
+// 获取实时日志流（兼容旧路由，等同于 /events/stream）
 func (api *LogsAPI) GetRealtimeLogs(c *gin.Context) {
-	// 设置SSE头
-	c.Header("Content-Type", "text/event-stream")
-	c.Header("Cache-Control", "no-cache")
-	c.Header("Connection", "keep-alive")
-	c.Header("Access-Control-Allow-Origin", "*")
-
-	// 模拟实时日志推送
-	for i := 0; i < 10; i++ {
-		log := map[string]interface{}{
-			"id":          i + 1,
-			"fingerprint": fmt.Sprintf("fp_%d", i+1),
-			"ip":          fmt.Sprintf("192.168.1.%d", 100+i),
-			"path":        "/api/test",
-			"action":      "allow",
-			"timestamp":   time.Now(),
-		}
-
-		c.SSEvent("log", log)
-		c.Writer.Flush()
-		
-		time.Sleep(time.Second)
-	}
+	api.events.StreamSSE(c)
 }
 
 // 搜索日志
@@ -507,6 +561,8 @@ func (api *LogsAPI) AdvancedSearchLogs(c *gin.Context) {
 		Path        string `json:"path,omitempty"`
 		Method      string `json:"method,omitempty"`
 		Action      string `json:"action,omitempty"`
+		Country     string `json:"country,omitempty"`
+		ASN         uint   `json:"asn,omitempty"`
 		MinScore    *int   `json:"min_score,omitempty"`
 		MaxScore    *int   `json:"max_score,omitempty"`
 		StartTime   string `json:"start_time,omitempty"`
@@ -533,6 +589,8 @@ func (api *LogsAPI) AdvancedSearchLogs(c *gin.Context) {
 		Path:        searchReq.Path,
 		Method:      searchReq.Method,
 		Action:      searchReq.Action,
+		Country:     searchReq.Country,
+		ASN:         searchReq.ASN,
 		MinScore:    searchReq.MinScore,
 		MaxScore:    searchReq.MaxScore,
 		OrderBy:     searchReq.OrderBy,
@@ -587,6 +645,10 @@ func (api *LogsAPI) RegisterRoutes(router *gin.RouterGroup) {
 		logs.GET("/recent", api.GetRecentAccessLogs)
 		logs.GET("/stats", api.GetLogStats)
 		logs.GET("/export", api.ExportLogs)
+		logs.POST("/export/jobs", api.CreateExportJob)
+		logs.GET("/export/jobs", api.ListExportJobs)
+		logs.GET("/export/jobs/:id", api.GetExportJob)
+		logs.GET("/export/jobs/:id/download", api.DownloadExportJob)
 		logs.GET("/realtime", api.GetRealtimeLogs)
 		logs.GET("/search", api.SearchLogs)
 		logs.DELETE("/cleanup", api.CleanupLogs)
@@ -13,13 +13,11 @@ type ProxyAPI struct {
 	detector  *collector.ProxyDetector
 }
 
-func NewProxyAPI(collector *collector.Collector) *ProxyAPI {
-	// 使用默认代理配置创建检测器
-	detector, _ := collector.NewProxyDetector(collector.DefaultProxyConfig)
-	
+func NewProxyAPI(c *collector.Collector) *ProxyAPI {
+	// 与采集器使用同一个检测器，保证检测结果与实际决策一致
 	return &ProxyAPI{
-		collector: collector,
-		detector:  detector,
+		collector: c,
+		detector:  c.ProxyDetector(),
 	}
 }
 
@@ -54,7 +52,7 @@ func (api *ProxyAPI) GetProxyInfo(c *gin.Context) {
 
 // 获取代理配置信息
 func (api *ProxyAPI) GetProxyConfig(c *gin.Context) {
-	config := collector.DefaultProxyConfig
+	config := api.detector.Config()
 
 	response := map[string]interface{}{
 		"trusted_proxies":    config.TrustedProxies,
@@ -62,6 +60,8 @@ func (api *ProxyAPI) GetProxyConfig(c *gin.Context) {
 		"header_priority":    config.HeaderPriority,
 		"skip_private_ranges": config.SkipPrivateRanges,
 		"max_proxy_depth":    config.MaxProxyDepth,
+		"header_proxies":     config.HeaderProxies,
+		"header_order":       api.detector.Headers(),
 	}
 
 	c.JSON(http.StatusOK, ConfigResponse{
@@ -165,7 +165,7 @@ func (api *ProxyAPI) ValidateProxyConfig(c *gin.Context) {
 		return
 	}
 
-	// 尝试创建检测器以验证配置
+	// 尝试创建检测器以验证配置（修改生效需更新配置文件中的proxy段并重启）
 	detector, err := collector.NewProxyDetector(configReq)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, ConfigResponse{
@@ -175,21 +175,20 @@ func (api *ProxyAPI) ValidateProxyConfig(c *gin.Context) {
 		return
 	}
 
-	// 验证成功，返回配置摘要
+	// 验证成功，返回填充默认值后的配置摘要
+	effective := detector.Config()
 	validation := map[string]interface{}{
 		"valid": true,
 		"summary": map[string]interface{}{
-			"trusted_proxy_count": len(configReq.TrustedProxies),
-			"trusted_header_count": len(configReq.TrustedHeaders),
-			"max_proxy_depth":     configReq.MaxProxyDepth,
-			"skip_private_ranges": configReq.SkipPrivateRanges,
+			"trusted_proxy_count": len(effective.TrustedProxies),
+			"trusted_header_count": len(effective.TrustedHeaders),
+			"max_proxy_depth":     effective.MaxProxyDepth,
+			"skip_private_ranges": effective.SkipPrivateRanges,
+			"header_order":        detector.Headers(),
 		},
-		"warnings": api.validateConfigWarnings(&configReq),
+		"warnings": api.validateConfigWarnings(&effective),
 	}
 
-	// 更新检测器（在实际应用中可能需要持久化配置）
-	api.detector = detector
-
 	c.JSON(http.StatusOK, ConfigResponse{
 		Success: true,
 		Data:    validation,
@@ -221,6 +220,13 @@ func (api *ProxyAPI) validateConfigWarnings(config *collector.ProxyConfig) []str
 		warnings = append(warnings, "建议添加内网IP段到可信代理列表")
 	}
 
+	// 信任所有地址等同于采信任意客户端伪造的转发头
+	for _, proxy := range config.TrustedProxies {
+		if proxy == "0.0.0.0/0" || proxy == "::/0" {
+			warnings = append(warnings, "可信代理包含 "+proxy+"，任何客户端都可以通过转发头伪造IP")
+		}
+	}
+
 	// 检查头优先级配置
 	if len(config.HeaderPriority) == 0 {
 		warnings = append(warnings, "未配置头优先级，将使用默认优先级")
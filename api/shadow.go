@@ -0,0 +1,124 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"securefingerprint/internal/limiter"
+	"securefingerprint/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ShadowAPI struct {
+	limiter     *limiter.Limiter
+	mysqlClient *storage.MySQLClient
+}
+
+func NewShadowAPI(limiter *limiter.Limiter, mysqlClient *storage.MySQLClient) *ShadowAPI {
+	return &ShadowAPI{
+		limiter:     limiter,
+		mysqlClient: mysqlClient,
+	}
+}
+
+// 获取影子模式配置
+func (api *ShadowAPI) GetConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"shadow":            api.limiter.ShadowConfig(),
+			"available_sources": limiter.Sources,
+		},
+	})
+}
+
+// 更新影子模式配置（运行时生效，重启后以配置文件为准）
+func (api *ShadowAPI) UpdateConfig(c *gin.Context) {
+	var shadow limiter.ShadowConfig
+	if err := c.ShouldBindJSON(&shadow); err != nil {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Error:   "无效的配置格式: " + err.Error(),
+		})
+		return
+	}
+
+	if err := shadow.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	api.limiter.UpdateShadow(shadow)
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Message: "影子模式配置更新成功",
+		Data:    shadow,
+	})
+}
+
+// 对比时间范围内影子决策与实际执行结果，默认最近24小时
+func (api *ShadowAPI) GetReport(c *gin.Context) {
+	endTime := time.Now()
+	startTime := endTime.Add(-24 * time.Hour)
+
+	if startTimeStr := c.Query("start_time"); startTimeStr != "" {
+		t, err := time.Parse("2006-01-02T15:04:05Z07:00", startTimeStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ConfigResponse{
+				Success: false,
+				Error:   "无效的开始时间格式",
+			})
+			return
+		}
+		startTime = t
+	}
+
+	if endTimeStr := c.Query("end_time"); endTimeStr != "" {
+		t, err := time.Parse("2006-01-02T15:04:05Z07:00", endTimeStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ConfigResponse{
+				Success: false,
+				Error:   "无效的结束时间格式",
+			})
+			return
+		}
+		endTime = t
+	}
+
+	if !startTime.Before(endTime) {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Error:   "开始时间必须早于结束时间",
+		})
+		return
+	}
+
+	report, err := api.mysqlClient.GetShadowReport(startTime, endTime)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ConfigResponse{
+			Success: false,
+			Error:   "生成影子模式报告失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Data:    report,
+	})
+}
+
+// 注册影子模式API路由
+func (api *ShadowAPI) RegisterRoutes(router *gin.RouterGroup) {
+	shadow := router.Group("/shadow")
+	{
+		shadow.GET("/config", api.GetConfig)
+		shadow.PUT("/config", api.UpdateConfig)
+		shadow.GET("/report", api.GetReport)
+	}
+}
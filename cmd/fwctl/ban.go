@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// 服务端单次批量封禁上限
+const batchBanLimit = 50
+
+func newBanCmd(o *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ban",
+		Short: "管理封禁",
+	}
+
+	var page, size int
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "列出封禁用户",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := o.client()
+			if err != nil {
+				return err
+			}
+			query := url.Values{"page": {strconv.Itoa(page)}, "size": {strconv.Itoa(size)}}
+			data, err := c.data(cmd.Context(), http.MethodGet, "/rule/ban", query, nil)
+			if err != nil {
+				return err
+			}
+			if o.output != outputTable {
+				return o.print(cmd, data)
+			}
+			return o.print(cmd, listField(data, "users"), "fingerprint", "ip", "reason", "banned_at", "expires_at", "ban_count")
+		},
+	}
+	listCmd.Flags().IntVar(&page, "page", 1, "页码")
+	listCmd.Flags().IntVar(&size, "size", 20, "每页数量")
+
+	var reason, duration string
+	addCmd := &cobra.Command{
+		Use:   "add <fingerprint>...",
+		Short: "封禁用户",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := o.client()
+			if err != nil {
+				return err
+			}
+			if len(args) > 1 {
+				return o.batchBan(cmd, c, args, reason, duration)
+			}
+			body := map[string]string{"fingerprint": args[0], "reason": reason, "duration": duration}
+			data, err := c.data(cmd.Context(), http.MethodPost, "/rule/ban", nil, body)
+			if err != nil {
+				return err
+			}
+			return o.print(cmd, data)
+		},
+	}
+	addCmd.Flags().StringVar(&reason, "reason", "手动封禁", "封禁原因")
+	addCmd.Flags().StringVar(&duration, "duration", "24h", "封禁时长，如 1h、24h")
+
+	removeCmd := &cobra.Command{
+		Use:     "remove <fingerprint>...",
+		Aliases: []string{"rm", "unban"},
+		Short:   "解除封禁",
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := o.client()
+			if err != nil {
+				return err
+			}
+			var failed int
+			for _, fingerprint := range args {
+				if _, err := c.do(cmd.Context(), http.MethodDelete, "/rule/ban/"+url.PathEscape(fingerprint), nil, nil); err != nil {
+					fmt.Fprintf(cmd.ErrOrStderr(), "%s: %v\n", fingerprint, err)
+					failed++
+					continue
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "已解除封禁: %s\n", fingerprint)
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d 个用户解除封禁失败", failed)
+			}
+			return nil
+		},
+	}
+
+	var file string
+	batchCmd := &cobra.Command{
+		Use:   "batch -f <file>",
+		Short: "从文件批量封禁（每行一个指纹，#开头为注释，-表示标准输入）",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fingerprints, err := readFingerprints(cmd, file)
+			if err != nil {
+				return err
+			}
+			if len(fingerprints) == 0 {
+				return fmt.Errorf("文件中没有指纹")
+			}
+			c, err := o.client()
+			if err != nil {
+				return err
+			}
+			return o.batchBan(cmd, c, fingerprints, reason, duration)
+		},
+	}
+	batchCmd.Flags().StringVarP(&file, "file", "f", "", "指纹列表文件")
+	batchCmd.Flags().StringVar(&reason, "reason", "批量封禁", "封禁原因")
+	batchCmd.Flags().StringVar(&duration, "duration", "24h", "封禁时长，如 1h、24h")
+	batchCmd.MarkFlagRequired("file")
+
+	cmd.AddCommand(listCmd, addCmd, removeCmd, batchCmd)
+	return cmd
+}
+
+// 按服务端上限分批提交封禁
+func (o *options) batchBan(cmd *cobra.Command, c *client, fingerprints []string, reason, duration string) error {
+	var results []interface{}
+	var failed int
+	for start := 0; start < len(fingerprints); start += batchBanLimit {
+		end := start + batchBanLimit
+		if end > len(fingerprints) {
+			end = len(fingerprints)
+		}
+		body := map[string]interface{}{
+			"fingerprints": fingerprints[start:end],
+			"reason":       reason,
+			"duration":     duration,
+		}
+		data, err := c.data(cmd.Context(), http.MethodPost, "/rule/ban/batch", nil, body)
+		if err != nil {
+			return fmt.Errorf("第 %d-%d 个指纹提交失败: %v", start+1, end, err)
+		}
+		batch, _ := listField(data, "results").([]interface{})
+		for _, item := range batch {
+			if result, ok := item.(map[string]interface{}); ok && result["success"] != true {
+				failed++
+			}
+		}
+		results = append(results, batch...)
+	}
+
+	if err := o.print(cmd, results, "fingerprint", "success", "error"); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d/%d 个用户封禁失败", failed, len(fingerprints))
+	}
+	return nil
+}
+
+// 读取指纹列表，跳过空行和注释
+func readFingerprints(cmd *cobra.Command, path string) ([]string, error) {
+	var reader io.Reader
+	if path == "-" {
+		reader = cmd.InOrStdin()
+	} else {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("打开文件失败: %v", err)
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	seen := make(map[string]bool)
+	var fingerprints []string
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || seen[line] {
+			continue
+		}
+		seen[line] = true
+		fingerprints = append(fingerprints, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取文件失败: %v", err)
+	}
+	return fingerprints, nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 管理API客户端
+type client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+	stream  *http.Client // 长连接请求不设超时
+}
+
+// 统一响应格式
+type apiResponse struct {
+	Success bool            `json:"success"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Message string          `json:"message,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+func newClient(profile *Profile, timeout time.Duration) *client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if profile.Insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &client{
+		baseURL: strings.TrimRight(profile.Server, "/") + "/" + strings.Trim(profile.APIPrefix, "/"),
+		token:   profile.Token,
+		http:    &http.Client{Transport: transport, Timeout: timeout},
+		stream:  &http.Client{Transport: transport},
+	}
+}
+
+func (c *client) newRequest(ctx context.Context, method, path string, query url.Values, body interface{}) (*http.Request, error) {
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return req, nil
+}
+
+// 发送请求并返回原始响应体
+func (c *client) raw(ctx context.Context, method, path string, query url.Values, body interface{}) (int, []byte, error) {
+	req, err := c.newRequest(ctx, method, path, query, body)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, nil, fmt.Errorf("读取响应失败: %v", err)
+	}
+	return resp.StatusCode, data, nil
+}
+
+// 发送请求并解析统一响应格式
+func (c *client) do(ctx context.Context, method, path string, query url.Values, body interface{}) (*apiResponse, error) {
+	status, data, err := c.raw(ctx, method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result apiResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("%s %s: HTTP %d: %s", method, path, status, strings.TrimSpace(string(data)))
+	}
+	if !result.Success || status >= 400 {
+		message := result.Error
+		if message == "" {
+			message = result.Message
+		}
+		return nil, fmt.Errorf("%s %s: HTTP %d: %s", method, path, status, message)
+	}
+	return &result, nil
+}
+
+// 发送请求并返回解码后的data字段
+func (c *client) data(ctx context.Context, method, path string, query url.Values, body interface{}) (interface{}, error) {
+	result, err := c.do(ctx, method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Data) == 0 {
+		return result.Message, nil
+	}
+	var data interface{}
+	if err := json.Unmarshal(result.Data, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// 服务端事件
+type sseEvent struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+// 处理函数返回的错误，不触发重连
+type handlerError struct{ error }
+
+// 订阅SSE事件流，断线后携带Last-Event-ID重连，直到ctx取消
+func (c *client) streamEvents(ctx context.Context, path string, query url.Values, handle func(sseEvent) error) error {
+	lastID := ""
+	backoff := time.Second
+	for {
+		req, err := c.newRequest(ctx, http.MethodGet, path, query, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "text/event-stream")
+		if lastID != "" {
+			req.Header.Set("Last-Event-ID", lastID)
+		}
+
+		resp, err := c.stream.Do(req)
+		if err == nil && resp.StatusCode != http.StatusOK {
+			data, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			return fmt.Errorf("订阅事件流失败: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+		}
+		if err == nil {
+			backoff = time.Second
+			err = readSSE(resp.Body, func(event sseEvent) error {
+				if event.ID != "" {
+					lastID = event.ID
+				}
+				return handle(event)
+			})
+			resp.Body.Close()
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		var herr handlerError
+		if errors.As(err, &herr) {
+			return herr.error
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+func readSSE(body io.Reader, handle func(sseEvent) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var event sseEvent
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if len(data) > 0 || event.Event != "" {
+				event.Data = strings.Join(data, "\n")
+				if err := handle(event); err != nil {
+					return handlerError{err}
+				}
+			}
+			event, data = sseEvent{}, nil
+		case strings.HasPrefix(line, ":"):
+			// 注释行（心跳）
+		case strings.HasPrefix(line, "id:"):
+			event.ID = strings.TrimSpace(strings.TrimPrefix(line, "id:"))
+		case strings.HasPrefix(line, "event:"):
+			event.Event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
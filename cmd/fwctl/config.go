@@ -0,0 +1,326 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// 配置分区与对应接口
+var configPaths = map[string]string{
+	"all":     "/config",
+	"scoring": "/config/scoring",
+	"limiter": "/config/limiter",
+}
+
+var configSections = []string{"all", "scoring", "limiter"}
+
+func newConfigCmd(o *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "查看、修改和比较服务端配置",
+	}
+
+	getCmd := &cobra.Command{
+		Use:       "get [all|scoring|limiter]",
+		Short:     "查看配置（默认all）",
+		Args:      cobra.MatchAll(cobra.MaximumNArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: configSections,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.get(cmd, configPaths[sectionArg(args)], nil)
+		},
+	}
+
+	var setFile string
+	setCmd := &cobra.Command{
+		Use:   "set <all|scoring|limiter> [key=value...]",
+		Short: "修改配置：-f 提交完整配置，或以 key=value 修改当前配置的指定字段",
+		Long: "修改配置。key为get输出中的字段路径（如 Shadow.Global），value按JSON解析，" +
+			"解析失败时作为字符串，例如:\n  fwctl config set limiter MaxRequestsPerWindow=200 Shadow.Sources='[\"score\"]'",
+		Args: cobra.MinimumNArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) == 0 {
+				return configSections, cobra.ShellCompDirectiveNoFileComp
+			}
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, ok := configPaths[args[0]]
+			if !ok {
+				return fmt.Errorf("未知的配置分区: %s", args[0])
+			}
+			assignments := args[1:]
+			if (setFile == "") == (len(assignments) == 0) {
+				return fmt.Errorf("需要指定 -f 文件或 key=value，二者只能选其一")
+			}
+
+			c, err := o.client()
+			if err != nil {
+				return err
+			}
+
+			var body interface{}
+			if setFile != "" {
+				if body, err = readDocument(cmd, setFile); err != nil {
+					return err
+				}
+			} else {
+				if body, err = c.data(cmd.Context(), http.MethodGet, path, nil, nil); err != nil {
+					return err
+				}
+				for _, assignment := range assignments {
+					if err := applyAssignment(body, assignment); err != nil {
+						return err
+					}
+				}
+			}
+
+			data, err := c.data(cmd.Context(), http.MethodPut, path, nil, body)
+			if err != nil {
+				return err
+			}
+			return o.print(cmd, data)
+		},
+	}
+	setCmd.Flags().StringVarP(&setFile, "file", "f", "", "配置文件（JSON或YAML，-表示标准输入）")
+
+	var diffFile string
+	diffCmd := &cobra.Command{
+		Use:       "diff [all|scoring|limiter] -f <file>",
+		Short:     "比较本地配置文件与服务端当前配置",
+		Args:      cobra.MatchAll(cobra.MaximumNArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: configSections,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			local, err := readDocument(cmd, diffFile)
+			if err != nil {
+				return err
+			}
+			c, err := o.client()
+			if err != nil {
+				return err
+			}
+			remote, err := c.data(cmd.Context(), http.MethodGet, configPaths[sectionArg(args)], nil, nil)
+			if err != nil {
+				return err
+			}
+
+			changes := diffConfig(remote, local)
+			if o.output != outputTable {
+				return o.print(cmd, changes)
+			}
+			if len(changes) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "配置一致")
+				return nil
+			}
+			return o.print(cmd, changes, "key", "remote", "local")
+		},
+	}
+	diffCmd.Flags().StringVarP(&diffFile, "file", "f", "", "本地配置文件（JSON或YAML，-表示标准输入）")
+	diffCmd.MarkFlagRequired("file")
+
+	var exportFile string
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "导出服务端配置",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := o.client()
+			if err != nil {
+				return err
+			}
+			status, data, err := c.raw(cmd.Context(), http.MethodPost, "/config/export", nil, nil)
+			if err != nil {
+				return err
+			}
+			if status != http.StatusOK {
+				return fmt.Errorf("导出配置失败: HTTP %d: %s", status, strings.TrimSpace(string(data)))
+			}
+
+			var config interface{}
+			if err := json.Unmarshal(data, &config); err != nil {
+				return fmt.Errorf("解析配置失败: %v", err)
+			}
+			if exportFile == "" {
+				if o.output == outputTable {
+					o.output = outputJSON
+				}
+				return o.print(cmd, config)
+			}
+
+			var out []byte
+			if strings.HasSuffix(exportFile, ".yaml") || strings.HasSuffix(exportFile, ".yml") {
+				out, err = yaml.Marshal(config)
+			} else {
+				out, err = json.MarshalIndent(config, "", "  ")
+			}
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(exportFile, out, 0o644); err != nil {
+				return fmt.Errorf("写入文件失败: %v", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "配置已导出到 %s\n", exportFile)
+			return nil
+		},
+	}
+	exportCmd.Flags().StringVarP(&exportFile, "file", "f", "", "写入文件（.yaml/.yml为YAML，其余为JSON），默认输出到标准输出")
+
+	var importFile string
+	importCmd := &cobra.Command{
+		Use:   "import -f <file>",
+		Short: "导入配置（格式同export）",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := readDocument(cmd, importFile)
+			if err != nil {
+				return err
+			}
+			c, err := o.client()
+			if err != nil {
+				return err
+			}
+			result, err := c.do(cmd.Context(), http.MethodPost, "/config/import", nil, config)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), result.Message)
+			return nil
+		},
+	}
+	importCmd.Flags().StringVarP(&importFile, "file", "f", "", "配置文件（JSON或YAML，-表示标准输入）")
+	importCmd.MarkFlagRequired("file")
+
+	cmd.AddCommand(getCmd, setCmd, diffCmd, exportCmd, importCmd)
+	return cmd
+}
+
+func sectionArg(args []string) string {
+	if len(args) == 0 {
+		return "all"
+	}
+	return args[0]
+}
+
+// 读取JSON或YAML文档，统一为JSON兼容的类型
+func readDocument(cmd *cobra.Command, path string) (interface{}, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(cmd.InOrStdin())
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %v", err)
+	}
+
+	// YAML是JSON的超集，先按YAML解析再经JSON转换，保证键为字符串、数字为float64
+	var document interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("解析文件失败: %v", err)
+	}
+	normalized, err := json.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("解析文件失败: %v", err)
+	}
+	var result interface{}
+	if err := json.Unmarshal(normalized, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// 按 a.b.c=value 修改文档中的字段，字段必须已存在
+func applyAssignment(document interface{}, assignment string) error {
+	key, raw, ok := strings.Cut(assignment, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("无效的赋值 %q，格式为 key=value", assignment)
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		value = raw
+	}
+
+	parts := strings.Split(key, ".")
+	current := document
+	for i, part := range parts {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("字段 %s 不是对象", strings.Join(parts[:i], "."))
+		}
+		name, found := lookupKey(object, part)
+		if !found {
+			return fmt.Errorf("未知的配置字段: %s", strings.Join(parts[:i+1], "."))
+		}
+		if i == len(parts)-1 {
+			object[name] = value
+			return nil
+		}
+		current = object[name]
+	}
+	return nil
+}
+
+// 查找字段名，忽略大小写和下划线（max_score 可匹配 MaxScore）
+func lookupKey(object map[string]interface{}, key string) (string, bool) {
+	if _, ok := object[key]; ok {
+		return key, true
+	}
+	normalize := func(s string) string {
+		return strings.ToLower(strings.ReplaceAll(s, "_", ""))
+	}
+	for name := range object {
+		if normalize(name) == normalize(key) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// 展开两份配置并列出不同的字段
+func diffConfig(remote, local interface{}) []interface{} {
+	remoteFlat := make(map[string]interface{})
+	localFlat := make(map[string]interface{})
+	flatten("", remote, remoteFlat)
+	flatten("", local, localFlat)
+
+	keys := make(map[string]bool)
+	for key := range remoteFlat {
+		keys[key] = true
+	}
+	for key := range localFlat {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	changes := make([]interface{}, 0)
+	for _, key := range sorted {
+		remoteValue, remoteOK := remoteFlat[key]
+		localValue, localOK := localFlat[key]
+		if remoteOK && localOK && reflect.DeepEqual(remoteValue, localValue) {
+			continue
+		}
+		change := map[string]interface{}{"key": key, "remote": remoteValue, "local": localValue}
+		if !remoteOK {
+			change["remote"] = "(无)"
+		}
+		if !localOK {
+			change["local"] = "(无)"
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
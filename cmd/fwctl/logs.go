@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func newLogsCmd(o *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "logs",
+		Short: "查询和跟踪访问日志",
+	}
+
+	var filter struct {
+		fingerprint, ip, path, method, action string
+		since                                 time.Duration
+		start, end                            string
+		minScore, maxScore                    string
+		size                                  int
+		cursor                                string
+	}
+	searchCmd := &cobra.Command{
+		Use:   "search",
+		Short: "按条件查询访问日志",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			query := url.Values{}
+			set := func(key, value string) {
+				if value != "" {
+					query.Set(key, value)
+				}
+			}
+			set("fingerprint", filter.fingerprint)
+			set("ip", filter.ip)
+			set("path", filter.path)
+			set("method", filter.method)
+			set("action", filter.action)
+			set("min_score", filter.minScore)
+			set("max_score", filter.maxScore)
+			set("start_time", filter.start)
+			set("end_time", filter.end)
+			if filter.since > 0 && filter.start == "" {
+				query.Set("start_time", time.Now().Add(-filter.since).Format(time.RFC3339))
+			}
+			query.Set("size", strconv.Itoa(filter.size))
+			query.Set("cursor", filter.cursor)
+
+			c, err := o.client()
+			if err != nil {
+				return err
+			}
+			data, err := c.data(cmd.Context(), "GET", "/logs", query, nil)
+			if err != nil {
+				return err
+			}
+			if o.output != outputTable {
+				return o.print(cmd, data)
+			}
+			if err := o.print(cmd, listField(data, "records"), "id", "timestamp", "fingerprint", "ip", "method", "path", "score", "action"); err != nil {
+				return err
+			}
+			if result, ok := data.(map[string]interface{}); ok && result["next_cursor"] != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "下一页: --cursor %s\n", formatCell(result["next_cursor"]))
+			}
+			return nil
+		},
+	}
+	flags := searchCmd.Flags()
+	flags.StringVar(&filter.fingerprint, "fingerprint", "", "用户指纹")
+	flags.StringVar(&filter.ip, "ip", "", "IP地址")
+	flags.StringVar(&filter.path, "path", "", "访问路径")
+	flags.StringVar(&filter.method, "method", "", "HTTP方法")
+	flags.StringVar(&filter.action, "action", "", "处理动作")
+	flags.StringVar(&filter.minScore, "min-score", "", "最低分数")
+	flags.StringVar(&filter.maxScore, "max-score", "", "最高分数")
+	flags.DurationVar(&filter.since, "since", 0, "查询最近一段时间，如 1h")
+	flags.StringVar(&filter.start, "start", "", "开始时间（RFC3339）")
+	flags.StringVar(&filter.end, "end", "", "结束时间（RFC3339）")
+	flags.IntVar(&filter.size, "size", 20, "返回条数（最多100）")
+	flags.StringVar(&filter.cursor, "cursor", "0", "游标，0表示从最新开始")
+	searchCmd.RegisterFlagCompletionFunc("action", completeActions)
+
+	var tail struct {
+		actions     []string
+		fingerprint string
+		ips         []string
+		pathPrefix  string
+		minRisk     float64
+	}
+	tailCmd := &cobra.Command{
+		Use:   "tail",
+		Short: "实时跟踪决策事件（Ctrl+C退出）",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			query := url.Values{}
+			if len(tail.actions) > 0 {
+				query.Set("action", strings.Join(tail.actions, ","))
+			}
+			if len(tail.ips) > 0 {
+				query.Set("ip", strings.Join(tail.ips, ","))
+			}
+			if tail.fingerprint != "" {
+				query.Set("fingerprint", tail.fingerprint)
+			}
+			if tail.pathPrefix != "" {
+				query.Set("path_prefix", tail.pathPrefix)
+			}
+			if tail.minRisk > 0 {
+				query.Set("min_risk", strconv.FormatFloat(tail.minRisk, 'f', -1, 64))
+			}
+
+			c, err := o.client()
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+			return o.tailEvents(ctx, cmd, c, query)
+		},
+	}
+	flags = tailCmd.Flags()
+	flags.StringSliceVar(&tail.actions, "action", nil, "只显示指定动作，可重复或逗号分隔")
+	flags.StringVar(&tail.fingerprint, "fingerprint", "", "用户指纹")
+	flags.StringSliceVar(&tail.ips, "ip", nil, "IP或CIDR，可重复或逗号分隔")
+	flags.StringVar(&tail.pathPrefix, "path-prefix", "", "路径前缀")
+	flags.Float64Var(&tail.minRisk, "min-risk", 0, "最低风险分")
+	tailCmd.RegisterFlagCompletionFunc("action", completeActions)
+
+	cmd.AddCommand(searchCmd, tailCmd)
+	return cmd
+}
+
+// 输出事件流，表格模式下每行一个事件，json/yaml模式下逐个输出事件对象
+func (o *options) tailEvents(ctx context.Context, cmd *cobra.Command, c *client, query url.Values) error {
+	out := cmd.OutOrStdout()
+	tw := tabwriter.NewWriter(out, 12, 4, 2, ' ', 0)
+	if o.output == outputTable {
+		fmt.Fprintln(tw, "TIME\tACTION\tFINGERPRINT\tIP\tMETHOD\tPATH\tSCORE\tREASON")
+		tw.Flush()
+	}
+
+	return c.streamEvents(ctx, "/events/stream", query, func(event sseEvent) error {
+		switch event.Event {
+		case "overflow":
+			fmt.Fprintln(cmd.ErrOrStderr(), "事件消费过慢，服务端断开订阅，正在重连")
+			return nil
+		case "reset":
+			fmt.Fprintln(cmd.ErrOrStderr(), "游标已过期，部分事件丢失")
+			return nil
+		case "decision":
+		default:
+			return nil
+		}
+
+		var decision map[string]interface{}
+		if err := json.Unmarshal([]byte(event.Data), &decision); err != nil {
+			return fmt.Errorf("解析事件失败: %v", err)
+		}
+		if o.output != outputTable {
+			return o.print(cmd, decision)
+		}
+		action := formatCell(decision["action"])
+		if shadow, ok := decision["shadow_action"].(string); ok && shadow != "" {
+			action += "(shadow:" + shadow + ")"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			formatCell(decision["timestamp"]), action, formatCell(decision["fingerprint"]),
+			formatCell(decision["ip"]), formatCell(decision["method"]), formatCell(decision["path"]),
+			formatCell(decision["score"]), formatCell(decision["reason"]))
+		return tw.Flush()
+	})
+}
+
+func completeActions(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return []string{"allow", "delay", "limit", "challenge", "ban", "reject"}, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// 全局选项
+type options struct {
+	configPath string
+	profile    string
+	server     string
+	token      string
+	insecure   bool
+	output     string
+	timeout    time.Duration
+}
+
+func main() {
+	if err := newRootCmd().Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, "错误:", err)
+		os.Exit(1)
+	}
+}
+
+func newRootCmd() *cobra.Command {
+	o := &options{}
+	root := &cobra.Command{
+		Use:           "fwctl",
+		Short:         "防火墙控制器管理命令行",
+		Long:          "fwctl 通过管理API操作防火墙控制器：封禁、白名单、分数、日志、统计和配置。",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			switch o.output {
+			case outputTable, outputJSON, outputYAML:
+				return nil
+			}
+			return fmt.Errorf("不支持的输出格式: %s（可选 table / json / yaml）", o.output)
+		},
+	}
+
+	flags := root.PersistentFlags()
+	flags.StringVar(&o.configPath, "config", defaultConfigPath(), "fwctl配置文件路径")
+	flags.StringVar(&o.profile, "profile", "", "使用的连接配置（默认为当前配置，可用FWCTL_PROFILE指定）")
+	flags.StringVar(&o.server, "server", "", "服务地址，覆盖连接配置（FWCTL_SERVER）")
+	flags.StringVar(&o.token, "token", "", "管理令牌，覆盖连接配置（FWCTL_TOKEN）")
+	flags.BoolVar(&o.insecure, "insecure", false, "跳过TLS证书校验")
+	flags.StringVarP(&o.output, "output", "o", outputTable, "输出格式: table / json / yaml")
+	flags.DurationVar(&o.timeout, "timeout", 30*time.Second, "请求超时")
+
+	root.RegisterFlagCompletionFunc("profile", o.completeProfiles)
+	root.RegisterFlagCompletionFunc("output", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{outputTable, outputJSON, outputYAML}, cobra.ShellCompDirectiveNoFileComp
+	})
+
+	root.AddCommand(
+		newProfileCmd(o),
+		newBanCmd(o),
+		newWhitelistCmd(o),
+		newScoreCmd(o),
+		newAnalysisCmd(o),
+		newLogsCmd(o),
+		newStatsCmd(o),
+		newConfigCmd(o),
+	)
+
+	root.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return fmt.Errorf("%v\n执行 %s --help 查看用法", err, cmd.CommandPath())
+	})
+	return root
+}
+
+// 按当前配置创建API客户端
+func (o *options) client() (*client, error) {
+	profile, err := o.resolveProfile()
+	if err != nil {
+		return nil, err
+	}
+	return newClient(profile, o.timeout), nil
+}
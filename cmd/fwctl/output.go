@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// 按输出格式打印数据，表格模式下columns指定列顺序（为空时按字段名排序）
+func (o *options) print(cmd *cobra.Command, data interface{}, columns ...string) error {
+	w := cmd.OutOrStdout()
+	switch o.output {
+	case outputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(data)
+	case outputYAML:
+		return yaml.NewEncoder(w).Encode(data)
+	}
+	return printTable(w, data, columns)
+}
+
+func printTable(w io.Writer, data interface{}, columns []string) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	defer tw.Flush()
+
+	switch value := data.(type) {
+	case []interface{}:
+		if len(value) == 0 {
+			fmt.Fprintln(tw, "(无数据)")
+			return nil
+		}
+		rows := make([]map[string]interface{}, 0, len(value))
+		for _, item := range value {
+			row, ok := item.(map[string]interface{})
+			if !ok {
+				row = map[string]interface{}{"value": item}
+			}
+			rows = append(rows, row)
+		}
+		if len(columns) == 0 {
+			columns = collectColumns(rows)
+		}
+		header := make([]string, len(columns))
+		for i, column := range columns {
+			header[i] = strings.ToUpper(column)
+		}
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+		for _, row := range rows {
+			cells := make([]string, len(columns))
+			for i, column := range columns {
+				cells[i] = formatCell(row[column])
+			}
+			fmt.Fprintln(tw, strings.Join(cells, "\t"))
+		}
+	case map[string]interface{}:
+		flat := make(map[string]interface{})
+		flatten("", value, flat)
+		keys := make([]string, 0, len(flat))
+		for key := range flat {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fmt.Fprintln(tw, "KEY\tVALUE")
+		for _, key := range keys {
+			fmt.Fprintf(tw, "%s\t%s\n", key, formatCell(flat[key]))
+		}
+	default:
+		fmt.Fprintln(tw, formatCell(value))
+	}
+	return nil
+}
+
+// 收集所有行出现过的字段名
+func collectColumns(rows []map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	var columns []string
+	for _, row := range rows {
+		for key := range row {
+			if !seen[key] {
+				seen[key] = true
+				columns = append(columns, key)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// 将嵌套对象展开为 a.b.c 形式的键
+func flatten(prefix string, value interface{}, out map[string]interface{}) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		out[prefix] = value
+		return
+	}
+	if len(object) == 0 && prefix != "" {
+		out[prefix] = value
+		return
+	}
+	for key, child := range object {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		flatten(key, child, out)
+	}
+}
+
+func formatCell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "-"
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t.Local().Format("2006-01-02 15:04:05")
+		}
+		return v
+	case float64:
+		if v == float64(int64(v)) {
+			return fmt.Sprintf("%d", int64(v))
+		}
+		return fmt.Sprintf("%.2f", v)
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	return fmt.Sprint(value)
+}
+
+// 从响应数据中取出列表字段（如 {"banned_users": [...]}），找不到时原样返回
+func listField(data interface{}, keys ...string) interface{} {
+	object, ok := data.(map[string]interface{})
+	if !ok {
+		return data
+	}
+	for _, key := range keys {
+		if list, ok := object[key].([]interface{}); ok {
+			return list
+		}
+	}
+	return data
+}
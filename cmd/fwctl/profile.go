@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// 连接配置
+type Profile struct {
+	Server    string `yaml:"server"`               // 服务地址，如 https://fw.example.com
+	APIPrefix string `yaml:"api_prefix,omitempty"` // 默认 /api/v1
+	Token     string `yaml:"token,omitempty"`      // 管理令牌（admin.token）
+	TokenEnv  string `yaml:"token_env,omitempty"`  // 从环境变量读取令牌，优先于token
+	Insecure  bool   `yaml:"insecure,omitempty"`   // 跳过TLS证书校验
+}
+
+// fwctl配置文件
+type CLIConfig struct {
+	Current  string              `yaml:"current"`
+	Profiles map[string]*Profile `yaml:"profiles"`
+}
+
+// 默认配置文件路径：$FWCTL_CONFIG 或 ~/.config/fwctl/config.yaml
+func defaultConfigPath() string {
+	if path := os.Getenv("FWCTL_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "fwctl.yaml"
+	}
+	return filepath.Join(dir, "fwctl", "config.yaml")
+}
+
+// 读取配置文件，不存在时返回空配置
+func loadCLIConfig(path string) (*CLIConfig, error) {
+	config := &CLIConfig{Profiles: make(map[string]*Profile)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %v", err)
+	}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("解析配置文件失败: %v", err)
+	}
+	if config.Profiles == nil {
+		config.Profiles = make(map[string]*Profile)
+	}
+	return config, nil
+}
+
+// 保存配置文件（包含令牌，权限0600）
+func (c *CLIConfig) save(path string) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("创建配置目录失败: %v", err)
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// 按 flag > 环境变量 > 配置文件 的优先级确定连接参数
+func (o *options) resolveProfile() (*Profile, error) {
+	config, err := loadCLIConfig(o.configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	name := o.profile
+	if name == "" {
+		name = os.Getenv("FWCTL_PROFILE")
+	}
+	if name == "" {
+		name = config.Current
+	}
+
+	profile := &Profile{}
+	if name != "" {
+		stored, ok := config.Profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("配置 %q 不存在，使用 fwctl profile set 创建", name)
+		}
+		*profile = *stored
+	}
+
+	if env := os.Getenv("FWCTL_SERVER"); env != "" {
+		profile.Server = env
+	}
+	if o.server != "" {
+		profile.Server = o.server
+	}
+	if profile.TokenEnv != "" {
+		profile.Token = os.Getenv(profile.TokenEnv)
+	}
+	if env := os.Getenv("FWCTL_TOKEN"); env != "" {
+		profile.Token = env
+	}
+	if o.token != "" {
+		profile.Token = o.token
+	}
+	if o.insecure {
+		profile.Insecure = true
+	}
+
+	if profile.Server == "" {
+		profile.Server = "http://localhost:8080"
+	}
+	if profile.APIPrefix == "" {
+		profile.APIPrefix = "/api/v1"
+	}
+	return profile, nil
+}
+
+func newProfileCmd(o *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "profile",
+		Short: "管理连接配置",
+	}
+
+	var profile Profile
+	setCmd := &cobra.Command{
+		Use:   "set <name>",
+		Short: "创建或更新连接配置",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := loadCLIConfig(o.configPath)
+			if err != nil {
+				return err
+			}
+			existing, ok := config.Profiles[args[0]]
+			if !ok {
+				existing = &Profile{}
+				config.Profiles[args[0]] = existing
+			}
+			flags := cmd.Flags()
+			if flags.Changed("server") {
+				existing.Server = profile.Server
+			}
+			if flags.Changed("api-prefix") {
+				existing.APIPrefix = profile.APIPrefix
+			}
+			if flags.Changed("token") {
+				existing.Token = profile.Token
+			}
+			if flags.Changed("token-env") {
+				existing.TokenEnv = profile.TokenEnv
+			}
+			if flags.Changed("insecure") {
+				existing.Insecure = profile.Insecure
+			}
+			if config.Current == "" {
+				config.Current = args[0]
+			}
+			if err := config.save(o.configPath); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "已保存配置 %s 到 %s\n", args[0], o.configPath)
+			return nil
+		},
+	}
+	setCmd.Flags().StringVar(&profile.Server, "server", "", "服务地址")
+	setCmd.Flags().StringVar(&profile.APIPrefix, "api-prefix", "", "API前缀（默认/api/v1）")
+	setCmd.Flags().StringVar(&profile.Token, "token", "", "管理令牌")
+	setCmd.Flags().StringVar(&profile.TokenEnv, "token-env", "", "从环境变量读取令牌")
+	setCmd.Flags().BoolVar(&profile.Insecure, "insecure", false, "跳过TLS证书校验")
+
+	useCmd := &cobra.Command{
+		Use:               "use <name>",
+		Short:             "切换默认连接配置",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: o.completeProfiles,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := loadCLIConfig(o.configPath)
+			if err != nil {
+				return err
+			}
+			if _, ok := config.Profiles[args[0]]; !ok {
+				return fmt.Errorf("配置 %q 不存在", args[0])
+			}
+			config.Current = args[0]
+			return config.save(o.configPath)
+		},
+	}
+
+	deleteCmd := &cobra.Command{
+		Use:               "delete <name>",
+		Short:             "删除连接配置",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: o.completeProfiles,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := loadCLIConfig(o.configPath)
+			if err != nil {
+				return err
+			}
+			delete(config.Profiles, args[0])
+			if config.Current == args[0] {
+				config.Current = ""
+			}
+			return config.save(o.configPath)
+		},
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "列出连接配置",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := loadCLIConfig(o.configPath)
+			if err != nil {
+				return err
+			}
+			names := make([]string, 0, len(config.Profiles))
+			for name := range config.Profiles {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			rows := make([]interface{}, 0, len(names))
+			for _, name := range names {
+				p := config.Profiles[name]
+				token := ""
+				switch {
+				case p.TokenEnv != "":
+					token = "$" + p.TokenEnv
+				case p.Token != "":
+					token = "******"
+				}
+				rows = append(rows, map[string]interface{}{
+					"current": name == config.Current,
+					"name":    name,
+					"server":  p.Server,
+					"token":   token,
+				})
+			}
+			return o.print(cmd, rows, "current", "name", "server", "token")
+		},
+	}
+
+	cmd.AddCommand(setCmd, useCmd, deleteCmd, listCmd)
+	return cmd
+}
+
+// 补全配置名
+func (o *options) completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	config, err := loadCLIConfig(o.configPath)
+	if err != nil || len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	names := make([]string, 0, len(config.Profiles))
+	for name := range config.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
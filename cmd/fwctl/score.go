@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+func newScoreCmd(o *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "score",
+		Short: "查看和调整用户分数",
+	}
+
+	getCmd := &cobra.Command{
+		Use:   "get <fingerprint>",
+		Short: "查看用户分数",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.get(cmd, "/score/"+url.PathEscape(args[0]), nil)
+		},
+	}
+
+	var by int
+	var reason string
+	adjustCmd := &cobra.Command{
+		Use:   "adjust <fingerprint> --by <n>",
+		Short: "调整用户分数（正数加分，负数扣分）",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if by == 0 {
+				return fmt.Errorf("--by 不能为0")
+			}
+			c, err := o.client()
+			if err != nil {
+				return err
+			}
+			body := map[string]interface{}{"adjustment": by, "reason": reason}
+			data, err := c.data(cmd.Context(), http.MethodPost, "/score/"+url.PathEscape(args[0])+"/adjust", nil, body)
+			if err != nil {
+				return err
+			}
+			return o.print(cmd, data)
+		},
+	}
+	adjustCmd.Flags().IntVar(&by, "by", 0, "调整分值")
+	adjustCmd.Flags().StringVar(&reason, "reason", "手动调整", "调整原因")
+	adjustCmd.MarkFlagRequired("by")
+
+	resetCmd := &cobra.Command{
+		Use:   "reset <fingerprint>",
+		Short: "重置用户分数为初始值",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := o.client()
+			if err != nil {
+				return err
+			}
+			data, err := c.data(cmd.Context(), http.MethodPost, "/score/"+url.PathEscape(args[0])+"/reset", nil, nil)
+			if err != nil {
+				return err
+			}
+			return o.print(cmd, data)
+		},
+	}
+
+	var hours int
+	historyCmd := &cobra.Command{
+		Use:   "history <fingerprint>",
+		Short: "查看用户分数历史",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			query := url.Values{"hours": {strconv.Itoa(hours)}}
+			return o.get(cmd, "/score/"+url.PathEscape(args[0])+"/history", query)
+		},
+	}
+	historyCmd.Flags().IntVar(&hours, "hours", 24, "时间范围（小时）")
+
+	cmd.AddCommand(getCmd, adjustCmd, resetCmd, historyCmd)
+	return cmd
+}
+
+func newAnalysisCmd(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "analysis <fingerprint>",
+		Short: "查看用户行为分析",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.get(cmd, "/rule/analysis/"+url.PathEscape(args[0]), nil)
+		},
+	}
+}
+
+// GET请求并打印data字段
+func (o *options) get(cmd *cobra.Command, path string, query url.Values, columns ...string) error {
+	c, err := o.client()
+	if err != nil {
+		return err
+	}
+	data, err := c.data(cmd.Context(), http.MethodGet, path, query, nil)
+	if err != nil {
+		return err
+	}
+	return o.print(cmd, data, columns...)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// 统计类别与对应接口
+var statsPaths = map[string]string{
+	"rules":  "/rule/stats",
+	"logs":   "/logs/stats",
+	"scores": "/score/stats",
+	"system": "/system/info",
+	"shadow": "/shadow/report",
+}
+
+var statsKinds = []string{"rules", "logs", "scores", "system", "shadow"}
+
+func newStatsCmd(o *options) *cobra.Command {
+	var since time.Duration
+	cmd := &cobra.Command{
+		Use:       "stats [rules|logs|scores|system|shadow]",
+		Short:     "查看统计信息（默认rules）",
+		Args:      cobra.MatchAll(cobra.MaximumNArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: statsKinds,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kind := "rules"
+			if len(args) == 1 {
+				kind = args[0]
+			}
+			path, ok := statsPaths[kind]
+			if !ok {
+				return fmt.Errorf("未知的统计类别: %s", kind)
+			}
+
+			query := url.Values{}
+			if since > 0 && (kind == "logs" || kind == "shadow") {
+				query.Set("start_time", time.Now().Add(-since).Format(time.RFC3339))
+			}
+			return o.get(cmd, path, query)
+		},
+	}
+	cmd.Flags().DurationVar(&since, "since", 0, "统计最近一段时间（logs、shadow）")
+	return cmd
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+func newWhitelistCmd(o *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "whitelist",
+		Aliases: []string{"wl"},
+		Short:   "管理白名单",
+	}
+
+	var page, size int
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "列出白名单用户",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := o.client()
+			if err != nil {
+				return err
+			}
+			query := url.Values{"page": {strconv.Itoa(page)}, "size": {strconv.Itoa(size)}}
+			data, err := c.data(cmd.Context(), http.MethodGet, "/rule/whitelist", query, nil)
+			if err != nil {
+				return err
+			}
+			if o.output != outputTable {
+				return o.print(cmd, data)
+			}
+			return o.print(cmd, listField(data, "users"), "fingerprint", "ip", "reason", "added_at", "expires_at")
+		},
+	}
+	listCmd.Flags().IntVar(&page, "page", 1, "页码")
+	listCmd.Flags().IntVar(&size, "size", 20, "每页数量")
+
+	var reason, duration string
+	addCmd := &cobra.Command{
+		Use:   "add <fingerprint>",
+		Short: "加入白名单",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := o.client()
+			if err != nil {
+				return err
+			}
+			body := map[string]string{"fingerprint": args[0], "reason": reason, "duration": duration}
+			data, err := c.data(cmd.Context(), http.MethodPost, "/rule/whitelist", nil, body)
+			if err != nil {
+				return err
+			}
+			return o.print(cmd, data)
+		},
+	}
+	addCmd.Flags().StringVar(&reason, "reason", "手动添加", "加入原因")
+	addCmd.Flags().StringVar(&duration, "duration", "permanent", "有效期，如 24h 或 permanent")
+
+	removeCmd := &cobra.Command{
+		Use:     "remove <fingerprint>...",
+		Aliases: []string{"rm"},
+		Short:   "移出白名单",
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := o.client()
+			if err != nil {
+				return err
+			}
+			var failed int
+			for _, fingerprint := range args {
+				if _, err := c.do(cmd.Context(), http.MethodDelete, "/rule/whitelist/"+url.PathEscape(fingerprint), nil, nil); err != nil {
+					fmt.Fprintf(cmd.ErrOrStderr(), "%s: %v\n", fingerprint, err)
+					failed++
+					continue
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "已移出白名单: %s\n", fingerprint)
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d 个用户移出白名单失败", failed)
+			}
+			return nil
+		},
+	}
+
+	cmd.AddCommand(listCmd, addCmd, removeCmd)
+	return cmd
+}
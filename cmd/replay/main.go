@@ -0,0 +1,106 @@
+// replay 将历史访问日志离线回放到决策流程，评估配置调整的影响
+//
+// 用法:
+//
+//	replay -config configs/config.yaml access.log
+//	replay -config current.yaml -compare candidate.yaml -format combined access.log.1.gz access.log
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"securefingerprint/internal/replay"
+)
+
+func main() {
+	configFile := flag.String("config", "configs/config.yaml", "基准配置文件")
+	compareFile := flag.String("compare", "", "候选配置文件，设置后输出两份配置的差异")
+	format := flag.String("format", replay.FormatAuto, "日志格式: auto / combined / nginx-json / ndjson")
+	salt := flag.String("salt", "", "指纹盐值，为空时使用配置文件中的fingerprint.salt")
+	output := flag.String("output", "text", "输出格式: text / json")
+	limit := flag.Int("limit", 50, "封禁列表最多显示条数，0表示不限制")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "用法: %s [选项] <日志文件>...（按时间顺序传入，\"-\"表示标准输入）\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *output != "text" && *output != "json" {
+		log.Fatalf("不支持的输出格式: %s", *output)
+	}
+
+	engines := []*replay.Engine{newEngine(*configFile, *salt)}
+	if *compareFile != "" {
+		engines = append(engines, newEngine(*compareFile, *salt))
+	}
+	defer func() {
+		for _, engine := range engines {
+			engine.Close()
+		}
+	}()
+
+	var skipped int64
+	for _, path := range flag.Args() {
+		reader, err := replay.Open(path, *format)
+		if err != nil {
+			log.Fatal(err)
+		}
+		n, err := replay.Run(reader, engines...)
+		reader.Close()
+		skipped += n
+		if err != nil {
+			log.Fatalf("%s: %v", path, err)
+		}
+	}
+	if skipped > 0 {
+		log.Printf("跳过%d行无法解析的日志", skipped)
+	}
+
+	base := engines[0].Report()
+	if len(engines) == 1 {
+		if *output == "json" {
+			writeJSON(base)
+			return
+		}
+		base.WriteText(os.Stdout, *limit)
+		return
+	}
+
+	diff := replay.Compare(base, engines[1].Report())
+	if *output == "json" {
+		writeJSON(diff)
+		return
+	}
+	diff.WriteText(os.Stdout, *limit)
+}
+
+func newEngine(configFile, salt string) *replay.Engine {
+	config, err := replay.LoadConfig(configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if salt != "" {
+		config.FingerprintSalt = salt
+	}
+	engine, err := replay.NewEngine(config)
+	if err != nil {
+		log.Fatalf("%s: %v", configFile, err)
+	}
+	return engine
+}
+
+func writeJSON(v interface{}) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		log.Fatalf("输出结果失败: %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"securefingerprint/api"
+	"securefingerprint/internal/events"
+	"securefingerprint/internal/limiter"
+	"securefingerprint/internal/metrics"
+	"securefingerprint/internal/resilience"
+	"securefingerprint/internal/siem"
+	"securefingerprint/internal/storage"
+	"securefingerprint/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 依赖故障时按路由策略放行、拒绝或降级为本地限流，ip为采集器按可信代理配置解析出的客户端IP
+func (app *App) handleDependencyFailure(c *gin.Context, ip, fingerprint, stage string, err error) {
+	// 熔断器打开时不再逐条打印，避免日志刷屏
+	if !errors.Is(err, resilience.ErrCircuitOpen) {
+		log.Printf("%s: %v", stage, err)
+	}
+
+	mode := app.failurePolicy.ModeFor(c.Request.URL.Path)
+	span := trace.SpanFromContext(c.Request.Context())
+	span.RecordError(err)
+	span.SetAttributes(attribute.String("firewall.failure_mode", mode))
+	app.degradedStats.Record(mode)
+	metrics.DegradedDecisions.WithLabelValues(mode).Inc()
+
+	var decision *limiter.LimitDecision
+	switch mode {
+	case resilience.ModeClosed:
+		decision = limiter.FailClosedDecision(stage)
+	case resilience.ModeLocal:
+		decision = app.localLimiter.Decide(fingerprint)
+	default:
+		span.SetAttributes(tracing.AttrAction.String("allow"), tracing.AttrReason.String(limiter.CategoryDegraded))
+		metrics.Decisions.WithLabelValues("allow", limiter.CategoryDegraded).Inc()
+		app.publishDegraded(c, ip, fingerprint, "allow", "降级模式: "+stage)
+		c.Header("X-Rate-Limit-Status", "degraded")
+		c.Next()
+		return
+	}
+	span.SetAttributes(tracing.AttrAction.String(decision.Action), tracing.AttrReason.String(decision.Category))
+	metrics.Decisions.WithLabelValues(decision.Action, decision.Category).Inc()
+	app.publishDegraded(c, ip, fingerprint, decision.Action, decision.Reason)
+
+	if app.limiter.ApplyDecision(c.Writer, c.Request, decision) {
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// 发布降级决策事件并导出到SIEM
+func (app *App) publishDegraded(c *gin.Context, ip, fingerprint, action, reason string) {
+	now := time.Now()
+	app.events.Publish(events.Event{
+		Timestamp:   now,
+		Fingerprint: fingerprint,
+		IP:          ip,
+		Method:      c.Request.Method,
+		Path:        c.Request.URL.Path,
+		UserAgent:   c.Request.UserAgent(),
+		Action:      action,
+		Category:    limiter.CategoryDegraded,
+		Reason:      reason,
+		Degraded:    true,
+	})
+
+	if app.siem != nil {
+		app.siem.Export(&siem.Record{
+			Access: &storage.AccessRecord{
+				Fingerprint: fingerprint,
+				IP:          ip,
+				UserAgent:   c.Request.UserAgent(),
+				Path:        c.Request.URL.Path,
+				Method:      c.Request.Method,
+				Action:      action,
+				Timestamp:   now,
+			},
+			Category: limiter.CategoryDegraded,
+			Reason:   reason,
+			Degraded: true,
+		})
+	}
+}
+
+// 获取熔断器与降级决策统计
+func (app *App) getResilienceStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, api.ConfigResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"failure_policy":     app.config.Security.FailurePolicy,
+			"redis_breaker":      app.redisClient.BreakerSummary(),
+			"degraded_decisions": app.degradedStats.Snapshot(),
+		},
+	})
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"securefingerprint/api"
+	"securefingerprint/internal/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 构建信息，通过 -ldflags "-X main.version=... -X main.buildTime=... -X main.gitCommit=..." 注入
+var (
+	version   = "dev"
+	buildTime = ""
+	gitCommit = ""
+)
+
+// 进程启动时间
+var processStartTime = time.Now()
+
+// 构建信息，未注入时回退到Go工具链记录的VCS信息
+func buildInfo() map[string]interface{} {
+	info := map[string]interface{}{
+		"version":    version,
+		"build_time": buildTime,
+		"git_commit": gitCommit,
+		"go_version": runtime.Version(),
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if gitCommit == "" {
+					info["git_commit"] = setting.Value
+				}
+			case "vcs.time":
+				if buildTime == "" {
+					info["build_time"] = setting.Value
+				}
+			case "vcs.modified":
+				info["vcs_modified"] = setting.Value == "true"
+			}
+		}
+	}
+
+	return info
+}
+
+// 获取系统信息
+func (app *App) getSystemInfo(c *gin.Context) {
+	info := buildInfo()
+	info["name"] = "Firewall Controller"
+	info["start_time"] = processStartTime
+	info["uptime"] = time.Since(processStartTime).Round(time.Second).String()
+	info["uptime_seconds"] = int64(time.Since(processStartTime).Seconds())
+	info["user_registration_allowed"] = app.config.User.AllowRegistration
+
+	c.JSON(http.StatusOK, api.ConfigResponse{
+		Success: true,
+		Data:    info,
+	})
+}
+
+// 就绪检查必须可用的依赖
+func (app *App) requiredDependencies() map[string]bool {
+	required := make(map[string]bool)
+	for _, name := range app.config.Health.Required {
+		required[name] = true
+	}
+	// 有路由配置为故障时拒绝请求，Redis不可用时实例不应接收流量
+	if app.failurePolicy.HasClosedMode() {
+		required["redis"] = true
+	}
+	return required
+}
+
+// 健康检查（完整依赖详情）
+func (app *App) getHealthCheck(c *gin.Context) {
+	statuses := app.health.CheckAll(c.Request.Context(), app.requiredDependencies())
+
+	status := "healthy"
+	code := http.StatusOK
+	for _, dep := range statuses {
+		if dep.Status != health.StatusUp {
+			status = "degraded"
+		}
+	}
+	if !health.Ready(statuses) {
+		status = "unhealthy"
+		code = http.StatusServiceUnavailable
+	}
+	if app.draining.Load() {
+		status = "draining"
+		code = http.StatusServiceUnavailable
+	}
+
+	c.JSON(code, api.ConfigResponse{
+		Success: code == http.StatusOK,
+		Data: map[string]interface{}{
+			"status":         status,
+			"timestamp":      time.Now(),
+			"uptime_seconds": int64(time.Since(processStartTime).Seconds()),
+			"build":          buildInfo(),
+			"services":       statuses,
+		},
+	})
+}
+
+// 存活检查：只要进程能处理请求即返回成功，不检查依赖
+func (app *App) getLiveness(c *gin.Context) {
+	c.JSON(http.StatusOK, api.ConfigResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"status":         "alive",
+			"timestamp":      time.Now(),
+			"uptime_seconds": int64(time.Since(processStartTime).Seconds()),
+		},
+	})
+}
+
+// 就绪检查：draining或必需依赖不可用时返回503
+func (app *App) getReadiness(c *gin.Context) {
+	if app.draining.Load() {
+		c.JSON(http.StatusServiceUnavailable, api.ConfigResponse{
+			Success: false,
+			Data: map[string]interface{}{
+				"status":    "draining",
+				"timestamp": time.Now(),
+			},
+		})
+		return
+	}
+
+	statuses := app.health.CheckAll(c.Request.Context(), app.requiredDependencies())
+	if !health.Ready(statuses) {
+		c.JSON(http.StatusServiceUnavailable, api.ConfigResponse{
+			Success: false,
+			Error:   "必需依赖不可用",
+			Data: map[string]interface{}{
+				"status":    "not_ready",
+				"timestamp": time.Now(),
+				"services":  statuses,
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, api.ConfigResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"status":    "ready",
+			"timestamp": time.Now(),
+			"services":  statuses,
+		},
+	})
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"securefingerprint/internal/headerorder"
+	"securefingerprint/internal/proxyproto"
+	"securefingerprint/internal/tlsfp"
+)
+
+// 后台任务，按注册顺序的逆序停止
+type backgroundJob struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// 注册后台任务
+func (app *App) addJob(name string, stop func(ctx context.Context) error) {
+	app.jobs = append(app.jobs, backgroundJob{name: name, stop: stop})
+}
+
+// 运行应用，收到SIGINT/SIGTERM后优雅关闭
+func (app *App) Run() error {
+	addr := fmt.Sprintf(":%d", app.config.Server.Port)
+	app.server = &http.Server{
+		Addr:              addr,
+		Handler:           app.router,
+		ReadTimeout:       app.config.Server.ReadTimeout,
+		ReadHeaderTimeout: app.config.Server.ReadHeaderTimeout,
+		WriteTimeout:      app.config.Server.WriteTimeout,
+		IdleTimeout:       app.config.Server.IdleTimeout,
+		MaxHeaderBytes:    app.config.Server.MaxHeaderBytes,
+		// 让采集器能取到PROXY协议头中的TLV、TLS ClientHello和原始请求头顺序
+		ConnContext: func(ctx context.Context, conn net.Conn) context.Context {
+			ctx = proxyproto.ContextWithConn(ctx, conn)
+			ctx = tlsfp.ContextWithConn(ctx, conn)
+			return headerorder.ContextWithConn(ctx, conn)
+		},
+	}
+
+	// 关闭时先断开事件流长连接，否则Shutdown会一直等待它们结束
+	app.server.RegisterOnShutdown(func() {
+		app.events.Close(context.Background())
+	})
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	if app.config.Server.ProxyProtocol.Enabled {
+		proxyListener, err := proxyproto.NewListener(listener, app.config.Server.ProxyProtocol)
+		if err != nil {
+			listener.Close()
+			return fmt.Errorf("PROXY协议配置错误: %v", err)
+		}
+		log.Printf("已启用PROXY协议，可信来源: %v", app.config.Server.ProxyProtocol.TrustedSources)
+		listener = proxyListener
+	}
+	serveTLS := false
+	if app.config.Server.TLS.Enabled {
+		certs, err := tlsfp.NewCertReloader(app.config.Server.TLS)
+		if err != nil {
+			listener.Close()
+			return fmt.Errorf("TLS配置错误: %v", err)
+		}
+		app.addJob("tls-certs", certs.Close)
+		// ClientHello在TLS层之下记录，PROXY协议头已被剥离
+		listener = tlsfp.NewListener(listener)
+		tlsConfig := tlsfp.NewServerConfig(certs)
+		if app.config.Server.HeaderCapture.Enabled {
+			// 请求头采集需要读取解密后的HTTP/1.x字节流，此时自行握手并只协商HTTP/1.1
+			tlsConfig.NextProtos = []string{"http/1.1"}
+			listener = tls.NewListener(listener, tlsConfig)
+		} else {
+			app.server.TLSConfig = tlsConfig
+			serveTLS = true
+		}
+		log.Printf("已启用TLS，证书: %s", app.config.Server.TLS.CertFile)
+	}
+	if app.config.Server.HeaderCapture.Enabled {
+		listener = headerorder.NewListener(listener)
+		log.Printf("已启用原始请求头采集")
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if serveTLS {
+			// 证书由TLSConfig.GetCertificate提供，ServeTLS负责TLS握手和HTTP/2协商
+			serveErr <- app.server.ServeTLS(listener, "", "")
+			return
+		}
+		serveErr <- app.server.Serve(listener)
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	select {
+	case err := <-serveErr:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+		// 恢复默认信号处理，再次收到信号时直接退出
+		stop()
+		log.Printf("收到退出信号，开始优雅关闭")
+	}
+
+	return app.Shutdown()
+}
+
+// 优雅关闭：标记draining、等待摘流、排空请求、依次停止后台任务
+func (app *App) Shutdown() error {
+	app.draining.Store(true)
+
+	if delay := app.config.Server.DrainDelay; delay > 0 {
+		log.Printf("进入draining状态，等待%v后停止接收请求", delay)
+		time.Sleep(delay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), app.config.Server.ShutdownTimeout)
+	defer cancel()
+
+	var shutdownErr error
+	if app.server != nil {
+		if err := app.server.Shutdown(ctx); err != nil {
+			shutdownErr = fmt.Errorf("HTTP服务关闭超时: %v", err)
+			log.Printf("%v", shutdownErr)
+		}
+	}
+
+	// 后台任务使用独立的截止时间，避免请求排空耗尽时间导致数据丢失
+	jobCtx, jobCancel := context.WithTimeout(context.Background(), app.config.Server.ShutdownTimeout)
+	defer jobCancel()
+	app.stopJobs(jobCtx)
+
+	app.Close()
+	log.Printf("服务已关闭")
+
+	return shutdownErr
+}
+
+// 按注册顺序的逆序停止后台任务
+func (app *App) stopJobs(ctx context.Context) {
+	for i := len(app.jobs) - 1; i >= 0; i-- {
+		job := app.jobs[i]
+		start := time.Now()
+		if err := job.stop(ctx); err != nil {
+			log.Printf("停止后台任务%s失败: %v", job.name, err)
+			continue
+		}
+		log.Printf("后台任务%s已停止，耗时%v", job.name, time.Since(start).Round(time.Millisecond))
+	}
+	app.jobs = nil
+}
+
+// 关闭应用
+func (app *App) Close() {
+	app.closeOnce.Do(func() {
+		if len(app.jobs) > 0 {
+			ctx, cancel := context.WithTimeout(context.Background(), app.config.Server.ShutdownTimeout)
+			app.stopJobs(ctx)
+			cancel()
+		}
+		if app.redisClient != nil {
+			app.redisClient.Close()
+		}
+		if app.mysqlClient != nil {
+			app.mysqlClient.Close()
+		}
+	})
+}
@@ -6,26 +6,57 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"securefingerprint/api"
+	"securefingerprint/internal/alerting"
 	"securefingerprint/internal/analyzer"
+	"securefingerprint/internal/botverify"
+	"securefingerprint/internal/clientsignals"
+	"securefingerprint/internal/cluster"
 	"securefingerprint/internal/collector"
+	"securefingerprint/internal/device"
+	"securefingerprint/internal/events"
+	"securefingerprint/internal/export"
 	"securefingerprint/internal/fingerprint"
+	"securefingerprint/internal/geoip"
+	"securefingerprint/internal/headerorder"
+	"securefingerprint/internal/health"
 	"securefingerprint/internal/limiter"
+	"securefingerprint/internal/metrics"
+	"securefingerprint/internal/proxyproto"
+	"securefingerprint/internal/resilience"
 	"securefingerprint/internal/scorer"
+	"securefingerprint/internal/siem"
 	"securefingerprint/internal/storage"
+	"securefingerprint/internal/tlsfp"
+	"securefingerprint/internal/tracing"
+	"securefingerprint/internal/useragent"
 	"securefingerprint/pkg/middleware"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gopkg.in/yaml.v3"
 )
 
 // 应用配置
 type Config struct {
 	Server struct {
-		Port  int  `yaml:"port"`
-		Debug bool `yaml:"debug"`
+		Port              int           `yaml:"port"`
+		Debug             bool          `yaml:"debug"`
+		ReadTimeout       time.Duration `yaml:"read_timeout"`        // 读取整个请求的超时
+		ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"` // 读取请求头的超时
+		WriteTimeout      time.Duration `yaml:"write_timeout"`       // 写响应超时
+		IdleTimeout       time.Duration `yaml:"idle_timeout"`        // keep-alive空闲超时
+		MaxHeaderBytes    int           `yaml:"max_header_bytes"`    // 请求头最大字节数
+		ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"`    // 优雅关闭的最长等待时间
+		DrainDelay        time.Duration `yaml:"drain_delay"`         // 进入draining后等待负载均衡摘除的时间
+		ProxyProtocol     proxyproto.Config `yaml:"proxy_protocol"` // L4负载均衡的PROXY协议
+		TLS               tlsfp.Config      `yaml:"tls"`            // 直接终结TLS并采集ClientHello指纹
+		HeaderCapture     headerorder.Config `yaml:"header_capture"` // 采集HTTP/1.x原始请求头顺序
 	} `yaml:"server"`
 
 	Redis struct {
@@ -33,6 +64,9 @@ type Config struct {
 		Password string `yaml:"password"`
 		DB       int    `yaml:"db"`
 		PoolSize int    `yaml:"pool_size"`
+		DialTimeout  time.Duration `yaml:"dial_timeout"`
+		ReadTimeout  time.Duration `yaml:"read_timeout"`
+		WriteTimeout time.Duration `yaml:"write_timeout"`
 	} `yaml:"redis"`
 
 	MySQL struct {
@@ -40,12 +74,15 @@ type Config struct {
 		MaxOpenConns    int           `yaml:"max_open_conns"`
 		MaxIdleConns    int           `yaml:"max_idle_conns"`
 		ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+		Writer          storage.AccessWriterConfig `yaml:"writer"`
 	} `yaml:"mysql"`
 
 	Security struct {
 		Scoring scorer.ScoringConfig   `yaml:"scoring"`
 		Limiter limiter.LimiterConfig `yaml:"limiter"`
 		Analyzer analyzer.AnalyzerConfig `yaml:"analyzer"`
+		FailurePolicy resilience.FailurePolicyConfig `yaml:"failure_policy"`
+		LocalLimiter  limiter.LocalLimiterConfig     `yaml:"local_limiter"`
 	} `yaml:"security"`
 
 	Logging struct {
@@ -65,6 +102,55 @@ type Config struct {
 	User struct {
 		AllowRegistration bool `yaml:"allow_registration"`
 	} `yaml:"user"`
+
+	Health struct {
+		CheckTimeout time.Duration `yaml:"check_timeout"` // 单个依赖检查超时
+		Required     []string      `yaml:"required"`      // 就绪检查必须可用的依赖
+	} `yaml:"health"`
+
+	Metrics struct {
+		Enabled       bool          `yaml:"enabled"`
+		Path          string        `yaml:"path"`
+		Listen        string        `yaml:"listen"`         // 独立监听地址，为空时挂载在主服务并要求管理员认证
+		GaugeInterval time.Duration `yaml:"gauge_interval"` // 封禁数等状态指标的刷新间隔
+	} `yaml:"metrics"`
+
+	Tracing tracing.Config `yaml:"tracing"`
+
+	Events events.Config `yaml:"events"`
+
+	Alerting alerting.Config `yaml:"alerting"`
+
+	SIEM siem.Config `yaml:"siem"`
+
+	Export export.JobConfig `yaml:"export"`
+
+	Proxy collector.ProxyConfig `yaml:"proxy"`
+
+	// UA和Client Hints解析
+	UserAgent useragent.Config `yaml:"user_agent"`
+
+	// 搜索引擎爬虫DNS验证
+	BotVerification botverify.Config `yaml:"bot_verification"`
+
+	// 离线GeoIP和ASN数据库
+	GeoIP geoip.Config `yaml:"geoip"`
+
+	// 浏览器端信号采集
+	ClientSignals clientsignals.Config `yaml:"client_signals"`
+
+	// 设备cookie，关联同一设备在不同网络下的指纹
+	Device device.Config `yaml:"device"`
+
+	// 指纹盐值、权重和按路由的指纹组成
+	Fingerprint fingerprint.Config `yaml:"fingerprint"`
+
+	// 相似指纹聚类为身份
+	Cluster cluster.Config `yaml:"identity_cluster"`
+
+	Admin struct {
+		Token string `yaml:"token"` // 管理接口Bearer令牌
+	} `yaml:"admin"`
 }
 
 // 应用实例
@@ -73,11 +159,29 @@ type App struct {
 	redisClient     *storage.RedisClient
 	mysqlClient     *storage.MySQLClient
 	collector       *collector.Collector
+	clientSignals   *clientsignals.Service
+	devices         *device.Manager
+	clusterer       *cluster.Clusterer
 	fingerprint     *fingerprint.Generator
 	scorer          *scorer.Scorer
 	analyzer        *analyzer.Analyzer
 	limiter         *limiter.Limiter
+	accessWriter    *storage.AccessWriter
+	health          *health.Checker
+	failurePolicy   *resilience.FailurePolicy
+	localLimiter    *limiter.LocalLimiter
+	botLimiter      *limiter.LocalLimiter
+	events          *events.Bus
+	alerts          *alerting.Engine
+	deadLetters     alerting.DeadLetterStore
+	siem            *siem.Exporter
+	exports         *export.JobManager
+	degradedStats   resilience.DegradedStats
 	router          *gin.Engine
+	server          *http.Server
+	draining        atomic.Bool
+	jobs            []backgroundJob
+	closeOnce       sync.Once
 }
 
 func main() {
@@ -112,10 +216,57 @@ func loadConfig(filename string) (*Config, error) {
 	if err := yaml.Unmarshal(data, &config); err != nil {
 		return nil, fmt.Errorf("解析配置文件失败: %v", err)
 	}
+	config.applyDefaults()
 
 	return &config, nil
 }
 
+// 为未配置的项填充默认值
+func (config *Config) applyDefaults() {
+	if config.Server.ReadTimeout <= 0 {
+		config.Server.ReadTimeout = 15 * time.Second
+	}
+	if config.Server.ReadHeaderTimeout <= 0 {
+		config.Server.ReadHeaderTimeout = 5 * time.Second
+	}
+	if config.Server.WriteTimeout <= 0 {
+		config.Server.WriteTimeout = 30 * time.Second
+	}
+	if config.Server.IdleTimeout <= 0 {
+		config.Server.IdleTimeout = 60 * time.Second
+	}
+	if config.Server.MaxHeaderBytes <= 0 {
+		config.Server.MaxHeaderBytes = 1 << 20
+	}
+	if config.Server.ShutdownTimeout <= 0 {
+		config.Server.ShutdownTimeout = 30 * time.Second
+	}
+	if config.Health.CheckTimeout <= 0 {
+		config.Health.CheckTimeout = 2 * time.Second
+	}
+	if config.Redis.DialTimeout <= 0 {
+		config.Redis.DialTimeout = time.Second
+	}
+	if config.Redis.ReadTimeout <= 0 {
+		config.Redis.ReadTimeout = 500 * time.Millisecond
+	}
+	if config.Redis.WriteTimeout <= 0 {
+		config.Redis.WriteTimeout = 500 * time.Millisecond
+	}
+	if config.Metrics.Path == "" {
+		config.Metrics.Path = "/metrics"
+	}
+	if config.Metrics.GaugeInterval <= 0 {
+		config.Metrics.GaugeInterval = 15 * time.Second
+	}
+	if config.Fingerprint.Salt == "" {
+		config.Fingerprint.Salt = fingerprint.DefaultSaltConfig.Salt
+	}
+	if config.Fingerprint.Overlap <= 0 {
+		config.Fingerprint.Overlap = fingerprint.DefaultSaltConfig.Overlap
+	}
+}
+
 // 创建应用实例
 func NewApp(config *Config) (*App, error) {
 	app := &App{config: config}
@@ -125,6 +276,11 @@ func NewApp(config *Config) (*App, error) {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	// 初始化链路追踪（最先注册，最后关闭，确保其他任务的span能被导出）
+	if err := app.initTracing(); err != nil {
+		return nil, fmt.Errorf("初始化链路追踪失败: %v", err)
+	}
+
 	// 初始化存储层
 	if err := app.initStorage(); err != nil {
 		return nil, fmt.Errorf("初始化存储失败: %v", err)
@@ -138,6 +294,12 @@ func NewApp(config *Config) (*App, error) {
 	// 初始化路由
 	app.initRoutes()
 
+	// 初始化监控指标
+	if err := app.initMetrics(); err != nil {
+		app.Close()
+		return nil, fmt.Errorf("初始化监控指标失败: %v", err)
+	}
+
 	return app, nil
 }
 
@@ -149,10 +311,14 @@ func (app *App) initStorage() error {
 		app.config.Redis.Password,
 		app.config.Redis.DB,
 		app.config.Redis.PoolSize,
+		app.config.Redis.DialTimeout,
+		app.config.Redis.ReadTimeout,
+		app.config.Redis.WriteTimeout,
 	)
 	if err != nil {
 		return fmt.Errorf("Redis连接失败: %v", err)
 	}
+	redisClient.UseCircuitBreaker(resilience.NewCircuitBreaker("redis", app.config.Security.FailurePolicy.Breaker))
 	app.redisClient = redisClient
 
 	// 初始化MySQL
@@ -167,16 +333,107 @@ func (app *App) initStorage() error {
 	}
 	app.mysqlClient = mysqlClient
 
+	// 访问日志异步写入，关闭时需要先排空队列
+	app.accessWriter = storage.NewAccessWriter(mysqlClient, app.config.MySQL.Writer)
+	app.addJob("access-writer", app.accessWriter.Close)
+
+	// 注册依赖健康检查
+	app.health = health.NewChecker(app.config.Health.CheckTimeout)
+	app.health.Register("redis", redisClient.Ping, redisClient.PoolStats)
+	app.health.Register("mysql", mysqlClient.Ping, mysqlClient.PoolStats)
+
 	return nil
 }
 
 // 初始化核心模块
 func (app *App) initModules() error {
 	// 初始化采集器
-	app.collector = collector.NewCollector()
+	proxyDetector, err := collector.NewProxyDetector(app.config.Proxy)
+	if err != nil {
+		return fmt.Errorf("代理配置错误: %v", err)
+	}
+	app.collector = collector.NewCollector(proxyDetector)
+	app.collector.SetTLSDetector(tlsfp.NewDetector(app.config.Server.TLS.Signatures))
+	headerLibrary, err := headerorder.NewLibrary(app.config.Server.HeaderCapture.Profiles)
+	if err != nil {
+		return fmt.Errorf("请求头顺序配置错误: %v", err)
+	}
+	app.collector.SetHeaderLibrary(headerLibrary)
+	agents, err := useragent.NewParser(app.config.UserAgent)
+	if err != nil {
+		return fmt.Errorf("UA模式库配置错误: %v", err)
+	}
+	app.collector.SetUserAgentParser(agents)
+	if app.config.BotVerification.Enabled {
+		verifier, err := botverify.NewVerifier(app.config.BotVerification, nil)
+		if err != nil {
+			return fmt.Errorf("爬虫验证配置错误: %v", err)
+		}
+		app.collector.SetBotVerifier(verifier)
+		app.botLimiter = limiter.NewLocalLimiter(verifier.Config().RateLimit)
+	}
+	if app.config.GeoIP.Enabled {
+		geo, err := geoip.NewDatabase(app.config.GeoIP)
+		if err != nil {
+			return fmt.Errorf("初始化GeoIP失败: %v", err)
+		}
+		app.collector.SetGeoIP(geo)
+		app.addJob("geoip", geo.Close)
+	}
+	if app.config.ClientSignals.Enabled {
+		signals, err := clientsignals.NewService(app.config.ClientSignals, func(r *http.Request) string {
+			ip, _ := proxyDetector.ExtractRealIP(r)
+			return ip
+		})
+		if err != nil {
+			return fmt.Errorf("初始化浏览器信号采集失败: %v", err)
+		}
+		app.clientSignals = signals
+		app.collector.SetClientSignals(signals)
+	}
 
-	// 初始化指纹生成器
-	app.fingerprint = fingerprint.NewGenerator("firewall-controller-salt")
+	// 初始化指纹生成器，TLS和浏览器信号组件只在对应功能开启时参与
+	app.fingerprint = fingerprint.NewGenerator(app.config.Fingerprint.Salt)
+	if app.config.Fingerprint.SaltFile != "" {
+		salts, err := fingerprint.NewSaltFile(app.config.Fingerprint.SaltConfig)
+		if err != nil {
+			return fmt.Errorf("加载指纹盐值失败: %v", err)
+		}
+		app.fingerprint.SetSaltSource(salts)
+		app.addJob("fingerprint-salts", salts.Close)
+	}
+	weights := fingerprint.DefaultWeights
+	if app.config.Fingerprint.Weights != nil {
+		weights = *app.config.Fingerprint.Weights
+		weights.TLS, weights.Client = 0, 0
+	}
+	if app.config.Server.TLS.Enabled {
+		weights.TLS = app.config.Server.TLS.FingerprintWeight
+	}
+	if app.config.ClientSignals.Enabled {
+		weights.Client = app.config.ClientSignals.FingerprintWeight
+	}
+	app.fingerprint.SetWeights(weights)
+	if err := app.fingerprint.SetProfiles(app.config.Fingerprint.Profiles); err != nil {
+		return fmt.Errorf("指纹组成配置错误: %v", err)
+	}
+	for _, profile := range app.config.Fingerprint.Profiles {
+		app.collector.AddHeaders(profile.Headers...)
+	}
+
+	// 初始化设备身份关联
+	if app.config.Device.Enabled {
+		devices, err := device.NewManager(app.config.Device, app.redisClient)
+		if err != nil {
+			return fmt.Errorf("初始化设备cookie失败: %v", err)
+		}
+		app.devices = devices
+	}
+
+	// 初始化身份聚类
+	if app.config.Cluster.Enabled {
+		app.clusterer = cluster.NewClusterer(app.config.Cluster, app.fingerprint, app.redisClient)
+	}
 
 	// 初始化打分系统
 	app.scorer = scorer.NewScorer(app.config.Security.Scoring, app.redisClient)
@@ -185,14 +442,63 @@ func (app *App) initModules() error {
 	app.analyzer = analyzer.NewAnalyzer(app.config.Security.Analyzer, app.redisClient)
 
 	// 初始化限制器
+	if err := app.config.Security.Limiter.Shadow.Validate(); err != nil {
+		return fmt.Errorf("影子模式配置错误: %v", err)
+	}
 	app.limiter = limiter.NewLimiter(app.config.Security.Limiter, app.redisClient)
 
+	// 初始化依赖故障策略
+	failurePolicy, err := resilience.NewFailurePolicy(app.config.Security.FailurePolicy)
+	if err != nil {
+		return err
+	}
+	app.failurePolicy = failurePolicy
+	app.localLimiter = limiter.NewLocalLimiter(app.config.Security.LocalLimiter)
+
+	// 初始化决策事件总线
+	app.events = events.NewBus(app.config.Events, app.redisClient)
+	app.addJob("event-bus", app.events.Close)
+
+	// 初始化告警
+	if app.config.Alerting.Enabled {
+		app.deadLetters = alerting.NewDeadLetterStore(app.redisClient, app.config.Alerting.DeadLetter.MaxEntries)
+		alerts, err := alerting.NewEngine(app.config.Alerting, app.events, app.redisClient, app.deadLetters)
+		if err != nil {
+			return fmt.Errorf("初始化告警失败: %v", err)
+		}
+		alerts.Start()
+		app.alerts = alerts
+		app.addJob("alerting", app.alerts.Close)
+	}
+
+	// 初始化SIEM导出
+	if app.config.SIEM.Enabled {
+		siemConfig := app.config.SIEM
+		siemConfig.Version = version
+		exporter, err := siem.NewExporter(siemConfig)
+		if err != nil {
+			return fmt.Errorf("初始化SIEM导出失败: %v", err)
+		}
+		app.siem = exporter
+		app.addJob("siem", app.siem.Close)
+	}
+
+	// 初始化异步导出
+	exports, err := export.NewJobManager(app.config.Export, app.mysqlClient)
+	if err != nil {
+		return fmt.Errorf("初始化日志导出失败: %v", err)
+	}
+	app.exports = exports
+	app.addJob("log-export", app.exports.Close)
+
 	return nil
 }
 
 // 初始化路由
 func (app *App) initRoutes() {
 	app.router = gin.New()
+	// 客户端IP统一由采集器按proxy配置解析，gin自身不采信任何转发头（访问日志中的IP为直连地址）
+	app.router.SetTrustedProxies(nil)
 
 	// 添加中间件
 	app.router.Use(gin.Logger())
@@ -202,16 +508,32 @@ func (app *App) initRoutes() {
 	// 添加防火墙中间件
 	app.router.Use(app.firewallMiddleware())
 
-	// API路由组
+	// API路由组，配置了admin.token时所有管理接口需要Bearer令牌认证
 	apiV1 := app.router.Group(app.config.WebUI.APIPrefix)
+	if app.config.Admin.Token != "" {
+		apiV1.Use(middleware.AdminAuth(app.config.Admin.Token))
+	} else {
+		log.Printf("未配置admin.token，管理接口不需要认证")
+	}
 
 	// 注册API路由
 	configAPI := api.NewConfigAPI(app.limiter, app.scorer)
 	configAPI.RegisterRoutes(apiV1)
 
-	logsAPI := api.NewLogsAPI(app.mysqlClient, app.redisClient)
+	eventsAPI := api.NewEventsAPI(app.events)
+	eventsAPI.RegisterRoutes(apiV1)
+
+	logsAPI := api.NewLogsAPI(app.mysqlClient, app.redisClient, eventsAPI, app.exports)
 	logsAPI.RegisterRoutes(apiV1)
 
+	shadowAPI := api.NewShadowAPI(app.limiter, app.mysqlClient)
+	shadowAPI.RegisterRoutes(apiV1)
+
+	if app.alerts != nil {
+		alertsAPI := api.NewAlertsAPI(app.alerts, app.deadLetters)
+		alertsAPI.RegisterRoutes(apiV1)
+	}
+
 	scoreAPI := api.NewScoreAPI(app.scorer, app.redisClient)
 	scoreAPI.RegisterRoutes(apiV1)
 
@@ -221,10 +543,28 @@ func (app *App) initRoutes() {
 	proxyAPI := api.NewProxyAPI(app.collector)
 	proxyAPI.RegisterRoutes(apiV1)
 
+	if app.clusterer != nil {
+		clusterAPI := api.NewClusterAPI(app.clusterer)
+		clusterAPI.RegisterRoutes(apiV1)
+	}
+
+	// 浏览器信号采集脚本和提交地址
+	if app.clientSignals != nil {
+		signalsConfig := app.clientSignals.Config()
+		app.router.GET(signalsConfig.ScriptPath, gin.WrapF(app.clientSignals.ServeScript))
+		app.router.POST(signalsConfig.BeaconPath, gin.WrapF(app.clientSignals.ServeBeacon))
+	}
+
 	// 系统信息API
 	apiV1.GET("/system/info", app.getSystemInfo)
 	apiV1.GET("/system/health", app.getHealthCheck)
 
+	// 存活和就绪探针供编排系统调用，不需要认证
+	probes := app.router.Group(app.config.WebUI.APIPrefix)
+	probes.GET("/system/live", app.getLiveness)
+	probes.GET("/system/ready", app.getReadiness)
+	apiV1.GET("/system/resilience", app.getResilienceStatus)
+
 	// 静态文件服务（WebUI）
 	if app.config.WebUI.Enabled {
 		app.router.Static("/static", app.config.WebUI.StaticPath+"/static")
@@ -249,59 +589,181 @@ func (app *App) initRoutes() {
 	}
 }
 
+// 浏览器信号脚本和提交地址不经过防火墙检查
+func (app *App) isClientSignalsPath(path string) bool {
+	if app.clientSignals == nil {
+		return false
+	}
+	config := app.clientSignals.Config()
+	return path == config.ScriptPath || path == config.BeaconPath
+}
+
 // 防火墙中间件
 func (app *App) firewallMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 跳过API和静态文件的防火墙检查
 		if c.Request.URL.Path == "/api/v1/system/health" ||
+		   c.Request.URL.Path == app.config.Metrics.Path ||
 		   c.Request.URL.Path == "/favicon.ico" ||
+		   app.isClientSignalsPath(c.Request.URL.Path) ||
 		   strings.HasPrefix(c.Request.URL.Path, "/static/") ||
 		   strings.HasPrefix(c.Request.URL.Path, app.config.WebUI.APIPrefix) {
 			c.Next()
 			return
 		}
 
+		pipelineStart := time.Now()
+
+		// 继承上游trace context，并传播给下游处理器
+		ctx := tracing.Extract(c.Request.Context(), c.Request.Header)
+		ctx, span := tracing.Tracer().Start(ctx, "firewall "+c.Request.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.method", c.Request.Method),
+				attribute.String("http.route", c.FullPath()),
+				attribute.String("url.path", c.Request.URL.Path),
+			),
+		)
+		defer span.End()
+		tracing.Inject(ctx, c.Request.Header)
+		c.Request = c.Request.WithContext(ctx)
+
 		// 采集访问信息
+		_, stage := startStage(ctx, metrics.StageCollect)
 		accessInfo := app.collector.CollectFromRequest(c.Request)
+		stage.end(nil)
+		if bot := accessInfo.BotVerification; bot != nil {
+			metrics.BotVerifications.WithLabelValues(bot.Crawler, bot.Status).Inc()
+		}
+
+		// 请求浏览器在后续请求中发送高熵Client Hints
+		if len(app.config.UserAgent.AcceptCH) > 0 {
+			c.Header("Accept-CH", strings.Join(app.config.UserAgent.AcceptCH, ", "))
+		}
 
 		// 生成用户指纹
-		userFingerprint := app.fingerprint.Generate(accessInfo)
+		_, stage = startStage(ctx, metrics.StageFingerprint)
+		userFingerprint, previousFingerprint := app.fingerprint.GenerateVersions(accessInfo)
+
+		// 盐值轮换重叠期内，把旧版本指纹的分数、封禁和白名单带到新指纹
+		if previousFingerprint != "" {
+			if _, err := app.redisClient.MigrateFingerprint(previousFingerprint, userFingerprint, app.config.Fingerprint.Overlap); err != nil {
+				log.Printf("迁移指纹状态失败: %v", err)
+			}
+		}
 
-		// 增加请求计数
-		app.redisClient.IncrementRequestRate(userFingerprint)
+		// 同一设备cookie的不同指纹合并为一个身份，后续评分、封禁和行为分析都使用身份指纹
+		if app.devices != nil {
+			identity, err := app.devices.Resolve(c.Writer, c.Request, accessInfo.IP, userFingerprint)
+			if err != nil {
+				log.Printf("解析设备身份失败: %v", err)
+			}
+			accessInfo.Device = identity
+			userFingerprint = identity.Fingerprint
+		}
 
-		// 计算用户分数
+		// 相似指纹归入同一身份，开启封禁扩散时新指纹继承身份的封禁
+		if app.clusterer != nil {
+			if _, err := app.clusterer.Observe(userFingerprint, app.fingerprint.Components(accessInfo)); err != nil {
+				log.Printf("指纹聚类失败: %v", err)
+			}
+		}
+		stage.end(nil)
+		span.SetAttributes(tracing.AttrFingerprint.String(tracing.HashFingerprint(userFingerprint)))
+
+		// 增加请求计数并计算用户分数
+		_, stage = startStage(ctx, metrics.StageScore)
+		app.redisClient.IncrementRequestRate(userFingerprint)
 		scoreResult, err := app.scorer.CalculateScore(userFingerprint, accessInfo)
+		stage.end(err)
 		if err != nil {
-			log.Printf("计算用户分数失败: %v", err)
-			c.Next()
+			app.handleDependencyFailure(c, accessInfo.IP, userFingerprint, "计算用户分数失败", err)
 			return
 		}
+		span.SetAttributes(tracing.AttrScore.Int(scoreResult.NewScore))
 
 		// 获取最近访问记录进行行为分析
+		stageCtx, stage := startStage(ctx, metrics.StageAnalyze)
+		_, recentSpan := tracing.Tracer().Start(stageCtx, "redis.get_recent_access")
 		recentAccess, _ := app.redisClient.GetRecentAccess(userFingerprint, 60)
+		recentSpan.SetAttributes(attribute.Int("firewall.recent_access_count", len(recentAccess)))
+		recentSpan.End()
 		analysisResult, _ := app.analyzer.AnalyzeUser(userFingerprint, recentAccess)
+		stage.end(nil)
+		span.SetAttributes(tracing.AttrRiskLevel.String(analysisResult.RiskLevel))
 
 		// 检查限制
-		decision, err := app.limiter.CheckLimit(userFingerprint, scoreResult.NewScore, analysisResult)
+		_, stage = startStage(ctx, metrics.StageLimit)
+		var decision *limiter.LimitDecision
+		if bot := accessInfo.BotVerification; bot.Verified() {
+			// 已验证的爬虫同样受封禁和国家、ASN规则约束，之后按爬虫策略放行或限流，不参与分数和行为分析限制
+			var limit *limiter.LocalLimiter
+			if bot.Policy == botverify.PolicyRateLimit {
+				limit = app.botLimiter
+			}
+			decision, err = app.limiter.CheckVerifiedBot(userFingerprint, bot.Crawler, limit, accessInfo.Geo)
+		} else {
+			decision, err = app.limiter.CheckLimit(userFingerprint, scoreResult.NewScore, analysisResult, accessInfo.Geo)
+		}
+		stage.end(err)
 		if err != nil {
-			log.Printf("检查限制失败: %v", err)
-			c.Next()
+			app.handleDependencyFailure(c, accessInfo.IP, userFingerprint, "检查限制失败", err)
 			return
 		}
+		span.SetAttributes(
+			tracing.AttrAction.String(decision.Action),
+			tracing.AttrReason.String(decision.Category),
+		)
+
+		// 新的封禁扩散到同一身份的其他指纹
+		if app.clusterer != nil && decision.Action == "ban" && decision.Category != limiter.CategoryBanned {
+			if err := app.clusterer.PropagateBan(userFingerprint, decision.BanDuration); err != nil {
+				log.Printf("扩散封禁失败: %v", err)
+			}
+		}
+
+		// 影子模式：记录并计数每个本应执行的决策，实际按decision放行或处理
+		var shadowRecords []*storage.ShadowRecord
+		for _, shadow := range decision.Shadows {
+			shadowRecords = append(shadowRecords, &storage.ShadowRecord{
+				Source:   shadow.Source,
+				Category: shadow.Category,
+				Action:   shadow.Action,
+				Reason:   shadow.Reason,
+			})
+			metrics.ShadowDecisions.WithLabelValues(shadow.Source, shadow.Action).Inc()
+			log.Printf("[shadow] 指纹%s 来源=%s 动作=%s 原因=%s 实际动作=%s",
+				userFingerprint, shadow.Source, shadow.Action, shadow.Reason, decision.Action)
+		}
+		if len(shadowRecords) > 0 {
+			// 第一个影子决策是关闭影子模式后会执行的决策
+			span.SetAttributes(tracing.AttrShadow.String(shadowRecords[0].Action))
+		}
 
 		// 记录访问日志到Redis
+		_, stage = startStage(ctx, metrics.StagePersist)
 		accessLog := &storage.AccessLog{
 			Fingerprint: userFingerprint,
 			IP:          accessInfo.IP,
 			UserAgent:   accessInfo.UserAgent,
 			Path:        accessInfo.Path,
+			Method:      accessInfo.Method,
 			Timestamp:   time.Now(),
 			Score:       scoreResult.NewScore,
 		}
+		if accessInfo.HeaderOrder != nil {
+			accessLog.HeaderOrder = accessInfo.HeaderOrder.Hash
+			accessLog.HeaderMismatch = accessInfo.HeaderOrder.Mismatch != nil
+		}
+		if accessInfo.ClientSignals != nil && accessInfo.ClientSignals.Bound {
+			accessLog.ClientEvidence = accessInfo.ClientSignals.Evidence
+		}
+		if accessInfo.Device != nil {
+			accessLog.DeviceReplay = accessInfo.Device.Replay
+		}
 		app.redisClient.LogAccess(accessLog)
 
-		// 记录访问日志到MySQL
+		// 记录访问日志到MySQL（异步批量写入，写入span通过链接关联到本请求）
 		accessRecord := &storage.AccessRecord{
 			Fingerprint: userFingerprint,
 			IP:          accessInfo.IP,
@@ -311,11 +773,63 @@ func (app *App) firewallMiddleware() gin.HandlerFunc {
 			Score:       scoreResult.NewScore,
 			Action:      decision.Action,
 			Timestamp:   time.Now(),
+			Shadows:     shadowRecords,
+			SpanContext: span.SpanContext(),
+		}
+		if geo := accessInfo.Geo; geo != nil {
+			accessRecord.Country = geo.Country
+			accessRecord.City = geo.City
+			accessRecord.ASN = geo.ASN
+			accessRecord.ASOrg = geo.Organization
+		}
+		if !app.accessWriter.Write(accessRecord) {
+			metrics.WriteQueueDropped.Inc()
+			log.Printf("访问日志队列已满，丢弃记录: %s", userFingerprint)
 		}
-		app.mysqlClient.LogAccess(accessRecord)
 
-		// 应用限制决策
-		if app.limiter.ApplyDecision(c.Writer, c.Request, decision) {
+		// 导出到SIEM
+		if app.siem != nil {
+			app.siem.Export(&siem.Record{
+				Access:   accessRecord,
+				Score:    scoreResult,
+				Analysis: analysisResult,
+				Category: decision.Category,
+				Reason:   decision.Reason,
+			})
+		}
+		stage.end(nil)
+
+		metrics.Decisions.WithLabelValues(decision.Action, decision.Category).Inc()
+		metrics.PipelineDuration.Observe(time.Since(pipelineStart).Seconds())
+
+		// 发布决策事件
+		event := events.Event{
+			Fingerprint: userFingerprint,
+			IP:          accessInfo.IP,
+			Method:      accessInfo.Method,
+			Path:        accessInfo.Path,
+			UserAgent:   accessInfo.UserAgent,
+			Action:      decision.Action,
+			Category:    decision.Category,
+			Reason:      decision.Reason,
+			Score:       scoreResult.NewScore,
+			RiskLevel:   analysisResult.RiskLevel,
+			RiskScore:   analysisResult.RiskScore,
+		}
+		if len(shadowRecords) > 0 {
+			event.ShadowSource = shadowRecords[0].Source
+			event.ShadowAction = shadowRecords[0].Action
+		}
+		app.events.Publish(event)
+
+		// 应用限制决策（包含限速延迟）
+		_, applySpan := tracing.Tracer().Start(ctx, "apply_decision", trace.WithAttributes(
+			tracing.AttrAction.String(decision.Action),
+			tracing.AttrDelayMs.Int64(decision.Delay.Milliseconds()),
+		))
+		blocked := app.limiter.ApplyDecision(c.Writer, c.Request, decision)
+		applySpan.End()
+		if blocked {
 			c.Abort()
 			return
 		}
@@ -328,53 +842,3 @@ func (app *App) firewallMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
-
-// 获取系统信息
-func (app *App) getSystemInfo(c *gin.Context) {
-	info := map[string]interface{}{
-		"name":         "Firewall Controller",
-		"version":      "1.0.0",
-		"go_version":   "1.21",
-		"build_time":   "2024-01-15T10:00:00Z",
-		"uptime":       time.Since(time.Now().Add(-time.Hour)).String(), // 模拟运行时间
-		"user_registration_allowed": app.config.User.AllowRegistration,
-	}
-
-	c.JSON(http.StatusOK, api.ConfigResponse{
-		Success: true,
-		Data:    info,
-	})
-}
-
-// 健康检查
-func (app *App) getHealthCheck(c *gin.Context) {
-	health := map[string]interface{}{
-		"status":    "healthy",
-		"timestamp": time.Now(),
-		"services": map[string]string{
-			"redis": "connected",
-			"mysql": "connected",
-		},
-	}
-
-	c.JSON(http.StatusOK, api.ConfigResponse{
-		Success: true,
-		Data:    health,
-	})
-}
-
-// 运行应用
-func (app *App) Run() error {
-	addr := fmt.Sprintf(":%d", app.config.Server.Port)
-	return app.router.Run(addr)
-}
-
-// 关闭应用
-func (app *App) Close() {
-	if app.redisClient != nil {
-		app.redisClient.Close()
-	}
-	if app.mysqlClient != nil {
-		app.mysqlClient.Close()
-	}
-}
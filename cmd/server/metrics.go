@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"securefingerprint/internal/metrics"
+	"securefingerprint/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 挂载指标端点：配置了独立监听地址时单独提供服务，否则挂载在主服务并要求管理员认证
+func (app *App) initMetrics() error {
+	if !app.config.Metrics.Enabled {
+		return nil
+	}
+
+	app.startGaugeCollector()
+
+	if app.config.Metrics.Listen != "" {
+		return app.startMetricsServer()
+	}
+
+	if app.config.Admin.Token == "" {
+		log.Printf("未配置admin.token和metrics.listen，指标端点未挂载")
+		return nil
+	}
+	app.router.GET(app.config.Metrics.Path, middleware.AdminAuth(app.config.Admin.Token), gin.WrapH(metrics.Handler()))
+	return nil
+}
+
+// 在独立地址上提供指标服务
+func (app *App) startMetricsServer() error {
+	mux := http.NewServeMux()
+	mux.Handle(app.config.Metrics.Path, metrics.Handler())
+	server := &http.Server{
+		Addr:              app.config.Metrics.Listen,
+		Handler:           mux,
+		ReadHeaderTimeout: app.config.Server.ReadHeaderTimeout,
+	}
+
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("指标服务异常退出: %v", err)
+		}
+	}()
+	log.Printf("指标服务监听于 %s%s", server.Addr, app.config.Metrics.Path)
+
+	app.addJob("metrics-server", server.Shutdown)
+	return nil
+}
+
+// 定期刷新封禁数、白名单数、写入队列深度等状态类指标
+func (app *App) startGaugeCollector() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(app.config.Metrics.GaugeInterval)
+		defer ticker.Stop()
+
+		for {
+			app.updateGauges(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	app.addJob("metrics-gauges", func(stopCtx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return nil
+		case <-stopCtx.Done():
+			return stopCtx.Err()
+		}
+	})
+}
+
+// 刷新状态类指标
+func (app *App) updateGauges(ctx context.Context) {
+	metrics.WriteQueueDepth.Set(float64(app.accessWriter.QueueDepth()))
+
+	if bans, err := app.redisClient.CountKeys(ctx, "banned:*"); err == nil {
+		metrics.ActiveBans.Set(float64(bans))
+	}
+	if whitelisted, err := app.redisClient.CountKeys(ctx, "whitelist:*"); err == nil {
+		metrics.WhitelistedFingerprints.Set(float64(whitelisted))
+	}
+}
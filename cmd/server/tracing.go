@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"securefingerprint/internal/metrics"
+	"securefingerprint/internal/tracing"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 决策管道阶段，结束时同时记录耗时指标和span
+type pipelineStage struct {
+	name  string
+	start time.Time
+	span  trace.Span
+}
+
+// 开始一个管道阶段
+func startStage(ctx context.Context, name string) (context.Context, *pipelineStage) {
+	ctx, span := tracing.Tracer().Start(ctx, name)
+	return ctx, &pipelineStage{name: name, start: time.Now(), span: span}
+}
+
+// 结束管道阶段
+func (s *pipelineStage) end(err error) {
+	metrics.ObserveStage(s.name, s.start)
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
+
+// 初始化链路追踪，关闭时刷新未导出的span
+func (app *App) initTracing() error {
+	shutdown, err := tracing.Init(app.config.Tracing)
+	if err != nil {
+		return err
+	}
+	app.addJob("tracing", shutdown)
+	return nil
+}
@@ -13,11 +13,17 @@ CREATE TABLE IF NOT EXISTS access_logs (
     score INT DEFAULT 100 COMMENT '用户分数',
     action VARCHAR(20) DEFAULT 'allow' COMMENT '处理动作',
     timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '访问时间',
+    country CHAR(2) NOT NULL DEFAULT '' COMMENT 'GeoIP国家代码',
+    city VARCHAR(100) NOT NULL DEFAULT '' COMMENT 'GeoIP城市',
+    asn INT UNSIGNED NOT NULL DEFAULT 0 COMMENT '自治系统号',
+    as_org VARCHAR(255) NOT NULL DEFAULT '' COMMENT '自治系统所属组织',
     INDEX idx_fingerprint (fingerprint),
     INDEX idx_timestamp (timestamp),
     INDEX idx_ip (ip),
     INDEX idx_action (action),
-    INDEX idx_score (score)
+    INDEX idx_score (score),
+    INDEX idx_country (country),
+    INDEX idx_asn (asn)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='访问日志表';
 
 -- 创建用户统计表
@@ -48,6 +54,25 @@ CREATE TABLE IF NOT EXISTS ban_history (
     INDEX idx_ip (ip)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='封禁历史表';
 
+-- 创建影子决策表
+CREATE TABLE IF NOT EXISTS shadow_decisions (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    fingerprint VARCHAR(64) NOT NULL COMMENT '用户指纹',
+    ip VARCHAR(45) NOT NULL COMMENT 'IP地址',
+    path VARCHAR(500) COMMENT '访问路径',
+    method VARCHAR(10) COMMENT 'HTTP方法',
+    score INT COMMENT '用户分数',
+    source VARCHAR(20) NOT NULL COMMENT '决策来源',
+    category VARCHAR(20) COMMENT '原因分类',
+    shadow_action VARCHAR(20) NOT NULL COMMENT '影子模式下本应执行的动作',
+    enforced_action VARCHAR(20) NOT NULL COMMENT '实际执行的动作',
+    reason VARCHAR(200) COMMENT '决策原因',
+    position TINYINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '同一请求中的评估顺序，0为关闭影子模式后会执行的决策',
+    timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '访问时间',
+    INDEX idx_timestamp (timestamp),
+    INDEX idx_fingerprint (fingerprint)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='影子决策表';
+
 -- 创建配置历史表
 CREATE TABLE IF NOT EXISTS config_history (
     id BIGINT AUTO_INCREMENT PRIMARY KEY,
@@ -132,6 +157,9 @@ BEGIN
     -- 清理过期的封禁历史
     DELETE FROM ban_history WHERE banned_at < DATE_SUB(NOW(), INTERVAL days_to_keep DAY);
     
+    -- 清理过期的影子决策
+    DELETE FROM shadow_decisions WHERE timestamp < DATE_SUB(NOW(), INTERVAL days_to_keep DAY);
+    
     -- 清理过期的配置历史
     DELETE FROM config_history WHERE created_at < DATE_SUB(NOW(), INTERVAL days_to_keep DAY);
     
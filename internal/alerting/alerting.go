@@ -0,0 +1,326 @@
+package alerting
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"securefingerprint/internal/events"
+)
+
+// 告警级别
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// 告警
+type Alert struct {
+	ID        string            `json:"id"`
+	Rule      string            `json:"rule"`
+	Severity  string            `json:"severity"`
+	GroupKey  string            `json:"group_key"`
+	Title     string            `json:"title"`
+	Summary   string            `json:"summary"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Count     int               `json:"count"` // 分组窗口内的触发次数
+	FirstSeen time.Time         `json:"first_seen"`
+	LastSeen  time.Time         `json:"last_seen"`
+	Event     *events.Event     `json:"event,omitempty"`
+	Final     bool              `json:"final,omitempty"` // 分组窗口结束时发送的汇总，Count为窗口内的总次数
+	Test      bool              `json:"test,omitempty"`
+}
+
+// 告警配置
+type Config struct {
+	Enabled     bool            `yaml:"enabled"`
+	GroupWindow time.Duration   `yaml:"group_window"` // 同一分组在窗口开始时发送一次，窗口内再次触发时在窗口结束后发送汇总
+	Rules       []RuleConfig    `yaml:"rules"`
+	Webhooks    []WebhookConfig `yaml:"webhooks"`
+	Retry       RetryConfig     `yaml:"retry"`
+	DeadLetter  struct {
+		MaxEntries int `yaml:"max_entries"` // 死信最多保留条数
+	} `yaml:"dead_letter"`
+}
+
+// 默认告警配置
+var DefaultConfig = Config{
+	GroupWindow: 5 * time.Minute,
+	Retry:       DefaultRetryConfig,
+}
+
+// 跨实例的分组去重，返回true表示本实例获得发送权
+type Claimer interface {
+	Claim(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// 分组状态
+type alertGroup struct {
+	firstSeen time.Time
+	lastSeen  time.Time
+	count     int
+	last      *Alert // 窗口内最近一次触发，汇总沿用其标题和事件
+	owner     bool   // 本实例获得了分组的发送权
+}
+
+// 告警引擎：订阅决策事件、评估规则、分组去重后投递
+type Engine struct {
+	config   Config
+	rules    []rule
+	notifier *Notifier
+	bus      *events.Bus
+	claimer  Claimer
+
+	mu     sync.Mutex
+	groups map[string]*alertGroup
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// 创建告警引擎
+func NewEngine(config Config, bus *events.Bus, claimer Claimer, deadLetters DeadLetterStore) (*Engine, error) {
+	if config.GroupWindow <= 0 {
+		config.GroupWindow = DefaultConfig.GroupWindow
+	}
+
+	rules := make([]rule, 0, len(config.Rules))
+	for _, ruleConfig := range config.Rules {
+		r, err := newRule(ruleConfig)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+
+	notifier, err := NewNotifier(config.Webhooks, config.Retry, deadLetters)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Engine{
+		config:   config,
+		rules:    rules,
+		notifier: notifier,
+		bus:      bus,
+		claimer:  claimer,
+		groups:   make(map[string]*alertGroup),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// 启动事件订阅
+func (e *Engine) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel = cancel
+	e.notifier.Start()
+	// 同步订阅，启动后发布的事件不会遗漏
+	sub := e.bus.Subscribe(events.Filter{}, events.Cursor{})
+	go e.run(ctx, sub)
+}
+
+// 检查分组窗口是否结束的间隔
+func (e *Engine) flushInterval() time.Duration {
+	interval := e.config.GroupWindow / 10
+	if interval < time.Second {
+		interval = time.Second
+	}
+	return interval
+}
+
+// 订阅循环，因处理过慢被断开时凭游标重新订阅
+func (e *Engine) run(ctx context.Context, sub *events.Subscription) {
+	defer close(e.done)
+
+	ticker := time.NewTicker(e.flushInterval())
+	defer ticker.Stop()
+
+	var cursor events.Cursor
+	for {
+		err := e.consume(ctx, sub, &cursor, ticker.C)
+		sub.Close()
+
+		if !errors.Is(err, events.ErrSlowConsumer) {
+			return
+		}
+		log.Printf("告警引擎处理过慢，从游标%s重新订阅", cursor)
+		sub = e.bus.Subscribe(events.Filter{}, cursor)
+	}
+}
+
+// 消费订阅直到订阅关闭或引擎停止
+func (e *Engine) consume(ctx context.Context, sub *events.Subscription, cursor *events.Cursor, flush <-chan time.Time) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-flush:
+			e.flushExpired(now)
+		case event, ok := <-sub.C:
+			if !ok {
+				return sub.Err()
+			}
+			if event.Cursor.ID > 0 {
+				*cursor = event.Cursor
+			}
+			e.Evaluate(&event)
+		}
+	}
+}
+
+// 评估单个事件
+func (e *Engine) Evaluate(event *events.Event) {
+	for _, r := range e.rules {
+		if alert := r.evaluate(event); alert != nil {
+			e.fire(alert)
+		}
+	}
+}
+
+// 分组去重后投递告警：分组打开时立即发送，窗口内的后续触发只计数，窗口结束时发送汇总
+func (e *Engine) fire(alert *Alert) {
+	now := time.Now()
+
+	e.mu.Lock()
+	group, ok := e.groups[alert.GroupKey]
+	if ok && now.Sub(group.firstSeen) < e.config.GroupWindow {
+		group.count++
+		group.lastSeen = now
+		group.last = alert
+		e.mu.Unlock()
+		return
+	}
+	summaries := e.expireLocked(now, false)
+	group = &alertGroup{firstSeen: now, lastSeen: now, count: 1, last: alert}
+	e.groups[alert.GroupKey] = group
+	e.mu.Unlock()
+	e.enqueue(summaries)
+
+	// 多实例时只有获得分组的实例发送
+	if e.claimer != nil {
+		claimed, err := e.claimer.Claim(context.Background(), "alerting:group:"+alert.GroupKey, e.config.GroupWindow)
+		if err == nil && !claimed {
+			return
+		}
+	}
+
+	e.mu.Lock()
+	group.owner = true
+	e.mu.Unlock()
+
+	e.notifier.Enqueue(&Alert{
+		ID:        newAlertID(),
+		Rule:      alert.Rule,
+		Severity:  alert.Severity,
+		GroupKey:  alert.GroupKey,
+		Title:     alert.Title,
+		Summary:   alert.Summary,
+		Labels:    alert.Labels,
+		Count:     1,
+		FirstSeen: now,
+		LastSeen:  now,
+		Event:     alert.Event,
+	})
+}
+
+// 发送窗口已结束分组的汇总
+func (e *Engine) flushExpired(now time.Time) {
+	e.mu.Lock()
+	summaries := e.expireLocked(now, false)
+	e.mu.Unlock()
+	e.enqueue(summaries)
+}
+
+// 移除窗口已结束（all为true时为全部）的分组，返回需要发送的汇总：
+// 只有本实例发送过首条告警且窗口内再次触发过的分组需要汇总，
+// 多实例时计数为获得发送权的实例上的触发次数
+func (e *Engine) expireLocked(now time.Time, all bool) []*Alert {
+	var summaries []*Alert
+	for key, group := range e.groups {
+		if !all && now.Sub(group.firstSeen) < e.config.GroupWindow {
+			continue
+		}
+		delete(e.groups, key)
+		if !group.owner || group.count <= 1 {
+			continue
+		}
+		summary := *group.last
+		summary.ID = newAlertID()
+		summary.Count = group.count
+		summary.FirstSeen = group.firstSeen
+		summary.LastSeen = group.lastSeen
+		summary.Final = true
+		summaries = append(summaries, &summary)
+	}
+	return summaries
+}
+
+func (e *Engine) enqueue(alerts []*Alert) {
+	for _, alert := range alerts {
+		e.notifier.Enqueue(alert)
+	}
+}
+
+// 规则列表
+func (e *Engine) Rules() []RuleConfig {
+	configs := make([]RuleConfig, 0, len(e.rules))
+	for _, r := range e.rules {
+		configs = append(configs, r.config())
+	}
+	return configs
+}
+
+// 投递器
+func (e *Engine) Notifier() *Notifier {
+	return e.notifier
+}
+
+// 停止订阅，发送未结束分组的汇总并等待投递队列清空
+func (e *Engine) Close(ctx context.Context) error {
+	if e.cancel != nil {
+		e.cancel()
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	e.mu.Lock()
+	summaries := e.expireLocked(time.Now(), true)
+	e.mu.Unlock()
+	e.enqueue(summaries)
+
+	return e.notifier.Close(ctx)
+}
+
+// 测试告警
+func TestAlert() *Alert {
+	now := time.Now()
+	return &Alert{
+		ID:        newAlertID(),
+		Rule:      "test",
+		Severity:  SeverityInfo,
+		GroupKey:  "test",
+		Title:     "测试告警",
+		Summary:   "这是一条测试告警，用于验证Webhook配置",
+		Count:     1,
+		FirstSeen: now,
+		LastSeen:  now,
+		Test:      true,
+	}
+}
+
+func newAlertID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,82 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// 投递失败的告警
+type DeadLetter struct {
+	Webhook   string    `json:"webhook"`
+	Alert     *Alert    `json:"alert"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error"`
+	FailedAt  time.Time `json:"failed_at"`
+}
+
+// 死信存储
+type DeadLetterStore interface {
+	Push(ctx context.Context, letter DeadLetter) error
+	List(ctx context.Context, limit int) ([]DeadLetter, error)
+	// 取出并清空全部死信，用于重新投递
+	Drain(ctx context.Context) ([]DeadLetter, error)
+}
+
+// 列表存储接口，由Redis实现
+type ListStore interface {
+	PushCapped(ctx context.Context, key string, payload []byte, maxLen int) error
+	ListRange(ctx context.Context, key string, limit int) ([][]byte, error)
+	DrainList(ctx context.Context, key string) ([][]byte, error)
+}
+
+const deadLetterKey = "alerting:dead_letters"
+
+// 基于Redis列表的死信存储，最新的在前
+type listDeadLetters struct {
+	store      ListStore
+	maxEntries int
+}
+
+// 创建死信存储
+func NewDeadLetterStore(store ListStore, maxEntries int) DeadLetterStore {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &listDeadLetters{store: store, maxEntries: maxEntries}
+}
+
+func (d *listDeadLetters) Push(ctx context.Context, letter DeadLetter) error {
+	payload, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+	return d.store.PushCapped(ctx, deadLetterKey, payload, d.maxEntries)
+}
+
+func (d *listDeadLetters) List(ctx context.Context, limit int) ([]DeadLetter, error) {
+	payloads, err := d.store.ListRange(ctx, deadLetterKey, limit)
+	if err != nil {
+		return nil, err
+	}
+	return decodeDeadLetters(payloads), nil
+}
+
+func (d *listDeadLetters) Drain(ctx context.Context) ([]DeadLetter, error) {
+	payloads, err := d.store.DrainList(ctx, deadLetterKey)
+	if err != nil {
+		return nil, err
+	}
+	return decodeDeadLetters(payloads), nil
+}
+
+func decodeDeadLetters(payloads [][]byte) []DeadLetter {
+	letters := make([]DeadLetter, 0, len(payloads))
+	for _, payload := range payloads {
+		var letter DeadLetter
+		if err := json.Unmarshal(payload, &letter); err == nil {
+			letters = append(letters, letter)
+		}
+	}
+	return letters
+}
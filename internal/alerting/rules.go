@@ -0,0 +1,207 @@
+package alerting
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"securefingerprint/internal/events"
+)
+
+// 规则类型
+const (
+	RuleRiskLevel   = "risk_level"   // 分析结果达到指定风险等级
+	RuleActionCount = "action_count" // 窗口内指定动作次数超过阈值，如封禁潮
+	RulePathRate    = "path_rate"    // 路径请求量超过历史基线的倍数
+)
+
+// 告警规则配置
+type RuleConfig struct {
+	Name        string        `yaml:"name" json:"name"`
+	Type        string        `yaml:"type" json:"type"`
+	Severity    string        `yaml:"severity" json:"severity"`         // info / warning / critical
+	RiskLevel   string        `yaml:"risk_level" json:"risk_level"`     // risk_level: 触发等级，默认critical
+	Action      string        `yaml:"action" json:"action"`             // action_count: 统计的动作，默认ban
+	Threshold   int           `yaml:"threshold" json:"threshold"`       // action_count: 窗口内次数阈值
+	PathPrefix  string        `yaml:"path_prefix" json:"path_prefix"`   // path_rate: 统计的路径前缀
+	Multiplier  float64       `yaml:"multiplier" json:"multiplier"`     // path_rate: 超过基线的倍数
+	MinRequests int           `yaml:"min_requests" json:"min_requests"` // path_rate: 触发所需的最少请求数
+	Window      time.Duration `yaml:"window" json:"window"`             // 统计窗口
+}
+
+// 规则评估器
+type rule interface {
+	config() RuleConfig
+	// 评估事件，触发时返回告警
+	evaluate(event *events.Event) *Alert
+}
+
+// 根据配置创建规则
+func newRule(config RuleConfig) (rule, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("告警规则缺少名称")
+	}
+	if config.Severity == "" {
+		config.Severity = SeverityWarning
+	}
+	if config.Window <= 0 {
+		config.Window = 5 * time.Minute
+	}
+
+	switch config.Type {
+	case RuleRiskLevel:
+		if config.RiskLevel == "" {
+			config.RiskLevel = "critical"
+		}
+		return &riskLevelRule{cfg: config}, nil
+	case RuleActionCount:
+		if config.Action == "" {
+			config.Action = "ban"
+		}
+		if config.Threshold <= 0 {
+			return nil, fmt.Errorf("告警规则%s的threshold必须大于0", config.Name)
+		}
+		return &actionCountRule{cfg: config}, nil
+	case RulePathRate:
+		if config.Multiplier <= 1 {
+			config.Multiplier = 3
+		}
+		if config.MinRequests <= 0 {
+			config.MinRequests = 100
+		}
+		return &pathRateRule{cfg: config}, nil
+	default:
+		return nil, fmt.Errorf("告警规则%s的类型无效: %s", config.Name, config.Type)
+	}
+}
+
+// 风险等级规则：每个指纹单独分组
+type riskLevelRule struct {
+	cfg RuleConfig
+}
+
+func (r *riskLevelRule) config() RuleConfig { return r.cfg }
+
+func (r *riskLevelRule) evaluate(event *events.Event) *Alert {
+	if event.RiskLevel != r.cfg.RiskLevel {
+		return nil
+	}
+	return &Alert{
+		Rule:     r.cfg.Name,
+		Severity: r.cfg.Severity,
+		GroupKey: r.cfg.Name + ":" + event.Fingerprint,
+		Title:    fmt.Sprintf("检测到%s风险行为", event.RiskLevel),
+		Summary: fmt.Sprintf("指纹 %s (IP %s) 风险分 %.1f，访问 %s %s，决策 %s",
+			event.Fingerprint, event.IP, event.RiskScore, event.Method, event.Path, event.Action),
+		Labels: map[string]string{
+			"fingerprint": event.Fingerprint,
+			"ip":          event.IP,
+			"risk_level":  event.RiskLevel,
+		},
+		Event: event,
+	}
+}
+
+// 动作计数规则：滑动窗口内动作次数超过阈值
+type actionCountRule struct {
+	cfg  RuleConfig
+	mu   sync.Mutex
+	hits []time.Time
+}
+
+func (r *actionCountRule) config() RuleConfig { return r.cfg }
+
+func (r *actionCountRule) evaluate(event *events.Event) *Alert {
+	if event.Action != r.cfg.Action {
+		return nil
+	}
+
+	r.mu.Lock()
+	now := event.Timestamp
+	cutoff := now.Add(-r.cfg.Window)
+	kept := r.hits[:0]
+	for _, hit := range r.hits {
+		if hit.After(cutoff) {
+			kept = append(kept, hit)
+		}
+	}
+	r.hits = append(kept, now)
+	count := len(r.hits)
+	r.mu.Unlock()
+
+	if count <= r.cfg.Threshold {
+		return nil
+	}
+	return &Alert{
+		Rule:     r.cfg.Name,
+		Severity: r.cfg.Severity,
+		GroupKey: r.cfg.Name,
+		Title:    fmt.Sprintf("%s内%s次数超过阈值", r.cfg.Window, r.cfg.Action),
+		Summary:  fmt.Sprintf("最近%s内共%d次%s，阈值%d", r.cfg.Window, count, r.cfg.Action, r.cfg.Threshold),
+		Labels: map[string]string{
+			"action": r.cfg.Action,
+			"count":  fmt.Sprintf("%d", count),
+		},
+		Event: event,
+	}
+}
+
+// 路径请求量规则：当前窗口请求数超过历史窗口EWMA基线的倍数
+type pathRateRule struct {
+	cfg         RuleConfig
+	mu          sync.Mutex
+	windowStart time.Time
+	count       int
+	baseline    float64
+	warm        bool
+}
+
+// 基线平滑系数
+const baselineAlpha = 0.2
+
+func (r *pathRateRule) config() RuleConfig { return r.cfg }
+
+func (r *pathRateRule) evaluate(event *events.Event) *Alert {
+	if !strings.HasPrefix(event.Path, r.cfg.PathPrefix) {
+		return nil
+	}
+
+	r.mu.Lock()
+	now := event.Timestamp
+	if r.windowStart.IsZero() {
+		r.windowStart = now
+	}
+	// 窗口滚动时更新基线，跳过的空窗口按0计入
+	for now.Sub(r.windowStart) >= r.cfg.Window {
+		if r.warm {
+			r.baseline = baselineAlpha*float64(r.count) + (1-baselineAlpha)*r.baseline
+		} else {
+			r.baseline = float64(r.count)
+			r.warm = true
+		}
+		r.count = 0
+		r.windowStart = r.windowStart.Add(r.cfg.Window)
+	}
+	r.count++
+	count, baseline, warm := r.count, r.baseline, r.warm
+	r.mu.Unlock()
+
+	if !warm || count < r.cfg.MinRequests || float64(count) <= baseline*r.cfg.Multiplier {
+		return nil
+	}
+	return &Alert{
+		Rule:     r.cfg.Name,
+		Severity: r.cfg.Severity,
+		GroupKey: r.cfg.Name,
+		Title:    fmt.Sprintf("路径%s请求量异常", r.cfg.PathPrefix),
+		Summary: fmt.Sprintf("当前%s窗口内%d次请求，基线%.1f，超过%.1f倍",
+			r.cfg.Window, count, baseline, r.cfg.Multiplier),
+		Labels: map[string]string{
+			"path_prefix": r.cfg.PathPrefix,
+			"count":       fmt.Sprintf("%d", count),
+			"baseline":    fmt.Sprintf("%.1f", baseline),
+		},
+		Event: event,
+	}
+}
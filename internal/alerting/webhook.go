@@ -0,0 +1,388 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+// Webhook配置
+type WebhookConfig struct {
+	Name        string            `yaml:"name" json:"name"`
+	URL         string            `yaml:"url" json:"url"`
+	Secret      string            `yaml:"secret" json:"-"`                  // HMAC-SHA256签名密钥
+	Template    string            `yaml:"template" json:"template"`         // Go text/template消息模板，为空时发送告警JSON
+	ContentType string            `yaml:"content_type" json:"content_type"` // 默认application/json
+	Headers     map[string]string `yaml:"headers" json:"-"`
+	Timeout     time.Duration     `yaml:"timeout" json:"timeout"`
+	MinSeverity string            `yaml:"min_severity" json:"min_severity"` // 低于该级别的告警不发送
+}
+
+// 重试配置
+type RetryConfig struct {
+	MaxAttempts    int           `yaml:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+}
+
+// 默认重试配置
+var DefaultRetryConfig = RetryConfig{
+	MaxAttempts:    5,
+	InitialBackoff: time.Second,
+	MaxBackoff:     time.Minute,
+}
+
+// 签名相关请求头
+const (
+	HeaderSignature = "X-Firewall-Signature"
+	HeaderTimestamp = "X-Firewall-Timestamp"
+	HeaderAlertID   = "X-Firewall-Alert-ID"
+)
+
+var severityRank = map[string]int{
+	SeverityInfo:     0,
+	SeverityWarning:  1,
+	SeverityCritical: 2,
+}
+
+// 单次投递结果
+type DeliveryResult struct {
+	Webhook    string `json:"webhook"`
+	Attempts   int    `json:"attempts"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// Webhook投递目标
+type webhook struct {
+	config   WebhookConfig
+	template *template.Template
+	client   *http.Client
+	queue    chan *Alert
+	pending  atomic.Int32 // 已入队但尚未投递完成的告警数
+}
+
+// 告警投递器，每个Webhook独立队列，互不阻塞
+type Notifier struct {
+	webhooks    []*webhook
+	retry       RetryConfig
+	deadLetters DeadLetterStore
+	ctx         context.Context
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+}
+
+// 创建投递器
+func NewNotifier(configs []WebhookConfig, retry RetryConfig, deadLetters DeadLetterStore) (*Notifier, error) {
+	if retry.MaxAttempts <= 0 {
+		retry.MaxAttempts = DefaultRetryConfig.MaxAttempts
+	}
+	if retry.InitialBackoff <= 0 {
+		retry.InitialBackoff = DefaultRetryConfig.InitialBackoff
+	}
+	if retry.MaxBackoff <= 0 {
+		retry.MaxBackoff = DefaultRetryConfig.MaxBackoff
+	}
+
+	n := &Notifier{retry: retry, deadLetters: deadLetters}
+	n.ctx, n.cancel = context.WithCancel(context.Background())
+
+	for _, config := range configs {
+		if config.Name == "" || config.URL == "" {
+			return nil, fmt.Errorf("Webhook配置缺少name或url")
+		}
+		if config.ContentType == "" {
+			config.ContentType = "application/json"
+		}
+		if config.Timeout <= 0 {
+			config.Timeout = 5 * time.Second
+		}
+
+		hook := &webhook{
+			config: config,
+			client: &http.Client{Timeout: config.Timeout},
+			queue:  make(chan *Alert, 1000),
+		}
+		if config.Template != "" {
+			tmpl, err := template.New(config.Name).Funcs(templateFuncs).Parse(config.Template)
+			if err != nil {
+				return nil, fmt.Errorf("Webhook %s模板解析失败: %v", config.Name, err)
+			}
+			hook.template = tmpl
+		}
+		n.webhooks = append(n.webhooks, hook)
+	}
+
+	return n, nil
+}
+
+// 模板函数
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"upper": strings.ToUpper,
+	"time": func(t time.Time) string {
+		return t.Format(time.RFC3339)
+	},
+}
+
+// 启动投递协程
+func (n *Notifier) Start() {
+	for _, hook := range n.webhooks {
+		n.wg.Add(1)
+		go n.worker(hook)
+	}
+}
+
+// 投递告警（非阻塞，队列满时写入死信）
+func (n *Notifier) Enqueue(alert *Alert) {
+	for _, hook := range n.webhooks {
+		if severityRank[alert.Severity] < severityRank[hook.config.MinSeverity] {
+			continue
+		}
+		hook.pending.Add(1)
+		select {
+		case hook.queue <- alert:
+		default:
+			hook.pending.Add(-1)
+			n.deadLetter(hook, alert, 0, "投递队列已满")
+		}
+	}
+}
+
+// 重新投递到指定Webhook
+func (n *Notifier) Redeliver(webhookName string, alert *Alert) bool {
+	for _, hook := range n.webhooks {
+		if hook.config.Name != webhookName {
+			continue
+		}
+		hook.pending.Add(1)
+		select {
+		case hook.queue <- alert:
+			return true
+		default:
+			hook.pending.Add(-1)
+			return false
+		}
+	}
+	return false
+}
+
+// Webhook配置列表
+func (n *Notifier) Webhooks() []WebhookConfig {
+	configs := make([]WebhookConfig, 0, len(n.webhooks))
+	for _, hook := range n.webhooks {
+		configs = append(configs, hook.config)
+	}
+	return configs
+}
+
+// 同步发送测试告警（不重试），name为空时发送到所有Webhook
+func (n *Notifier) SendTest(ctx context.Context, name string) ([]DeliveryResult, error) {
+	alert := TestAlert()
+	var results []DeliveryResult
+	for _, hook := range n.webhooks {
+		if name != "" && hook.config.Name != name {
+			continue
+		}
+		start := time.Now()
+		status, err := n.send(ctx, hook, alert)
+		result := DeliveryResult{
+			Webhook:    hook.config.Name,
+			Attempts:   1,
+			StatusCode: status,
+			DurationMs: time.Since(start).Milliseconds(),
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	if name != "" && len(results) == 0 {
+		return nil, fmt.Errorf("Webhook不存在: %s", name)
+	}
+	return results, nil
+}
+
+func (n *Notifier) worker(hook *webhook) {
+	defer n.wg.Done()
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case alert, ok := <-hook.queue:
+			if !ok {
+				return
+			}
+			n.deliver(hook, alert)
+			hook.pending.Add(-1)
+		}
+	}
+}
+
+// 带指数退避的投递，最终失败写入死信
+func (n *Notifier) deliver(hook *webhook, alert *Alert) {
+	var lastErr error
+	attempts := 0 // 实际尝试次数，不可重试的状态码会提前结束
+	for attempt := 1; attempt <= n.retry.MaxAttempts; attempt++ {
+		attempts = attempt
+		status, err := n.send(n.ctx, hook, alert)
+		if err == nil {
+			return
+		}
+		lastErr = err
+		if !retryable(status) {
+			break
+		}
+		if attempt == n.retry.MaxAttempts {
+			break
+		}
+
+		select {
+		case <-n.ctx.Done():
+			n.deadLetter(hook, alert, attempt, "服务关闭: "+err.Error())
+			return
+		case <-time.After(n.backoff(attempt)):
+		}
+	}
+
+	log.Printf("告警投递失败(%s, 尝试%d次): %v", hook.config.Name, attempts, lastErr)
+	n.deadLetter(hook, alert, attempts, lastErr.Error())
+}
+
+// 网络错误、429和5xx可重试
+func retryable(status int) bool {
+	return status == 0 || status == http.StatusTooManyRequests || status >= 500
+}
+
+// 第attempt次失败后的等待时间，带±20%抖动
+func (n *Notifier) backoff(attempt int) time.Duration {
+	backoff := n.retry.InitialBackoff << uint(attempt-1)
+	if backoff <= 0 || backoff > n.retry.MaxBackoff {
+		backoff = n.retry.MaxBackoff
+	}
+	jitter := time.Duration((rand.Float64()*0.4 - 0.2) * float64(backoff))
+	return backoff + jitter
+}
+
+// 发送一次请求
+func (n *Notifier) send(ctx context.Context, hook *webhook, alert *Alert) (int, error) {
+	body, err := hook.render(alert)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", hook.config.ContentType)
+	req.Header.Set("User-Agent", "firewall-controller-alerting")
+	req.Header.Set(HeaderAlertID, alert.ID)
+	for key, value := range hook.config.Headers {
+		req.Header.Set(key, value)
+	}
+	if hook.config.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(HeaderTimestamp, timestamp)
+		req.Header.Set(HeaderSignature, "sha256="+Sign(hook.config.Secret, timestamp, body))
+	}
+
+	resp, err := hook.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("Webhook返回状态码%d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// 渲染消息体
+func (hook *webhook) render(alert *Alert) ([]byte, error) {
+	if hook.template == nil {
+		return json.Marshal(alert)
+	}
+	var buf bytes.Buffer
+	if err := hook.template.Execute(&buf, alert); err != nil {
+		return nil, fmt.Errorf("渲染告警模板失败: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// 计算签名：HMAC-SHA256(secret, timestamp + "." + body)，接收方据此校验来源并拒绝重放
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (n *Notifier) deadLetter(hook *webhook, alert *Alert, attempts int, reason string) {
+	if n.deadLetters == nil {
+		return
+	}
+	letter := DeadLetter{
+		Webhook:   hook.config.Name,
+		Alert:     alert,
+		Attempts:  attempts,
+		LastError: reason,
+		FailedAt:  time.Now(),
+	}
+	if err := n.deadLetters.Push(context.Background(), letter); err != nil {
+		log.Printf("写入告警死信失败: %v", err)
+	}
+}
+
+// 等待队列中的告警投递完毕
+func (n *Notifier) Close(ctx context.Context) error {
+	drained := make(chan struct{})
+	go func() {
+		for _, hook := range n.webhooks {
+			for hook.pending.Load() > 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(50 * time.Millisecond):
+				}
+			}
+		}
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+	}
+	n.cancel()
+	n.wg.Wait()
+
+	// 未来得及投递的告警写入死信，避免丢失
+	for _, hook := range n.webhooks {
+		for len(hook.queue) > 0 {
+			n.deadLetter(hook, <-hook.queue, 0, "服务关闭前未投递")
+		}
+	}
+	return ctx.Err()
+}
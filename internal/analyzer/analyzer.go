@@ -7,7 +7,7 @@ import (
 	"strings"
 	"time"
 
-	"securefingerprint/internal/collector"
+	"securefingerprint/internal/clock"
 	"securefingerprint/internal/storage"
 )
 
@@ -57,6 +57,10 @@ type AccessPattern struct {
 	RequestRate      []RatePoint       `json:"request_rate"`
 	UserAgents       map[string]int    `json:"user_agents"`
 	Methods          map[string]int    `json:"methods"`
+	HeaderOrders     map[string]int    `json:"header_orders"`     // 原始请求头顺序签名
+	HeaderMismatches map[string]int    `json:"header_mismatches"` // 头顺序与UA不一致的签名
+	ClientEvidence   map[string]int    `json:"client_evidence"`   // 浏览器信号中的自动化工具证据
+	DeviceReplayIPs  map[string]int    `json:"device_replay_ips"` // 设备cookie重放请求的来源IP
 }
 
 type RatePoint struct {
@@ -65,17 +69,24 @@ type RatePoint struct {
 }
 
 type Analyzer struct {
-	config      AnalyzerConfig
-	redisClient *storage.RedisClient
+	config AnalyzerConfig
+	store  storage.Store
+	clock  clock.Clock
 }
 
-func NewAnalyzer(config AnalyzerConfig, redisClient *storage.RedisClient) *Analyzer {
+func NewAnalyzer(config AnalyzerConfig, store storage.Store) *Analyzer {
 	return &Analyzer{
-		config:      config,
-		redisClient: redisClient,
+		config: config,
+		store:  store,
+		clock:  clock.Real{},
 	}
 }
 
+// 替换时间来源（回放时使用模拟时钟）
+func (a *Analyzer) SetClock(c clock.Clock) {
+	a.clock = c
+}
+
 // 分析用户行为
 func (a *Analyzer) AnalyzeUser(fingerprint string, recentAccess []storage.AccessLog) (*AnalysisResult, error) {
 	if len(recentAccess) == 0 {
@@ -83,7 +94,7 @@ func (a *Analyzer) AnalyzeUser(fingerprint string, recentAccess []storage.Access
 			Fingerprint: fingerprint,
 			RiskLevel:   "low",
 			RiskScore:   0,
-			Timestamp:   time.Now(),
+			Timestamp:   a.clock.Now(),
 		}, nil
 	}
 
@@ -114,7 +125,7 @@ func (a *Analyzer) AnalyzeUser(fingerprint string, recentAccess []storage.Access
 			"unique_user_agents": len(pattern.UserAgents),
 			"analysis_window":   a.config.AnalysisWindow.String(),
 		},
-		Timestamp: time.Now(),
+		Timestamp: a.clock.Now(),
 	}
 
 	return result, nil
@@ -127,6 +138,10 @@ func (a *Analyzer) extractAccessPattern(logs []storage.AccessLog) *AccessPattern
 		TimeDistribution: make(map[int]int),
 		UserAgents:       make(map[string]int),
 		Methods:          make(map[string]int),
+		HeaderOrders:     make(map[string]int),
+		HeaderMismatches: make(map[string]int),
+		ClientEvidence:   make(map[string]int),
+		DeviceReplayIPs:  make(map[string]int),
 	}
 
 	// 按时间排序
@@ -150,6 +165,24 @@ func (a *Analyzer) extractAccessPattern(logs []storage.AccessLog) *AccessPattern
 		if log.Method != "" {
 			pattern.Methods[log.Method]++
 		}
+
+		// 请求头顺序统计
+		if log.HeaderOrder != "" {
+			pattern.HeaderOrders[log.HeaderOrder]++
+			if log.HeaderMismatch {
+				pattern.HeaderMismatches[log.HeaderOrder]++
+			}
+		}
+
+		// 浏览器信号证据统计
+		for _, evidence := range log.ClientEvidence {
+			pattern.ClientEvidence[evidence]++
+		}
+
+		// 设备cookie重放统计
+		if log.DeviceReplay {
+			pattern.DeviceReplayIPs[log.IP]++
+		}
 	}
 
 	// 计算请求频率
@@ -225,6 +258,21 @@ func (a *Analyzer) detectBehaviors(pattern *AccessPattern, logs []storage.Access
 		behaviors = append(behaviors, *behavior)
 	}
 
+	// 7. 检测请求头顺序与UA不一致（伪装浏览器）
+	if behavior := a.detectHeaderOrderMismatch(pattern); behavior != nil {
+		behaviors = append(behaviors, *behavior)
+	}
+
+	// 8. 检测浏览器端的自动化工具信号
+	if behavior := a.detectAutomationSignals(pattern); behavior != nil {
+		behaviors = append(behaviors, *behavior)
+	}
+
+	// 9. 检测设备cookie跨网段重放
+	if behavior := a.detectDeviceReplay(pattern); behavior != nil {
+		behaviors = append(behaviors, *behavior)
+	}
+
 	return behaviors
 }
 
@@ -254,7 +302,7 @@ func (a *Analyzer) detectFrequentRequests(pattern *AccessPattern) *DetectedBehav
 			Description: fmt.Sprintf("检测到频繁请求，峰值: %d请求/分钟", maxRate),
 			Evidence:    []string{fmt.Sprintf("峰值时间: %s", strings.Join(peakTimes, ", "))},
 			Confidence:  0.9,
-			Timestamp:   time.Now(),
+			Timestamp:   a.clock.Now(),
 		}
 	}
 
@@ -290,7 +338,7 @@ func (a *Analyzer) detectPathSpam(pattern *AccessPattern) *DetectedBehavior {
 			Description: fmt.Sprintf("检测到路径重复访问，路径多样性: %.2f%%", pathDiversity*100),
 			Evidence:    suspiciousPaths,
 			Confidence:  0.8,
-			Timestamp:   time.Now(),
+			Timestamp:   a.clock.Now(),
 		}
 	}
 
@@ -334,7 +382,7 @@ func (a *Analyzer) detectBotBehavior(pattern *AccessPattern, logs []storage.Acce
 			Description: "检测到疑似机器人行为",
 			Evidence:    botIndicators,
 			Confidence:  math.Min(confidence, 1.0),
-			Timestamp:   time.Now(),
+			Timestamp:   a.clock.Now(),
 		}
 	}
 
@@ -383,7 +431,7 @@ func (a *Analyzer) detectScanningBehavior(pattern *AccessPattern) *DetectedBehav
 				Description: fmt.Sprintf("检测到扫描行为，访问了%d个扫描相关路径", accessedScanPaths),
 				Evidence:    scanningIndicators,
 				Confidence:  scanningScore,
-				Timestamp:   time.Now(),
+				Timestamp:   a.clock.Now(),
 			}
 		}
 	}
@@ -417,7 +465,7 @@ func (a *Analyzer) detectAbnormalTimePattern(pattern *AccessPattern) *DetectedBe
 			Description: fmt.Sprintf("检测到异常时间访问模式，%.1f%%的访问发生在深夜", nightRatio*100),
 			Evidence:    []string{fmt.Sprintf("深夜(0-6点)访问次数: %d", nightRequests)},
 			Confidence:  0.6,
-			Timestamp:   time.Now(),
+			Timestamp:   a.clock.Now(),
 		}
 	}
 
@@ -433,7 +481,7 @@ func (a *Analyzer) detectUserAgentAnomalies(pattern *AccessPattern) *DetectedBeh
 			Description: "缺少User-Agent信息",
 			Evidence:    []string{"所有请求都没有User-Agent"},
 			Confidence:  0.9,
-			Timestamp:   time.Now(),
+			Timestamp:   a.clock.Now(),
 		}
 	}
 
@@ -456,13 +504,93 @@ func (a *Analyzer) detectUserAgentAnomalies(pattern *AccessPattern) *DetectedBeh
 			Description: "检测到可疑的User-Agent",
 			Evidence:    suspiciousUAs,
 			Confidence:  0.7,
-			Timestamp:   time.Now(),
+			Timestamp:   a.clock.Now(),
 		}
 	}
 
 	return nil
 }
 
+// 检测请求头顺序与UA声明的浏览器不一致
+func (a *Analyzer) detectHeaderOrderMismatch(pattern *AccessPattern) *DetectedBehavior {
+	total, mismatched := 0, 0
+	for _, count := range pattern.HeaderOrders {
+		total += count
+	}
+	var evidence []string
+	for order, count := range pattern.HeaderMismatches {
+		mismatched += count
+		evidence = append(evidence, fmt.Sprintf("头顺序签名: %s (%d次)", order, count))
+	}
+	if mismatched == 0 {
+		return nil
+	}
+	sort.Strings(evidence)
+
+	ratio := float64(mismatched) / float64(total)
+	severity := "warning"
+	if ratio >= 0.5 {
+		severity = "danger"
+	}
+
+	return &DetectedBehavior{
+		Type:        "header_order_mismatch",
+		Severity:    severity,
+		Description: fmt.Sprintf("请求头顺序与UA声明的浏览器不符，疑似伪装浏览器 (%d/%d次)", mismatched, total),
+		Evidence:    evidence,
+		Confidence:  0.85,
+		Timestamp:   a.clock.Now(),
+	}
+}
+
+// 检测浏览器信号中的自动化工具证据（webdriver、无头浏览器等）
+func (a *Analyzer) detectAutomationSignals(pattern *AccessPattern) *DetectedBehavior {
+	if len(pattern.ClientEvidence) == 0 {
+		return nil
+	}
+
+	var evidence []string
+	severity := "warning"
+	for item, count := range pattern.ClientEvidence {
+		evidence = append(evidence, fmt.Sprintf("%s (%d次)", item, count))
+		if strings.Contains(item, "webdriver") || strings.Contains(item, "Headless") {
+			severity = "danger"
+		}
+	}
+	sort.Strings(evidence)
+
+	return &DetectedBehavior{
+		Type:        "automation_signals",
+		Severity:    severity,
+		Description: "浏览器端信号显示为自动化工具或无头浏览器",
+		Evidence:    evidence,
+		Confidence:  0.9,
+		Timestamp:   a.clock.Now(),
+	}
+}
+
+// 检测设备cookie在过多网段中出现（同一cookie被复制给多个客户端或跳IP使用）
+func (a *Analyzer) detectDeviceReplay(pattern *AccessPattern) *DetectedBehavior {
+	if len(pattern.DeviceReplayIPs) == 0 {
+		return nil
+	}
+
+	var evidence []string
+	for ip, count := range pattern.DeviceReplayIPs {
+		evidence = append(evidence, fmt.Sprintf("%s (%d次)", ip, count))
+	}
+	sort.Strings(evidence)
+
+	return &DetectedBehavior{
+		Type:        "device_replay",
+		Severity:    "danger",
+		Description: "同一设备cookie在过多网段中出现，疑似被复制重放",
+		Evidence:    evidence,
+		Confidence:  0.8,
+		Timestamp:   a.clock.Now(),
+	}
+}
+
 // 辅助函数：判断是否为机器人User-Agent
 func (a *Analyzer) isBotUserAgent(ua string) bool {
 	if ua == "" {
@@ -610,6 +738,18 @@ func (a *Analyzer) generateRecommendations(behaviors []DetectedBehavior, riskSco
 		recommendations = append(recommendations, "建议限制对特定路径的访问频率")
 	}
 
+	if behaviorTypes["device_replay"] {
+		recommendations = append(recommendations, "建议封禁该设备身份，设备cookie疑似被多个客户端共用")
+	}
+
+	if behaviorTypes["automation_signals"] {
+		recommendations = append(recommendations, "建议封禁或验证该用户，浏览器端检测到自动化工具")
+	}
+
+	if behaviorTypes["header_order_mismatch"] {
+		recommendations = append(recommendations, "建议进行浏览器验证（如JS挑战），疑似HTTP库伪装浏览器")
+	}
+
 	// 基于风险分数生成建议
 	if riskScore >= 80 {
 		recommendations = append(recommendations, "建议立即封禁该用户")
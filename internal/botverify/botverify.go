@@ -0,0 +1,332 @@
+package botverify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"securefingerprint/internal/clock"
+	"securefingerprint/internal/limiter"
+)
+
+// 验证结果
+const (
+	StatusVerified   = "verified"   // IP属于爬虫公布的地址段，或反向解析后正向确认
+	StatusSpoofed    = "spoofed"    // 冒充爬虫的UA
+	StatusUnverified = "unverified" // DNS出错等原因无法判断
+)
+
+// 已验证爬虫的策略
+const (
+	PolicyAllow     = "allow"      // 直接放行，不参与分数和行为分析限制
+	PolicyRateLimit = "rate_limit" // 按爬虫名称限流
+)
+
+// 爬虫验证配置
+type Config struct {
+	Enabled    bool                       `yaml:"enabled"`
+	Timeout    time.Duration              `yaml:"timeout"`     // 单次验证的DNS查询超时
+	CacheTTL   time.Duration              `yaml:"cache_ttl"`   // 验证和冒充结果的缓存时间
+	ErrorTTL   time.Duration              `yaml:"error_ttl"`   // DNS出错时结果的缓存时间
+	MaxEntries int                        `yaml:"max_entries"` // 最多缓存的结果数
+	Policy     string                     `yaml:"policy"`      // 已验证爬虫的默认策略：allow/rate_limit
+	RateLimit  limiter.LocalLimiterConfig `yaml:"rate_limit"`  // rate_limit策略下每个爬虫的限流
+	Crawlers   []Crawler                  `yaml:"crawlers"`    // 为空时使用DefaultCrawlers
+}
+
+// 可验证的爬虫
+type Crawler struct {
+	Name       string   `yaml:"name"`
+	Agents     []string `yaml:"agents"`      // UA解析出的机器人名称，为空时同Name
+	Domains    []string `yaml:"domains"`     // 反向解析结果必须属于的域名
+	RangeFiles []string `yaml:"range_files"` // 公布的IP地址段文件（JSON prefixes格式或每行一个CIDR）
+	Policy     string   `yaml:"policy"`      // 为空时使用默认策略
+}
+
+// 默认爬虫验证配置
+var DefaultConfig = Config{
+	Timeout:    2 * time.Second,
+	CacheTTL:   24 * time.Hour,
+	ErrorTTL:   5 * time.Minute,
+	MaxEntries: 100000,
+	Policy:     PolicyAllow,
+	RateLimit: limiter.LocalLimiterConfig{
+		Window:      time.Minute,
+		MaxRequests: 600,
+	},
+}
+
+// 主要搜索引擎公布的反向解析域名
+var DefaultCrawlers = []Crawler{
+	{Name: "Googlebot", Agents: []string{"Googlebot", "Google-InspectionTool"}, Domains: []string{"googlebot.com", "google.com", "googleusercontent.com"}},
+	{Name: "bingbot", Domains: []string{"search.msn.com"}},
+	{Name: "Applebot", Domains: []string{"applebot.apple.com"}},
+	{Name: "Baiduspider", Domains: []string{"baidu.com", "baidu.jp"}},
+	{Name: "YandexBot", Domains: []string{"yandex.ru", "yandex.net", "yandex.com"}},
+	{Name: "Sogou", Agents: []string{"Sogou web spider"}, Domains: []string{"sogou.com"}},
+	{Name: "PetalBot", Domains: []string{"petalsearch.com", "aspiegel.com"}},
+}
+
+// DNS解析器，*net.Resolver满足该接口，测试时可替换为桩实现
+type Resolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// 爬虫验证结果
+type Result struct {
+	Crawler string `json:"crawler"`
+	Status  string `json:"status"`
+	Method  string `json:"method,omitempty"` // ip_range/dns
+	Host    string `json:"host,omitempty"`   // 正向确认的反向解析主机名
+	Policy  string `json:"policy,omitempty"` // 已验证时的策略
+}
+
+// 是否为已验证的爬虫
+func (r *Result) Verified() bool {
+	return r != nil && r.Status == StatusVerified
+}
+
+// 是否冒充爬虫
+func (r *Result) Spoofed() bool {
+	return r != nil && r.Status == StatusSpoofed
+}
+
+type crawler struct {
+	Crawler
+	ranges []*net.IPNet
+}
+
+type cacheEntry struct {
+	result  Result
+	expires time.Time
+}
+
+// 进行中的验证，同一爬虫和IP的并发请求共享一次DNS查询
+type pending struct {
+	done   chan struct{}
+	result Result
+}
+
+// 爬虫验证器
+type Verifier struct {
+	config   Config
+	resolver Resolver
+	crawlers map[string]*crawler // 按UA机器人名称索引
+	clock    clock.Clock
+
+	mu      sync.Mutex
+	cache   map[string]*cacheEntry
+	pending map[string]*pending
+}
+
+// 创建爬虫验证器，resolver为空时使用系统DNS
+func NewVerifier(config Config, resolver Resolver) (*Verifier, error) {
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultConfig.Timeout
+	}
+	if config.CacheTTL <= 0 {
+		config.CacheTTL = DefaultConfig.CacheTTL
+	}
+	if config.ErrorTTL <= 0 {
+		config.ErrorTTL = DefaultConfig.ErrorTTL
+	}
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = DefaultConfig.MaxEntries
+	}
+	if config.Policy == "" {
+		config.Policy = DefaultConfig.Policy
+	}
+	if config.RateLimit.MaxRequests <= 0 {
+		config.RateLimit = DefaultConfig.RateLimit
+	}
+	if config.Crawlers == nil {
+		config.Crawlers = DefaultCrawlers
+	}
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	v := &Verifier{
+		config:   config,
+		resolver: resolver,
+		crawlers: make(map[string]*crawler),
+		clock:    clock.Real{},
+		cache:    make(map[string]*cacheEntry),
+		pending:  make(map[string]*pending),
+	}
+	for _, c := range config.Crawlers {
+		if c.Name == "" {
+			return nil, fmt.Errorf("爬虫缺少名称")
+		}
+		if c.Policy == "" {
+			c.Policy = config.Policy
+		}
+		if err := validatePolicy(c.Policy); err != nil {
+			return nil, fmt.Errorf("爬虫%s: %v", c.Name, err)
+		}
+		if len(c.Domains) == 0 && len(c.RangeFiles) == 0 {
+			return nil, fmt.Errorf("爬虫%s未配置domains或range_files", c.Name)
+		}
+		entry := &crawler{Crawler: c}
+		for _, file := range c.RangeFiles {
+			ranges, err := LoadRanges(file)
+			if err != nil {
+				return nil, fmt.Errorf("爬虫%s: %v", c.Name, err)
+			}
+			entry.ranges = append(entry.ranges, ranges...)
+		}
+		agents := c.Agents
+		if len(agents) == 0 {
+			agents = []string{c.Name}
+		}
+		for _, agent := range agents {
+			v.crawlers[agent] = entry
+		}
+	}
+	return v, nil
+}
+
+func validatePolicy(policy string) error {
+	if policy != PolicyAllow && policy != PolicyRateLimit {
+		return fmt.Errorf("未知的爬虫策略: %s（可选: %s, %s）", policy, PolicyAllow, PolicyRateLimit)
+	}
+	return nil
+}
+
+// 替换时间来源
+func (v *Verifier) SetClock(c clock.Clock) {
+	v.clock = c
+}
+
+// 获取验证配置（已填充默认值）
+func (v *Verifier) Config() Config {
+	return v.config
+}
+
+// 验证自称为爬虫的请求，agent为UA解析出的机器人名称，不是可验证的爬虫时返回nil
+func (v *Verifier) Verify(agent, ip string) *Result {
+	c, ok := v.crawlers[agent]
+	if !ok {
+		return nil
+	}
+	key := c.Name + "|" + ip
+
+	v.mu.Lock()
+	if entry, ok := v.cache[key]; ok && v.clock.Now().Before(entry.expires) {
+		v.mu.Unlock()
+		result := entry.result
+		return &result
+	}
+	if p, ok := v.pending[key]; ok {
+		v.mu.Unlock()
+		<-p.done
+		result := p.result
+		return &result
+	}
+	p := &pending{done: make(chan struct{})}
+	v.pending[key] = p
+	v.mu.Unlock()
+
+	p.result = v.verify(c, ip)
+	ttl := v.config.CacheTTL
+	if p.result.Status == StatusUnverified {
+		ttl = v.config.ErrorTTL
+	}
+
+	v.mu.Lock()
+	delete(v.pending, key)
+	if len(v.cache) >= v.config.MaxEntries {
+		v.evictExpired()
+	}
+	v.cache[key] = &cacheEntry{result: p.result, expires: v.clock.Now().Add(ttl)}
+	v.mu.Unlock()
+	close(p.done)
+
+	result := p.result
+	return &result
+}
+
+// 清理过期结果，仍然超出容量时整体重置
+func (v *Verifier) evictExpired() {
+	now := v.clock.Now()
+	for key, entry := range v.cache {
+		if !now.Before(entry.expires) {
+			delete(v.cache, key)
+		}
+	}
+	if len(v.cache) >= v.config.MaxEntries {
+		v.cache = make(map[string]*cacheEntry)
+	}
+}
+
+// 先匹配公布的地址段，再反向解析并正向确认
+func (v *Verifier) verify(c *crawler, ip string) Result {
+	result := Result{Crawler: c.Name, Status: StatusSpoofed}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return result
+	}
+	for _, network := range c.ranges {
+		if network.Contains(addr) {
+			result.Status, result.Method, result.Policy = StatusVerified, "ip_range", c.Policy
+			return result
+		}
+	}
+	if len(c.Domains) == 0 {
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), v.config.Timeout)
+	defer cancel()
+
+	hosts, err := v.resolver.LookupAddr(ctx, ip)
+	if err != nil {
+		if !isNotFound(err) {
+			result.Status = StatusUnverified
+		}
+		return result
+	}
+	for _, host := range hosts {
+		host = strings.ToLower(strings.TrimSuffix(host, "."))
+		if !inDomains(host, c.Domains) {
+			continue
+		}
+		// 反向解析结果可由IP所有者任意设置，必须正向解析回同一IP
+		addrs, err := v.resolver.LookupHost(ctx, host)
+		if err != nil {
+			if !isNotFound(err) {
+				result.Status = StatusUnverified
+			}
+			continue
+		}
+		for _, a := range addrs {
+			if forward := net.ParseIP(a); forward != nil && forward.Equal(addr) {
+				result.Status, result.Method, result.Host, result.Policy = StatusVerified, "dns", host, c.Policy
+				return result
+			}
+		}
+	}
+	return result
+}
+
+// 主机名是否属于域名（不接受googlebot.com.evil.com或evilgooglebot.com）
+func inDomains(host string, domains []string) bool {
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.Trim(domain, "."))
+		if strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// 域名不存在（没有PTR记录等）是确定的否定结果，其他错误可能是暂时的
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
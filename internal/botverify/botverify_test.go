@@ -0,0 +1,301 @@
+package botverify
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"securefingerprint/internal/clock"
+)
+
+// 桩解析器，记录查询次数
+type stubResolver struct {
+	mu      sync.Mutex
+	ptr     map[string][]string
+	hosts   map[string][]string
+	err     error // 非空时所有查询返回该错误
+	lookups int
+}
+
+func (s *stubResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lookups++
+	if s.err != nil {
+		return nil, s.err
+	}
+	if names, ok := s.ptr[addr]; ok {
+		return names, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: addr, IsNotFound: true}
+}
+
+func (s *stubResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lookups++
+	if s.err != nil {
+		return nil, s.err
+	}
+	if addrs, ok := s.hosts[host]; ok {
+		return addrs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func newStubResolver() *stubResolver {
+	return &stubResolver{
+		ptr: map[string][]string{
+			"66.249.66.1":  {"crawl-66-249-66-1.googlebot.com."},
+			"203.0.113.5":  {"crawl-203-0-113-5.googlebot.com.evil.example."},
+			"203.0.113.6":  {"crawl-66-249-66-1.googlebot.com."},
+			"203.0.113.7":  {"evilgooglebot.com."},
+			"157.55.39.1":  {"msnbot-157-55-39-1.search.msn.com."},
+			"2001:db8::10": {"crawl.googlebot.com."},
+		},
+		hosts: map[string][]string{
+			"crawl-66-249-66-1.googlebot.com":   {"66.249.66.1"},
+			"msnbot-157-55-39-1.search.msn.com": {"157.55.39.1"},
+			"crawl.googlebot.com":               {"2001:db8:0:0::10"},
+		},
+	}
+}
+
+func newTestVerifier(t *testing.T, config Config, resolver Resolver) *Verifier {
+	t.Helper()
+	v, err := NewVerifier(config, resolver)
+	if err != nil {
+		t.Fatalf("NewVerifier: %v", err)
+	}
+	return v
+}
+
+func TestVerifyDNS(t *testing.T) {
+	tests := []struct {
+		name   string
+		agent  string
+		ip     string
+		status string
+		host   string
+	}{
+		{name: "反向解析后正向确认", agent: "Googlebot", ip: "66.249.66.1", status: StatusVerified, host: "crawl-66-249-66-1.googlebot.com"},
+		{name: "同一爬虫的其他UA名称", agent: "Google-InspectionTool", ip: "66.249.66.1", status: StatusVerified, host: "crawl-66-249-66-1.googlebot.com"},
+		{name: "IPv6正向确认", agent: "Googlebot", ip: "2001:db8::10", status: StatusVerified, host: "crawl.googlebot.com"},
+		{name: "Bing", agent: "bingbot", ip: "157.55.39.1", status: StatusVerified, host: "msnbot-157-55-39-1.search.msn.com"},
+		{name: "没有PTR记录", agent: "Googlebot", ip: "198.51.100.1", status: StatusSpoofed},
+		{name: "域名后缀伪造", agent: "Googlebot", ip: "203.0.113.5", status: StatusSpoofed},
+		{name: "相似域名", agent: "Googlebot", ip: "203.0.113.7", status: StatusSpoofed},
+		{name: "PTR指向真实主机但正向解析不一致", agent: "Googlebot", ip: "203.0.113.6", status: StatusSpoofed},
+		{name: "其他爬虫的主机名", agent: "Googlebot", ip: "157.55.39.1", status: StatusSpoofed},
+		{name: "无效IP", agent: "Googlebot", ip: "not-an-ip", status: StatusSpoofed},
+	}
+
+	v := newTestVerifier(t, DefaultConfig, newStubResolver())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := v.Verify(tt.agent, tt.ip)
+			if result == nil {
+				t.Fatal("结果为nil")
+			}
+			if result.Status != tt.status || result.Host != tt.host {
+				t.Errorf("Verify(%q, %q) = %+v, 期望状态%s主机%q", tt.agent, tt.ip, result, tt.status, tt.host)
+			}
+			if result.Verified() && (result.Method != "dns" || result.Policy != PolicyAllow) {
+				t.Errorf("已验证结果的方法或策略错误: %+v", result)
+			}
+		})
+	}
+}
+
+func TestVerifyUnknownAgent(t *testing.T) {
+	resolver := newStubResolver()
+	v := newTestVerifier(t, DefaultConfig, resolver)
+	if result := v.Verify("curl", "66.249.66.1"); result != nil {
+		t.Errorf("非爬虫UA应返回nil, 得到%+v", result)
+	}
+	if resolver.lookups != 0 {
+		t.Errorf("非爬虫UA不应查询DNS, 查询了%d次", resolver.lookups)
+	}
+}
+
+func TestVerifyCache(t *testing.T) {
+	resolver := newStubResolver()
+	v := newTestVerifier(t, DefaultConfig, resolver)
+	sim := clock.NewSimulated(time.Unix(1700000000, 0))
+	v.SetClock(sim)
+
+	v.Verify("Googlebot", "66.249.66.1")
+	lookups := resolver.lookups
+	v.Verify("Googlebot", "66.249.66.1")
+	v.Verify("Google-InspectionTool", "66.249.66.1")
+	if resolver.lookups != lookups {
+		t.Errorf("缓存期内不应重复查询, 查询次数%d -> %d", lookups, resolver.lookups)
+	}
+
+	sim.Advance(DefaultConfig.CacheTTL)
+	v.Verify("Googlebot", "66.249.66.1")
+	if resolver.lookups == lookups {
+		t.Error("缓存过期后应重新查询")
+	}
+}
+
+func TestVerifyTemporaryError(t *testing.T) {
+	resolver := newStubResolver()
+	resolver.err = &net.DNSError{Err: "i/o timeout", Name: "66.249.66.1", IsTimeout: true}
+	v := newTestVerifier(t, DefaultConfig, resolver)
+	sim := clock.NewSimulated(time.Unix(1700000000, 0))
+	v.SetClock(sim)
+
+	if result := v.Verify("Googlebot", "66.249.66.1"); result.Status != StatusUnverified {
+		t.Fatalf("DNS超时应为%s, 得到%s", StatusUnverified, result.Status)
+	}
+
+	// 出错结果只缓存ErrorTTL，恢复后重新验证
+	resolver.err = nil
+	sim.Advance(DefaultConfig.ErrorTTL / 2)
+	if result := v.Verify("Googlebot", "66.249.66.1"); result.Status != StatusUnverified {
+		t.Errorf("ErrorTTL内应返回缓存结果, 得到%s", result.Status)
+	}
+	sim.Advance(DefaultConfig.ErrorTTL)
+	if result := v.Verify("Googlebot", "66.249.66.1"); result.Status != StatusVerified {
+		t.Errorf("ErrorTTL后应重新验证, 得到%s", result.Status)
+	}
+}
+
+func TestVerifyConcurrent(t *testing.T) {
+	block := make(chan struct{})
+	resolver := &blockingResolver{stubResolver: newStubResolver(), block: block}
+	v := newTestVerifier(t, DefaultConfig, resolver)
+
+	var wg sync.WaitGroup
+	results := make([]*Result, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = v.Verify("Googlebot", "66.249.66.1")
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(block)
+	wg.Wait()
+
+	for _, result := range results {
+		if !result.Verified() {
+			t.Errorf("并发验证结果错误: %+v", result)
+		}
+	}
+	if resolver.lookups != 2 {
+		t.Errorf("并发请求应共享一次验证（反向+正向2次查询）, 实际%d次", resolver.lookups)
+	}
+}
+
+// 第一次查询阻塞到block关闭
+type blockingResolver struct {
+	*stubResolver
+	block chan struct{}
+}
+
+func (b *blockingResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	<-b.block
+	return b.stubResolver.LookupAddr(ctx, addr)
+}
+
+func TestVerifyRanges(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "duckduckbot.txt")
+	if err := os.WriteFile(file, []byte("# DuckDuckBot\n20.191.45.212\n40.88.21.0/24 # 注释\n\n2001:db8:1::/48\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	resolver := newStubResolver()
+	config := DefaultConfig
+	config.Crawlers = []Crawler{
+		{Name: "DuckDuckBot", RangeFiles: []string{file}, Policy: PolicyRateLimit},
+		{Name: "Googlebot", Domains: []string{"googlebot.com"}},
+	}
+	v := newTestVerifier(t, config, resolver)
+
+	tests := []struct {
+		agent  string
+		ip     string
+		status string
+	}{
+		{"DuckDuckBot", "20.191.45.212", StatusVerified},
+		{"DuckDuckBot", "40.88.21.77", StatusVerified},
+		{"DuckDuckBot", "2001:db8:1:2::1", StatusVerified},
+		{"DuckDuckBot", "40.88.22.1", StatusSpoofed},
+		{"Googlebot", "66.249.66.1", StatusVerified},
+	}
+	for _, tt := range tests {
+		result := v.Verify(tt.agent, tt.ip)
+		if result.Status != tt.status {
+			t.Errorf("Verify(%q, %q) = %s, 期望%s", tt.agent, tt.ip, result.Status, tt.status)
+		}
+		if tt.agent == "DuckDuckBot" && result.Verified() && (result.Method != "ip_range" || result.Policy != PolicyRateLimit) {
+			t.Errorf("地址段验证的方法或策略错误: %+v", result)
+		}
+	}
+	if resolver.lookups != 2 {
+		t.Errorf("只有Googlebot需要查询DNS, 实际查询%d次", resolver.lookups)
+	}
+}
+
+func TestParseRangesJSON(t *testing.T) {
+	data := []byte(`{
+  "creationTime": "2024-01-01T00:00:00.000000",
+  "prefixes": [
+    {"ipv6Prefix": "2001:4860:4801:10::/64"},
+    {"ipv4Prefix": "66.249.64.0/27"}
+  ]
+}`)
+	ranges, err := ParseRanges(data)
+	if err != nil {
+		t.Fatalf("ParseRanges: %v", err)
+	}
+	if len(ranges) != 2 {
+		t.Fatalf("期望2个地址段, 得到%d", len(ranges))
+	}
+	if !ranges[1].Contains(net.ParseIP("66.249.64.31")) || ranges[1].Contains(net.ParseIP("66.249.64.32")) {
+		t.Errorf("IPv4地址段解析错误: %v", ranges[1])
+	}
+
+	if _, err := ParseRanges([]byte("66.249.64.0/33\n")); err == nil {
+		t.Error("无效地址段应返回错误")
+	}
+}
+
+func TestNewVerifierValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		crawlers []Crawler
+	}{
+		{"缺少名称", []Crawler{{Domains: []string{"example.com"}}}},
+		{"缺少验证方式", []Crawler{{Name: "ExampleBot"}}},
+		{"未知策略", []Crawler{{Name: "ExampleBot", Domains: []string{"example.com"}, Policy: "block"}}},
+		{"地址段文件不存在", []Crawler{{Name: "ExampleBot", RangeFiles: []string{"/nonexistent/ranges.json"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig
+			config.Crawlers = tt.crawlers
+			if _, err := NewVerifier(config, nil); err == nil {
+				t.Error("期望返回错误")
+			}
+		})
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	if isNotFound(errors.New("no such host")) {
+		t.Error("非DNSError不应视为不存在")
+	}
+	if !isNotFound(&net.DNSError{IsNotFound: true}) {
+		t.Error("IsNotFound的DNSError应视为不存在")
+	}
+}
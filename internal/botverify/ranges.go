@@ -0,0 +1,92 @@
+package botverify
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// 搜索引擎公布的地址段文件格式（Google、Bing等使用）
+type publishedRanges struct {
+	Prefixes []struct {
+		IPv4Prefix string `json:"ipv4Prefix"`
+		IPv6Prefix string `json:"ipv6Prefix"`
+	} `json:"prefixes"`
+}
+
+// 加载地址段文件，支持JSON prefixes格式和每行一个CIDR或IP的文本格式（#开头为注释）
+func LoadRanges(file string) ([]*net.IPNet, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("读取地址段文件失败: %v", err)
+	}
+	ranges, err := ParseRanges(data)
+	if err != nil {
+		return nil, fmt.Errorf("解析地址段文件%s失败: %v", file, err)
+	}
+	return ranges, nil
+}
+
+// 解析地址段数据
+func ParseRanges(data []byte) ([]*net.IPNet, error) {
+	var prefixes []string
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var published publishedRanges
+		if err := json.Unmarshal(trimmed, &published); err != nil {
+			return nil, err
+		}
+		for _, p := range published.Prefixes {
+			if p.IPv4Prefix != "" {
+				prefixes = append(prefixes, p.IPv4Prefix)
+			}
+			if p.IPv6Prefix != "" {
+				prefixes = append(prefixes, p.IPv6Prefix)
+			}
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			line, _, _ := strings.Cut(scanner.Text(), "#")
+			if line = strings.TrimSpace(line); line != "" {
+				prefixes = append(prefixes, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	ranges := make([]*net.IPNet, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		network, err := parseRange(prefix)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, network)
+	}
+	return ranges, nil
+}
+
+// 解析CIDR，单个IP视为/32或/128
+func parseRange(prefix string) (*net.IPNet, error) {
+	if !strings.Contains(prefix, "/") {
+		ip := net.ParseIP(prefix)
+		if ip == nil {
+			return nil, fmt.Errorf("无效的地址: %s", prefix)
+		}
+		bits := 128
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, network, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("无效的地址段: %s", prefix)
+	}
+	return network, nil
+}
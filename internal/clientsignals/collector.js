@@ -0,0 +1,111 @@
+// 浏览器信号采集脚本，由服务端填入令牌和提交地址
+(function () {
+  "use strict";
+  var token = __TOKEN__;
+  var beacon = __BEACON__;
+  if (__FRESH__) {
+    return;
+  }
+
+  // FNV-1a 32位哈希，只提交摘要，不上传画布原始数据
+  function hash(text) {
+    var h = 0x811c9dc5;
+    for (var i = 0; i < text.length; i++) {
+      h ^= text.charCodeAt(i);
+      h = (h + ((h << 1) + (h << 4) + (h << 7) + (h << 8) + (h << 24))) >>> 0;
+    }
+    return ("0000000" + h.toString(16)).slice(-8);
+  }
+
+  function canvasHash() {
+    try {
+      var canvas = document.createElement("canvas");
+      canvas.width = 240;
+      canvas.height = 60;
+      var ctx = canvas.getContext("2d");
+      ctx.textBaseline = "top";
+      ctx.font = "14px 'Arial'";
+      ctx.fillStyle = "#f60";
+      ctx.fillRect(100, 1, 62, 20);
+      ctx.fillStyle = "#069";
+      ctx.fillText("fw-signals \u{1F600} 指纹", 2, 15);
+      ctx.fillStyle = "rgba(102, 204, 0, 0.7)";
+      ctx.fillText("fw-signals \u{1F600} 指纹", 4, 17);
+      return hash(canvas.toDataURL());
+    } catch (e) {
+      return "";
+    }
+  }
+
+  function webgl() {
+    var result = { hash: "", vendor: "", renderer: "" };
+    try {
+      var canvas = document.createElement("canvas");
+      var gl = canvas.getContext("webgl") || canvas.getContext("experimental-webgl");
+      if (!gl) {
+        return result;
+      }
+      var info = gl.getExtension("WEBGL_debug_renderer_info");
+      if (info) {
+        result.vendor = String(gl.getParameter(info.UNMASKED_VENDOR_WEBGL));
+        result.renderer = String(gl.getParameter(info.UNMASKED_RENDERER_WEBGL));
+      }
+      var params = [
+        gl.getParameter(gl.MAX_TEXTURE_SIZE),
+        gl.getParameter(gl.MAX_VERTEX_ATTRIBS),
+        gl.getParameter(gl.MAX_VIEWPORT_DIMS),
+        gl.getParameter(gl.SHADING_LANGUAGE_VERSION),
+        (gl.getSupportedExtensions() || []).join(",")
+      ];
+      result.hash = hash(params.join("|"));
+    } catch (e) {}
+    return result;
+  }
+
+  var nav = window.navigator || {};
+  var gl = webgl();
+  var timezone = "";
+  try {
+    timezone = Intl.DateTimeFormat().resolvedOptions().timeZone || "";
+  } catch (e) {}
+
+  var signals = {
+    screen: {
+      width: screen.width || 0,
+      height: screen.height || 0,
+      color_depth: screen.colorDepth || 0,
+      pixel_ratio: window.devicePixelRatio || 0
+    },
+    timezone: timezone,
+    timezone_offset: new Date().getTimezoneOffset(),
+    languages: nav.languages ? Array.prototype.slice.call(nav.languages) : [],
+    platform: nav.platform || "",
+    hardware_concurrency: nav.hardwareConcurrency || 0,
+    device_memory: nav.deviceMemory || 0,
+    touch_points: nav.maxTouchPoints || 0,
+    webdriver: nav.webdriver === true,
+    plugins: nav.plugins ? nav.plugins.length : 0,
+    cookie_enabled: nav.cookieEnabled === true,
+    canvas_hash: canvasHash(),
+    webgl_hash: gl.hash,
+    webgl_vendor: gl.vendor,
+    webgl_renderer: gl.renderer,
+    has_chrome_object: typeof window.chrome === "object"
+  };
+
+  var body = JSON.stringify({ token: token, signals: signals });
+  if (window.fetch) {
+    fetch(beacon, {
+      method: "POST",
+      body: body,
+      credentials: "same-origin",
+      keepalive: true,
+      headers: { "Content-Type": "application/json" }
+    }).catch(function () {});
+  } else {
+    var xhr = new XMLHttpRequest();
+    xhr.open("POST", beacon, true);
+    xhr.setRequestHeader("Content-Type", "application/json");
+    xhr.send(body);
+  }
+})();
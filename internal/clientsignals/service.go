@@ -0,0 +1,202 @@
+package clientsignals
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//go:embed collector.js
+var collectorScript string
+
+// 浏览器信号采集配置
+type Config struct {
+	Enabled           bool          `yaml:"enabled"`
+	Secret            string        `yaml:"secret"` // 签名密钥，为空时每次启动随机生成（重启后已有cookie失效）
+	CookieName        string        `yaml:"cookie_name"`
+	CookieMaxAge      time.Duration `yaml:"cookie_max_age"`     // 信号cookie有效期，过期后脚本重新采集
+	TokenTTL          time.Duration `yaml:"token_ttl"`          // 脚本令牌有效期
+	ScriptPath        string        `yaml:"script_path"`        // 采集脚本地址，页面通过<script src>引入
+	BeaconPath        string        `yaml:"beacon_path"`        // 信号提交地址
+	FingerprintWeight float64       `yaml:"fingerprint_weight"` // 信号摘要在用户指纹中的权重，0表示不参与
+}
+
+// 默认浏览器信号采集配置
+var DefaultConfig = Config{
+	Enabled:      false,
+	CookieName:   "fw_cs",
+	CookieMaxAge: 24 * time.Hour,
+	TokenTTL:     5 * time.Minute,
+	ScriptPath:   "/_fw/signals.js",
+	BeaconPath:   "/_fw/beacon",
+}
+
+// 信号提交请求体上限
+const maxBeaconBytes = 16 << 10
+
+// 请求携带的浏览器信号
+type Result struct {
+	Hash     string    `json:"hash"`               // 设备信号摘要
+	Evidence []string  `json:"evidence,omitempty"` // 自动化工具证据
+	Bound    bool      `json:"bound"`              // cookie与当前网络和UA一致，为false时cookie来自其他客户端，不予采用
+	IssuedAt time.Time `json:"issued_at"`
+}
+
+// 浏览器信号服务：提供采集脚本、接收信号并签发cookie、从请求中读取cookie
+type Service struct {
+	config   Config
+	signer   *signer
+	clientIP func(r *http.Request) string
+	now      func() time.Time
+}
+
+// 创建服务，clientIP用于获取经可信代理解析后的客户端IP
+func NewService(config Config, clientIP func(r *http.Request) string) (*Service, error) {
+	if config.CookieName == "" {
+		config.CookieName = DefaultConfig.CookieName
+	}
+	if config.CookieMaxAge <= 0 {
+		config.CookieMaxAge = DefaultConfig.CookieMaxAge
+	}
+	if config.TokenTTL <= 0 {
+		config.TokenTTL = DefaultConfig.TokenTTL
+	}
+	if config.ScriptPath == "" {
+		config.ScriptPath = DefaultConfig.ScriptPath
+	}
+	if config.BeaconPath == "" {
+		config.BeaconPath = DefaultConfig.BeaconPath
+	}
+	if config.Secret == "" {
+		log.Printf("浏览器信号未配置签名密钥，使用随机密钥，重启后已签发的cookie失效")
+	}
+
+	signer, err := newSigner(config.Secret)
+	if err != nil {
+		return nil, err
+	}
+	return &Service{config: config, signer: signer, clientIP: clientIP, now: time.Now}, nil
+}
+
+// 当前配置（已填充默认值）
+func (s *Service) Config() Config {
+	return s.config
+}
+
+// 返回采集脚本，脚本内嵌绑定到当前客户端的令牌
+func (s *Service) ServeScript(w http.ResponseWriter, r *http.Request) {
+	binding := s.signer.binding(s.clientIP(r), r.UserAgent())
+	token, err := s.signer.issueToken(binding, s.now())
+	if err != nil {
+		http.Error(w, "生成令牌失败", http.StatusInternalServerError)
+		return
+	}
+
+	// 已有有效cookie时脚本不再提交
+	fresh := s.hasBoundCookie(r)
+	tokenJSON, _ := json.Marshal(token)
+	beaconJSON, _ := json.Marshal(s.config.BeaconPath)
+	script := strings.NewReplacer(
+		"__TOKEN__", string(tokenJSON),
+		"__BEACON__", string(beaconJSON),
+		"__FRESH__", fmt.Sprint(fresh),
+	).Replace(collectorScript)
+
+	w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	io.WriteString(w, script)
+}
+
+// 信号提交请求
+type beacon struct {
+	Token   string  `json:"token"`
+	Signals Signals `json:"signals"`
+}
+
+// 接收脚本提交的信号，校验令牌后签发信号cookie
+func (s *Service) ServeBeacon(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "仅支持POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body beacon
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxBeaconBytes)).Decode(&body); err != nil {
+		http.Error(w, "无效的信号数据", http.StatusBadRequest)
+		return
+	}
+
+	var t token
+	if err := s.signer.Open(purposeToken, body.Token, &t); err != nil {
+		http.Error(w, "无效的令牌", http.StatusForbidden)
+		return
+	}
+	now := s.now()
+	if now.Sub(time.Unix(t.Issued, 0)) > s.config.TokenTTL {
+		http.Error(w, "令牌已过期", http.StatusForbidden)
+		return
+	}
+	// 令牌只能由获取脚本的客户端使用
+	binding := s.signer.binding(s.clientIP(r), r.UserAgent())
+	if t.Binding != binding {
+		http.Error(w, "令牌与客户端不匹配", http.StatusForbidden)
+		return
+	}
+
+	value, err := s.signer.Seal(purposeCookie, claims{
+		Binding:  binding,
+		Hash:     body.Signals.Hash(),
+		Evidence: body.Signals.Evidence(r.UserAgent()),
+		Issued:   now.Unix(),
+	})
+	if err != nil {
+		http.Error(w, "签发cookie失败", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.config.CookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   int(s.config.CookieMaxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// 读取请求中的信号cookie，没有或签名、有效期不符时返回nil。
+// ip为经可信代理解析后的客户端IP
+func (s *Service) FromRequest(r *http.Request, ip string) *Result {
+	cookie, err := r.Cookie(s.config.CookieName)
+	if err != nil {
+		return nil
+	}
+	var c claims
+	if err := s.signer.Open(purposeCookie, cookie.Value, &c); err != nil {
+		return nil
+	}
+	issued := time.Unix(c.Issued, 0)
+	if s.now().Sub(issued) > s.config.CookieMaxAge {
+		return nil
+	}
+	return &Result{
+		Hash:     c.Hash,
+		Evidence: c.Evidence,
+		Bound:    c.Binding == s.signer.binding(ip, r.UserAgent()),
+		IssuedAt: issued,
+	}
+}
+
+// 请求是否带有与当前客户端绑定的有效cookie
+func (s *Service) hasBoundCookie(r *http.Request) bool {
+	result := s.FromRequest(r, s.clientIP(r))
+	return result != nil && result.Bound
+}
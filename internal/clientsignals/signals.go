@@ -0,0 +1,90 @@
+package clientsignals
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// 浏览器端采集的信号
+type Signals struct {
+	Screen              Screen   `json:"screen"`
+	Timezone            string   `json:"timezone"`
+	TimezoneOffset      int      `json:"timezone_offset"` // 分钟，与Date.getTimezoneOffset一致
+	Languages           []string `json:"languages"`
+	Platform            string   `json:"platform"`
+	HardwareConcurrency int      `json:"hardware_concurrency"`
+	DeviceMemory        float64  `json:"device_memory"`
+	TouchPoints         int      `json:"touch_points"`
+	Webdriver           bool     `json:"webdriver"`
+	Plugins             int      `json:"plugins"`
+	CookieEnabled       bool     `json:"cookie_enabled"`
+	CanvasHash          string   `json:"canvas_hash"`
+	WebGLHash           string   `json:"webgl_hash"`
+	WebGLVendor         string   `json:"webgl_vendor"`
+	WebGLRenderer       string   `json:"webgl_renderer"`
+	HasChromeObject     bool     `json:"has_chrome_object"` // window.chrome是否存在
+}
+
+// 屏幕信息
+type Screen struct {
+	Width      int     `json:"width"`
+	Height     int     `json:"height"`
+	ColorDepth int     `json:"color_depth"`
+	PixelRatio float64 `json:"pixel_ratio"`
+}
+
+// 设备相关信号的摘要，同一浏览器多次采集结果一致，同网段同浏览器的不同设备通常不同
+func (s *Signals) Hash() string {
+	parts := []string{
+		fmt.Sprintf("screen:%dx%dx%d@%.2f", s.Screen.Width, s.Screen.Height, s.Screen.ColorDepth, s.Screen.PixelRatio),
+		"tz:" + s.Timezone,
+		"lang:" + strings.Join(s.Languages, ","),
+		"platform:" + s.Platform,
+		fmt.Sprintf("hw:%d/%.1f/%d", s.HardwareConcurrency, s.DeviceMemory, s.TouchPoints),
+		"canvas:" + s.CanvasHash,
+		"webgl:" + s.WebGLHash + "/" + s.WebGLVendor + "/" + s.WebGLRenderer,
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "|")))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// 自动化工具和无头浏览器的证据
+func (s *Signals) Evidence(userAgent string) []string {
+	var evidence []string
+	ua := strings.ToLower(userAgent)
+
+	if s.Webdriver {
+		evidence = append(evidence, "navigator.webdriver为true")
+	}
+	if strings.Contains(ua, "headless") {
+		evidence = append(evidence, "UA包含Headless")
+	}
+	if len(s.Languages) == 0 {
+		evidence = append(evidence, "navigator.languages为空")
+	}
+	if s.Screen.Width == 0 || s.Screen.Height == 0 {
+		evidence = append(evidence, "屏幕尺寸为0")
+	}
+	if s.HardwareConcurrency == 0 {
+		evidence = append(evidence, "hardwareConcurrency为0")
+	}
+	// 无GPU环境下的软件渲染器
+	renderer := strings.ToLower(s.WebGLRenderer)
+	if strings.Contains(renderer, "swiftshader") || strings.Contains(renderer, "llvmpipe") {
+		evidence = append(evidence, fmt.Sprintf("软件WebGL渲染器: %s", s.WebGLRenderer))
+	}
+	if s.CanvasHash == "" {
+		evidence = append(evidence, "Canvas不可用")
+	}
+	// Chromium系浏览器都有window.chrome对象
+	if strings.Contains(ua, "chrome/") && !strings.Contains(ua, "crios/") && !s.HasChromeObject {
+		evidence = append(evidence, "Chrome UA缺少window.chrome")
+	}
+	// 移动设备都支持触摸
+	if (strings.Contains(ua, "iphone") || strings.Contains(ua, "android")) && s.TouchPoints == 0 {
+		evidence = append(evidence, "移动设备UA但不支持触摸")
+	}
+	return evidence
+}
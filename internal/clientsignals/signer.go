@@ -0,0 +1,57 @@
+package clientsignals
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"securefingerprint/internal/cookiesign"
+)
+
+// 签名用途，令牌和cookie的签名互不通用
+const (
+	purposeToken  = "token"
+	purposeCookie = "cookie"
+)
+
+// 脚本令牌和信号cookie的签名
+type signer struct {
+	*cookiesign.Signer
+}
+
+func newSigner(secret string) (*signer, error) {
+	s, err := cookiesign.NewSigner(secret)
+	if err != nil {
+		return nil, err
+	}
+	return &signer{Signer: s}, nil
+}
+
+// 客户端绑定标识：IPv4的/24或IPv6的/64网段加完整UA。
+// 同一网络的同一浏览器才能使用签发的令牌和cookie，复制到其他客户端后失效
+func (s *signer) binding(ip, userAgent string) string {
+	return hex.EncodeToString(s.MAC("bind|" + cookiesign.Network(ip) + "|" + userAgent))[:16]
+}
+
+// 脚本令牌，签发后在有效期内用于提交信号
+type token struct {
+	Binding string `json:"b"`
+	Issued  int64  `json:"t"`
+	Nonce   string `json:"n"`
+}
+
+// 信号cookie内容
+type claims struct {
+	Binding  string   `json:"b"`
+	Hash     string   `json:"h"`
+	Evidence []string `json:"e,omitempty"`
+	Issued   int64    `json:"t"`
+}
+
+func (s *signer) issueToken(binding string, now time.Time) (string, error) {
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return s.Seal(purposeToken, token{Binding: binding, Issued: now.Unix(), Nonce: hex.EncodeToString(nonce)})
+}
@@ -0,0 +1,51 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// 时间来源，便于回放时使用模拟时钟
+type Clock interface {
+	Now() time.Time
+}
+
+// 系统时钟
+type Real struct{}
+
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+// 模拟时钟，只在Set/Advance时前进
+type Simulated struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// 创建从指定时间开始的模拟时钟
+func NewSimulated(start time.Time) *Simulated {
+	return &Simulated{now: start}
+}
+
+func (s *Simulated) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.now
+}
+
+// 设置当前时间，早于当前时间时忽略，保证时间单调
+func (s *Simulated) Set(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t.After(s.now) {
+		s.now = t
+	}
+}
+
+// 前进指定时长
+func (s *Simulated) Advance(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = s.now.Add(d)
+}
@@ -0,0 +1,266 @@
+package cluster
+
+import (
+	"fmt"
+	"time"
+
+	"securefingerprint/internal/fingerprint"
+)
+
+// 身份聚类配置
+type Config struct {
+	Enabled         bool          `yaml:"enabled"`
+	Threshold       float64       `yaml:"threshold"`        // 加权相似度达到该值时归入同一身份
+	MaxCandidates   int           `yaml:"max_candidates"`   // 每个索引组件最多比较的候选指纹数
+	TTL             time.Duration `yaml:"ttl"`              // 组件向量和聚类关系的保留时间
+	IndexComponents []string      `yaml:"index_components"` // 用于查找候选指纹的组件，应选择区分度高的组件
+	MatchComponents []string      `yaml:"match_components"` // 候选指纹至少有一个组件与之一致时才参与聚类，应选择能区分同网段同浏览器不同设备的组件
+	PropagateBans   bool          `yaml:"propagate_bans"`   // 封禁扩散到同一身份的所有指纹，包括之后加入的指纹
+}
+
+// 默认身份聚类配置
+var DefaultConfig = Config{
+	Enabled:         false,
+	Threshold:       0.8,
+	MaxCandidates:   50,
+	TTL:             24 * time.Hour,
+	IndexComponents: []string{"ip", "client"},
+	MatchComponents: []string{"client"},
+	PropagateBans:   false,
+}
+
+// 聚类状态存储，RedisClient为生产实现
+type Store interface {
+	SaveComponents(fingerprint string, components map[string]string, ttl time.Duration) error
+	GetComponents(fingerprint string) (map[string]string, error)
+	IndexComponent(component, value, fingerprint string, ttl time.Duration) error
+	FindByComponent(component, value string, limit int) ([]string, error)
+	GetCluster(fingerprint string) (string, error)
+	JoinCluster(fingerprint, cluster string, ttl time.Duration) error
+	ClusterMembers(cluster string) ([]string, error)
+	BanCluster(cluster string, duration time.Duration) error
+	ClusterBanTTL(cluster string) (time.Duration, error)
+	UnbanCluster(cluster string) error
+	BanUser(fingerprint string, duration time.Duration) error
+	UnbanUser(fingerprint string) error
+	IsUserBanned(fingerprint string) (bool, time.Duration, error)
+}
+
+// 身份中的指纹
+type Member struct {
+	Fingerprint  string  `json:"fingerprint"`
+	Similarity   float64 `json:"similarity"` // 与查询指纹的相似度
+	Banned       bool    `json:"banned"`
+	BanRemaining string  `json:"ban_remaining,omitempty"`
+}
+
+// 身份：相似指纹的聚类，以最先出现的指纹作为ID
+type Identity struct {
+	ID      string   `json:"id"`
+	Members []Member `json:"members"`
+}
+
+// 身份聚类器：保存指纹的组件向量，把相似指纹归入同一身份
+type Clusterer struct {
+	config    Config
+	generator *fingerprint.Generator
+	store     Store
+}
+
+// 创建身份聚类器，相似度使用generator的权重计算
+func NewClusterer(config Config, generator *fingerprint.Generator, store Store) *Clusterer {
+	if config.Threshold <= 0 || config.Threshold > 1 {
+		config.Threshold = DefaultConfig.Threshold
+	}
+	if config.MaxCandidates <= 0 {
+		config.MaxCandidates = DefaultConfig.MaxCandidates
+	}
+	if config.TTL <= 0 {
+		config.TTL = DefaultConfig.TTL
+	}
+	if len(config.IndexComponents) == 0 {
+		config.IndexComponents = DefaultConfig.IndexComponents
+	}
+	if len(config.MatchComponents) == 0 {
+		config.MatchComponents = DefaultConfig.MatchComponents
+	}
+	return &Clusterer{config: config, generator: generator, store: store}
+}
+
+// 当前配置（已填充默认值）
+func (c *Clusterer) Config() Config {
+	return c.config
+}
+
+// 记录指纹并返回所属身份ID。已聚类的指纹只查询一次存储；
+// 新指纹与候选指纹比较，归入相似度最高且达到阈值的身份，否则自成一个身份。
+// IP网段和UA相同的不同用户相似度也很高，候选指纹还需有一个match_components组件一致。
+// 开启封禁扩散时，加入已封禁身份的新指纹按身份剩余时间封禁
+func (c *Clusterer) Observe(fp string, vector fingerprint.ComponentVector) (string, error) {
+	cluster, err := c.store.GetCluster(fp)
+	if err != nil {
+		return "", fmt.Errorf("查询身份失败: %v", err)
+	}
+	if cluster != "" {
+		return cluster, nil
+	}
+
+	if err := c.store.SaveComponents(fp, vector, c.config.TTL); err != nil {
+		return "", fmt.Errorf("保存指纹组件失败: %v", err)
+	}
+
+	cluster = fp
+	best := 0.0
+	for _, candidate := range c.candidates(fp, vector) {
+		components, err := c.store.GetComponents(candidate)
+		if err != nil || len(components) == 0 || !c.distinctMatch(vector, components) {
+			continue
+		}
+		similarity := c.generator.CalculateSimilarity(vector, components)
+		if similarity < c.config.Threshold || similarity <= best {
+			continue
+		}
+		candidateCluster, err := c.store.GetCluster(candidate)
+		if err != nil || candidateCluster == "" {
+			continue
+		}
+		cluster, best = candidateCluster, similarity
+	}
+
+	if err := c.store.JoinCluster(fp, cluster, c.config.TTL); err != nil {
+		return "", fmt.Errorf("加入身份失败: %v", err)
+	}
+	for _, component := range c.config.IndexComponents {
+		if value := vector[component]; value != "" {
+			if err := c.store.IndexComponent(component, value, fp, c.config.TTL); err != nil {
+				return cluster, fmt.Errorf("索引指纹组件失败: %v", err)
+			}
+		}
+	}
+
+	if c.config.PropagateBans && cluster != fp {
+		ttl, err := c.store.ClusterBanTTL(cluster)
+		if err != nil {
+			return cluster, fmt.Errorf("查询身份封禁失败: %v", err)
+		}
+		if ttl > 0 {
+			if err := c.store.BanUser(fp, ttl); err != nil {
+				return cluster, fmt.Errorf("扩散封禁失败: %v", err)
+			}
+		}
+	}
+	return cluster, nil
+}
+
+// 是否有一个区分设备的组件一致，缺失的组件不算一致
+func (c *Clusterer) distinctMatch(a, b fingerprint.ComponentVector) bool {
+	for _, component := range c.config.MatchComponents {
+		if value := a[component]; value != "" && value == b[component] {
+			return true
+		}
+	}
+	return false
+}
+
+// 通过索引组件查找候选指纹（去重，不含自身）
+func (c *Clusterer) candidates(fp string, vector fingerprint.ComponentVector) []string {
+	seen := map[string]bool{fp: true}
+	var result []string
+	for _, component := range c.config.IndexComponents {
+		value := vector[component]
+		if value == "" {
+			continue
+		}
+		found, err := c.store.FindByComponent(component, value, c.config.MaxCandidates)
+		if err != nil {
+			continue
+		}
+		for _, candidate := range found {
+			if !seen[candidate] {
+				seen[candidate] = true
+				result = append(result, candidate)
+			}
+		}
+	}
+	return result
+}
+
+// 查询指纹所属身份及其全部指纹，指纹未聚类时返回nil
+func (c *Clusterer) Identity(fp string) (*Identity, error) {
+	cluster, err := c.store.GetCluster(fp)
+	if err != nil {
+		return nil, fmt.Errorf("查询身份失败: %v", err)
+	}
+	if cluster == "" {
+		return nil, nil
+	}
+	members, err := c.store.ClusterMembers(cluster)
+	if err != nil {
+		return nil, fmt.Errorf("查询身份成员失败: %v", err)
+	}
+
+	vector, _ := c.store.GetComponents(fp)
+	identity := &Identity{ID: cluster}
+	for _, member := range members {
+		m := Member{Fingerprint: member, Similarity: 1}
+		if member != fp {
+			components, _ := c.store.GetComponents(member)
+			m.Similarity = c.generator.CalculateSimilarity(vector, components)
+		}
+		if banned, ttl, err := c.store.IsUserBanned(member); err == nil && banned {
+			m.Banned, m.BanRemaining = true, ttl.Round(time.Second).String()
+		}
+		identity.Members = append(identity.Members, m)
+	}
+	return identity, nil
+}
+
+// 封禁整个身份：封禁当前所有指纹，并记录身份封禁供之后加入的指纹继承
+func (c *Clusterer) BanIdentity(cluster string, duration time.Duration) (int, error) {
+	if err := c.store.BanCluster(cluster, duration); err != nil {
+		return 0, fmt.Errorf("封禁身份失败: %v", err)
+	}
+	members, err := c.store.ClusterMembers(cluster)
+	if err != nil {
+		return 0, fmt.Errorf("查询身份成员失败: %v", err)
+	}
+	for _, member := range members {
+		if err := c.store.BanUser(member, duration); err != nil {
+			return 0, fmt.Errorf("封禁指纹%s失败: %v", member, err)
+		}
+	}
+	return len(members), nil
+}
+
+// 解除整个身份的封禁
+func (c *Clusterer) UnbanIdentity(cluster string) (int, error) {
+	if err := c.store.UnbanCluster(cluster); err != nil {
+		return 0, fmt.Errorf("解除身份封禁失败: %v", err)
+	}
+	members, err := c.store.ClusterMembers(cluster)
+	if err != nil {
+		return 0, fmt.Errorf("查询身份成员失败: %v", err)
+	}
+	for _, member := range members {
+		if err := c.store.UnbanUser(member); err != nil {
+			return 0, fmt.Errorf("解除指纹%s封禁失败: %v", member, err)
+		}
+	}
+	return len(members), nil
+}
+
+// 指纹被封禁后扩散到所在身份，未开启封禁扩散时不做处理
+func (c *Clusterer) PropagateBan(fp string, duration time.Duration) error {
+	if !c.config.PropagateBans {
+		return nil
+	}
+	cluster, err := c.store.GetCluster(fp)
+	if err != nil {
+		return fmt.Errorf("查询身份失败: %v", err)
+	}
+	if cluster == "" {
+		return nil
+	}
+	_, err = c.BanIdentity(cluster, duration)
+	return err
+}
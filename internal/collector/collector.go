@@ -6,6 +6,15 @@ import (
 	"regexp"
 	"strings"
 	"time"
+
+	"securefingerprint/internal/botverify"
+	"securefingerprint/internal/clientsignals"
+	"securefingerprint/internal/device"
+	"securefingerprint/internal/geoip"
+	"securefingerprint/internal/headerorder"
+	"securefingerprint/internal/proxyproto"
+	"securefingerprint/internal/tlsfp"
+	"securefingerprint/internal/useragent"
 )
 
 // 访问信息结构体
@@ -24,24 +33,71 @@ type AccessInfo struct {
 	IsBot         bool              `json:"is_bot"`
 	LoginStatus   bool              `json:"login_status"`
 	IsBehindProxy bool              `json:"is_behind_proxy"` // 是否通过代理
+	Proto         string            `json:"proto"`           // 客户端使用的协议，经可信代理时取Forwarded proto
+	Host          string            `json:"host"`            // 客户端请求的Host，经可信代理时取Forwarded host
+	ForwardedBy   string            `json:"forwarded_by"`    // 接收客户端请求的代理接口（Forwarded by）
+	ProxyProtocol *ProxyProtocolInfo `json:"proxy_protocol,omitempty"` // L4负载均衡通过PROXY协议传递的信息
+	TLS           *TLSFingerprint    `json:"tls,omitempty"`            // 本服务终结TLS时的客户端握手指纹
+	HeaderOrder   *headerorder.Signature `json:"header_order,omitempty"` // 原始请求头顺序（仅HTTP/1.x直连）
+	ClientSignals *clientsignals.Result  `json:"client_signals,omitempty"` // 浏览器端采集的信号（信号cookie）
+	Device        *device.Identity       `json:"device,omitempty"`         // 设备cookie对应的身份，生成指纹后填写
+	Agent         *useragent.Agent       `json:"agent,omitempty"`          // 结构化的UA和Client Hints解析结果
+	BotVerification *botverify.Result    `json:"bot_verification,omitempty"` // 自称搜索引擎爬虫时的DNS验证结果
+	Geo           *geoip.Info            `json:"geo,omitempty"`            // IP的国家、城市和ASN
 	Timestamp     time.Time         `json:"timestamp"`
 }
 
+// PROXY协议信息
+type ProxyProtocolInfo struct {
+	Version     int                 `json:"version"`
+	Balancer    string              `json:"balancer"`              // 负载均衡地址（TCP连接对端）
+	Destination string              `json:"destination,omitempty"` // 负载均衡接收连接的地址
+	Authority   string              `json:"authority,omitempty"`   // 客户端TLS SNI
+	ALPN        string              `json:"alpn,omitempty"`
+	TLS         *proxyproto.TLSInfo `json:"tls,omitempty"` // 负载均衡终结TLS时的连接信息
+}
+
+// TLS客户端指纹
+type TLSFingerprint struct {
+	Version   string          `json:"version"`
+	SNI       string          `json:"sni,omitempty"`
+	ALPN      []string        `json:"alpn,omitempty"`
+	JA3       string          `json:"ja3"`
+	JA3String string          `json:"ja3_string"`
+	JA4       string          `json:"ja4"`
+	Stack     string          `json:"stack"`              // 识别出的TLS栈
+	Mismatch  *tlsfp.Mismatch `json:"mismatch,omitempty"` // UA与TLS栈不一致
+}
+
 type Collector struct {
 	botPatterns    []*regexp.Regexp
 	mobilePatterns []*regexp.Regexp
+	agents         *useragent.Parser
+	bots           *botverify.Verifier
+	geo            *geoip.Database
+	proxy          *ProxyDetector
+	tls            *tlsfp.Detector
+	headerOrder    *headerorder.Library
+	signals        *clientsignals.Service
+	extraHeaders   []string
 }
 
-func NewCollector() *Collector {
-	// 常见机器人User-Agent模式
+// 创建采集器，proxy为空时使用默认代理配置
+func NewCollector(proxy *ProxyDetector) *Collector {
+	if proxy == nil {
+		proxy, _ = NewProxyDetector(DefaultProxyConfig)
+	}
+
+	// 内置头顺序总是有效
+	headerOrder, _ := headerorder.NewLibrary(nil)
+
+	// 设备类型是指纹的组成部分，沿用原有的模式，避免升级后已有指纹变化
 	botPatterns := []*regexp.Regexp{
 		regexp.MustCompile(`(?i)(bot|crawler|spider|scraper|curl|wget|python|java|go-http)`),
 		regexp.MustCompile(`(?i)(googlebot|bingbot|slurp|duckduckbot|baiduspider|yandexbot)`),
 		regexp.MustCompile(`(?i)(facebookexternalhit|twitterbot|linkedinbot|whatsapp)`),
 		regexp.MustCompile(`(?i)(postman|insomnia|httpie|apache-httpclient)`),
 	}
-
-	// 移动设备User-Agent模式
 	mobilePatterns := []*regexp.Regexp{
 		regexp.MustCompile(`(?i)(mobile|android|iphone|ipad|ipod|blackberry|windows phone)`),
 		regexp.MustCompile(`(?i)(opera mini|opera mobi|samsung|nokia|huawei|xiaomi)`),
@@ -50,20 +106,57 @@ func NewCollector() *Collector {
 	return &Collector{
 		botPatterns:    botPatterns,
 		mobilePatterns: mobilePatterns,
+		agents:         useragent.Default(),
+		proxy:          proxy,
+		tls:            tlsfp.NewDetector(nil),
+		headerOrder:    headerOrder,
 	}
 }
 
+// 追加需要提取的请求头（如指纹组成中配置的头）
+func (c *Collector) AddHeaders(names ...string) {
+	c.extraHeaders = append(c.extraHeaders, names...)
+}
+
+// 设置UA解析器（使用配置中的模式库）
+func (c *Collector) SetUserAgentParser(parser *useragent.Parser) {
+	c.agents = parser
+}
+
+// 设置爬虫验证器，自称搜索引擎爬虫的请求通过DNS确认真伪
+func (c *Collector) SetBotVerifier(verifier *botverify.Verifier) {
+	c.bots = verifier
+}
+
+// 设置GeoIP数据库，用于查询国家和ASN并按ASN识别网络类型
+func (c *Collector) SetGeoIP(db *geoip.Database) {
+	c.geo = db
+}
+
+// 设置浏览器信号服务，用于读取信号cookie
+func (c *Collector) SetClientSignals(service *clientsignals.Service) {
+	c.signals = service
+}
+
+// 设置已知头顺序库（包含配置中追加的顺序）
+func (c *Collector) SetHeaderLibrary(library *headerorder.Library) {
+	c.headerOrder = library
+}
+
+// 设置TLS栈检测器（使用配置中的已知签名）
+func (c *Collector) SetTLSDetector(detector *tlsfp.Detector) {
+	c.tls = detector
+}
+
+// 获取采集器使用的代理检测器
+func (c *Collector) ProxyDetector() *ProxyDetector {
+	return c.proxy
+}
+
 // 从HTTP请求中采集访问信息
 func (c *Collector) CollectFromRequest(r *http.Request) *AccessInfo {
-	// 获取原始IP
-	originalHost, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		originalHost = r.RemoteAddr
-	}
-
 	info := &AccessInfo{
-		IP:           c.extractIP(r),
-		OriginalIP:   originalHost,
+		OriginalIP:   remoteHost(r.RemoteAddr),
 		UserAgent:    r.UserAgent(),
 		Referer:      r.Referer(),
 		Path:         r.URL.Path,
@@ -73,20 +166,78 @@ func (c *Collector) CollectFromRequest(r *http.Request) *AccessInfo {
 		Timestamp:    time.Now(),
 	}
 
+	// 经PROXY协议接入时RemoteAddr已是协议头中的客户端地址
+	if header, balancer := proxyproto.HeaderFromContext(r.Context()); header != nil {
+		info.ProxyProtocol = &ProxyProtocolInfo{
+			Version:   header.Version,
+			Balancer:  remoteHost(balancer.String()),
+			Authority: header.Authority,
+			ALPN:      header.ALPN,
+			TLS:       header.TLS,
+		}
+		if header.Destination != nil {
+			info.ProxyProtocol.Destination = header.Destination.String()
+		}
+		if header.TLS != nil && header.TLS.Client&proxyproto.SSLClientSSL != 0 {
+			info.Proto = "https"
+		}
+	}
+
+	// 直接终结TLS时记录ClientHello指纹
+	if hello := tlsfp.FromContext(r.Context()); hello != nil {
+		info.TLS = c.tlsFingerprint(info.UserAgent, hello)
+	}
+
+	// 只在直连地址为可信代理时采信转发头
+	address := c.proxy.Resolve(r)
+	info.IP, info.ProxyChain = address.IP, address.Chain
+	info.Host, info.ForwardedBy = r.Host, address.By
+	if info.Proto == "" {
+		info.Proto = "http"
+	}
+	if r.TLS != nil || info.TLS != nil {
+		info.Proto = "https"
+	}
+	if address.Proto != "" {
+		info.Proto = address.Proto
+	}
+	if address.Host != "" {
+		info.Host = address.Host
+	}
+
+	// 读取浏览器信号cookie，绑定校验需要解析后的客户端IP
+	if c.signals != nil {
+		info.ClientSignals = c.signals.FromRequest(r, info.IP)
+	}
+
 	// 检测是否通过代理
-	info.IsBehindProxy = c.detectProxy(r)
-	
-	// 提取代理链
-	info.ProxyChain = c.extractProxyChain(r)
+	info.IsBehindProxy = len(info.ProxyChain) > 0 || c.detectProxy(r)
 
-	// 分析设备类型
+	// 分析设备类型（指纹输入），解析出的设备类型见Agent.Device
 	info.DeviceType = c.detectDeviceType(info.UserAgent)
+
+	// 解析UA和Client Hints，机器人判断基于解析结果
+	info.Agent = c.agents.ParseRequest(r)
 	
+	// 查询IP的地理位置和自治系统
+	if c.geo != nil {
+		info.Geo = c.geo.Lookup(info.IP)
+	}
+
 	// 分析网络类型
-	info.NetworkType = c.detectNetworkType(info.IP)
+	info.NetworkType = c.detectNetworkType(info.IP, info.Geo)
 	
 	// 检测是否为机器人
-	info.IsBot = c.detectBot(info.UserAgent)
+	info.IsBot = info.Agent.Bot != nil
+	if info.IsBot && c.bots != nil {
+		info.BotVerification = c.bots.Verify(info.Agent.Bot.Name, info.IP)
+	}
+
+	// 直连的HTTP/1.x请求记录原始头顺序
+	if signature := headerorder.FromRequest(r); signature != nil {
+		c.headerOrder.Classify(tlsfp.ClaimedBrowser(info.UserAgent), signature)
+		info.HeaderOrder = signature
+	}
 	
 	// 检测登录状态（通过cookie或session）
 	info.LoginStatus = c.detectLoginStatus(r)
@@ -94,103 +245,18 @@ func (c *Collector) CollectFromRequest(r *http.Request) *AccessInfo {
 	return info
 }
 
-// 提取真实IP地址
-func (c *Collector) extractIP(r *http.Request) string {
-	// 代理头优先级列表（按可信度排序）
-	proxyHeaders := []string{
-		"CF-Connecting-IP",    // Cloudflare
-		"True-Client-IP",      // Akamai, Cloudflare
-		"X-Real-IP",          // Nginx
-		"X-Forwarded-For",    // 标准代理头
-		"X-Client-IP",        // Apache
-		"X-Forwarded",        // 非标准
-		"X-Cluster-Client-IP", // 集群代理
-		"Forwarded-For",      // RFC 7239
-		"Forwarded",          // RFC 7239
-	}
-
-	// 按优先级检查代理头
-	for _, header := range proxyHeaders {
-		if ip := r.Header.Get(header); ip != "" {
-			// 处理可能包含多个IP的情况
-			if realIP := c.parseIPFromHeader(ip, header); realIP != "" {
-				// 验证IP是否有效且不是内网IP（在代理环境中）
-				if c.isValidPublicIP(realIP) {
-					return realIP
-				}
-			}
-		}
-	}
-
-	// 如果没有找到有效的代理头，使用RemoteAddr
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
+// 计算ClientHello指纹并检查与UA是否一致
+func (c *Collector) tlsFingerprint(userAgent string, hello *tlsfp.ClientHello) *TLSFingerprint {
+	return &TLSFingerprint{
+		Version:   tlsfp.VersionName(hello.MaxVersion()),
+		SNI:       hello.ServerName,
+		ALPN:      hello.ALPN,
+		JA3:       hello.JA3(),
+		JA3String: hello.JA3String(),
+		JA4:       hello.JA4(),
+		Stack:     c.tls.Stack(hello),
+		Mismatch:  c.tls.Check(userAgent, hello),
 	}
-	return host
-}
-
-// 从代理头中解析IP
-func (c *Collector) parseIPFromHeader(headerValue, headerName string) string {
-	headerValue = strings.TrimSpace(headerValue)
-	
-	switch headerName {
-	case "X-Forwarded-For":
-		// X-Forwarded-For: client, proxy1, proxy2
-		// 取第一个IP（客户端IP）
-		if ips := strings.Split(headerValue, ","); len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
-		}
-		
-	case "Forwarded":
-		// RFC 7239: Forwarded: for=192.0.2.60;proto=http;by=203.0.113.43
-		// 解析 for= 参数
-		parts := strings.Split(headerValue, ";")
-		for _, part := range parts {
-			part = strings.TrimSpace(part)
-			if strings.HasPrefix(part, "for=") {
-				forValue := strings.TrimPrefix(part, "for=")
-				// 移除可能的引号和端口号
-				forValue = strings.Trim(forValue, "\"'")
-				if colonIndex := strings.LastIndex(forValue, ":"); colonIndex > 0 {
-					// 检查是否是IPv6格式 [::1]:port
-					if !strings.HasPrefix(forValue, "[") {
-						forValue = forValue[:colonIndex]
-					}
-				}
-				return forValue
-			}
-		}
-		
-	default:
-		// 其他头直接返回，但需要清理
-		// 移除可能的端口号
-		if colonIndex := strings.LastIndex(headerValue, ":"); colonIndex > 0 {
-			// 检查是否是IPv6格式
-			if !strings.HasPrefix(headerValue, "[") && !strings.Contains(headerValue[:colonIndex], ":") {
-				headerValue = headerValue[:colonIndex]
-			}
-		}
-		return headerValue
-	}
-	
-	return headerValue
-}
-
-// 验证是否为有效的公网IP
-func (c *Collector) isValidPublicIP(ipStr string) bool {
-	ip := net.ParseIP(ipStr)
-	if ip == nil {
-		return false
-	}
-
-	// 检查是否为有效IP格式
-	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
-		return false
-	}
-
-	// 检查是否为内网IP
-	return !c.isPrivateIP(ip)
 }
 
 // 提取关键HTTP头信息
@@ -205,7 +271,7 @@ func (c *Collector) extractHeaders(r *http.Request) map[string]string {
 		"DNT", "Sec-Fetch-Dest", "Sec-Fetch-Mode", "Sec-Fetch-Site",
 	}
 
-	for _, header := range importantHeaders {
+	for _, header := range append(importantHeaders, c.extraHeaders...) {
 		if value := r.Header.Get(header); value != "" {
 			headers[header] = value
 		}
@@ -239,7 +305,7 @@ func (c *Collector) detectDeviceType(userAgent string) string {
 }
 
 // 检测网络类型
-func (c *Collector) detectNetworkType(ip string) string {
+func (c *Collector) detectNetworkType(ip string, geo *geoip.Info) string {
 	// 解析IP地址
 	parsedIP := net.ParseIP(ip)
 	if parsedIP == nil {
@@ -256,9 +322,11 @@ func (c *Collector) detectNetworkType(ip string) string {
 		return "proxy"
 	}
 
-	// 检查是否为移动网络IP段（这里需要根据实际情况配置）
-	if c.isMobileIP(parsedIP) {
-		return "mobile"
+	// 按ASN识别移动运营商和IDC
+	if c.geo != nil {
+		if networkType := c.geo.NetworkType(geo); networkType != "" {
+			return networkType
+		}
 	}
 
 	return "broadband"
@@ -304,55 +372,6 @@ func (c *Collector) isProxyIP(ip net.IP) bool {
 	return false
 }
 
-// 检查是否为移动网络IP（需要根据实际运营商IP段配置）
-func (c *Collector) isMobileIP(ip net.IP) bool {
-	// 这里应该维护移动运营商的IP段数据库
-	// 为演示目的，返回false
-	return false
-}
-
-// 检测是否为机器人
-func (c *Collector) detectBot(userAgent string) bool {
-	if userAgent == "" {
-		return true // 没有User-Agent通常是机器人
-	}
-
-	ua := strings.ToLower(userAgent)
-	
-	for _, pattern := range c.botPatterns {
-		if pattern.MatchString(ua) {
-			return true
-		}
-	}
-
-	// 检查User-Agent是否过于简单
-	if len(userAgent) < 10 {
-		return true
-	}
-
-	// 检查是否包含常见浏览器标识
-	browserKeywords := []string{"chrome", "firefox", "safari", "edge", "opera"}
-	hasValidBrowser := false
-	for _, keyword := range browserKeywords {
-		if strings.Contains(ua, keyword) {
-			hasValidBrowser = true
-			break
-		}
-	}
-
-	// 如果没有浏览器标识但有明显的编程语言标识，可能是机器人
-	if !hasValidBrowser {
-		programmingKeywords := []string{"python", "java", "node", "php", "ruby", "go", "rust"}
-		for _, keyword := range programmingKeywords {
-			if strings.Contains(ua, keyword) {
-				return true
-			}
-		}
-	}
-
-	return false
-}
-
 // 检测登录状态
 func (c *Collector) detectLoginStatus(r *http.Request) bool {
 	// 检查常见的登录相关cookie
@@ -420,61 +439,6 @@ func (c *Collector) detectProxy(r *http.Request) bool {
 	return false
 }
 
-// 提取代理链信息
-func (c *Collector) extractProxyChain(r *http.Request) []string {
-	var proxyChain []string
-
-	// 从X-Forwarded-For提取代理链
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		ips := strings.Split(xff, ",")
-		for _, ip := range ips {
-			ip = strings.TrimSpace(ip)
-			if ip != "" {
-				proxyChain = append(proxyChain, ip)
-			}
-		}
-	}
-
-	// 从Via头提取代理信息
-	if via := r.Header.Get("Via"); via != "" {
-		// Via: 1.1 proxy1.example.com, 1.0 proxy2.example.com
-		vias := strings.Split(via, ",")
-		for _, v := range vias {
-			v = strings.TrimSpace(v)
-			if v != "" {
-				// 提取代理服务器信息
-				parts := strings.Fields(v)
-				if len(parts) >= 2 {
-					proxyChain = append(proxyChain, parts[1]) // 代理服务器名
-				}
-			}
-		}
-	}
-
-	// 从Forwarded头提取（RFC 7239）
-	if forwarded := r.Header.Get("Forwarded"); forwarded != "" {
-		// Forwarded: for=192.0.2.60;proto=http;by=203.0.113.43
-		parts := strings.Split(forwarded, ",")
-		for _, part := range parts {
-			part = strings.TrimSpace(part)
-			// 解析by参数（代理服务器）
-			if strings.Contains(part, "by=") {
-				byStart := strings.Index(part, "by=") + 3
-				byEnd := strings.Index(part[byStart:], ";")
-				if byEnd == -1 {
-					byEnd = len(part[byStart:])
-				}
-				proxyServer := strings.Trim(part[byStart:byStart+byEnd], "\"'")
-				if proxyServer != "" {
-					proxyChain = append(proxyChain, proxyServer)
-				}
-			}
-		}
-	}
-
-	return proxyChain
-}
-
 // 获取代理信息摘要
 func (c *Collector) GetProxySummary(info *AccessInfo) map[string]interface{} {
 	return map[string]interface{}{
@@ -0,0 +1,351 @@
+package collector
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// RFC 7239 Forwarded头中的一个节点（for/by参数）
+type ForwardedNode struct {
+	Raw        string `json:"raw"`                  // 原始值（已去掉引号）
+	IP         net.IP `json:"ip,omitempty"`         // 节点为IP时的地址
+	Port       string `json:"port,omitempty"`       // 端口，可能是数字或混淆端口（_开头）
+	Unknown    bool   `json:"unknown,omitempty"`    // 节点为unknown
+	Obfuscated string `json:"obfuscated,omitempty"` // 混淆标识（_开头）
+}
+
+// 节点地址的字符串形式，非IP节点返回空
+func (n *ForwardedNode) Address() string {
+	if n == nil || n.IP == nil {
+		return ""
+	}
+	return n.IP.String()
+}
+
+// Forwarded头中的一个元素，对应一次代理转发
+type ForwardedElement struct {
+	For        *ForwardedNode    `json:"for,omitempty"`
+	By         *ForwardedNode    `json:"by,omitempty"`
+	Proto      string            `json:"proto,omitempty"`
+	Host       string            `json:"host,omitempty"`
+	Extensions map[string]string `json:"extensions,omitempty"` // 其他扩展参数
+}
+
+// 解析Forwarded头，多个头按出现顺序拼接。任一元素有语法错误时返回错误
+func ParseForwarded(values []string) ([]ForwardedElement, error) {
+	var elements []ForwardedElement
+	for _, result := range parseForwardedElements(values) {
+		if result.err != nil {
+			return nil, result.err
+		}
+		elements = append(elements, result.element)
+	}
+	return elements, nil
+}
+
+// 单个元素的解析结果
+type forwardedResult struct {
+	element ForwardedElement
+	err     error
+}
+
+// 逐个元素解析，出错的元素跳到下一个逗号继续。
+// 客户端可以在左侧附加任意内容，只有从右到左遍历时实际到达的元素出错才应放弃该头
+func parseForwardedElements(values []string) []forwardedResult {
+	var results []forwardedResult
+	for _, value := range values {
+		p := &forwardedParser{input: value}
+		for {
+			p.skipSpace()
+			if p.done() {
+				break
+			}
+			// 允许空元素（1#规则中的 ", ," ）
+			if p.peek() == ',' {
+				p.pos++
+				continue
+			}
+
+			element, err := p.element()
+			if err == nil {
+				p.skipSpace()
+				if !p.done() && p.peek() != ',' {
+					err = fmt.Errorf("位置%d: 期望','，实际为%q", p.pos, p.peek())
+				}
+			}
+			if err != nil {
+				results = append(results, forwardedResult{err: err})
+				p.skipElement()
+				continue
+			}
+			results = append(results, forwardedResult{element: element})
+		}
+	}
+	return results
+}
+
+type forwardedParser struct {
+	input string
+	pos   int
+}
+
+func (p *forwardedParser) done() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *forwardedParser) peek() byte {
+	return p.input[p.pos]
+}
+
+// 跳过当前元素剩余内容直到引号外的逗号
+func (p *forwardedParser) skipElement() {
+	quoted := false
+	for !p.done() {
+		c := p.peek()
+		switch {
+		case quoted && c == '\\':
+			p.pos++
+		case c == '"':
+			quoted = !quoted
+		case !quoted && c == ',':
+			p.pos++
+			return
+		}
+		p.pos++
+	}
+}
+
+func (p *forwardedParser) skipSpace() {
+	for !p.done() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+// forwarded-element = [ forwarded-pair ] *( ";" [ forwarded-pair ] )
+func (p *forwardedParser) element() (ForwardedElement, error) {
+	var element ForwardedElement
+	seen := make(map[string]bool)
+
+	for {
+		p.skipSpace()
+		if p.done() || p.peek() == ',' {
+			break
+		}
+		if p.peek() == ';' {
+			p.pos++
+			continue
+		}
+
+		name, value, err := p.pair()
+		if err != nil {
+			return element, err
+		}
+		if seen[name] {
+			return element, fmt.Errorf("参数%s在同一元素中重复出现", name)
+		}
+		seen[name] = true
+
+		switch name {
+		case "for":
+			node, err := parseForwardedNode(value)
+			if err != nil {
+				return element, fmt.Errorf("无效的for参数: %v", err)
+			}
+			element.For = node
+		case "by":
+			node, err := parseForwardedNode(value)
+			if err != nil {
+				return element, fmt.Errorf("无效的by参数: %v", err)
+			}
+			element.By = node
+		case "proto":
+			if !isValidScheme(value) {
+				return element, fmt.Errorf("无效的proto参数: %q", value)
+			}
+			element.Proto = strings.ToLower(value)
+		case "host":
+			if value == "" {
+				return element, fmt.Errorf("host参数不能为空")
+			}
+			element.Host = value
+		default:
+			if element.Extensions == nil {
+				element.Extensions = make(map[string]string)
+			}
+			element.Extensions[name] = value
+		}
+
+		p.skipSpace()
+		if p.done() || p.peek() == ',' {
+			break
+		}
+		if p.peek() != ';' {
+			return element, fmt.Errorf("位置%d: 期望';'，实际为%q", p.pos, p.peek())
+		}
+	}
+
+	return element, nil
+}
+
+// forwarded-pair = token "=" value，参数名不区分大小写
+func (p *forwardedParser) pair() (string, string, error) {
+	name := p.token()
+	if name == "" {
+		return "", "", fmt.Errorf("位置%d: 缺少参数名", p.pos)
+	}
+	if p.done() || p.peek() != '=' {
+		return "", "", fmt.Errorf("位置%d: 参数%s缺少'='", p.pos, name)
+	}
+	p.pos++
+
+	if !p.done() && p.peek() == '"' {
+		value, err := p.quoted()
+		return strings.ToLower(name), value, err
+	}
+	value := p.token()
+	if value == "" {
+		return "", "", fmt.Errorf("位置%d: 参数%s的值为空", p.pos, name)
+	}
+	return strings.ToLower(name), value, nil
+}
+
+// token = 1*tchar（RFC 7230）
+func (p *forwardedParser) token() string {
+	start := p.pos
+	for !p.done() && isTokenChar(p.peek()) {
+		p.pos++
+	}
+	return p.input[start:p.pos]
+}
+
+// quoted-string，支持 \ 转义
+func (p *forwardedParser) quoted() (string, error) {
+	start := p.pos
+	p.pos++ // 跳过起始引号
+
+	var b strings.Builder
+	for !p.done() {
+		c := p.peek()
+		switch {
+		case c == '"':
+			p.pos++
+			return b.String(), nil
+		case c == '\\':
+			p.pos++
+			if p.done() {
+				return "", fmt.Errorf("位置%d: 转义符后缺少字符", p.pos)
+			}
+			b.WriteByte(p.peek())
+		case c < 0x20 && c != '\t' || c == 0x7f:
+			return "", fmt.Errorf("位置%d: 引号字符串中包含控制字符", p.pos)
+		default:
+			b.WriteByte(c)
+		}
+		p.pos++
+	}
+	return "", fmt.Errorf("位置%d: 引号字符串未闭合", start)
+}
+
+func isTokenChar(c byte) bool {
+	if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' {
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
+
+// URI scheme = ALPHA *( ALPHA / DIGIT / "+" / "-" / "." )
+func isValidScheme(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		isAlpha := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+		if i == 0 && !isAlpha {
+			return false
+		}
+		if !isAlpha && !(c >= '0' && c <= '9') && c != '+' && c != '-' && c != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// node = nodename [ ":" node-port ]
+// nodename = IPv4address / "[" IPv6address "]" / "unknown" / obfnode
+func parseForwardedNode(value string) (*ForwardedNode, error) {
+	node := &ForwardedNode{Raw: value}
+
+	name, port := value, ""
+	if strings.HasPrefix(value, "[") {
+		end := strings.IndexByte(value, ']')
+		if end < 0 {
+			return nil, fmt.Errorf("IPv6地址缺少']': %q", value)
+		}
+		name = value[1:end]
+		rest := value[end+1:]
+		if rest != "" {
+			if rest[0] != ':' {
+				return nil, fmt.Errorf("IPv6地址后有多余内容: %q", value)
+			}
+			port = rest[1:]
+			if port == "" {
+				return nil, fmt.Errorf("端口为空: %q", value)
+			}
+		}
+		ip := net.ParseIP(name)
+		if ip == nil || ip.To4() != nil {
+			return nil, fmt.Errorf("无效的IPv6地址: %q", name)
+		}
+		node.IP = ip
+	} else {
+		if i := strings.IndexByte(value, ':'); i >= 0 {
+			name, port = value[:i], value[i+1:]
+			if strings.IndexByte(port, ':') >= 0 {
+				return nil, fmt.Errorf("IPv6地址必须使用方括号: %q", value)
+			}
+			if port == "" {
+				return nil, fmt.Errorf("端口为空: %q", value)
+			}
+		}
+		switch {
+		case strings.EqualFold(name, "unknown"):
+			node.Unknown = true
+		case isObfuscated(name):
+			node.Obfuscated = name
+		default:
+			ip := net.ParseIP(name)
+			if ip == nil || ip.To4() == nil {
+				return nil, fmt.Errorf("无效的节点名: %q", name)
+			}
+			node.IP = ip.To4()
+		}
+	}
+
+	if port != "" {
+		if !isObfuscated(port) {
+			n, err := strconv.Atoi(port)
+			if err != nil || len(port) > 5 || n < 0 || n > 65535 {
+				return nil, fmt.Errorf("无效的端口: %q", port)
+			}
+		}
+		node.Port = port
+	}
+	return node, nil
+}
+
+// obfnode/obfport = "_" 1*( ALPHA / DIGIT / "." / "_" / "-" )
+func isObfuscated(s string) bool {
+	if len(s) < 2 || s[0] != '_' {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,311 @@
+package collector
+
+import (
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestParseForwarded(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		want    []ForwardedElement
+		wantErr bool
+	}{
+		{
+			name:   "IPv4 for",
+			values: []string{"for=192.0.2.60"},
+			want:   []ForwardedElement{{For: &ForwardedNode{Raw: "192.0.2.60", IP: net.ParseIP("192.0.2.60").To4()}}},
+		},
+		{
+			name:   "全部标准参数",
+			values: []string{"for=192.0.2.60;proto=http;by=203.0.113.43;host=example.com"},
+			want: []ForwardedElement{{
+				For:   &ForwardedNode{Raw: "192.0.2.60", IP: net.ParseIP("192.0.2.60").To4()},
+				By:    &ForwardedNode{Raw: "203.0.113.43", IP: net.ParseIP("203.0.113.43").To4()},
+				Proto: "http",
+				Host:  "example.com",
+			}},
+		},
+		{
+			name:   "带引号的IPv6和端口",
+			values: []string{`For="[2001:db8:cafe::17]:4711"`},
+			want: []ForwardedElement{{
+				For: &ForwardedNode{Raw: "[2001:db8:cafe::17]:4711", IP: net.ParseIP("2001:db8:cafe::17"), Port: "4711"},
+			}},
+		},
+		{
+			name:   "带引号的IPv4端口",
+			values: []string{`for="192.0.2.43:47011"`},
+			want: []ForwardedElement{{
+				For: &ForwardedNode{Raw: "192.0.2.43:47011", IP: net.ParseIP("192.0.2.43").To4(), Port: "47011"},
+			}},
+		},
+		{
+			name:   "多个元素",
+			values: []string{"for=192.0.2.43, for=198.51.100.17"},
+			want: []ForwardedElement{
+				{For: &ForwardedNode{Raw: "192.0.2.43", IP: net.ParseIP("192.0.2.43").To4()}},
+				{For: &ForwardedNode{Raw: "198.51.100.17", IP: net.ParseIP("198.51.100.17").To4()}},
+			},
+		},
+		{
+			name:   "多个头按顺序拼接",
+			values: []string{"for=192.0.2.43", "for=198.51.100.17;proto=HTTPS"},
+			want: []ForwardedElement{
+				{For: &ForwardedNode{Raw: "192.0.2.43", IP: net.ParseIP("192.0.2.43").To4()}},
+				{For: &ForwardedNode{Raw: "198.51.100.17", IP: net.ParseIP("198.51.100.17").To4()}, Proto: "https"},
+			},
+		},
+		{
+			name:   "引号内的逗号和分号",
+			values: []string{`for=192.0.2.1;host="a,b;c", for=192.0.2.2`},
+			want: []ForwardedElement{
+				{For: &ForwardedNode{Raw: "192.0.2.1", IP: net.ParseIP("192.0.2.1").To4()}, Host: "a,b;c"},
+				{For: &ForwardedNode{Raw: "192.0.2.2", IP: net.ParseIP("192.0.2.2").To4()}},
+			},
+		},
+		{
+			name:   "引号转义",
+			values: []string{`host="ex\"ample.com"`},
+			want:   []ForwardedElement{{Host: `ex"ample.com`}},
+		},
+		{
+			name:   "unknown节点",
+			values: []string{`for=unknown, for="UNKNOWN:8080"`},
+			want: []ForwardedElement{
+				{For: &ForwardedNode{Raw: "unknown", Unknown: true}},
+				{For: &ForwardedNode{Raw: "UNKNOWN:8080", Unknown: true, Port: "8080"}},
+			},
+		},
+		{
+			name:   "混淆节点和端口",
+			values: []string{`for=_hidden, for="_SEVKISEK:_port-1"`},
+			want: []ForwardedElement{
+				{For: &ForwardedNode{Raw: "_hidden", Obfuscated: "_hidden"}},
+				{For: &ForwardedNode{Raw: "_SEVKISEK:_port-1", Obfuscated: "_SEVKISEK", Port: "_port-1"}},
+			},
+		},
+		{
+			name:   "扩展参数",
+			values: []string{"for=192.0.2.1;secret=abc"},
+			want: []ForwardedElement{{
+				For:        &ForwardedNode{Raw: "192.0.2.1", IP: net.ParseIP("192.0.2.1").To4()},
+				Extensions: map[string]string{"secret": "abc"},
+			}},
+		},
+		{
+			name:   "空格与空元素",
+			values: []string{" for=192.0.2.1 ; proto=https ,, "},
+			want: []ForwardedElement{{
+				For:   &ForwardedNode{Raw: "192.0.2.1", IP: net.ParseIP("192.0.2.1").To4()},
+				Proto: "https",
+			}},
+		},
+		{name: "空值", values: []string{""}, want: nil},
+		{name: "未加引号的IPv6", values: []string{"for=2001:db8::1"}, wantErr: true},
+		{name: "IPv6缺少方括号", values: []string{`for="2001:db8::1"`}, wantErr: true},
+		{name: "IPv6缺少右方括号", values: []string{`for="[2001:db8::1"`}, wantErr: true},
+		{name: "方括号中是IPv4", values: []string{`for="[192.0.2.1]"`}, wantErr: true},
+		{name: "方括号后多余内容", values: []string{`for="[2001:db8::1]x"`}, wantErr: true},
+		{name: "端口超出范围", values: []string{`for="192.0.2.1:70000"`}, wantErr: true},
+		{name: "端口为空", values: []string{`for="192.0.2.1:"`}, wantErr: true},
+		{name: "无效节点名", values: []string{"for=example.com"}, wantErr: true},
+		{name: "仅有下划线的混淆节点", values: []string{"for=_"}, wantErr: true},
+		{name: "引号未闭合", values: []string{`for="192.0.2.1`}, wantErr: true},
+		{name: "缺少等号", values: []string{"for"}, wantErr: true},
+		{name: "值为空", values: []string{"for=;proto=http"}, wantErr: true},
+		{name: "参数重复", values: []string{"for=192.0.2.1;For=192.0.2.2"}, wantErr: true},
+		{name: "无效proto", values: []string{"proto=1http"}, wantErr: true},
+		{name: "空host", values: []string{`host=""`}, wantErr: true},
+		{name: "参数间缺少分隔符", values: []string{`for="192.0.2.1"proto=http`}, wantErr: true},
+		{name: "第二个头无效", values: []string{"for=192.0.2.1", "for=[::1]"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseForwarded(tt.values)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("期望错误，实际解析为 %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("解析结果不一致\n实际: %s\n期望: %s", describeElements(got), describeElements(tt.want))
+			}
+		})
+	}
+}
+
+func describeElements(elements []ForwardedElement) string {
+	var s string
+	for _, element := range elements {
+		s += "{"
+		if element.For != nil {
+			s += "for=" + describeNode(element.For) + " "
+		}
+		if element.By != nil {
+			s += "by=" + describeNode(element.By) + " "
+		}
+		s += "proto=" + element.Proto + " host=" + element.Host + "} "
+	}
+	return s
+}
+
+func describeNode(node *ForwardedNode) string {
+	return node.Raw + "(ip=" + node.Address() + " port=" + node.Port + " obf=" + node.Obfuscated + ")"
+}
+
+func TestResolveForwarded(t *testing.T) {
+	detector, err := NewProxyDetector(ProxyConfig{
+		TrustedProxies: []string{"10.0.0.0/8", "2001:db8:ffff::/48"},
+		TrustedHeaders: []string{"Forwarded", "X-Forwarded-For"},
+		HeaderPriority: map[string]int{"Forwarded": 100, "X-Forwarded-For": 50},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		xff        string
+		want       ClientAddress
+	}{
+		{
+			name:       "不可信直连地址忽略Forwarded",
+			remoteAddr: "203.0.113.9:1234",
+			forwarded:  []string{"for=198.51.100.1;proto=https"},
+			want:       ClientAddress{IP: "203.0.113.9"},
+		},
+		{
+			name:       "单级可信代理",
+			remoteAddr: "10.0.0.1:1234",
+			forwarded:  []string{"for=198.51.100.1;proto=https;host=shop.example.com;by=10.0.0.1"},
+			want: ClientAddress{
+				IP: "198.51.100.1", Chain: []string{"10.0.0.1"}, Header: "Forwarded",
+				Proto: "https", Host: "shop.example.com", By: "10.0.0.1",
+			},
+		},
+		{
+			name:       "从右到左跳过可信代理并忽略客户端伪造的元素",
+			remoteAddr: "10.0.0.1:1234",
+			forwarded:  []string{`for=1.1.1.1;proto=http, for="198.51.100.7:5000";proto=https;host=a.example, for=10.0.0.2;proto=http`},
+			want: ClientAddress{
+				IP: "198.51.100.7", Chain: []string{"10.0.0.2", "10.0.0.1"}, Header: "Forwarded",
+				Proto: "https", Host: "a.example",
+			},
+		},
+		{
+			name:       "IPv6客户端和IPv6可信代理",
+			remoteAddr: "[2001:db8:ffff::1]:443",
+			forwarded:  []string{`for="[2001:db8:cafe::17]:4711";by="[2001:db8:ffff::1]"`},
+			want: ClientAddress{
+				IP: "2001:db8:cafe::17", Chain: []string{"2001:db8:ffff::1"}, Header: "Forwarded",
+				By: "2001:db8:ffff::1",
+			},
+		},
+		{
+			name:       "全部为可信代理时取最左侧",
+			remoteAddr: "10.0.0.1:1234",
+			forwarded:  []string{"for=10.0.0.3, for=10.0.0.2"},
+			want:       ClientAddress{IP: "10.0.0.3", Chain: []string{"10.0.0.2", "10.0.0.1"}, Header: "Forwarded"},
+		},
+		{
+			name:       "客户端为unknown时回退到下一个头",
+			remoteAddr: "10.0.0.1:1234",
+			forwarded:  []string{"for=unknown"},
+			xff:        "198.51.100.2",
+			want:       ClientAddress{IP: "198.51.100.2", Chain: []string{"10.0.0.1"}, Header: "X-Forwarded-For"},
+		},
+		{
+			name:       "客户端为混淆标识时使用直连地址",
+			remoteAddr: "10.0.0.1:1234",
+			forwarded:  []string{"for=_gateway"},
+			want:       ClientAddress{IP: "10.0.0.1"},
+		},
+		{
+			name:       "语法错误时放弃Forwarded",
+			remoteAddr: "10.0.0.1:1234",
+			forwarded:  []string{"for=198.51.100.1;for=198.51.100.2"},
+			xff:        "198.51.100.3",
+			want:       ClientAddress{IP: "198.51.100.3", Chain: []string{"10.0.0.1"}, Header: "X-Forwarded-For"},
+		},
+		{
+			name:       "左侧伪造的语法错误元素不影响解析",
+			remoteAddr: "10.0.0.1:1234",
+			forwarded:  []string{`for=[::1];x="a,b, for="unterminated, for=198.51.100.5;proto=https`},
+			want:       ClientAddress{IP: "198.51.100.5", Chain: []string{"10.0.0.1"}, Header: "Forwarded", Proto: "https"},
+		},
+		{
+			name:       "可信代理追加的元素语法错误时放弃Forwarded",
+			remoteAddr: "10.0.0.1:1234",
+			forwarded:  []string{"for=198.51.100.1, for=10.0.0.2;for=10.0.0.3"},
+			xff:        "198.51.100.6",
+			want:       ClientAddress{IP: "198.51.100.6", Chain: []string{"10.0.0.1"}, Header: "X-Forwarded-For"},
+		},
+		{
+			name:       "左侧伪造的无效元素不影响解析",
+			remoteAddr: "10.0.0.1:1234",
+			forwarded:  []string{"for=_forged, for=198.51.100.4"},
+			want:       ClientAddress{IP: "198.51.100.4", Chain: []string{"10.0.0.1"}, Header: "Forwarded"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &http.Request{RemoteAddr: tt.remoteAddr, Header: make(http.Header)}
+			for _, value := range tt.forwarded {
+				r.Header.Add("Forwarded", value)
+			}
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+
+			got := detector.Resolve(r)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("解析结果不一致\n实际: %+v\n期望: %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectFromRequestForwarded(t *testing.T) {
+	detector, err := NewProxyDetector(ProxyConfig{
+		TrustedProxies: []string{"10.0.0.0/8"},
+		TrustedHeaders: []string{"Forwarded"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCollector(detector)
+
+	r, _ := http.NewRequest(http.MethodGet, "http://internal.local/path", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("Forwarded", `for="198.51.100.1:5555";proto=https;host=www.example.com;by=10.0.0.1`)
+
+	info := c.CollectFromRequest(r)
+	if info.IP != "198.51.100.1" || info.OriginalIP != "10.0.0.1" {
+		t.Fatalf("IP = %s, OriginalIP = %s", info.IP, info.OriginalIP)
+	}
+	if info.Proto != "https" || info.Host != "www.example.com" || info.ForwardedBy != "10.0.0.1" {
+		t.Fatalf("Proto = %s, Host = %s, ForwardedBy = %s", info.Proto, info.Host, info.ForwardedBy)
+	}
+	if !reflect.DeepEqual(info.ProxyChain, []string{"10.0.0.1"}) || !info.IsBehindProxy {
+		t.Fatalf("ProxyChain = %v, IsBehindProxy = %v", info.ProxyChain, info.IsBehindProxy)
+	}
+
+	// 直连客户端自带Forwarded头时不采信
+	r.RemoteAddr = "203.0.113.5:1234"
+	info = c.CollectFromRequest(r)
+	if info.IP != "203.0.113.5" || info.Proto != "http" || info.Host != "internal.local" || info.ForwardedBy != "" {
+		t.Fatalf("不可信直连地址: IP = %s, Proto = %s, Host = %s, ForwardedBy = %s", info.IP, info.Proto, info.Host, info.ForwardedBy)
+	}
+}
@@ -1,20 +1,28 @@
 package collector
 
 import (
+	"fmt"
 	"net"
 	"net/http"
+	"sort"
 	"strings"
 )
 
 // 代理配置
 type ProxyConfig struct {
-	TrustedProxies    []string          `yaml:"trusted_proxies"`    // 可信代理IP/CIDR列表
-	TrustedHeaders    []string          `yaml:"trusted_headers"`    // 可信的代理头列表
-	HeaderPriority    map[string]int    `yaml:"header_priority"`    // 头优先级配置
-	SkipPrivateRanges bool              `yaml:"skip_private_ranges"` // 是否跳过内网IP
-	MaxProxyDepth     int               `yaml:"max_proxy_depth"`     // 最大代理深度
+	TrustedProxies    []string       `yaml:"trusted_proxies" json:"trusted_proxies"`         // 可信代理IP/CIDR列表，只有直连地址在其中时才采信转发头
+	TrustedHeaders    []string       `yaml:"trusted_headers" json:"trusted_headers"`         // 采信的转发头列表
+	HeaderPriority    map[string]int `yaml:"header_priority" json:"header_priority"`         // 头优先级配置
+	SkipPrivateRanges bool           `yaml:"skip_private_ranges" json:"skip_private_ranges"` // 遍历转发链时将内网地址视为内部代理
+	MaxProxyDepth     int            `yaml:"max_proxy_depth" json:"max_proxy_depth"`         // 最多跳过的代理层数
+
+	// 只在直连地址属于指定地址段时采信的头，用于CDN直接回源时的CF-Connecting-IP等头，值为CDN公布的回源地址段
+	HeaderProxies map[string][]string `yaml:"header_proxies" json:"header_proxies"`
 }
 
+// CDN设置的单值客户端IP头，任何客户端都能伪造，必须在header_proxies中配置CDN的回源地址段
+var cdnHeaders = []string{"CF-Connecting-IP", "True-Client-IP", "Fastly-Client-IP"}
+
 // 默认代理配置
 var DefaultProxyConfig = ProxyConfig{
 	TrustedProxies: []string{
@@ -26,10 +34,8 @@ var DefaultProxyConfig = ProxyConfig{
 		"fc00::/7",          // IPv6内网
 	},
 	TrustedHeaders: []string{
-		"X-Real-IP",
 		"X-Forwarded-For",
-		"CF-Connecting-IP",
-		"True-Client-IP",
+		"X-Real-IP",
 	},
 	HeaderPriority: map[string]int{
 		"CF-Connecting-IP": 100, // Cloudflare最高优先级
@@ -46,32 +52,54 @@ var DefaultProxyConfig = ProxyConfig{
 type ProxyDetector struct {
 	config       ProxyConfig
 	trustedNets  []*net.IPNet
+	headerNets   map[string][]*net.IPNet // 按规范化头名索引的header_proxies地址段
 	headersByPriority []string
 }
 
 // 创建代理检测器
 func NewProxyDetector(config ProxyConfig) (*ProxyDetector, error) {
+	if config.TrustedProxies == nil {
+		config.TrustedProxies = DefaultProxyConfig.TrustedProxies
+	}
+	if config.TrustedHeaders == nil {
+		config.TrustedHeaders = DefaultProxyConfig.TrustedHeaders
+	}
+	if config.HeaderPriority == nil {
+		config.HeaderPriority = DefaultProxyConfig.HeaderPriority
+	}
+	if config.MaxProxyDepth <= 0 {
+		config.MaxProxyDepth = DefaultProxyConfig.MaxProxyDepth
+	}
+
 	detector := &ProxyDetector{
-		config: config,
+		config:     config,
+		headerNets: make(map[string][]*net.IPNet),
 	}
 
 	// 解析可信代理网络
-	for _, cidr := range config.TrustedProxies {
-		_, network, err := net.ParseCIDR(cidr)
+	networks, err := parseNetworks(config.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	detector.trustedNets = networks
+
+	for header, cidrs := range config.HeaderProxies {
+		networks, err := parseNetworks(cidrs)
 		if err != nil {
-			// 尝试解析为单个IP
-			ip := net.ParseIP(cidr)
-			if ip != nil {
-				if ip.To4() != nil {
-					_, network, _ = net.ParseCIDR(cidr + "/32")
-				} else {
-					_, network, _ = net.ParseCIDR(cidr + "/128")
-				}
-			} else {
-				return nil, err
+			return nil, fmt.Errorf("%s的地址段: %v", header, err)
+		}
+		if len(networks) == 0 {
+			return nil, fmt.Errorf("%s未配置地址段", header)
+		}
+		detector.headerNets[http.CanonicalHeaderKey(header)] = networks
+	}
+	for _, header := range config.TrustedHeaders {
+		header = http.CanonicalHeaderKey(header)
+		for _, cdn := range cdnHeaders {
+			if header == http.CanonicalHeaderKey(cdn) && detector.headerNets[header] == nil {
+				return nil, fmt.Errorf("%s可被客户端伪造，需要在header_proxies中配置CDN的回源地址段", header)
 			}
 		}
-		detector.trustedNets = append(detector.trustedNets, network)
 	}
 
 	// 按优先级排序头列表
@@ -80,48 +108,66 @@ func NewProxyDetector(config ProxyConfig) (*ProxyDetector, error) {
 	return detector, nil
 }
 
-// 按优先级排序头
+// 按优先级排序头，包含可信头列表和header_proxies中的头
 func (pd *ProxyDetector) sortHeadersByPriority() {
-	headerPriority := make(map[string]int)
-	
-	// 使用配置的优先级
-	for header, priority := range pd.config.HeaderPriority {
-		headerPriority[header] = priority
-	}
-	
-	// 为未配置优先级的头设置默认值
+	headers := make([]string, 0, len(pd.config.TrustedHeaders)+len(pd.headerNets))
+	seen := make(map[string]bool)
 	for _, header := range pd.config.TrustedHeaders {
-		if _, exists := headerPriority[header]; !exists {
-			headerPriority[header] = 50 // 默认优先级
+		header = http.CanonicalHeaderKey(header)
+		if !seen[header] {
+			seen[header] = true
+			headers = append(headers, header)
 		}
 	}
-	
-	// 排序
-	headers := make([]string, 0, len(headerPriority))
-	for header := range headerPriority {
-		headers = append(headers, header)
+	extra := make([]string, 0, len(pd.headerNets))
+	for header := range pd.headerNets {
+		if !seen[header] {
+			extra = append(extra, header)
+		}
 	}
-	
-	// 按优先级降序排序
-	for i := 0; i < len(headers)-1; i++ {
-		for j := i + 1; j < len(headers); j++ {
-			if headerPriority[headers[i]] < headerPriority[headers[j]] {
-				headers[i], headers[j] = headers[j], headers[i]
+	sort.Strings(extra)
+	headers = append(headers, extra...)
+
+	priority := func(header string) int {
+		for name, value := range pd.config.HeaderPriority {
+			if http.CanonicalHeaderKey(name) == header {
+				return value
 			}
 		}
+		return 50 // 默认优先级
 	}
-	
+
+	// 按优先级降序排序，优先级相同时保持配置顺序
+	sort.SliceStable(headers, func(i, j int) bool {
+		return priority(headers[i]) > priority(headers[j])
+	})
+
 	pd.headersByPriority = headers
 }
 
+// 获取生效的配置（已填充默认值）
+func (pd *ProxyDetector) Config() ProxyConfig {
+	return pd.config
+}
+
+// 按优先级排列的采信头
+func (pd *ProxyDetector) Headers() []string {
+	return pd.headersByPriority
+}
+
 // 检查IP是否为可信代理
 func (pd *ProxyDetector) IsTrustedProxy(ipStr string) bool {
+	return containsIP(pd.trustedNets, ipStr)
+}
+
+// IP是否属于任一地址段
+func containsIP(networks []*net.IPNet, ipStr string) bool {
 	ip := net.ParseIP(ipStr)
 	if ip == nil {
 		return false
 	}
 
-	for _, network := range pd.trustedNets {
+	for _, network := range networks {
 		if network.Contains(ip) {
 			return true
 		}
@@ -130,86 +176,163 @@ func (pd *ProxyDetector) IsTrustedProxy(ipStr string) bool {
 	return false
 }
 
-// 从请求中提取真实客户端IP
+// 解析CIDR列表，单个IP视为/32或/128
+func parseNetworks(cidrs []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			// 尝试解析为单个IP
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, err
+			}
+			if ip.To4() != nil {
+				_, network, _ = net.ParseCIDR(cidr + "/32")
+			} else {
+				_, network, _ = net.ParseCIDR(cidr + "/128")
+			}
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// 客户端地址解析结果
+type ClientAddress struct {
+	IP     string   `json:"ip"`               // 客户端IP
+	Chain  []string `json:"chain"`            // 经过的可信代理，从客户端一侧到本服务
+	Header string   `json:"header,omitempty"` // 采信的转发头，未采信时为空
+	Proto  string   `json:"proto,omitempty"`  // 客户端使用的协议（Forwarded proto）
+	Host   string   `json:"host,omitempty"`   // 客户端请求的Host（Forwarded host）
+	By     string   `json:"by,omitempty"`     // 接收客户端请求的代理接口（Forwarded by）
+}
+
+// 从请求中提取真实客户端IP和经过的代理链（从客户端一侧到本服务）
 func (pd *ProxyDetector) ExtractRealIP(r *http.Request) (string, []string) {
-	originalIP, _, _ := net.SplitHostPort(r.RemoteAddr)
-	
-	var proxyChain []string
-	var realIP string
+	address := pd.Resolve(r)
+	return address.IP, address.Chain
+}
 
-	// 如果直连的IP不是可信代理，直接返回
-	if !pd.IsTrustedProxy(originalIP) {
-		return originalIP, nil
-	}
+// 解析客户端地址。只有直连地址是可信代理时才采信转发头，否则任何客户端都能通过请求头伪造IP；
+// header_proxies中的头只在直连地址属于该头的地址段时采信
+func (pd *ProxyDetector) Resolve(r *http.Request) ClientAddress {
+	remoteIP := remoteHost(r.RemoteAddr)
+	trusted := pd.IsTrustedProxy(remoteIP)
 
-	// 按优先级检查头
 	for _, header := range pd.headersByPriority {
-		value := r.Header.Get(header)
-		if value == "" {
+		if networks, ok := pd.headerNets[header]; ok {
+			if !containsIP(networks, remoteIP) {
+				continue
+			}
+		} else if !trusted {
 			continue
 		}
-
-		ips := pd.parseIPsFromHeader(value, header)
-		if len(ips) == 0 {
+		values := r.Header.Values(header)
+		if len(values) == 0 {
 			continue
 		}
 
-		// 构建代理链
-		proxyChain = append(proxyChain, ips...)
-
-		// 从右到左找第一个非可信代理的IP作为真实客户端IP
-		for i := len(ips) - 1; i >= 0; i-- {
-			ip := strings.TrimSpace(ips[i])
-			if ip != "" && net.ParseIP(ip) != nil {
-				if !pd.IsTrustedProxy(ip) {
-					realIP = ip
-					break
-				}
+		if header == "Forwarded" {
+			if address, ok := pd.resolveForwarded(values, remoteIP); ok {
+				return address
 			}
+			continue
 		}
 
-		if realIP != "" {
-			break
+		hops := pd.parseIPsFromHeader(strings.Join(values, ","), header)
+		if i, chain, ok := pd.walkChain(hops, remoteIP); ok {
+			return ClientAddress{IP: normalizeIP(hops[i]), Chain: chain, Header: header}
 		}
 	}
 
-	// 如果没找到真实IP，使用第一个有效IP
-	if realIP == "" && len(proxyChain) > 0 {
-		for _, ip := range proxyChain {
-			ip = strings.TrimSpace(ip)
-			if ip != "" && net.ParseIP(ip) != nil {
-				if pd.config.SkipPrivateRanges {
-					parsedIP := net.ParseIP(ip)
-					if !isPrivateIP(parsedIP) {
-						realIP = ip
-						break
-					}
-				} else {
-					realIP = ip
-					break
-				}
-			}
+	return ClientAddress{IP: remoteIP}
+}
+
+// 按RFC 7239解析Forwarded头，客户端所在元素由可信代理追加，其proto/host/by同样可信
+func (pd *ProxyDetector) resolveForwarded(values []string, remoteIP string) (ClientAddress, bool) {
+	results := parseForwardedElements(values)
+	if len(results) == 0 {
+		return ClientAddress{}, false
+	}
+
+	// 出错的元素视为无效地址，只有遍历到它时才放弃该头
+	hops := make([]string, len(results))
+	for i, result := range results {
+		if result.err == nil {
+			hops[i] = result.element.For.Address()
+		}
+	}
+	i, chain, ok := pd.walkChain(hops, remoteIP)
+	if !ok {
+		return ClientAddress{}, false
+	}
+
+	client := results[i].element
+	return ClientAddress{
+		IP:     normalizeIP(hops[i]),
+		Chain:  chain,
+		Header: "Forwarded",
+		Proto:  client.Proto,
+		Host:   client.Host,
+		By:     client.By.Address(),
+	}, true
+}
+
+// 从右到左遍历转发链：右侧由离本服务最近的代理追加，只有可信代理追加的值可信，
+// 跳过可信代理后遇到的第一个地址即为客户端，左侧客户端自填的内容全部忽略。
+// 返回客户端在hops中的下标
+func (pd *ProxyDetector) walkChain(hops []string, remoteIP string) (int, []string, bool) {
+	chain := []string{remoteIP}
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := normalizeIP(hops[i])
+		if ip == "" {
+			// 可信代理追加了无法解析的地址（如unknown或混淆标识），放弃该头
+			return 0, nil, false
+		}
+		if i == 0 || !pd.isInternalHop(ip) || len(chain) >= pd.config.MaxProxyDepth {
+			return i, chain, true
 		}
+		chain = append([]string{ip}, chain...)
 	}
+	return 0, nil, false
+}
 
-	// 如果还是没找到，使用原始IP
-	if realIP == "" {
-		realIP = originalIP
+// 是否为可跳过的内部代理
+func (pd *ProxyDetector) isInternalHop(ipStr string) bool {
+	if pd.IsTrustedProxy(ipStr) {
+		return true
 	}
+	return pd.config.SkipPrivateRanges && isPrivateIP(net.ParseIP(ipStr))
+}
 
-	// 限制代理链深度
-	if len(proxyChain) > pd.config.MaxProxyDepth {
-		proxyChain = proxyChain[:pd.config.MaxProxyDepth]
+// 取RemoteAddr中的主机部分，没有端口时原样返回
+func remoteHost(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return strings.Trim(remoteAddr, "[]")
 	}
+	return host
+}
 
-	return realIP, proxyChain
+// 规范化转发头中的地址：去掉引号、端口和IPv6方括号，无效地址返回空
+func normalizeIP(value string) string {
+	value = strings.Trim(strings.TrimSpace(value), "\"'")
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+	ip := net.ParseIP(strings.Trim(value, "[]"))
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
 }
 
 // 从头中解析IP列表
 func (pd *ProxyDetector) parseIPsFromHeader(value, header string) []string {
 	var ips []string
 
-	switch header {
+	switch http.CanonicalHeaderKey(header) {
 	case "X-Forwarded-For":
 		// X-Forwarded-For: client, proxy1, proxy2
 		parts := strings.Split(value, ",")
@@ -220,24 +343,6 @@ func (pd *ProxyDetector) parseIPsFromHeader(value, header string) []string {
 			}
 		}
 
-	case "Forwarded":
-		// RFC 7239: Forwarded: for=192.0.2.60;proto=http;by=203.0.113.43
-		parts := strings.Split(value, ",")
-		for _, part := range parts {
-			part = strings.TrimSpace(part)
-			if strings.Contains(part, "for=") {
-				forStart := strings.Index(part, "for=") + 4
-				forEnd := strings.Index(part[forStart:], ";")
-				if forEnd == -1 {
-					forEnd = len(part[forStart:])
-				}
-				forValue := strings.Trim(part[forStart:forStart+forEnd], "\"'")
-				if forValue != "" {
-					ips = append(ips, forValue)
-				}
-			}
-		}
-
 	default:
 		// 其他头通常只包含单个IP
 		ip := strings.TrimSpace(value)
@@ -277,8 +382,9 @@ func isPrivateIP(ip net.IP) bool {
 
 // 获取代理检测报告
 func (pd *ProxyDetector) GetProxyReport(r *http.Request) map[string]interface{} {
-	originalIP, _, _ := net.SplitHostPort(r.RemoteAddr)
-	realIP, proxyChain := pd.ExtractRealIP(r)
+	originalIP := remoteHost(r.RemoteAddr)
+	address := pd.Resolve(r)
+	realIP, proxyChain := address.IP, address.Chain
 	
 	report := map[string]interface{}{
 		"original_ip":     originalIP,
@@ -288,6 +394,17 @@ func (pd *ProxyDetector) GetProxyReport(r *http.Request) map[string]interface{}
 		"is_behind_proxy": len(proxyChain) > 0,
 		"is_trusted_proxy": pd.IsTrustedProxy(originalIP),
 		"proxy_headers":   make(map[string]string),
+		"resolved":        address,
+	}
+
+	// RFC 7239 Forwarded头的解析结果
+	if values := r.Header.Values("Forwarded"); len(values) > 0 {
+		elements, err := ParseForwarded(values)
+		if err != nil {
+			report["forwarded_error"] = err.Error()
+		} else {
+			report["forwarded"] = elements
+		}
 	}
 
 	// 收集代理头信息
@@ -0,0 +1,106 @@
+package collector
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestResolveDefaultHeaders(t *testing.T) {
+	detector, err := NewProxyDetector(ProxyConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 默认配置下内网代理转发的CDN头不被采信，只使用X-Forwarded-For
+	req := &http.Request{RemoteAddr: "10.0.0.1:1234", Header: http.Header{}}
+	req.Header.Set("CF-Connecting-IP", "1.2.3.4")
+	req.Header.Set("True-Client-IP", "1.2.3.5")
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	if got := detector.Resolve(req); got.IP != "198.51.100.1" || got.Header != "X-Forwarded-For" {
+		t.Errorf("Resolve() = %+v, 期望采信X-Forwarded-For", got)
+	}
+}
+
+func TestResolveHeaderProxies(t *testing.T) {
+	detector, err := NewProxyDetector(ProxyConfig{
+		TrustedProxies: []string{"10.0.0.0/8"},
+		TrustedHeaders: []string{"X-Forwarded-For", "CF-Connecting-IP"},
+		HeaderPriority: map[string]int{"CF-Connecting-IP": 100, "X-Forwarded-For": 70},
+		HeaderProxies:  map[string][]string{"cf-connecting-ip": {"173.245.48.0/20", "2400:cb00::/32"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		cf         string
+		xff        string
+		want       ClientAddress
+	}{
+		{
+			name:       "CDN回源地址采信CDN头",
+			remoteAddr: "173.245.48.10:443",
+			cf:         "198.51.100.1",
+			xff:        "1.1.1.1",
+			want:       ClientAddress{IP: "198.51.100.1", Chain: []string{"173.245.48.10"}, Header: "Cf-Connecting-Ip"},
+		},
+		{
+			name:       "IPv6回源地址",
+			remoteAddr: "[2400:cb00::1]:443",
+			cf:         "2001:db8::7",
+			want:       ClientAddress{IP: "2001:db8::7", Chain: []string{"2400:cb00::1"}, Header: "Cf-Connecting-Ip"},
+		},
+		{
+			name:       "可信代理转发的CDN头不采信",
+			remoteAddr: "10.0.0.1:1234",
+			cf:         "1.2.3.4",
+			xff:        "198.51.100.2",
+			want:       ClientAddress{IP: "198.51.100.2", Chain: []string{"10.0.0.1"}, Header: "X-Forwarded-For"},
+		},
+		{
+			name:       "公网直连伪造CDN头",
+			remoteAddr: "203.0.113.9:1234",
+			cf:         "1.2.3.4",
+			want:       ClientAddress{IP: "203.0.113.9"},
+		},
+		{
+			name:       "CDN回源地址不采信X-Forwarded-For",
+			remoteAddr: "173.245.48.10:443",
+			xff:        "1.2.3.4",
+			want:       ClientAddress{IP: "173.245.48.10"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &http.Request{RemoteAddr: tt.remoteAddr, Header: http.Header{}}
+			if tt.cf != "" {
+				req.Header.Set("CF-Connecting-IP", tt.cf)
+			}
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if got := detector.Resolve(req); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Resolve() = %+v, 期望 %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewProxyDetectorRequiresCDNRanges(t *testing.T) {
+	for _, header := range []string{"CF-Connecting-IP", "true-client-ip"} {
+		_, err := NewProxyDetector(ProxyConfig{TrustedHeaders: []string{"X-Forwarded-For", header}})
+		if err == nil {
+			t.Errorf("%s未配置header_proxies时应返回错误", header)
+		}
+	}
+	if _, err := NewProxyDetector(ProxyConfig{HeaderProxies: map[string][]string{"CF-Connecting-IP": {}}}); err == nil {
+		t.Error("header_proxies地址段为空时应返回错误")
+	}
+	if _, err := NewProxyDetector(ProxyConfig{HeaderProxies: map[string][]string{"CF-Connecting-IP": {"not-a-cidr"}}}); err == nil {
+		t.Error("无效地址段应返回错误")
+	}
+}
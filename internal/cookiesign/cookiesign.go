@@ -0,0 +1,80 @@
+package cookiesign
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// 签名无效或值被篡改
+var ErrInvalidSignature = errors.New("签名无效")
+
+// HMAC签名，用于令牌和cookie等需要防篡改的值
+type Signer struct {
+	key []byte
+}
+
+// 创建签名器，secret为空时随机生成密钥（重启后已签发的值失效）
+func NewSigner(secret string) (*Signer, error) {
+	if secret != "" {
+		return &Signer{key: []byte(secret)}, nil
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("生成签名密钥失败: %v", err)
+	}
+	return &Signer{key: key}, nil
+}
+
+// 计算HMAC-SHA256
+func (s *Signer) MAC(data string) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// 对JSON载荷签名，结果为 base64(载荷).base64(签名)。
+// purpose区分用途，不同用途的签名互不通用
+func (s *Signer) Seal(purpose string, payload interface{}) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.MAC(purpose+"|"+encoded)), nil
+}
+
+// 验证签名并解码载荷
+func (s *Signer) Open(purpose, value string, payload interface{}) error {
+	encoded, signature, ok := strings.Cut(value, ".")
+	if !ok {
+		return ErrInvalidSignature
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, s.MAC(purpose+"|"+encoded)) {
+		return ErrInvalidSignature
+	}
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	return json.Unmarshal(data, payload)
+}
+
+// 客户端所在网段：IPv4取/24，IPv6取/64，无法解析时原样返回
+func Network(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return parsed.Mask(net.CIDRMask(64, 128)).String()
+}
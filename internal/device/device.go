@@ -0,0 +1,182 @@
+package device
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"securefingerprint/internal/cookiesign"
+)
+
+// 设备cookie配置
+type Config struct {
+	Enabled         bool          `yaml:"enabled"`
+	Secret          string        `yaml:"secret"` // 签名密钥，为空时每次启动随机生成（重启后所有设备重新分配ID）
+	CookieName      string        `yaml:"cookie_name"`
+	MaxAge          time.Duration `yaml:"max_age"`          // cookie有效期，超过后重新分配设备ID
+	RotateInterval  time.Duration `yaml:"rotate_interval"`  // 重新签发cookie的间隔，活跃设备的有效期随之延长
+	LinkTTL         time.Duration `yaml:"link_ttl"`         // 设备ID与身份的关联保留时间
+	ReplayWindow    time.Duration `yaml:"replay_window"`    // 统计同一设备ID出现网段数的时间窗口
+	ReplayThreshold int           `yaml:"replay_threshold"` // 窗口内不同网段数超过该值视为cookie重放
+}
+
+// 默认设备cookie配置
+var DefaultConfig = Config{
+	Enabled:         false,
+	CookieName:      "fw_did",
+	MaxAge:          30 * 24 * time.Hour,
+	RotateInterval:  24 * time.Hour,
+	LinkTTL:         30 * 24 * time.Hour,
+	ReplayWindow:    time.Hour,
+	ReplayThreshold: 5,
+}
+
+// 设备关联状态存储，RedisClient为生产实现
+type Store interface {
+	// 关联设备ID与指纹，设备ID已有关联时返回已关联的指纹
+	LinkDevice(deviceID, fingerprint string, ttl time.Duration) (string, error)
+	// 记录设备ID出现的网段，返回窗口内的不同网段数
+	TrackDeviceNetwork(deviceID, network string, window time.Duration) (int, error)
+}
+
+// 签名用途
+const purposeDevice = "device"
+
+// 设备cookie内容
+type claims struct {
+	ID          string `json:"d"`
+	Fingerprint string `json:"f"` // 签发时的指纹，设备首次关联时作为身份
+	Created     int64  `json:"c"`
+	Issued      int64  `json:"t"`
+}
+
+// 请求对应的身份
+type Identity struct {
+	DeviceID    string `json:"device_id"`
+	Fingerprint string `json:"fingerprint"` // 身份指纹，评分、封禁和行为分析使用
+	Linked      bool   `json:"linked"`      // 请求携带了有效的设备cookie，身份为签发该cookie时的指纹
+	Networks    int    `json:"networks"`    // 重放窗口内该设备ID出现的不同网段数
+	Replay      bool   `json:"replay"`      // 设备cookie在过多网段中出现，疑似被复制重放
+}
+
+// 设备ID管理：签发和轮换cookie，把同一设备的不同指纹关联为一个身份
+type Manager struct {
+	config Config
+	signer *cookiesign.Signer
+	store  Store
+	now    func() time.Time
+}
+
+// 创建设备ID管理器
+func NewManager(config Config, store Store) (*Manager, error) {
+	if config.CookieName == "" {
+		config.CookieName = DefaultConfig.CookieName
+	}
+	if config.MaxAge <= 0 {
+		config.MaxAge = DefaultConfig.MaxAge
+	}
+	if config.RotateInterval <= 0 {
+		config.RotateInterval = DefaultConfig.RotateInterval
+	}
+	if config.LinkTTL <= 0 {
+		config.LinkTTL = DefaultConfig.LinkTTL
+	}
+	if config.ReplayWindow <= 0 {
+		config.ReplayWindow = DefaultConfig.ReplayWindow
+	}
+	if config.ReplayThreshold <= 0 {
+		config.ReplayThreshold = DefaultConfig.ReplayThreshold
+	}
+	if config.Secret == "" {
+		log.Printf("设备cookie未配置签名密钥，使用随机密钥，重启后所有设备重新分配ID")
+	}
+
+	signer, err := cookiesign.NewSigner(config.Secret)
+	if err != nil {
+		return nil, err
+	}
+	return &Manager{config: config, signer: signer, store: store, now: time.Now}, nil
+}
+
+// 解析请求的设备身份，需要时通过w签发或轮换cookie。
+// ip为经可信代理解析后的客户端IP，fingerprint为本次请求生成的指纹。
+// 没有有效cookie时身份就是fingerprint；存储出错时同样退回fingerprint并返回错误
+func (m *Manager) Resolve(w http.ResponseWriter, r *http.Request, ip, fingerprint string) (*Identity, error) {
+	now := m.now()
+	c, ok := m.readCookie(r, now)
+	if !ok {
+		// 新设备只签发cookie，不写存储：不保存cookie的客户端不会产生关联记录
+		id, err := newDeviceID()
+		if err != nil {
+			return &Identity{Fingerprint: fingerprint}, err
+		}
+		m.setCookie(w, r, claims{ID: id, Fingerprint: fingerprint, Created: now.Unix(), Issued: now.Unix()})
+		return &Identity{DeviceID: id, Fingerprint: fingerprint}, nil
+	}
+
+	if now.Sub(time.Unix(c.Issued, 0)) >= m.config.RotateInterval {
+		c.Issued = now.Unix()
+		m.setCookie(w, r, c)
+	}
+
+	identity := &Identity{DeviceID: c.ID, Fingerprint: fingerprint}
+	linked, err := m.store.LinkDevice(c.ID, c.Fingerprint, m.config.LinkTTL)
+	if err != nil {
+		return identity, fmt.Errorf("关联设备身份失败: %v", err)
+	}
+	identity.Fingerprint = linked
+	identity.Linked = true
+
+	networks, err := m.store.TrackDeviceNetwork(c.ID, cookiesign.Network(ip), m.config.ReplayWindow)
+	if err != nil {
+		return identity, fmt.Errorf("记录设备网段失败: %v", err)
+	}
+	identity.Networks = networks
+	identity.Replay = networks > m.config.ReplayThreshold
+	return identity, nil
+}
+
+// 读取并校验设备cookie
+func (m *Manager) readCookie(r *http.Request, now time.Time) (claims, bool) {
+	var c claims
+	cookie, err := r.Cookie(m.config.CookieName)
+	if err != nil {
+		return c, false
+	}
+	if err := m.signer.Open(purposeDevice, cookie.Value, &c); err != nil || c.ID == "" {
+		return c, false
+	}
+	if now.Sub(time.Unix(c.Issued, 0)) > m.config.MaxAge {
+		return c, false
+	}
+	return c, true
+}
+
+func (m *Manager) setCookie(w http.ResponseWriter, r *http.Request, c claims) {
+	value, err := m.signer.Seal(purposeDevice, c)
+	if err != nil {
+		log.Printf("签发设备cookie失败: %v", err)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     m.config.CookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   int(m.config.MaxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// 随机生成128位设备ID
+func newDeviceID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("生成设备ID失败: %v", err)
+	}
+	return hex.EncodeToString(id), nil
+}
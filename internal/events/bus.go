@@ -0,0 +1,488 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"securefingerprint/internal/metrics"
+)
+
+// 订阅者处理过慢、缓冲区溢出时断开订阅
+var ErrSlowConsumer = errors.New("订阅者处理过慢，已断开，请使用游标重新订阅")
+
+// 决策事件
+type Event struct {
+	ID           uint64    `json:"id"`       // 事件流内单调递增的序号，启用Redis时由所有实例共享
+	Cursor       Cursor    `json:"cursor"`   // 恢复订阅用的游标，Redis不可用时本实例直接推送的事件没有游标
+	Instance     string    `json:"instance"` // 产生事件的实例
+	Timestamp    time.Time `json:"timestamp"`
+	Fingerprint  string    `json:"fingerprint"`
+	IP           string    `json:"ip"`
+	Method       string    `json:"method"`
+	Path         string    `json:"path"`
+	UserAgent    string    `json:"user_agent"`
+	Action       string    `json:"action"`
+	Category     string    `json:"category"`
+	Reason       string    `json:"reason"`
+	Score        int       `json:"score"`
+	RiskLevel    string    `json:"risk_level"`
+	RiskScore    float64   `json:"risk_score"`
+	Degraded     bool      `json:"degraded"`
+	ShadowSource string    `json:"shadow_source,omitempty"` // 影子模式下未执行的决策来源，多个时为第一个（关闭影子模式后会执行的决策）
+	ShadowAction string    `json:"shadow_action,omitempty"` // 影子模式下本应执行的动作
+}
+
+// 订阅游标：事件流和流内序号，格式为 <stream>:<id>
+// 启用Redis时事件流为广播频道，各实例共享序号，游标可在任一实例上恢复；否则为本实例
+type Cursor struct {
+	Stream string
+	ID     uint64
+}
+
+// 解析游标，没有事件流部分的旧格式游标视为不属于任何事件流
+func ParseCursor(raw string) (Cursor, error) {
+	var cursor Cursor
+	rawID := raw
+	if i := strings.LastIndex(raw, ":"); i >= 0 {
+		cursor.Stream, rawID = raw[:i], raw[i+1:]
+	}
+	id, err := strconv.ParseUint(rawID, 10, 64)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("无效的游标: %s", raw)
+	}
+	cursor.ID = id
+	return cursor, nil
+}
+
+func (c Cursor) String() string {
+	if c.ID == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", c.Stream, c.ID)
+}
+
+func (c Cursor) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c *Cursor) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*c = Cursor{}
+		return nil
+	}
+	cursor, err := ParseCursor(string(text))
+	if err != nil {
+		return err
+	}
+	*c = cursor
+	return nil
+}
+
+// 事件总线配置
+type Config struct {
+	BufferSize       int           `yaml:"buffer_size"`       // 环形缓冲区和Redis历史列表大小，决定可恢复的历史事件数
+	SubscriberBuffer int           `yaml:"subscriber_buffer"` // 每个订阅者的发送缓冲
+	Heartbeat        time.Duration `yaml:"heartbeat"`         // 心跳间隔
+	RedisChannel     string        `yaml:"redis_channel"`     // 跨实例广播的Redis频道，序号和历史列表存放在 <频道>:seq 和 <频道>:history，为空时不广播
+}
+
+// 默认事件总线配置
+var DefaultConfig = Config{
+	BufferSize:       10000,
+	SubscriberBuffer: 256,
+	Heartbeat:        15 * time.Second,
+	RedisChannel:     "firewall:events",
+}
+
+// 从Redis历史列表补齐事件的超时
+const historyTimeout = 3 * time.Second
+
+// 跨实例广播使用的发布订阅接口
+type PubSub interface {
+	// 原子地分配全局序号、写入历史列表并发布，消息格式为 <序号>:<payload>
+	PublishSequenced(ctx context.Context, channel, seqKey, historyKey string, payload []byte, maxLen int) (uint64, error)
+	Subscribe(ctx context.Context, channel string) (<-chan []byte, func() error)
+	// 读取历史列表，最新的在前
+	ListRange(ctx context.Context, key string, limit int) ([][]byte, error)
+}
+
+// 事件总线：本地环形缓冲 + 订阅者扇出 + Redis跨实例广播
+// 启用Redis时所有事件（包括本实例的）都经Redis分配序号后再分发，各实例的缓冲区和游标一致
+type Bus struct {
+	config   Config
+	instance string
+	stream   string // 游标所属的事件流
+
+	mu     sync.Mutex
+	ring   []Event // 按序号升序的环形缓冲
+	head   int     // 缓冲区写满后最早事件的下标
+	lastID uint64  // 最近写入缓冲的序号
+	subs   map[*Subscription]struct{}
+
+	pubsub    PubSub
+	outbox    chan Event
+	cancel    context.CancelFunc
+	done      chan struct{}
+	closed    bool
+	closeOnce sync.Once
+}
+
+// 订阅
+type Subscription struct {
+	C      <-chan Event
+	Gap    bool // 游标早于可恢复的历史或不属于当前事件流，部分事件可能已丢失
+	ch     chan Event
+	filter Filter
+	bus    *Bus
+	err    error
+	once   sync.Once
+}
+
+// 创建事件总线，pubsub为nil时只在本实例内分发
+func NewBus(config Config, pubsub PubSub) *Bus {
+	if config.BufferSize <= 0 {
+		config.BufferSize = DefaultConfig.BufferSize
+	}
+	if config.SubscriberBuffer <= 0 {
+		config.SubscriberBuffer = DefaultConfig.SubscriberBuffer
+	}
+	if config.Heartbeat <= 0 {
+		config.Heartbeat = DefaultConfig.Heartbeat
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &Bus{
+		config:   config,
+		instance: instanceID(),
+		ring:     make([]Event, 0, config.BufferSize),
+		subs:     make(map[*Subscription]struct{}),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+
+	b.stream = b.instance
+	if pubsub != nil && config.RedisChannel != "" {
+		b.stream = config.RedisChannel
+		b.pubsub = pubsub
+		b.outbox = make(chan Event, config.SubscriberBuffer*4)
+		// 同步订阅频道，创建后发布的事件不会遗漏
+		messages, unsubscribe := pubsub.Subscribe(ctx, config.RedisChannel)
+		go b.runRemote(ctx, messages, unsubscribe)
+	} else {
+		close(b.done)
+	}
+
+	return b
+}
+
+func instanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// 心跳间隔
+func (b *Bus) Heartbeat() time.Duration {
+	return b.config.Heartbeat
+}
+
+// 发布决策事件，不会阻塞调用方
+func (b *Bus) Publish(event Event) {
+	event.Instance = b.instance
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	if b.outbox == nil {
+		b.mu.Lock()
+		event.ID = b.lastID + 1
+		b.dispatchLocked(event)
+		b.mu.Unlock()
+		return
+	}
+
+	select {
+	case b.outbox <- event:
+	default:
+		metrics.EventsDropped.WithLabelValues("redis").Inc()
+		b.dispatch(event)
+	}
+}
+
+// 分发事件，ID为0的事件（Redis不可用时本实例直接推送）不写入缓冲，也无法凭游标恢复
+func (b *Bus) dispatch(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.dispatchLocked(event)
+}
+
+// 写入环形缓冲并分发给本地订阅者
+func (b *Bus) dispatchLocked(event Event) {
+	if b.closed {
+		return
+	}
+
+	if event.ID > 0 {
+		if event.ID <= b.lastID {
+			// 序号回退说明Redis中的计数已重置，旧序号不再对应同一事件
+			log.Printf("事件序号回退(%d <= %d)，Redis数据可能已重置，清空事件缓冲", event.ID, b.lastID)
+			b.ring, b.head = b.ring[:0], 0
+		}
+		event.Cursor = Cursor{Stream: b.stream, ID: event.ID}
+		b.lastID = event.ID
+		if len(b.ring) < b.config.BufferSize {
+			b.ring = append(b.ring, event)
+		} else {
+			b.ring[b.head] = event
+			b.head = (b.head + 1) % len(b.ring)
+		}
+	}
+
+	for sub := range b.subs {
+		if !sub.filter.Match(&event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			// 慢订阅者直接断开，客户端凭游标从缓冲区补齐
+			metrics.EventsDropped.WithLabelValues("subscriber").Inc()
+			b.removeLocked(sub, ErrSlowConsumer)
+		}
+	}
+}
+
+// 订阅事件，游标属于当前事件流时先补发序号大于游标的事件。
+// 本地缓冲不足时（例如重连到了另一个实例）从Redis历史列表补齐
+func (b *Bus) Subscribe(filter Filter, cursor Cursor) *Subscription {
+	var history []Event
+	if cursor.ID > 0 && cursor.Stream == b.stream && b.pubsub != nil {
+		b.mu.Lock()
+		_, gap := b.backlogLocked(cursor.ID, nil)
+		b.mu.Unlock()
+		if gap {
+			history = b.loadHistory()
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	gap := false
+	var backlog []Event
+	if cursor.ID > 0 {
+		if cursor.Stream != b.stream {
+			gap = true
+		} else {
+			backlog, gap = b.backlogLocked(cursor.ID, history)
+		}
+	}
+
+	ch := make(chan Event, b.config.SubscriberBuffer+len(backlog))
+	sub := &Subscription{C: ch, Gap: gap, ch: ch, filter: filter, bus: b}
+
+	for _, event := range backlog {
+		if filter.Match(&event) {
+			ch <- event
+		}
+	}
+
+	if b.closed {
+		sub.close(nil)
+		return sub
+	}
+	b.subs[sub] = struct{}{}
+	metrics.EventSubscribers.Inc()
+	return sub
+}
+
+// 合并历史事件和缓冲区，按序号返回大于cursor的事件；
+// 紧接游标的事件已不可得，或游标晚于已知的最新事件时gap为true
+func (b *Bus) backlogLocked(cursor uint64, history []Event) ([]Event, bool) {
+	events := history
+	var last uint64
+	if len(history) > 0 {
+		last = history[len(history)-1].ID
+	}
+	for i := 0; i < len(b.ring); i++ {
+		if event := b.ring[(b.head+i)%len(b.ring)]; event.ID > last {
+			events = append(events, event)
+		}
+	}
+
+	if len(events) == 0 || cursor > events[len(events)-1].ID {
+		return nil, true
+	}
+	gap := cursor+1 < events[0].ID
+
+	var backlog []Event
+	for _, event := range events {
+		if event.ID > cursor {
+			backlog = append(backlog, event)
+		}
+	}
+	return backlog, gap
+}
+
+// 从Redis历史列表读取事件，按序号升序返回，失败时返回nil
+func (b *Bus) loadHistory() []Event {
+	ctx, cancel := context.WithTimeout(context.Background(), historyTimeout)
+	defer cancel()
+
+	messages, err := b.pubsub.ListRange(ctx, b.historyKey(), b.config.BufferSize)
+	if err != nil {
+		log.Printf("读取事件历史失败: %v", err)
+		return nil
+	}
+
+	events := make([]Event, 0, len(messages))
+	for i := len(messages) - 1; i >= 0; i-- {
+		event, err := b.decode(messages[i])
+		if err != nil {
+			continue
+		}
+		if len(events) > 0 && event.ID <= events[len(events)-1].ID {
+			// 计数重置前的旧事件
+			events = events[:0]
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func (b *Bus) seqKey() string {
+	return b.config.RedisChannel + ":seq"
+}
+
+func (b *Bus) historyKey() string {
+	return b.config.RedisChannel + ":history"
+}
+
+// 解析 <序号>:<事件JSON> 格式的Redis消息
+func (b *Bus) decode(message []byte) (Event, error) {
+	rawID, payload, ok := strings.Cut(string(message), ":")
+	if !ok {
+		return Event{}, fmt.Errorf("无效的事件消息")
+	}
+	id, err := strconv.ParseUint(rawID, 10, 64)
+	if err != nil || id == 0 {
+		return Event{}, fmt.Errorf("无效的事件序号: %s", rawID)
+	}
+	var event Event
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		return Event{}, err
+	}
+	event.ID = id
+	event.Cursor = Cursor{Stream: b.stream, ID: id}
+	return event, nil
+}
+
+func (b *Bus) removeLocked(sub *Subscription, err error) {
+	if _, ok := b.subs[sub]; !ok {
+		return
+	}
+	delete(b.subs, sub)
+	metrics.EventSubscribers.Dec()
+	sub.close(err)
+}
+
+// 取消订阅
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.removeLocked(s, nil)
+}
+
+// 订阅结束原因，C关闭后有效
+func (s *Subscription) Err() error {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	return s.err
+}
+
+func (s *Subscription) close(err error) {
+	s.once.Do(func() {
+		s.err = err
+		close(s.ch)
+	})
+}
+
+// 将本实例事件经Redis分配序号后发布，并按序号分发收到的所有事件
+func (b *Bus) runRemote(ctx context.Context, messages <-chan []byte, unsubscribe func() error) {
+	defer close(b.done)
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-b.outbox:
+			payload, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			if _, err := b.pubsub.PublishSequenced(ctx, b.config.RedisChannel, b.seqKey(), b.historyKey(), payload, b.config.BufferSize); err != nil {
+				// Redis不可用时只推送给本实例的订阅者
+				metrics.EventsDropped.WithLabelValues("redis").Inc()
+				event.ID = 0
+				b.dispatch(event)
+			}
+		case message, ok := <-messages:
+			if !ok {
+				return
+			}
+			event, err := b.decode(message)
+			if err != nil {
+				log.Printf("解析远程事件失败: %v", err)
+				continue
+			}
+			b.receive(event)
+		}
+	}
+}
+
+// 分发收到的事件，订阅断开期间错过的事件先从历史列表补齐
+func (b *Bus) receive(event Event) {
+	b.mu.Lock()
+	last := b.lastID
+	b.mu.Unlock()
+
+	if last > 0 && event.ID > last+1 {
+		for _, missed := range b.loadHistory() {
+			if missed.ID > last && missed.ID < event.ID {
+				b.dispatch(missed)
+			}
+		}
+	}
+	b.dispatch(event)
+}
+
+// 关闭总线，断开所有订阅者
+func (b *Bus) Close(ctx context.Context) error {
+	b.closeOnce.Do(func() {
+		b.mu.Lock()
+		b.closed = true
+		for sub := range b.subs {
+			b.removeLocked(sub, nil)
+		}
+		b.mu.Unlock()
+		b.cancel()
+	})
+
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
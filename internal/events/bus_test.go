@@ -0,0 +1,160 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 内存中的Redis替身：共享序号、历史列表和频道广播
+type fakeRedis struct {
+	mu      sync.Mutex
+	seq     uint64
+	history [][]byte
+	subs    []chan []byte
+}
+
+func (f *fakeRedis) PublishSequenced(ctx context.Context, channel, seqKey, historyKey string, payload []byte, maxLen int) (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.seq++
+	message := []byte(fmt.Sprintf("%d:%s", f.seq, payload))
+	f.history = append([][]byte{message}, f.history...)
+	if len(f.history) > maxLen {
+		f.history = f.history[:maxLen]
+	}
+	for _, sub := range f.subs {
+		sub <- message
+	}
+	return f.seq, nil
+}
+
+func (f *fakeRedis) Subscribe(ctx context.Context, channel string) (<-chan []byte, func() error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ch := make(chan []byte, 1024)
+	f.subs = append(f.subs, ch)
+	return ch, func() error { return nil }
+}
+
+func (f *fakeRedis) ListRange(ctx context.Context, key string, limit int) ([][]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if limit > len(f.history) {
+		limit = len(f.history)
+	}
+	return append([][]byte(nil), f.history[:limit]...), nil
+}
+
+// 接收n个事件
+func receive(t *testing.T, sub *Subscription, n int) []Event {
+	t.Helper()
+	var events []Event
+	for len(events) < n {
+		select {
+		case event := <-sub.C:
+			events = append(events, event)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("只收到%d个事件, 期望%d个", len(events), n)
+		}
+	}
+	return events
+}
+
+func TestResumeAcrossInstances(t *testing.T) {
+	redis := &fakeRedis{}
+	config := Config{BufferSize: 5, RedisChannel: "firewall:events"}
+	a := NewBus(config, redis)
+	defer a.Close(context.Background())
+
+	sub := a.Subscribe(Filter{}, Cursor{})
+	for i := 0; i < 4; i++ {
+		a.Publish(Event{Path: fmt.Sprintf("/%d", i)})
+	}
+	received := receive(t, sub, 4)
+	sub.Close()
+	cursor := received[1].Cursor
+
+	// 游标属于共享事件流，在刚启动、缓冲区为空的另一个实例上从Redis历史补齐
+	b := NewBus(config, redis)
+	defer b.Close(context.Background())
+	resumed := b.Subscribe(Filter{}, cursor)
+	defer resumed.Close()
+	if resumed.Gap {
+		t.Errorf("Gap = true, 期望从历史列表恢复")
+	}
+	backlog := receive(t, resumed, 2)
+	if backlog[0].Path != "/2" || backlog[1].Path != "/3" || backlog[1].Cursor != received[3].Cursor {
+		t.Errorf("补发事件 = %+v, 期望/2和/3", backlog)
+	}
+
+	// 之后的事件在两个实例上序号一致
+	a.Publish(Event{Path: "/4"})
+	if event := receive(t, resumed, 1)[0]; event.ID != 5 || event.Cursor.String() != "firewall:events:5" {
+		t.Errorf("新事件游标 = %s, 期望firewall:events:5", event.Cursor)
+	}
+}
+
+func TestResumeGap(t *testing.T) {
+	tests := []struct {
+		name    string
+		cursor  Cursor
+		gap     bool
+		backlog int
+	}{
+		{name: "缓冲区内的游标", cursor: Cursor{Stream: "firewall:events", ID: 6}, backlog: 2},
+		{name: "最新事件的游标", cursor: Cursor{Stream: "firewall:events", ID: 8}},
+		{name: "早于历史列表的游标", cursor: Cursor{Stream: "firewall:events", ID: 1}, gap: true, backlog: 5},
+		{name: "晚于最新事件的游标", cursor: Cursor{Stream: "firewall:events", ID: 20}, gap: true},
+		{name: "其他事件流的游标", cursor: Cursor{Stream: "host-1", ID: 6}, gap: true},
+	}
+
+	redis := &fakeRedis{}
+	bus := NewBus(Config{BufferSize: 5, RedisChannel: "firewall:events"}, redis)
+	defer bus.Close(context.Background())
+	sub := bus.Subscribe(Filter{}, Cursor{})
+	for i := 0; i < 8; i++ {
+		bus.Publish(Event{})
+	}
+	receive(t, sub, 8)
+	sub.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := bus.Subscribe(Filter{}, tt.cursor)
+			defer sub.Close()
+			if sub.Gap != tt.gap {
+				t.Errorf("Gap = %v, 期望 %v", sub.Gap, tt.gap)
+			}
+			if len(sub.C) != tt.backlog {
+				t.Errorf("补发%d个事件, 期望%d个", len(sub.C), tt.backlog)
+			}
+		})
+	}
+}
+
+func TestLocalBus(t *testing.T) {
+	bus := NewBus(Config{BufferSize: 3}, nil)
+	defer bus.Close(context.Background())
+	for i := 0; i < 5; i++ {
+		bus.Publish(Event{})
+	}
+
+	cursor := Cursor{Stream: bus.instance, ID: 3}
+	sub := bus.Subscribe(Filter{}, cursor)
+	defer sub.Close()
+	if sub.Gap {
+		t.Errorf("Gap = true, 期望缓冲区覆盖游标")
+	}
+	if events := receive(t, sub, 2); events[0].ID != 4 || events[1].ID != 5 {
+		t.Errorf("补发事件序号 = %d,%d, 期望4,5", events[0].ID, events[1].ID)
+	}
+
+	old := bus.Subscribe(Filter{}, Cursor{Stream: bus.instance, ID: 1})
+	defer old.Close()
+	if !old.Gap || len(old.C) != 3 {
+		t.Errorf("Gap = %v, 补发%d个, 期望Gap并补发缓冲区中的3个", old.Gap, len(old.C))
+	}
+}
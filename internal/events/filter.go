@@ -0,0 +1,114 @@
+package events
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// 订阅过滤条件，空字段表示不过滤
+type Filter struct {
+	Actions     map[string]bool
+	Fingerprint string
+	Networks    []*net.IPNet
+	PathPrefix  string
+	MinRisk     float64
+}
+
+// 从查询参数解析过滤条件：action=ban,delay&fingerprint=...&ip=10.0.0.0/8&path_prefix=/api&min_risk=50
+func FilterFromQuery(values url.Values) (Filter, error) {
+	var filter Filter
+
+	for _, raw := range values["action"] {
+		for _, action := range strings.Split(raw, ",") {
+			action = strings.TrimSpace(action)
+			if action == "" {
+				continue
+			}
+			if filter.Actions == nil {
+				filter.Actions = make(map[string]bool)
+			}
+			filter.Actions[action] = true
+		}
+	}
+
+	filter.Fingerprint = values.Get("fingerprint")
+	filter.PathPrefix = values.Get("path_prefix")
+
+	for _, raw := range values["ip"] {
+		for _, value := range strings.Split(raw, ",") {
+			value = strings.TrimSpace(value)
+			if value == "" {
+				continue
+			}
+			network, err := parseNetwork(value)
+			if err != nil {
+				return filter, err
+			}
+			filter.Networks = append(filter.Networks, network)
+		}
+	}
+
+	if raw := values.Get("min_risk"); raw != "" {
+		minRisk, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return filter, fmt.Errorf("无效的min_risk: %s", raw)
+		}
+		filter.MinRisk = minRisk
+	}
+
+	return filter, nil
+}
+
+// 解析IP或CIDR，单个IP视为/32或/128
+func parseNetwork(value string) (*net.IPNet, error) {
+	if strings.Contains(value, "/") {
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("无效的CIDR: %s", value)
+		}
+		return network, nil
+	}
+
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, fmt.Errorf("无效的IP: %s", value)
+	}
+	bits := 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		bits = 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// 判断事件是否满足过滤条件
+func (f Filter) Match(event *Event) bool {
+	if len(f.Actions) > 0 && !f.Actions[event.Action] {
+		return false
+	}
+	if f.Fingerprint != "" && f.Fingerprint != event.Fingerprint {
+		return false
+	}
+	if f.PathPrefix != "" && !strings.HasPrefix(event.Path, f.PathPrefix) {
+		return false
+	}
+	if f.MinRisk > 0 && event.RiskScore < f.MinRisk {
+		return false
+	}
+	if len(f.Networks) > 0 {
+		ip := net.ParseIP(event.IP)
+		if ip == nil {
+			return false
+		}
+		for _, network := range f.Networks {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,206 @@
+package export
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"securefingerprint/internal/storage"
+)
+
+// 导出格式
+const (
+	FormatCSV    = "csv"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+)
+
+// 访问记录数据源
+type Source interface {
+	StreamAccessRecords(ctx context.Context, query *storage.AccessRecordQuery, fn func(*storage.AccessRecord) error) error
+}
+
+// 导出选项
+type Options struct {
+	Format string `json:"format"`
+	Gzip   bool   `json:"gzip"`
+}
+
+// 校验导出格式
+func (o Options) Validate() error {
+	switch o.Format {
+	case FormatCSV, FormatJSON, FormatNDJSON:
+		return nil
+	default:
+		return fmt.Errorf("不支持的导出格式，支持: csv, json, ndjson")
+	}
+}
+
+// 文件扩展名
+func (o Options) Extension() string {
+	if o.Gzip {
+		return o.Format + ".gz"
+	}
+	return o.Format
+}
+
+// 响应Content-Type
+func (o Options) ContentType() string {
+	if o.Gzip {
+		return "application/gzip"
+	}
+	switch o.Format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	default:
+		return "application/json"
+	}
+}
+
+// 记录编码器
+type encoder interface {
+	begin() error
+	write(record *storage.AccessRecord) error
+	end(count int64) error
+}
+
+// 流式导出访问记录，返回导出行数
+func Write(ctx context.Context, w io.Writer, source Source, query *storage.AccessRecordQuery, opts Options) (int64, error) {
+	if err := opts.Validate(); err != nil {
+		return 0, err
+	}
+
+	var gz *gzip.Writer
+	if opts.Gzip {
+		gz = gzip.NewWriter(w)
+		w = gz
+	}
+
+	var enc encoder
+	switch opts.Format {
+	case FormatCSV:
+		enc = &csvEncoder{w: csv.NewWriter(w)}
+	case FormatNDJSON:
+		enc = &ndjsonEncoder{enc: json.NewEncoder(w)}
+	default:
+		enc = &jsonEncoder{w: w}
+	}
+
+	if err := enc.begin(); err != nil {
+		return 0, err
+	}
+
+	var count int64
+	err := source.StreamAccessRecords(ctx, query, func(record *storage.AccessRecord) error {
+		count++
+		return enc.write(record)
+	})
+	if err != nil {
+		return count, err
+	}
+
+	if err := enc.end(count); err != nil {
+		return count, err
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// CSV编码器，使用encoding/csv处理引号、逗号和换行
+type csvEncoder struct {
+	w   *csv.Writer
+	row []string
+}
+
+func (e *csvEncoder) begin() error {
+	return e.w.Write([]string{"ID", "Fingerprint", "IP", "UserAgent", "Path", "Method", "Score", "Action", "Timestamp", "Country", "City", "ASN", "ASOrg"})
+}
+
+func (e *csvEncoder) write(record *storage.AccessRecord) error {
+	e.row = append(e.row[:0],
+		strconv.Itoa(record.ID),
+		record.Fingerprint,
+		record.IP,
+		record.UserAgent,
+		record.Path,
+		record.Method,
+		strconv.Itoa(record.Score),
+		record.Action,
+		record.Timestamp.Format("2006-01-02 15:04:05"),
+		record.Country,
+		record.City,
+		formatASN(record.ASN),
+		record.ASOrg,
+	)
+	return e.w.Write(e.row)
+}
+
+// 没有ASN信息时输出空值而不是0
+func formatASN(asn uint) string {
+	if asn == 0 {
+		return ""
+	}
+	return strconv.FormatUint(uint64(asn), 10)
+}
+
+func (e *csvEncoder) end(count int64) error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// NDJSON编码器，每行一条记录
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonEncoder) begin() error { return nil }
+
+func (e *ndjsonEncoder) write(record *storage.AccessRecord) error {
+	return e.enc.Encode(record)
+}
+
+func (e *ndjsonEncoder) end(count int64) error { return nil }
+
+// JSON编码器，保持原有导出结构，total_count写在末尾
+type jsonEncoder struct {
+	w     io.Writer
+	first bool
+}
+
+func (e *jsonEncoder) begin() error {
+	e.first = true
+	exportTime, _ := json.Marshal(time.Now())
+	_, err := fmt.Fprintf(e.w, `{"export_time":%s,"logs":[`, exportTime)
+	return err
+}
+
+func (e *jsonEncoder) write(record *storage.AccessRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if !e.first {
+		if _, err := e.w.Write([]byte(",")); err != nil {
+			return err
+		}
+	}
+	e.first = false
+	_, err = e.w.Write(data)
+	return err
+}
+
+func (e *jsonEncoder) end(count int64) error {
+	_, err := fmt.Fprintf(e.w, "],\"total_count\":%d}\n", count)
+	return err
+}
@@ -0,0 +1,305 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"securefingerprint/internal/storage"
+)
+
+// 导出任务状态
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
+// 任务不存在
+var ErrJobNotFound = errors.New("导出任务不存在或已过期")
+
+// 异步导出配置
+type JobConfig struct {
+	Dir           string        `yaml:"dir"`            // 导出文件目录
+	Retention     time.Duration `yaml:"retention"`      // 完成后文件保留时间
+	MaxConcurrent int           `yaml:"max_concurrent"` // 同时运行的任务数
+}
+
+// 默认异步导出配置
+var DefaultJobConfig = JobConfig{
+	Dir:           "exports",
+	Retention:     24 * time.Hour,
+	MaxConcurrent: 2,
+}
+
+// 导出任务
+type Job struct {
+	ID          string                    `json:"id"`
+	Status      string                    `json:"status"`
+	Options     Options                   `json:"options"`
+	Query       storage.AccessRecordQuery `json:"query"`
+	Rows        int64                     `json:"rows"`
+	Bytes       int64                     `json:"bytes"`
+	Error       string                    `json:"error,omitempty"`
+	CreatedAt   time.Time                 `json:"created_at"`
+	StartedAt   *time.Time                `json:"started_at,omitempty"`
+	CompletedAt *time.Time                `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time                `json:"expires_at,omitempty"`
+
+	path string
+}
+
+// 下载文件名
+func (j *Job) Filename() string {
+	return fmt.Sprintf("access_logs_%s.%s", j.CreatedAt.Format("20060102_150405"), j.Options.Extension())
+}
+
+// 异步导出任务管理器，任务状态保存在本实例内存中，文件写入本地目录
+type JobManager struct {
+	config JobConfig
+	source Source
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+
+	slots  chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// 创建任务管理器并启动过期清理
+func NewJobManager(config JobConfig, source Source) (*JobManager, error) {
+	if config.Dir == "" {
+		config.Dir = DefaultJobConfig.Dir
+	}
+	if config.Retention <= 0 {
+		config.Retention = DefaultJobConfig.Retention
+	}
+	if config.MaxConcurrent <= 0 {
+		config.MaxConcurrent = DefaultJobConfig.MaxConcurrent
+	}
+	if err := os.MkdirAll(config.Dir, 0o750); err != nil {
+		return nil, fmt.Errorf("创建导出目录失败: %v", err)
+	}
+
+	m := &JobManager{
+		config: config,
+		source: source,
+		jobs:   make(map[string]*Job),
+		slots:  make(chan struct{}, config.MaxConcurrent),
+	}
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+
+	m.wg.Add(1)
+	go m.janitor()
+
+	return m, nil
+}
+
+// 提交导出任务
+func (m *JobManager) Submit(query storage.AccessRecordQuery, opts Options) (Job, error) {
+	if err := opts.Validate(); err != nil {
+		return Job{}, err
+	}
+	query.Limit, query.Offset, query.Cursor = 0, 0, nil
+
+	job := &Job{
+		ID:        newJobID(),
+		Status:    JobPending,
+		Options:   opts,
+		Query:     query,
+		CreatedAt: time.Now(),
+	}
+	job.path = filepath.Join(m.config.Dir, job.ID+"."+opts.Extension())
+
+	m.mu.Lock()
+	m.jobs[job.ID] = job
+	snapshot := *job
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go m.run(job)
+
+	return snapshot, nil
+}
+
+// 获取任务
+func (m *JobManager) Get(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+	return *job, nil
+}
+
+// 任务列表，按创建时间倒序
+func (m *JobManager) List() []Job {
+	m.mu.Lock()
+	jobs := make([]Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, *job)
+	}
+	m.mu.Unlock()
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+	})
+	return jobs
+}
+
+// 已完成任务的文件路径
+func (m *JobManager) FilePath(id string) (Job, string, error) {
+	job, err := m.Get(id)
+	if err != nil {
+		return job, "", err
+	}
+	if job.Status != JobCompleted {
+		return job, "", fmt.Errorf("导出任务尚未完成: %s", job.Status)
+	}
+	return job, filepath.Join(m.config.Dir, job.ID+"."+job.Options.Extension()), nil
+}
+
+func (m *JobManager) run(job *Job) {
+	defer m.wg.Done()
+
+	select {
+	case m.slots <- struct{}{}:
+	case <-m.ctx.Done():
+		m.finish(job, 0, 0, m.ctx.Err())
+		return
+	}
+	defer func() { <-m.slots }()
+
+	m.mu.Lock()
+	now := time.Now()
+	job.Status = JobRunning
+	job.StartedAt = &now
+	query := job.Query
+	m.mu.Unlock()
+
+	rows, size, err := m.writeFile(job.path, &query, job.Options)
+	m.finish(job, rows, size, err)
+}
+
+// 写入临时文件，完成后重命名，避免下载到不完整的文件
+func (m *JobManager) writeFile(path string, query *storage.AccessRecordQuery, opts Options) (int64, int64, error) {
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	buffered := bufio.NewWriterSize(file, 64<<10)
+	rows, err := Write(m.ctx, buffered, m.source, query, opts)
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return rows, 0, err
+	}
+
+	info, err := os.Stat(tmp)
+	if err != nil {
+		os.Remove(tmp)
+		return rows, 0, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return rows, 0, err
+	}
+	return rows, info.Size(), nil
+}
+
+func (m *JobManager) finish(job *Job, rows, size int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	expires := now.Add(m.config.Retention)
+	job.Rows = rows
+	job.Bytes = size
+	job.CompletedAt = &now
+	job.ExpiresAt = &expires
+	if err != nil {
+		job.Status = JobFailed
+		job.Error = err.Error()
+		log.Printf("导出任务%s失败: %v", job.ID, err)
+		return
+	}
+	job.Status = JobCompleted
+}
+
+// 定期删除过期任务及其文件
+func (m *JobManager) janitor() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.removeExpired(time.Now())
+		}
+	}
+}
+
+func (m *JobManager) removeExpired(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, job := range m.jobs {
+		if job.ExpiresAt == nil || now.Before(*job.ExpiresAt) {
+			continue
+		}
+		if err := os.Remove(job.path); err != nil && !os.IsNotExist(err) {
+			log.Printf("删除过期导出文件失败: %v", err)
+		}
+		delete(m.jobs, id)
+	}
+}
+
+// 取消运行中的任务并等待退出
+func (m *JobManager) Close(ctx context.Context) error {
+	m.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func newJobID() string {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
@@ -7,22 +7,31 @@ import (
 	"fmt"
 	"net"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"securefingerprint/internal/collector"
+	"securefingerprint/internal/headerorder"
 )
 
 type Generator struct {
-	salt string // 用于增加指纹安全性的盐值
+	salts          SaltSource         // 用于增加指纹安全性的盐值，支持按版本轮换
+	componentSalt  string             // 组件向量使用的盐值，不随盐值轮换变化
+	weights        FingerprintWeights // Generate使用的权重
+	defaultProfile Profile            // 未匹配路由时使用的指纹组成
+	profiles       []Profile          // 按路由选择的指纹组成
 }
 
 // 指纹组件权重配置
 type FingerprintWeights struct {
-	IP        float64 `json:"ip"`         // IP地址权重
-	UserAgent float64 `json:"user_agent"` // User-Agent权重
-	Headers   float64 `json:"headers"`    // HTTP头权重
-	Network   float64 `json:"network"`    // 网络类型权重
-	Device    float64 `json:"device"`     // 设备类型权重
+	IP        float64 `yaml:"ip" json:"ip"`                 // IP地址权重
+	UserAgent float64 `yaml:"user_agent" json:"user_agent"` // User-Agent权重
+	Headers   float64 `yaml:"headers" json:"headers"`       // HTTP头权重
+	Network   float64 `yaml:"network" json:"network"`       // 网络类型权重
+	Device    float64 `yaml:"device" json:"device"`         // 设备类型权重
+	TLS       float64 `yaml:"tls" json:"tls"`               // TLS客户端指纹（JA4）权重，默认不参与
+	Client    float64 `yaml:"client" json:"client"`         // 浏览器端信号摘要权重，默认不参与
 }
 
 // 默认权重配置
@@ -32,43 +41,74 @@ var DefaultWeights = FingerprintWeights{
 	Headers:   0.15, // HTTP头占15%
 	Network:   0.1,  // 网络类型占10%
 	Device:    0.05, // 设备类型占5%
+	TLS:       0,    // 仅在本服务终结TLS时可用
+	Client:    0,    // 仅在开启浏览器信号采集时可用
 }
 
 func NewGenerator(salt string) *Generator {
 	if salt == "" {
 		salt = "firewall-controller-default-salt"
 	}
-	return &Generator{salt: salt}
+	return &Generator{
+		salts:          staticSalt{Version: 0, Value: salt},
+		componentSalt:  salt,
+		weights:        DefaultWeights,
+		defaultProfile: DefaultProfile,
+	}
+}
+
+// 设置盐值来源（如盐值文件），替换创建时的固定盐值
+func (g *Generator) SetSaltSource(source SaltSource) {
+	g.salts = source
 }
 
-// 生成用户指纹
+// 设置Generate使用的权重
+func (g *Generator) SetWeights(weights FingerprintWeights) {
+	g.weights = weights
+}
+
+// 生成用户指纹，按请求路径选择指纹组成
 func (g *Generator) Generate(info *collector.AccessInfo) string {
-	return g.GenerateWithWeights(info, DefaultWeights)
+	current, _ := g.GenerateVersions(info)
+	return current
 }
 
-// 使用自定义权重生成指纹
+// 使用当前盐值生成指纹，盐值轮换重叠期内同时返回上一版本的指纹，否则previous为空
+func (g *Generator) GenerateVersions(info *collector.AccessInfo) (current, previous string) {
+	profile := g.ProfileFor(info.Path)
+	fingerprintData := g.combineComponents(g.extractComponents(info, profile), profile.weights(g.weights))
+	salt, previousSalt := g.salts.Salts(time.Now())
+	current = g.hashFingerprint(fingerprintData, salt)
+	if previousSalt != nil {
+		previous = g.hashFingerprint(fingerprintData, *previousSalt)
+	}
+	return current, previous
+}
+
+// 使用自定义权重和默认指纹组成生成指纹
 func (g *Generator) GenerateWithWeights(info *collector.AccessInfo, weights FingerprintWeights) string {
-	components := g.extractComponents(info)
+	components := g.extractComponents(info, &g.defaultProfile)
 	
 	// 根据权重组合指纹组件
 	fingerprintData := g.combineComponents(components, weights)
 	
 	// 生成最终指纹
-	return g.hashFingerprint(fingerprintData)
+	salt, _ := g.salts.Salts(time.Now())
+	return g.hashFingerprint(fingerprintData, salt)
 }
 
-// 提取指纹组件
-func (g *Generator) extractComponents(info *collector.AccessInfo) map[string]string {
+// 按指纹组成提取指纹组件
+func (g *Generator) extractComponents(info *collector.AccessInfo, profile *Profile) map[string]string {
 	components := make(map[string]string)
 
 	// IP地址组件（对IP进行部分模糊化以增加稳定性）
-	components["ip"] = g.normalizeIP(info.IP)
+	components["ip"] = g.normalizeIP(info.IP, profile.IPv4Prefix, profile.IPv6Prefix)
 	
 	// User-Agent组件（提取关键特征）
 	components["user_agent"] = g.normalizeUserAgent(info.UserAgent)
 	
 	// HTTP头组件（选择稳定的头信息）
-	components["headers"] = g.normalizeHeaders(info.Headers)
+	components["headers"] = g.normalizeHeaders(info.Headers, info.HeaderOrder, profile.Headers)
 	
 	// 网络类型组件
 	components["network"] = info.NetworkType
@@ -76,22 +116,40 @@ func (g *Generator) extractComponents(info *collector.AccessInfo) map[string]str
 	// 设备类型组件
 	components["device"] = info.DeviceType
 
+	// TLS组件使用JA4，套件和扩展排序后哈希，不受Chrome扩展顺序随机化影响
+	components["tls"] = "none"
+	if info.TLS != nil {
+		components["tls"] = info.TLS.JA4
+	}
+
+	// 浏览器信号组件，区分同网段同浏览器的不同设备。cookie来自其他网络或UA时不采用
+	components["client"] = "none"
+	if info.ClientSignals != nil && info.ClientSignals.Bound {
+		components["client"] = info.ClientSignals.Hash
+	}
+
 	return components
 }
 
 // 规范化IP地址（保留网段信息，增加稳定性）
-func (g *Generator) normalizeIP(ip string) string {
+func (g *Generator) normalizeIP(ip string, ipv4Prefix, ipv6Prefix int) string {
 	parsedIP := net.ParseIP(ip)
 	if parsedIP == nil {
 		return ip
 	}
 
-	// 对于IPv4，保留前3个八位组
+	// 对于IPv4，默认保留前3个八位组
 	if ipv4 := parsedIP.To4(); ipv4 != nil {
+		if ipv4Prefix != 24 {
+			return fmt.Sprintf("%s/%d", ipv4.Mask(net.CIDRMask(ipv4Prefix, 32)), ipv4Prefix)
+		}
 		return fmt.Sprintf("%d.%d.%d.0", ipv4[0], ipv4[1], ipv4[2])
 	}
 
-	// 对于IPv6，保留前64位
+	// 对于IPv6，默认保留前64位
+	if ipv6Prefix != 64 {
+		return fmt.Sprintf("%s/%d", parsedIP.Mask(net.CIDRMask(ipv6Prefix, 128)), ipv6Prefix)
+	}
 	if ipv6 := parsedIP.To16(); ipv6 != nil {
 		return fmt.Sprintf("%02x%02x:%02x%02x:%02x%02x:%02x%02x::",
 			ipv6[0], ipv6[1], ipv6[2], ipv6[3],
@@ -101,7 +159,9 @@ func (g *Generator) normalizeIP(ip string) string {
 	return ip
 }
 
-// 规范化User-Agent（提取关键特征，忽略版本号细节）
+// 规范化User-Agent（提取关键特征，忽略版本号细节）。
+// 指纹输入保持原有的子串规则，改用UA解析结果会使Edge、iOS、Android等客户端的指纹全部变化；
+// 结构化的解析结果只记录在AccessInfo.Agent上
 func (g *Generator) normalizeUserAgent(userAgent string) string {
 	if userAgent == "" {
 		return "empty"
@@ -156,18 +216,12 @@ func (g *Generator) normalizeUserAgent(userAgent string) string {
 	return fmt.Sprintf("%s_%s_%s", browser, os, engine)
 }
 
-// 规范化HTTP头信息
-func (g *Generator) normalizeHeaders(headers map[string]string) string {
-	if len(headers) == 0 {
+// 规范化HTTP头信息，有原始头顺序时加入核心头的顺序和大小写
+func (g *Generator) normalizeHeaders(headers map[string]string, order *headerorder.Signature, stableHeaders []string) string {
+	if len(headers) == 0 && order == nil {
 		return "empty"
 	}
 
-	// 选择稳定的头信息进行指纹生成
-	stableHeaders := []string{
-		"Accept", "Accept-Language", "Accept-Encoding",
-		"DNT", "Upgrade-Insecure-Requests",
-	}
-
 	var headerParts []string
 	for _, header := range stableHeaders {
 		if value, exists := headers[header]; exists {
@@ -181,6 +235,11 @@ func (g *Generator) normalizeHeaders(headers map[string]string) string {
 
 	// 排序确保一致性
 	sort.Strings(headerParts)
+
+	// 核心头不受Cookie、Referer等可选头影响，同一客户端的各请求保持一致
+	if order != nil && order.Core != "" {
+		headerParts = append(headerParts, "order:"+order.Core)
+	}
 	
 	if len(headerParts) == 0 {
 		return "empty"
@@ -253,14 +312,20 @@ func (g *Generator) combineComponents(components map[string]string, weights Fing
 	if weights.Device > 0 {
 		parts = append(parts, fmt.Sprintf("dev:%.2f:%s", weights.Device, components["device"]))
 	}
+	if weights.TLS > 0 {
+		parts = append(parts, fmt.Sprintf("tls:%.2f:%s", weights.TLS, components["tls"]))
+	}
+	if weights.Client > 0 {
+		parts = append(parts, fmt.Sprintf("cli:%.2f:%s", weights.Client, components["client"]))
+	}
 
 	return strings.Join(parts, "|")
 }
 
-// 生成最终哈希指纹
-func (g *Generator) hashFingerprint(data string) string {
+// 生成最终哈希指纹，版本0为无前缀的旧格式，其他版本加 v<版本>_ 前缀
+func (g *Generator) hashFingerprint(data string, salt Salt) string {
 	// 添加盐值
-	saltedData := fmt.Sprintf("%s|salt:%s", data, g.salt)
+	saltedData := fmt.Sprintf("%s|salt:%s", data, salt.Value)
 	
 	// 使用SHA256生成哈希
 	hasher := sha256.New()
@@ -268,7 +333,26 @@ func (g *Generator) hashFingerprint(data string) string {
 	hash := hasher.Sum(nil)
 	
 	// 转换为16进制字符串
-	return hex.EncodeToString(hash)
+	if salt.Version == 0 {
+		return hex.EncodeToString(hash)
+	}
+	return fmt.Sprintf("v%d_%s", salt.Version, hex.EncodeToString(hash))
+}
+
+// 指纹的盐值版本，无前缀的旧格式为0，格式无效时返回-1
+func Version(fingerprint string) int {
+	prefix, _, ok := strings.Cut(fingerprint, "_")
+	if !ok {
+		return 0
+	}
+	if !strings.HasPrefix(prefix, "v") {
+		return -1
+	}
+	version, err := strconv.Atoi(prefix[1:])
+	if err != nil || version <= 0 {
+		return -1
+	}
+	return version
 }
 
 // 生成短指纹（用于显示）
@@ -286,6 +370,13 @@ func (g *Generator) GenerateShort(info *collector.AccessInfo) string {
 
 // 验证指纹格式
 func (g *Generator) ValidateFingerprint(fingerprint string) bool {
+	if Version(fingerprint) < 0 {
+		return false
+	}
+	if i := strings.Index(fingerprint, "_"); i >= 0 {
+		fingerprint = fingerprint[i+1:]
+	}
+
 	// 检查长度（SHA256的十六进制表示应该是64个字符）
 	if len(fingerprint) != 64 {
 		return false
@@ -296,48 +387,77 @@ func (g *Generator) ValidateFingerprint(fingerprint string) bool {
 	return err == nil
 }
 
-// 比较两个指纹的相似度
-func (g *Generator) CalculateSimilarity(fp1, fp2 string) float64 {
-	if !g.ValidateFingerprint(fp1) || !g.ValidateFingerprint(fp2) {
-		return 0.0
+// 按组件分别哈希的指纹向量，键为组件名，缺失的组件值为空
+type ComponentVector map[string]string
+
+// 组件名对应的权重
+func (w FingerprintWeights) Of(component string) float64 {
+	switch component {
+	case "ip":
+		return w.IP
+	case "user_agent":
+		return w.UserAgent
+	case "headers":
+		return w.Headers
+	case "network":
+		return w.Network
+	case "device":
+		return w.Device
+	case "tls":
+		return w.TLS
+	case "client":
+		return w.Client
 	}
-	
-	if fp1 == fp2 {
-		return 1.0
-	}
-	
-	// 计算汉明距离
-	bytes1, _ := hex.DecodeString(fp1)
-	bytes2, _ := hex.DecodeString(fp2)
-	
-	if len(bytes1) != len(bytes2) {
-		return 0.0
+	return 0
+}
+
+// 生成组件向量，每个规范化后的组件单独加盐哈希，可以保存和比较而不暴露原始值。
+// 使用创建时的固定盐值，盐值轮换后已保存的向量仍可比较，身份聚类不会因此拆分
+func (g *Generator) Components(info *collector.AccessInfo) ComponentVector {
+	vector := make(ComponentVector)
+	for name, value := range g.extractComponents(info, &g.defaultProfile) {
+		if value == "" || value == "none" || value == "empty" {
+			vector[name] = ""
+			continue
+		}
+		sum := sha256.Sum256([]byte(name + ":" + value + "|salt:" + g.componentSalt))
+		vector[name] = hex.EncodeToString(sum[:8])
 	}
-	
-	var differentBits int
-	for i := 0; i < len(bytes1); i++ {
-		xor := bytes1[i] ^ bytes2[i]
-		// 计算字节中的1的个数
-		for xor != 0 {
-			differentBits++
-			xor &= xor - 1
+	return vector
+}
+
+// 按权重计算两个组件向量的相似度（0-1）：一致组件的权重之和除以参与比较的权重之和。
+// 权重为0或任一方缺失的组件不参与比较
+func (g *Generator) CalculateSimilarity(a, b ComponentVector) float64 {
+	var total, matched float64
+	for name, value := range a {
+		weight := g.weights.Of(name)
+		other := b[name]
+		if weight <= 0 || value == "" || other == "" {
+			continue
+		}
+		total += weight
+		if value == other {
+			matched += weight
 		}
 	}
-	
-	totalBits := len(bytes1) * 8
-	similarity := 1.0 - float64(differentBits)/float64(totalBits)
-	
-	return similarity
+	if total == 0 {
+		return 0
+	}
+	return matched / total
 }
 
-// 获取指纹详细信息（用于调试）
+// 获取指纹详细信息（用于调试），包含请求路径选中的指纹组成
 func (g *Generator) GetFingerprintDetails(info *collector.AccessInfo) map[string]interface{} {
-	components := g.extractComponents(info)
+	profile := g.ProfileFor(info.Path)
+	weights := profile.weights(g.weights)
+	components := g.extractComponents(info, profile)
 	
 	return map[string]interface{}{
+		"profile":    profile,
 		"components": components,
-		"weights":    DefaultWeights,
-		"combined":   g.combineComponents(components, DefaultWeights),
+		"weights":    weights,
+		"combined":   g.combineComponents(components, weights),
 		"fingerprint": g.Generate(info),
 		"short_fingerprint": g.GenerateShort(info),
 	}
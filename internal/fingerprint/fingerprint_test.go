@@ -0,0 +1,94 @@
+package fingerprint
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"securefingerprint/internal/collector"
+)
+
+// 升级UA解析后，同一请求生成的指纹必须与升级前一致，否则已有的分数、封禁和白名单全部失效。
+// 期望值由引入UA模式库之前的版本生成
+func TestGenerateStable(t *testing.T) {
+	tests := []struct {
+		name        string
+		ua          string
+		userAgent   string
+		device      string
+		fingerprint string
+	}{
+		{
+			name:        "Edge",
+			ua:          "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.2478.51",
+			userAgent:   "chrome_windows_webkit",
+			device:      "desktop",
+			fingerprint: "a768a21ca1478637a6fe2ba46ffb16f50c91973a99713e853b4612e0fbb5c64c",
+		},
+		{
+			name:        "iPhone",
+			ua:          "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4.1 Mobile/15E148 Safari/604.1",
+			userAgent:   "safari_macos_webkit",
+			device:      "mobile",
+			fingerprint: "b22157acf18c121a21469c52ab070828700ff552bdc750c3e8cdc9a0088e62a5",
+		},
+		{
+			name:        "iPad",
+			ua:          "Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.6 Mobile/15E148 Safari/604.1",
+			userAgent:   "safari_macos_webkit",
+			device:      "tablet",
+			fingerprint: "dee2dcbc1e4d50f9bc01855acfe2d9d7fa40e20cfabc96a7f548d6cb70a7b8bc",
+		},
+		{
+			name:        "Android",
+			ua:          "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.6367.82 Mobile Safari/537.36",
+			userAgent:   "chrome_linux_webkit",
+			device:      "mobile",
+			fingerprint: "2239b54b763726ee4a00f255e334c52a8e144a46975adde1a7e67ff959087628",
+		},
+		{
+			name:        "HeadlessChrome",
+			ua:          "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/124.0.6367.60 Safari/537.36",
+			userAgent:   "chrome_linux_webkit",
+			device:      "desktop",
+			fingerprint: "c6a2a7e8586849ae7157598283d6ad677f2100c73b8732a4fffd2dbe34feaa6e",
+		},
+		{
+			name:        "Firefox",
+			ua:          "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:125.0) Gecko/20100101 Firefox/125.0",
+			userAgent:   "firefox_macos_gecko",
+			device:      "desktop",
+			fingerprint: "b4d211835e394337d4c65c02379bcd4dd95ccf6db73003dd537582c7f249859d",
+		},
+		{
+			name:        "Go-http-client",
+			ua:          "Go-http-client/1.1",
+			userAgent:   "other_other_other",
+			device:      "bot",
+			fingerprint: "524a652440e1fc3db2a32a250fb4d845c821c3ba554fa8943eab4015b3fb9dc0",
+		},
+	}
+
+	c := collector.NewCollector(nil)
+	g := NewGenerator("test-salt")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = "203.0.113.7:40000"
+			r.Header.Set("User-Agent", tt.ua)
+			r.Header.Set("Accept", "text/html")
+			r.Header.Set("Accept-Language", "zh-CN,zh;q=0.9,en;q=0.8")
+			info := c.CollectFromRequest(r)
+
+			components := g.extractComponents(info, &g.defaultProfile)
+			if components["user_agent"] != tt.userAgent {
+				t.Errorf("UA组件 = %s, 期望 %s", components["user_agent"], tt.userAgent)
+			}
+			if components["device"] != tt.device {
+				t.Errorf("设备组件 = %s, 期望 %s", components["device"], tt.device)
+			}
+			if got := g.Generate(info); got != tt.fingerprint {
+				t.Errorf("Generate() = %s, 期望 %s", got, tt.fingerprint)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package fingerprint
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 指纹配置
+type Config struct {
+	SaltConfig `yaml:",inline"`
+	Weights    *FingerprintWeights `yaml:"weights"`  // 组件权重，未配置时使用默认权重
+	Profiles   []Profile           `yaml:"profiles"` // 按路由选择的指纹组成
+}
+
+// 指纹组成，按请求路径选择。不同组成生成的指纹互不相同，分数和封禁分别计算
+type Profile struct {
+	Name       string   `yaml:"name" json:"name"`
+	Routes     []string `yaml:"routes" json:"routes,omitempty"`         // 路径前缀，匹配最长的前缀；名为default的组成用于未匹配的路径
+	Components []string `yaml:"components" json:"components,omitempty"` // 参与指纹的组件，为空时使用全部权重大于0的组件
+	IPv4Prefix int      `yaml:"ipv4_prefix" json:"ipv4_prefix"`         // IPv4保留的前缀长度
+	IPv6Prefix int      `yaml:"ipv6_prefix" json:"ipv6_prefix"`         // IPv6保留的前缀长度
+	Headers    []string `yaml:"headers" json:"headers"`                 // 参与指纹的请求头
+}
+
+// 指纹组件名
+var ComponentNames = []string{"ip", "user_agent", "headers", "network", "device", "tls", "client"}
+
+// 默认指纹组成，与未配置组成时生成的指纹一致
+var DefaultProfile = Profile{
+	Name:       "default",
+	IPv4Prefix: 24,
+	IPv6Prefix: 64,
+	Headers: []string{
+		"Accept", "Accept-Language", "Accept-Encoding",
+		"DNT", "Upgrade-Insecure-Requests",
+	},
+}
+
+// 设置按路由选择的指纹组成，名为default的组成替换默认组成
+func (g *Generator) SetProfiles(profiles []Profile) error {
+	known := make(map[string]bool)
+	for _, name := range ComponentNames {
+		known[name] = true
+	}
+
+	names := make(map[string]bool)
+	defaultProfile := DefaultProfile
+	var routed []Profile
+	for _, profile := range profiles {
+		if profile.Name == "" {
+			return fmt.Errorf("指纹组成缺少名称")
+		}
+		if names[profile.Name] {
+			return fmt.Errorf("指纹组成%s重复", profile.Name)
+		}
+		names[profile.Name] = true
+
+		for _, component := range profile.Components {
+			if !known[component] {
+				return fmt.Errorf("指纹组成%s包含未知组件%s", profile.Name, component)
+			}
+		}
+		if profile.IPv4Prefix == 0 {
+			profile.IPv4Prefix = DefaultProfile.IPv4Prefix
+		}
+		if profile.IPv6Prefix == 0 {
+			profile.IPv6Prefix = DefaultProfile.IPv6Prefix
+		}
+		if profile.IPv4Prefix < 0 || profile.IPv4Prefix > 32 || profile.IPv6Prefix < 0 || profile.IPv6Prefix > 128 {
+			return fmt.Errorf("指纹组成%s的IP前缀长度无效", profile.Name)
+		}
+		if len(profile.Headers) == 0 {
+			profile.Headers = DefaultProfile.Headers
+		}
+
+		if profile.Name == DefaultProfile.Name {
+			defaultProfile = profile
+			continue
+		}
+		if len(profile.Routes) == 0 {
+			return fmt.Errorf("指纹组成%s未配置路由", profile.Name)
+		}
+		routed = append(routed, profile)
+	}
+
+	g.defaultProfile = defaultProfile
+	g.profiles = routed
+	return nil
+}
+
+// 请求路径对应的指纹组成
+func (g *Generator) ProfileFor(path string) *Profile {
+	var matched *Profile
+	longest := -1
+	for i := range g.profiles {
+		for _, route := range g.profiles[i].Routes {
+			if strings.HasPrefix(path, route) && len(route) > longest {
+				matched, longest = &g.profiles[i], len(route)
+			}
+		}
+	}
+	if matched == nil {
+		return &g.defaultProfile
+	}
+	return matched
+}
+
+// 组成实际使用的权重：未列出的组件权重为0
+func (p *Profile) weights(base FingerprintWeights) FingerprintWeights {
+	if len(p.Components) == 0 {
+		return base
+	}
+	included := make(map[string]bool)
+	for _, component := range p.Components {
+		included[component] = true
+	}
+	weights := base
+	for name, weight := range map[string]*float64{
+		"ip":         &weights.IP,
+		"user_agent": &weights.UserAgent,
+		"headers":    &weights.Headers,
+		"network":    &weights.Network,
+		"device":     &weights.Device,
+		"tls":        &weights.TLS,
+		"client":     &weights.Client,
+	} {
+		if !included[name] {
+			*weight = 0
+		}
+	}
+	return weights
+}
@@ -0,0 +1,222 @@
+package fingerprint
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 指纹盐值配置
+type SaltConfig struct {
+	Salt           string        `yaml:"salt"`            // 版本0（无前缀的旧格式）使用的盐值，盐值文件中的版本0优先
+	SaltFile       string        `yaml:"salt_file"`       // 盐值文件，包含各版本的盐值和生效时间
+	Overlap        time.Duration `yaml:"overlap"`         // 新版本生效后同时计算新旧指纹并迁移状态的时间
+	ReloadInterval time.Duration `yaml:"reload_interval"` // 检查盐值文件变化的间隔
+}
+
+// 默认指纹盐值配置
+var DefaultSaltConfig = SaltConfig{
+	Salt:           "firewall-controller-salt",
+	Overlap:        24 * time.Hour,
+	ReloadInterval: time.Minute,
+}
+
+// 盐值版本。版本0生成无前缀的旧格式指纹，其他版本的指纹以 v<版本>_ 开头
+type Salt struct {
+	Version    int       `yaml:"version" json:"version"`
+	Value      string    `yaml:"salt" json:"-"`
+	ActiveFrom time.Time `yaml:"active_from" json:"active_from"` // 生效时间，早于该时间继续使用上一版本
+}
+
+// 盐值来源
+type SaltSource interface {
+	// 当前使用的盐值，以及轮换重叠期内的上一版本盐值（不在重叠期时为nil）
+	Salts(now time.Time) (current Salt, previous *Salt)
+}
+
+// 固定盐值
+type staticSalt Salt
+
+func (s staticSalt) Salts(time.Time) (Salt, *Salt) {
+	return Salt(s), nil
+}
+
+// 盐值文件，定期检查修改时间并重新加载，加载失败时继续使用已加载的盐值。
+// 文件格式：
+//
+//	salts:
+//	  - version: 1
+//	    salt: "..."
+//	    active_from: 2026-01-01T00:00:00Z
+type SaltFile struct {
+	path     string
+	base     Salt
+	overlap  time.Duration
+	interval time.Duration
+
+	mu      sync.RWMutex
+	salts   []Salt // 按版本升序
+	modTime time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// 加载盐值文件并启动后台检查，config.Salt作为版本0
+func NewSaltFile(config SaltConfig) (*SaltFile, error) {
+	if config.SaltFile == "" {
+		return nil, fmt.Errorf("未配置盐值文件(salt_file)")
+	}
+	if config.Overlap < 0 {
+		config.Overlap = 0
+	}
+	if config.ReloadInterval <= 0 {
+		config.ReloadInterval = DefaultSaltConfig.ReloadInterval
+	}
+
+	f := &SaltFile{
+		path:     config.SaltFile,
+		base:     Salt{Version: 0, Value: config.Salt},
+		overlap:  config.Overlap,
+		interval: config.ReloadInterval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if _, err := f.reload(); err != nil {
+		return nil, err
+	}
+
+	go f.run()
+	return f, nil
+}
+
+func (f *SaltFile) run() {
+	defer close(f.done)
+
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+			reloaded, err := f.reload()
+			if err != nil {
+				log.Printf("重新加载指纹盐值失败，继续使用旧盐值: %v", err)
+			} else if reloaded {
+				log.Printf("已重新加载指纹盐值: %s", f.path)
+			}
+		}
+	}
+}
+
+// 文件有变化时重新加载，返回是否加载了新盐值
+func (f *SaltFile) reload() (bool, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return false, fmt.Errorf("读取盐值文件失败: %v", err)
+	}
+
+	f.mu.RLock()
+	unchanged := f.salts != nil && info.ModTime().Equal(f.modTime)
+	f.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return false, fmt.Errorf("读取盐值文件失败: %v", err)
+	}
+	salts, err := parseSalts(data, f.base)
+	if err != nil {
+		return false, err
+	}
+
+	f.mu.Lock()
+	f.salts = salts
+	f.modTime = info.ModTime()
+	f.mu.Unlock()
+	return true, nil
+}
+
+// 解析并校验盐值文件，文件中没有版本0时使用base
+func parseSalts(data []byte, base Salt) ([]Salt, error) {
+	var file struct {
+		Salts []Salt `yaml:"salts"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("解析盐值文件失败: %v", err)
+	}
+
+	salts := file.Salts
+	hasBase := false
+	for _, salt := range salts {
+		if salt.Version == 0 {
+			hasBase = true
+		}
+	}
+	if !hasBase {
+		salts = append(salts, base)
+	}
+	sort.Slice(salts, func(i, j int) bool { return salts[i].Version < salts[j].Version })
+
+	for i, salt := range salts {
+		if salt.Version < 0 {
+			return nil, fmt.Errorf("盐值版本%d无效", salt.Version)
+		}
+		if salt.Value == "" {
+			return nil, fmt.Errorf("盐值版本%d的盐值为空", salt.Version)
+		}
+		if i == 0 {
+			continue
+		}
+		if salt.Version == salts[i-1].Version {
+			return nil, fmt.Errorf("盐值版本%d重复", salt.Version)
+		}
+		if salt.ActiveFrom.Before(salts[i-1].ActiveFrom) {
+			return nil, fmt.Errorf("盐值版本%d的生效时间早于版本%d", salt.Version, salts[i-1].Version)
+		}
+	}
+	return salts, nil
+}
+
+// 当前盐值为已生效的最高版本；生效后overlap内同时返回上一版本
+func (f *SaltFile) Salts(now time.Time) (Salt, *Salt) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	current := 0
+	for i, salt := range f.salts {
+		if !salt.ActiveFrom.After(now) {
+			current = i
+		}
+	}
+	if current == 0 || now.Sub(f.salts[current].ActiveFrom) >= f.overlap {
+		return f.salts[current], nil
+	}
+	previous := f.salts[current-1]
+	return f.salts[current], &previous
+}
+
+// 停止后台检查
+func (f *SaltFile) Close(ctx context.Context) error {
+	select {
+	case <-f.stop:
+	default:
+		close(f.stop)
+	}
+
+	select {
+	case <-f.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,267 @@
+package geoip
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// GeoIP配置
+type Config struct {
+	Enabled        bool          `yaml:"enabled"`
+	CityDB         string        `yaml:"city_db"`         // GeoLite2-City/GeoLite2-Country或DB-IP City Lite的mmdb文件
+	ASNDB          string        `yaml:"asn_db"`          // GeoLite2-ASN或DB-IP ASN Lite的mmdb文件
+	Language       string        `yaml:"language"`        // 国家和城市名称的语言，数据库中没有时使用英文
+	ReloadInterval time.Duration `yaml:"reload_interval"` // 检查数据库文件变化的间隔
+	MobileASNs     []uint        `yaml:"mobile_asns"`     // 移动运营商ASN，网络类型识别为mobile
+	HostingASNs    []uint        `yaml:"hosting_asns"`    // 云服务和IDC的ASN，网络类型识别为hosting
+}
+
+// 默认GeoIP配置
+var DefaultConfig = Config{
+	Language:       "en",
+	ReloadInterval: time.Minute,
+}
+
+// IP的地理位置和自治系统信息
+type Info struct {
+	Country      string `json:"country,omitempty"` // ISO 3166-1两位国家代码
+	CountryName  string `json:"country_name,omitempty"`
+	City         string `json:"city,omitempty"`
+	ASN          uint   `json:"asn,omitempty"`
+	Organization string `json:"organization,omitempty"` // 自治系统所属组织
+}
+
+// 城市/国家库记录
+type cityRecord struct {
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+// ASN库记录
+type asnRecord struct {
+	Number       uint   `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+// 单个mmdb文件，修改时间变化时重新打开
+type dbFile struct {
+	path string
+
+	mu      sync.RWMutex
+	reader  *maxminddb.Reader
+	modTime time.Time
+}
+
+// 文件有变化时重新打开，返回是否加载了新文件。加载失败时继续使用已打开的文件
+func (f *dbFile) reload() (bool, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return false, fmt.Errorf("读取GeoIP数据库失败: %v", err)
+	}
+
+	f.mu.RLock()
+	unchanged := f.reader != nil && info.ModTime().Equal(f.modTime)
+	f.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	reader, err := maxminddb.Open(f.path)
+	if err != nil {
+		return false, fmt.Errorf("打开GeoIP数据库%s失败: %v", f.path, err)
+	}
+
+	// 查询在读锁内完成，取得写锁后旧文件不再被使用，可以直接关闭
+	f.mu.Lock()
+	old := f.reader
+	f.reader = reader
+	f.modTime = info.ModTime()
+	f.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+	return true, nil
+}
+
+func (f *dbFile) lookup(ip net.IP, result interface{}) error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.reader == nil {
+		return fmt.Errorf("GeoIP数据库已关闭")
+	}
+	return f.reader.Lookup(ip, result)
+}
+
+func (f *dbFile) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.reader != nil {
+		f.reader.Close()
+		f.reader = nil
+	}
+}
+
+// GeoIP数据库，定期检查文件变化并热加载
+type Database struct {
+	config  Config
+	files   []*dbFile
+	city    *dbFile
+	asn     *dbFile
+	mobile  map[uint]bool
+	hosting map[uint]bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// 打开配置的数据库并启动后台检查
+func NewDatabase(config Config) (*Database, error) {
+	if config.CityDB == "" && config.ASNDB == "" {
+		return nil, fmt.Errorf("未配置GeoIP数据库(city_db/asn_db)")
+	}
+	if config.Language == "" {
+		config.Language = DefaultConfig.Language
+	}
+	if config.ReloadInterval <= 0 {
+		config.ReloadInterval = DefaultConfig.ReloadInterval
+	}
+
+	d := &Database{
+		config:  config,
+		mobile:  make(map[uint]bool),
+		hosting: make(map[uint]bool),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for _, asn := range config.MobileASNs {
+		d.mobile[asn] = true
+	}
+	for _, asn := range config.HostingASNs {
+		d.hosting[asn] = true
+	}
+	if config.CityDB != "" {
+		d.city = &dbFile{path: config.CityDB}
+		d.files = append(d.files, d.city)
+	}
+	if config.ASNDB != "" {
+		d.asn = &dbFile{path: config.ASNDB}
+		d.files = append(d.files, d.asn)
+	}
+	for _, f := range d.files {
+		if _, err := f.reload(); err != nil {
+			d.closeFiles()
+			return nil, err
+		}
+	}
+
+	go d.run()
+	return d, nil
+}
+
+func (d *Database) run() {
+	defer close(d.done)
+
+	ticker := time.NewTicker(d.config.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+			for _, f := range d.files {
+				reloaded, err := f.reload()
+				if err != nil {
+					log.Printf("重新加载GeoIP数据库失败，继续使用旧数据: %v", err)
+				} else if reloaded {
+					log.Printf("已重新加载GeoIP数据库: %s", f.path)
+				}
+			}
+		}
+	}
+}
+
+// 查询IP的地理位置和自治系统，IP无效或数据库中没有记录时返回nil
+func (d *Database) Lookup(ip string) *Info {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return nil
+	}
+
+	info := &Info{}
+	if d.city != nil {
+		var record cityRecord
+		if err := d.city.lookup(addr, &record); err == nil {
+			info.Country = record.Country.ISOCode
+			info.CountryName = d.name(record.Country.Names)
+			info.City = d.name(record.City.Names)
+		}
+	}
+	if d.asn != nil {
+		var record asnRecord
+		if err := d.asn.lookup(addr, &record); err == nil {
+			info.ASN = record.Number
+			info.Organization = record.Organization
+		}
+	}
+	if *info == (Info{}) {
+		return nil
+	}
+	return info
+}
+
+// 按配置的语言选择名称
+func (d *Database) name(names map[string]string) string {
+	if name, ok := names[d.config.Language]; ok {
+		return name
+	}
+	return names["en"]
+}
+
+// 根据ASN判断网络类型，未配置该ASN时返回空字符串
+func (d *Database) NetworkType(info *Info) string {
+	switch {
+	case info == nil || info.ASN == 0:
+		return ""
+	case d.mobile[info.ASN]:
+		return "mobile"
+	case d.hosting[info.ASN]:
+		return "hosting"
+	default:
+		return ""
+	}
+}
+
+// 停止后台检查并关闭数据库
+func (d *Database) Close(ctx context.Context) error {
+	select {
+	case <-d.stop:
+	default:
+		close(d.stop)
+	}
+
+	select {
+	case <-d.done:
+		d.closeFiles()
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *Database) closeFiles() {
+	for _, f := range d.files {
+		f.close()
+	}
+}
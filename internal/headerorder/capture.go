@@ -0,0 +1,280 @@
+package headerorder
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	maxHeaderBlock = 1<<20 + 4096 // 与http.Server默认MaxHeaderBytes一致
+	maxPending     = 16           // 每个连接最多缓存的未取走请求头
+	maxChunkLine   = 4096
+)
+
+// 原始请求头中的请求行和头名（保留顺序和大小写，不保存头的值）
+type block struct {
+	method string
+	target string
+	names  []string
+}
+
+// 在HTTP/1.x连接上记录每个请求的原始头名顺序的监听器，需位于最上层（TLS之上）
+type Listener struct {
+	net.Listener
+}
+
+// 包装监听器
+func NewListener(inner net.Listener) *Listener {
+	return &Listener{Listener: inner}
+}
+
+// 接受连接
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{Conn: conn}, nil
+}
+
+// 解析状态
+const (
+	stateHeaders = iota
+	stateBody
+	stateChunkSize
+	stateChunkData
+	stateTrailer
+	stateDone // 协议升级、HTTP/2或解析失败后不再记录
+)
+
+// 记录请求头的连接。按HTTP/1.x报文边界跳过请求体，只解析请求头部分
+type Conn struct {
+	net.Conn
+
+	mu        sync.Mutex
+	state     int
+	buffer    []byte
+	remaining int64 // 请求体或当前分块剩余字节数
+	pending   []*block
+}
+
+func (c *Conn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.mu.Lock()
+		c.consume(b[:n])
+		c.mu.Unlock()
+	}
+	return n, err
+}
+
+// 被包装的连接，供其他包继续解开包装（如tls.Conn、PROXY协议连接）
+func (c *Conn) NetConn() net.Conn {
+	return c.Conn
+}
+
+func (c *Conn) consume(data []byte) {
+	for len(data) > 0 {
+		switch c.state {
+		case stateDone:
+			return
+
+		case stateHeaders:
+			start := len(c.buffer)
+			c.buffer = append(c.buffer, data...)
+			end := headerEnd(c.buffer, start)
+			if end < 0 {
+				if len(c.buffer) > maxHeaderBlock {
+					c.stop()
+				}
+				return
+			}
+			data = c.buffer[end:]
+			c.buffer = c.buffer[:end]
+			c.finishHeaders()
+
+		case stateBody:
+			n := min(int64(len(data)), c.remaining)
+			data = data[n:]
+			c.remaining -= n
+			if c.remaining == 0 {
+				c.state = stateHeaders
+			}
+
+		case stateChunkSize, stateTrailer:
+			i := bytes.IndexByte(data, '\n')
+			if i < 0 {
+				c.buffer = append(c.buffer, data...)
+				if len(c.buffer) > maxChunkLine {
+					c.stop()
+				}
+				return
+			}
+			line := strings.TrimRight(string(append(c.buffer, data[:i]...)), "\r")
+			data = data[i+1:]
+			c.buffer = nil
+			c.finishLine(line)
+
+		case stateChunkData:
+			n := min(int64(len(data)), c.remaining)
+			data = data[n:]
+			c.remaining -= n
+			if c.remaining == 0 {
+				c.state = stateChunkSize
+			}
+		}
+	}
+}
+
+// 请求头结束位置（空行之后），从上次扫描处往前回退3字节继续查找
+func headerEnd(buffer []byte, from int) int {
+	from = max(from-3, 0)
+	for i := from; i < len(buffer); i++ {
+		if buffer[i] != '\n' {
+			continue
+		}
+		// 请求行之前允许出现空行（RFC 7230 3.5）
+		if rest := buffer[:i]; len(bytes.TrimLeft(rest, "\r\n")) == 0 {
+			continue
+		}
+		if i+1 < len(buffer) && buffer[i+1] == '\n' {
+			return i + 2
+		}
+		if i+2 < len(buffer) && buffer[i+1] == '\r' && buffer[i+2] == '\n' {
+			return i + 3
+		}
+	}
+	return -1
+}
+
+// 解析请求头并确定请求体的长度
+func (c *Conn) finishHeaders() {
+	lines := strings.Split(strings.TrimLeft(string(c.buffer), "\r\n"), "\n")
+	requestLine := strings.Fields(strings.TrimRight(lines[0], "\r"))
+	if len(requestLine) != 3 || !strings.HasPrefix(requestLine[2], "HTTP/1.") {
+		// HTTP/2明文前导或无法识别的协议
+		c.stop()
+		return
+	}
+
+	b := &block{method: requestLine[0], target: requestLine[1]}
+	var contentLength int64
+	var chunked, upgrade bool
+	for _, line := range lines[1:] {
+		line = strings.TrimRight(line, "\r")
+		if line == "" || line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		i := strings.IndexByte(line, ':')
+		if i <= 0 {
+			continue
+		}
+		name, value := line[:i], strings.TrimSpace(line[i+1:])
+		b.names = append(b.names, name)
+
+		switch strings.ToLower(name) {
+		case "content-length":
+			contentLength, _ = strconv.ParseInt(value, 10, 64)
+		case "transfer-encoding":
+			chunked = strings.Contains(strings.ToLower(value), "chunked")
+		case "upgrade":
+			upgrade = true
+		}
+	}
+
+	c.pending = append(c.pending, b)
+	if len(c.pending) > maxPending {
+		c.pending = c.pending[1:]
+	}
+
+	c.buffer = nil
+	switch {
+	case upgrade || b.method == http.MethodConnect:
+		// 升级后（如WebSocket）不再是HTTP报文
+		c.state = stateDone
+	case chunked:
+		c.state = stateChunkSize
+	case contentLength > 0:
+		c.state, c.remaining = stateBody, contentLength
+	default:
+		c.state = stateHeaders
+	}
+}
+
+// 处理分块编码的大小行或trailer行
+func (c *Conn) finishLine(line string) {
+	if c.state == stateTrailer {
+		if line == "" {
+			c.state = stateHeaders
+		}
+		return
+	}
+
+	if i := strings.IndexByte(line, ';'); i >= 0 {
+		line = line[:i]
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(line), 16, 64)
+	if err != nil || size < 0 {
+		c.stop()
+		return
+	}
+	if size == 0 {
+		c.state = stateTrailer
+		return
+	}
+	// 分块数据之后的CRLF一并跳过
+	c.state, c.remaining = stateChunkData, size+2
+}
+
+func (c *Conn) stop() {
+	c.state = stateDone
+	c.buffer = nil
+}
+
+// 取出与请求行匹配的请求头，同时丢弃之前未被取走的记录
+func (c *Conn) take(method, target string) *block {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, b := range c.pending {
+		if b.method == method && b.target == target {
+			c.pending = c.pending[i+1:]
+			return b
+		}
+	}
+	return nil
+}
+
+type contextKey struct{}
+
+// 将连接保存到context，用于http.Server.ConnContext
+func ContextWithConn(ctx context.Context, conn net.Conn) context.Context {
+	for conn != nil {
+		if c, ok := conn.(*Conn); ok {
+			return context.WithValue(ctx, contextKey{}, c)
+		}
+		wrapper, ok := conn.(interface{ NetConn() net.Conn })
+		if !ok {
+			break
+		}
+		conn = wrapper.NetConn()
+	}
+	return ctx
+}
+
+// 获取请求的原始头名顺序，连接未记录（HTTP/2、未开启采集）时返回nil
+func FromRequest(r *http.Request) *Signature {
+	c, ok := r.Context().Value(contextKey{}).(*Conn)
+	if !ok {
+		return nil
+	}
+	b := c.take(r.Method, r.RequestURI)
+	if b == nil {
+		return nil
+	}
+	return NewSignature(b.names)
+}
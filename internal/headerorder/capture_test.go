@@ -0,0 +1,240 @@
+package headerorder
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 按固定大小分片写入，模拟请求跨多次Read到达
+func feed(data string, size int) *Conn {
+	c := &Conn{}
+	for len(data) > 0 {
+		n := min(size, len(data))
+		c.consume([]byte(data[:n]))
+		data = data[n:]
+	}
+	return c
+}
+
+func TestConsume(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		want      []block
+		wantState int
+	}{
+		{
+			name: "管线化请求",
+			input: "GET /a HTTP/1.1\r\nHost: example.com\r\nAccept: */*\r\n\r\n" +
+				"GET /b HTTP/1.1\r\nuser-agent: curl\r\nHost: example.com\r\n\r\n" +
+				"HEAD /c HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want: []block{
+				{method: "GET", target: "/a", names: []string{"Host", "Accept"}},
+				{method: "GET", target: "/b", names: []string{"user-agent", "Host"}},
+				{method: "HEAD", target: "/c", names: []string{"Host"}},
+			},
+			wantState: stateHeaders,
+		},
+		{
+			name: "Content-Length请求体中的伪造请求被跳过",
+			input: "POST /a HTTP/1.1\r\nHost: example.com\r\nContent-Length: 37\r\n\r\n" +
+				"GET /fake HTTP/1.1\r\nX-Fake: 1\r\n\r\n\r\n\r\n" +
+				"GET /b HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want: []block{
+				{method: "POST", target: "/a", names: []string{"Host", "Content-Length"}},
+				{method: "GET", target: "/b", names: []string{"Host"}},
+			},
+			wantState: stateHeaders,
+		},
+		{
+			name: "分块请求体",
+			input: "POST /upload HTTP/1.1\r\nHost: example.com\r\nTransfer-Encoding: gzip, chunked\r\n\r\n" +
+				"19;name=value\r\nGET /fake HTTP/1.1\r\n\r\n\r\nX\r\n" +
+				"A\r\n0123456789\r\n" +
+				"0\r\nX-Checksum: abc\r\nX-Other: def\r\n\r\n" +
+				"GET /next HTTP/1.1\r\nHost: example.com\r\nTransfer-Encoding: chunked\r\n\r\n" +
+				"0\r\n\r\n" +
+				"DELETE /last HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want: []block{
+				{method: "POST", target: "/upload", names: []string{"Host", "Transfer-Encoding"}},
+				{method: "GET", target: "/next", names: []string{"Host", "Transfer-Encoding"}},
+				{method: "DELETE", target: "/last", names: []string{"Host"}},
+			},
+			wantState: stateHeaders,
+		},
+		{
+			name: "头折行不计为新头",
+			input: "GET /a HTTP/1.1\r\nHost: example.com\r\nX-Long: first\r\n second: part\r\n\tthird: part\r\nAccept: */*\r\n\r\n" +
+				"GET /b HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want: []block{
+				{method: "GET", target: "/a", names: []string{"Host", "X-Long", "Accept"}},
+				{method: "GET", target: "/b", names: []string{"Host"}},
+			},
+			wantState: stateHeaders,
+		},
+		{
+			name:  "请求行之前的空行和只有LF的换行",
+			input: "\r\n\nGET /a HTTP/1.0\nHost: example.com\nAccept: */*\n\nGET /b HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want: []block{
+				{method: "GET", target: "/a", names: []string{"Host", "Accept"}},
+				{method: "GET", target: "/b", names: []string{"Host"}},
+			},
+			wantState: stateHeaders,
+		},
+		{
+			name: "不完整的请求头等待后续数据",
+			input: "GET /a HTTP/1.1\r\nHost: example.com\r\n\r\n" +
+				"GET /b HTTP/1.1\r\nHost: exam",
+			want: []block{
+				{method: "GET", target: "/a", names: []string{"Host"}},
+			},
+			wantState: stateHeaders,
+		},
+		{
+			name: "请求体未接收完",
+			input: "POST /a HTTP/1.1\r\nContent-Length: 100\r\n\r\n" +
+				"GET /fake HTTP/1.1\r\n\r\n",
+			want: []block{
+				{method: "POST", target: "/a", names: []string{"Content-Length"}},
+			},
+			wantState: stateBody,
+		},
+		{
+			name: "协议升级后停止解析",
+			input: "GET /ws HTTP/1.1\r\nHost: example.com\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n" +
+				"GET /frame HTTP/1.1\r\n\r\n",
+			want: []block{
+				{method: "GET", target: "/ws", names: []string{"Host", "Upgrade", "Connection"}},
+			},
+			wantState: stateDone,
+		},
+		{
+			name:      "HTTP/2前导",
+			input:     "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n",
+			wantState: stateDone,
+		},
+		{
+			name: "无效的分块大小",
+			input: "POST /a HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\nzz\r\n" +
+				"GET /b HTTP/1.1\r\n\r\n",
+			want: []block{
+				{method: "POST", target: "/a", names: []string{"Transfer-Encoding"}},
+			},
+			wantState: stateDone,
+		},
+	}
+
+	for _, tt := range tests {
+		for _, size := range []int{len(tt.input), 1, 7} {
+			t.Run(fmt.Sprintf("%s/分片%d", tt.name, size), func(t *testing.T) {
+				c := feed(tt.input, size)
+				var got []block
+				for _, b := range c.pending {
+					got = append(got, *b)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("pending = %+v, 期望 %+v", got, tt.want)
+				}
+				if c.state != tt.wantState {
+					t.Errorf("state = %d, 期望 %d", c.state, tt.wantState)
+				}
+			})
+		}
+	}
+}
+
+func TestConsumeLimits(t *testing.T) {
+	var requests strings.Builder
+	for i := 0; i < maxPending+4; i++ {
+		fmt.Fprintf(&requests, "GET /%d HTTP/1.1\r\nHost: example.com\r\n\r\n", i)
+	}
+	c := feed(requests.String(), 1024)
+	if len(c.pending) != maxPending || c.pending[0].target != "/4" {
+		t.Errorf("pending数量 = %d, 首个 = %s, 期望只保留最近%d个", len(c.pending), c.pending[0].target, maxPending)
+	}
+
+	c = feed("GET / HTTP/1.1\r\nX-Big: "+strings.Repeat("a", maxHeaderBlock), 4096)
+	if c.state != stateDone || c.buffer != nil {
+		t.Errorf("超长请求头: state = %d, 期望停止解析并释放缓冲", c.state)
+	}
+
+	c = feed("POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n"+strings.Repeat("0", maxChunkLine+1), 512)
+	if c.state != stateDone {
+		t.Errorf("超长分块大小行: state = %d, 期望停止解析", c.state)
+	}
+}
+
+func TestTake(t *testing.T) {
+	c := feed("GET /a HTTP/1.1\r\nA: 1\r\n\r\nGET /b HTTP/1.1\r\nB: 1\r\n\r\nGET /a HTTP/1.1\r\nC: 1\r\n\r\n", 64)
+
+	// 第一个/a被取走后，第二次取/a应得到第三个请求，并丢弃未被取走的/b
+	if b := c.take("GET", "/a"); b == nil || !reflect.DeepEqual(b.names, []string{"A"}) {
+		t.Fatalf("take(/a) = %+v, 期望第一个请求", b)
+	}
+	if b := c.take("GET", "/a"); b == nil || !reflect.DeepEqual(b.names, []string{"C"}) {
+		t.Fatalf("take(/a) = %+v, 期望第三个请求", b)
+	}
+	if b := c.take("GET", "/b"); b != nil {
+		t.Errorf("take(/b) = %+v, 期望已被丢弃", b)
+	}
+}
+
+// 通过真实的http.Server验证管线化请求各自拿到自己的头顺序
+func TestFromRequestPipelined(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := NewListener(inner)
+
+	server := &http.Server{
+		ConnContext: ContextWithConn,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.Copy(io.Discard, r.Body)
+			order := ""
+			if sig := FromRequest(r); sig != nil {
+				order = strings.Join(sig.Names, ",")
+			}
+			fmt.Fprintf(w, "%s %s", r.URL.Path, order)
+		}),
+	}
+	go server.Serve(listener)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", inner.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	fmt.Fprint(conn,
+		"GET /a HTTP/1.1\r\nHost: example.com\r\nUser-Agent: test\r\nAccept: */*\r\n\r\n"+
+			"POST /b HTTP/1.1\r\nHost: example.com\r\nContent-Length: 19\r\nX-B: 1\r\n\r\nGET /a HTTP/1.1\r\n\r\n"+
+			"PUT /c HTTP/1.1\r\nTransfer-Encoding: chunked\r\nHost: example.com\r\n\r\n5\r\nhello\r\n0\r\n\r\n"+
+			"GET /a HTTP/1.1\r\naccept: */*\r\nhost: example.com\r\n\r\n")
+
+	want := []string{
+		"/a Host,User-Agent,Accept",
+		"/b Host,Content-Length,X-B",
+		"/c Transfer-Encoding,Host",
+		"/a accept,host",
+	}
+	reader := bufio.NewReader(conn)
+	for _, w := range want {
+		resp, err := http.ReadResponse(reader, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != w {
+			t.Errorf("响应 = %q, 期望 %q", body, w)
+		}
+	}
+}
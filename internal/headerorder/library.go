@@ -0,0 +1,153 @@
+package headerorder
+
+import (
+	"fmt"
+	"net/textproto"
+	"strings"
+)
+
+// 至少有这么多头出现在已知顺序中才做判断
+const minOverlap = 4
+
+// 请求头采集配置
+type Config struct {
+	Enabled  bool      `yaml:"enabled"`
+	Profiles []Profile `yaml:"profiles"` // 追加的已知浏览器头顺序，与内置顺序一起使用
+}
+
+// 已知客户端的HTTP/1.1头顺序。只列出相对顺序稳定的头，其他头不参与比较
+type Profile struct {
+	Name   string   `yaml:"name" json:"name"`
+	Family string   `yaml:"family" json:"family"` // chromium/firefox/safari，与UA声明的浏览器比较
+	Order  []string `yaml:"order" json:"order"`
+}
+
+// 内置的浏览器头顺序
+var DefaultProfiles = []Profile{
+	{
+		// 页面导航和fetch/XHR请求中以下头的相对顺序一致
+		Name:   "chrome",
+		Family: "chromium",
+		Order: []string{
+			"host", "connection", "user-agent", "accept",
+			"sec-fetch-site", "sec-fetch-mode", "sec-fetch-user", "sec-fetch-dest",
+			"referer", "accept-encoding", "accept-language", "cookie",
+		},
+	},
+	{
+		Name:   "firefox",
+		Family: "firefox",
+		Order: []string{
+			"host", "user-agent", "accept", "accept-language", "accept-encoding",
+			"connection", "upgrade-insecure-requests",
+			"sec-fetch-dest", "sec-fetch-mode", "sec-fetch-site", "sec-fetch-user",
+		},
+	},
+	{
+		Name:   "safari",
+		Family: "safari",
+		Order: []string{
+			"host", "accept", "sec-fetch-site", "sec-fetch-dest", "accept-language",
+			"sec-fetch-mode", "user-agent", "accept-encoding", "connection",
+		},
+	},
+}
+
+// 头顺序与UA声明的浏览器不一致
+type Mismatch struct {
+	Claimed string `json:"claimed"`           // UA声明的浏览器
+	Matched string `json:"matched,omitempty"` // 实际匹配的已知顺序
+	Reason  string `json:"reason"`
+}
+
+// 已知头顺序库
+type Library struct {
+	profiles []compiledProfile
+}
+
+type compiledProfile struct {
+	Profile
+	index map[string]int
+}
+
+// 创建头顺序库，extra追加在内置顺序之后
+func NewLibrary(extra []Profile) (*Library, error) {
+	l := &Library{}
+	for _, profile := range append(append([]Profile{}, DefaultProfiles...), extra...) {
+		if profile.Name == "" || len(profile.Order) < minOverlap {
+			return nil, fmt.Errorf("头顺序%q至少需要名称和%d个头", profile.Name, minOverlap)
+		}
+		compiled := compiledProfile{Profile: profile, index: make(map[string]int)}
+		compiled.Family = strings.ToLower(profile.Family)
+		for i, name := range profile.Order {
+			compiled.index[strings.ToLower(name)] = i
+		}
+		l.profiles = append(l.profiles, compiled)
+	}
+	return l, nil
+}
+
+// 请求头是否符合已知顺序，返回是否一致以及参与比较的头数
+func (p *compiledProfile) matches(names []string) (bool, int) {
+	last, overlap, ordered := -1, 0, true
+	for _, name := range names {
+		i, ok := p.index[strings.ToLower(name)]
+		if !ok {
+			continue
+		}
+		if i < last {
+			ordered = false
+		}
+		last = max(last, i)
+		overlap++
+	}
+	return ordered, overlap
+}
+
+// 填写签名匹配的已知顺序，并与UA声明的浏览器（claimed，非浏览器UA为空）比较
+func (l *Library) Classify(claimed string, sig *Signature) {
+	claimedKnown, claimedMatched := false, false
+	for i := range l.profiles {
+		profile := &l.profiles[i]
+		ok, overlap := profile.matches(sig.Names)
+		if overlap < minOverlap {
+			continue
+		}
+		if profile.Family == claimed {
+			claimedKnown = true
+		}
+		if !ok {
+			continue
+		}
+		if sig.Profile == "" {
+			sig.Profile = profile.Name
+		}
+		if profile.Family == claimed {
+			claimedMatched = true
+			sig.Profile = profile.Name
+		}
+	}
+
+	if claimed == "" {
+		return
+	}
+	// 浏览器在HTTP/1.1中以规范大小写发送这些头，HTTP库常使用小写
+	for _, name := range sig.Names {
+		if coreHeaders[strings.ToLower(name)] && name != textproto.CanonicalMIMEHeaderKey(name) {
+			sig.Mismatch = &Mismatch{
+				Claimed: claimed,
+				Reason:  fmt.Sprintf("UA声明%s浏览器，但头名%q的大小写不符", claimed, name),
+			}
+			return
+		}
+	}
+	if !claimedKnown || claimedMatched {
+		return
+	}
+
+	reason := fmt.Sprintf("UA声明%s浏览器，请求头顺序不符", claimed)
+	if sig.Profile != "" {
+		reason = fmt.Sprintf("UA声明%s浏览器，请求头顺序与%s一致", claimed, sig.Profile)
+	}
+	sig.Mismatch = &Mismatch{Claimed: claimed, Matched: sig.Profile, Reason: reason}
+}
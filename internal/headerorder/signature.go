@@ -0,0 +1,72 @@
+package headerorder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/textproto"
+	"strings"
+)
+
+// 头名大小写风格
+const (
+	CasingCanonical = "canonical" // Accept-Encoding
+	CasingLower     = "lower"     // accept-encoding
+	CasingMixed     = "mixed"     // 混用，如Chrome HTTP/1.1的sec-ch-ua与User-Agent
+)
+
+// 各浏览器都会发送、且相对顺序在页面和XHR请求间一致的头，用于稳定的指纹组件
+var coreHeaders = map[string]bool{
+	"host":            true,
+	"connection":      true,
+	"user-agent":      true,
+	"accept":          true,
+	"accept-encoding": true,
+	"accept-language": true,
+}
+
+// 请求头顺序签名
+type Signature struct {
+	Names    []string  `json:"names"`              // 按发送顺序的头名，保留原始大小写
+	Order    string    `json:"order"`              // 小写头名按顺序以逗号连接
+	Core     string    `json:"core"`               // 核心头按发送顺序和原始大小写连接，不受Cookie、Referer等可选头影响
+	Hash     string    `json:"hash"`               // Order的SHA256前12位
+	Casing   string    `json:"casing"`             // 头名大小写风格
+	Profile  string    `json:"profile,omitempty"`  // 匹配的已知浏览器顺序
+	Mismatch *Mismatch `json:"mismatch,omitempty"` // 与UA声明的浏览器不一致
+}
+
+// 根据原始头名生成签名
+func NewSignature(names []string) *Signature {
+	sig := &Signature{Names: names}
+
+	lower := make([]string, len(names))
+	var core []string
+	canonical, allLower := true, true
+	for i, name := range names {
+		lower[i] = strings.ToLower(name)
+		if coreHeaders[lower[i]] {
+			core = append(core, name)
+		}
+		if name != textproto.CanonicalMIMEHeaderKey(name) {
+			canonical = false
+		}
+		if name != lower[i] {
+			allLower = false
+		}
+	}
+
+	sig.Order = strings.Join(lower, ",")
+	sig.Core = strings.Join(core, ",")
+	sum := sha256.Sum256([]byte(sig.Order))
+	sig.Hash = hex.EncodeToString(sum[:])[:12]
+
+	switch {
+	case canonical:
+		sig.Casing = CasingCanonical
+	case allLower:
+		sig.Casing = CasingLower
+	default:
+		sig.Casing = CasingMixed
+	}
+	return sig
+}
@@ -0,0 +1,136 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 依赖状态
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// 依赖检查函数
+type CheckFunc func(ctx context.Context) error
+
+// 依赖连接池统计函数
+type StatsFunc func() map[string]interface{}
+
+// 单个依赖的检查结果
+type DependencyStatus struct {
+	Name          string                 `json:"name"`
+	Status        string                 `json:"status"`
+	Required      bool                   `json:"required"`
+	LatencyMs     float64                `json:"latency_ms"`
+	LastError     string                 `json:"last_error,omitempty"`
+	LastErrorAt   *time.Time             `json:"last_error_at,omitempty"`
+	LastSuccessAt *time.Time             `json:"last_success_at,omitempty"`
+	Pool          map[string]interface{} `json:"pool,omitempty"`
+}
+
+type dependency struct {
+	name          string
+	check         CheckFunc
+	stats         StatsFunc
+	lastError     string
+	lastErrorAt   time.Time
+	lastSuccessAt time.Time
+}
+
+// 依赖健康检查器
+type Checker struct {
+	timeout time.Duration
+	mu      sync.Mutex
+	deps    []*dependency
+}
+
+// 创建健康检查器
+func NewChecker(timeout time.Duration) *Checker {
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	return &Checker{timeout: timeout}
+}
+
+// 注册依赖
+func (c *Checker) Register(name string, check CheckFunc, stats StatsFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deps = append(c.deps, &dependency{name: name, check: check, stats: stats})
+}
+
+// 并发检查所有依赖，required中的依赖会被标记为必需
+func (c *Checker) CheckAll(ctx context.Context, required map[string]bool) []DependencyStatus {
+	c.mu.Lock()
+	deps := make([]*dependency, len(c.deps))
+	copy(deps, c.deps)
+	c.mu.Unlock()
+
+	results := make([]DependencyStatus, len(deps))
+	var wg sync.WaitGroup
+	for i, dep := range deps {
+		wg.Add(1)
+		go func(i int, dep *dependency) {
+			defer wg.Done()
+			results[i] = c.checkOne(ctx, dep, required[dep.name])
+		}(i, dep)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// 检查单个依赖并记录最近一次错误
+func (c *Checker) checkOne(ctx context.Context, dep *dependency, required bool) DependencyStatus {
+	checkCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := dep.check(checkCtx)
+	latency := time.Since(start)
+
+	c.mu.Lock()
+	if err != nil {
+		dep.lastError = err.Error()
+		dep.lastErrorAt = time.Now()
+	} else {
+		dep.lastSuccessAt = time.Now()
+	}
+	status := DependencyStatus{
+		Name:      dep.name,
+		Status:    StatusUp,
+		Required:  required,
+		LatencyMs: float64(latency.Microseconds()) / 1000,
+		LastError: dep.lastError,
+	}
+	if !dep.lastErrorAt.IsZero() {
+		t := dep.lastErrorAt
+		status.LastErrorAt = &t
+	}
+	if !dep.lastSuccessAt.IsZero() {
+		t := dep.lastSuccessAt
+		status.LastSuccessAt = &t
+	}
+	c.mu.Unlock()
+
+	if err != nil {
+		status.Status = StatusDown
+	}
+	if dep.stats != nil {
+		status.Pool = dep.stats()
+	}
+
+	return status
+}
+
+// 判断必需依赖是否全部可用
+func Ready(statuses []DependencyStatus) bool {
+	for _, status := range statuses {
+		if status.Required && status.Status != StatusUp {
+			return false
+		}
+	}
+	return true
+}
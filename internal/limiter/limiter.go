@@ -1,11 +1,17 @@
 package limiter
 
 import (
+	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"securefingerprint/internal/analyzer"
+	"securefingerprint/internal/clock"
+	"securefingerprint/internal/geoip"
 	"securefingerprint/internal/storage"
 )
 
@@ -17,6 +23,70 @@ type LimiterConfig struct {
 	DelayResponseMs      int           `yaml:"delay_response_ms"`        // 限速延迟时间
 	WarningThreshold     int           `yaml:"warning_threshold"`        // 警告阈值
 	CriticalThreshold    int           `yaml:"critical_threshold"`       // 严重阈值
+	Shadow               ShadowConfig  `yaml:"shadow"`                   // 影子模式
+	Geo                  GeoRules      `yaml:"geo"`                      // 按国家和ASN的访问规则
+}
+
+// 按国家和ASN的访问规则，需要启用GeoIP
+type GeoRules struct {
+	BlockCountries     []string `yaml:"block_countries" json:"block_countries"`         // 直接拒绝的国家代码（ISO 3166-1两位）
+	BlockASNs          []uint   `yaml:"block_asns" json:"block_asns"`                   // 直接拒绝的ASN
+	ChallengeCountries []string `yaml:"challenge_countries" json:"challenge_countries"` // 需要人机验证的国家代码
+	ChallengeASNs      []uint   `yaml:"challenge_asns" json:"challenge_asns"`           // 需要人机验证的ASN
+}
+
+// 返回命中的规则描述，没有命中时返回空字符串
+func matchGeo(info *geoip.Info, countries []string, asns []uint) string {
+	for _, country := range countries {
+		if info.Country != "" && strings.EqualFold(country, info.Country) {
+			return "国家" + info.Country
+		}
+	}
+	for _, asn := range asns {
+		if info.ASN != 0 && asn == info.ASN {
+			return fmt.Sprintf("AS%d", info.ASN)
+		}
+	}
+	return ""
+}
+
+// 影子模式配置：决策照常计算、记录和计数，但不执行
+type ShadowConfig struct {
+	Global  bool     `yaml:"global" json:"global"`   // 所有来源均为影子模式
+	Sources []string `yaml:"sources" json:"sources"` // 处于影子模式的决策来源
+}
+
+// 校验影子模式来源
+func (c ShadowConfig) Validate() error {
+	for _, source := range c.Sources {
+		known := false
+		for _, s := range Sources {
+			if s == source {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("未知的影子模式来源: %s（可选: %s）", source, strings.Join(Sources, ", "))
+		}
+	}
+	return nil
+}
+
+// 决策来源是否处于影子模式
+func (c ShadowConfig) Contains(source string) bool {
+	if source == "" {
+		return false
+	}
+	if c.Global {
+		return true
+	}
+	for _, s := range c.Sources {
+		if s == source {
+			return true
+		}
+	}
+	return false
 }
 
 // 默认限制器配置
@@ -31,34 +101,110 @@ var DefaultLimiterConfig = LimiterConfig{
 
 // 限制决策
 type LimitDecision struct {
-	Action      string        `json:"action"`       // "allow", "delay", "challenge", "ban"
+	Action      string        `json:"action"`       // "allow", "delay", "challenge", "ban", "reject"
 	Reason      string        `json:"reason"`       // 限制原因
 	Delay       time.Duration `json:"delay"`        // 延迟时间
 	BanDuration time.Duration `json:"ban_duration"` // 封禁时长
 	Headers     map[string]string `json:"headers"`  // 响应头
 	StatusCode  int           `json:"status_code"`  // HTTP状态码
 	Message     string        `json:"message"`      // 响应消息
+	Degraded    bool          `json:"degraded"`     // 是否为依赖故障时的降级决策
+	Category    string        `json:"category"`     // 原因分类，用于监控统计
+	Source      string        `json:"source"`       // 决策来源，用于影子模式
+	Shadows     []*LimitDecision `json:"shadows,omitempty"` // 影子模式下被跳过的决策，按评估顺序排列
 }
 
+// 决策来源
+const (
+	SourceRules     = "rules"      // 封禁名单（手动封禁及已生效的封禁）和地区规则
+	SourceRateLimit = "rate_limit" // 请求频率限制
+	SourceScore     = "score"      // 用户分数限制
+	SourceAnalysis  = "analysis"   // 行为分析限制
+)
+
+// 全部决策来源
+var Sources = []string{SourceRules, SourceRateLimit, SourceScore, SourceAnalysis}
+
+// 限制原因分类
+const (
+	CategoryNormal      = "normal"       // 正常访问
+	CategoryBanned      = "banned"       // 已在封禁期内
+	CategoryRateLimit   = "rate_limit"   // 请求频率过高
+	CategoryScore       = "score"        // 用户分数过低
+	CategoryRisk        = "risk"         // 行为分析风险等级
+	CategoryBot         = "bot"          // 机器人行为
+	CategoryScanning    = "scanning"     // 恶意扫描
+	CategoryDegraded    = "degraded"     // 依赖故障降级
+	CategoryVerifiedBot = "verified_bot" // 已验证的搜索引擎爬虫
+	CategoryGeo         = "geo"          // 国家或ASN规则
+)
+
 type Limiter struct {
-	config      LimiterConfig
-	redisClient *storage.RedisClient
+	mu     sync.RWMutex // 保护config，配置可通过管理接口在运行时更新
+	config LimiterConfig
+	store  storage.Store
+	clock  clock.Clock
 }
 
-func NewLimiter(config LimiterConfig, redisClient *storage.RedisClient) *Limiter {
+func NewLimiter(config LimiterConfig, store storage.Store) *Limiter {
 	return &Limiter{
-		config:      config,
-		redisClient: redisClient,
+		config: config,
+		store:  store,
+		clock:  clock.Real{},
 	}
 }
 
-// 检查并应用限制
-func (l *Limiter) CheckLimit(fingerprint string, userScore int, analysisResult *analyzer.AnalysisResult) (*LimitDecision, error) {
-	// 1. 首先检查是否已被封禁
-	if banned, duration, err := l.redisClient.IsUserBanned(fingerprint); err == nil && banned {
-		return &LimitDecision{
+// 替换时间来源（回放时使用模拟时钟）
+func (l *Limiter) SetClock(c clock.Clock) {
+	l.clock = c
+}
+
+// 当前配置的快照，单次检查内使用同一份配置
+func (l *Limiter) snapshot() LimiterConfig {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.config
+}
+
+// 单次限制检查的状态：同一份配置快照和按评估顺序收集的影子决策
+type limitCheck struct {
+	limiter     *Limiter
+	config      LimiterConfig
+	fingerprint string
+	shadows     []*LimitDecision
+}
+
+func (l *Limiter) newCheck(fingerprint string) *limitCheck {
+	return &limitCheck{limiter: l, config: l.snapshot(), fingerprint: fingerprint}
+}
+
+// 影子模式下的来源只记录决策并返回nil，继续评估后续来源；否则执行决策并附上之前的影子决策
+func (c *limitCheck) resolve(decision *LimitDecision) *LimitDecision {
+	if decision == nil {
+		return nil
+	}
+	if c.config.Shadow.Contains(decision.Source) {
+		c.shadows = append(c.shadows, decision)
+		return nil
+	}
+	c.limiter.enforce(c.fingerprint, decision)
+	decision.Shadows = c.shadows
+	return decision
+}
+
+// 封禁状态和国家、ASN规则，对所有请求（包括已验证的爬虫）生效
+func (c *limitCheck) checkRules(geo *geoip.Info) (*LimitDecision, error) {
+	// 首先检查是否已被封禁
+	banned, duration, err := c.limiter.store.IsUserBanned(c.fingerprint)
+	if err != nil {
+		return nil, fmt.Errorf("检查封禁状态失败: %v", err)
+	}
+	if banned {
+		decision := c.resolve(&LimitDecision{
 			Action:      "ban",
 			Reason:      "用户已被封禁",
+			Category:    CategoryBanned,
+			Source:      SourceRules,
 			BanDuration: duration,
 			StatusCode:  403,
 			Message:     fmt.Sprintf("您已被封禁，剩余时间: %v", duration.Round(time.Minute)),
@@ -66,22 +212,48 @@ func (l *Limiter) CheckLimit(fingerprint string, userScore int, analysisResult *
 				"X-Rate-Limit-Status": "banned",
 				"Retry-After":         fmt.Sprintf("%.0f", duration.Seconds()),
 			},
-		}, nil
+		})
+		if decision != nil {
+			return decision, nil
+		}
+	}
+
+	// 检查国家和ASN规则
+	if geo != nil {
+		if decision := c.resolve(c.limiter.checkGeoRules(c.config, geo)); decision != nil {
+			return decision, nil
+		}
+	}
+	return nil, nil
+}
+
+// 检查并应用限制，geo为空时跳过国家和ASN规则
+// 影子模式下的来源只计算决策不执行，继续评估后续来源，被跳过的决策按评估顺序记录在返回结果的Shadows中
+func (l *Limiter) CheckLimit(fingerprint string, userScore int, analysisResult *analyzer.AnalysisResult, geo *geoip.Info) (*LimitDecision, error) {
+	check := l.newCheck(fingerprint)
+
+	// 1. 封禁状态、国家和ASN规则
+	if decision, err := check.checkRules(geo); err != nil || decision != nil {
+		return decision, err
 	}
 
 	// 2. 检查请求频率
-	if decision := l.checkRateLimit(fingerprint); decision != nil {
+	decision, err := l.checkRateLimit(check.config, fingerprint)
+	if err != nil {
+		return nil, fmt.Errorf("检查请求频率失败: %v", err)
+	}
+	if decision := check.resolve(decision); decision != nil {
 		return decision, nil
 	}
 
 	// 3. 基于用户分数决策
-	if decision := l.checkScoreBasedLimit(fingerprint, userScore); decision != nil {
+	if decision := check.resolve(l.checkScoreBasedLimit(check.config, fingerprint, userScore)); decision != nil {
 		return decision, nil
 	}
 
 	// 4. 基于行为分析结果决策
 	if analysisResult != nil {
-		if decision := l.checkAnalysisBasedLimit(fingerprint, analysisResult); decision != nil {
+		if decision := check.resolve(l.checkAnalysisBasedLimit(check.config, fingerprint, analysisResult)); decision != nil {
 			return decision, nil
 		}
 	}
@@ -90,61 +262,157 @@ func (l *Limiter) CheckLimit(fingerprint string, userScore int, analysisResult *
 	return &LimitDecision{
 		Action:     "allow",
 		Reason:     "正常访问",
+		Category:   CategoryNormal,
 		StatusCode: 200,
 		Headers: map[string]string{
 			"X-Rate-Limit-Status": "ok",
 		},
+		Shadows: check.shadows,
 	}, nil
 }
 
+// 已验证爬虫的限制检查：封禁和国家、ASN规则照常生效，爬虫策略代替频率、分数和行为分析限制
+// limit为空时直接放行，否则同一爬虫的所有请求共享限流窗口，超出时返回429（频率限制处于影子模式时放行）
+func (l *Limiter) CheckVerifiedBot(fingerprint, crawler string, limit *LocalLimiter, geo *geoip.Info) (*LimitDecision, error) {
+	check := l.newCheck(fingerprint)
+	if decision, err := check.checkRules(geo); err != nil || decision != nil {
+		return decision, err
+	}
+
+	decision := &LimitDecision{
+		Action:     "allow",
+		Reason:     fmt.Sprintf("已验证的爬虫: %s", crawler),
+		Category:   CategoryVerifiedBot,
+		StatusCode: http.StatusOK,
+		Headers: map[string]string{
+			"X-Rate-Limit-Status": "ok",
+		},
+	}
+
+	if limit != nil {
+		if allowed, remaining := limit.Allow(crawler); !allowed {
+			limited := check.resolve(&LimitDecision{
+				Action:     "reject",
+				Reason:     fmt.Sprintf("已验证的爬虫%s超出限流: %d/%s", crawler, limit.config.MaxRequests, limit.config.Window),
+				Category:   CategoryVerifiedBot,
+				Source:     SourceRateLimit,
+				StatusCode: http.StatusTooManyRequests,
+				Message:    "请求过于频繁，请稍后再试",
+				Headers: map[string]string{
+					"X-Rate-Limit-Status": "rate_limited",
+					"Retry-After":         fmt.Sprintf("%.0f", remaining.Seconds()),
+				},
+			})
+			if limited != nil {
+				return limited, nil
+			}
+		}
+	}
+
+	decision.Shadows = check.shadows
+	return decision, nil
+}
+
+// 判断决策来源是否处于影子模式
+func (l *Limiter) IsShadow(source string) bool {
+	return l.snapshot().Shadow.Contains(source)
+}
+
+// 执行决策的副作用（新的封禁写入存储）
+func (l *Limiter) enforce(fingerprint string, decision *LimitDecision) {
+	if decision.Action != "ban" || decision.Category == CategoryBanned {
+		return
+	}
+	if err := l.store.BanUser(fingerprint, decision.BanDuration); err != nil {
+		// 记录错误但继续执行
+		log.Printf("封禁用户时出错: %v", err)
+	}
+}
+
 // 检查频率限制
-func (l *Limiter) checkRateLimit(fingerprint string) *LimitDecision {
-	rate, err := l.redisClient.GetRequestRate(fingerprint)
+func (l *Limiter) checkRateLimit(config LimiterConfig, fingerprint string) (*LimitDecision, error) {
+	rate, err := l.store.GetRequestRate(fingerprint)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
-	if rate > l.config.MaxRequestsPerWindow {
+	if rate > config.MaxRequestsPerWindow {
 		// 超过频率限制，应用延迟
-		delay := time.Duration(l.config.DelayResponseMs) * time.Millisecond
+		delay := time.Duration(config.DelayResponseMs) * time.Millisecond
 		
 		// 根据超出程度调整延迟
-		if rate > l.config.MaxRequestsPerWindow*2 {
+		if rate > config.MaxRequestsPerWindow*2 {
 			delay *= 3
-		} else if rate > l.config.MaxRequestsPerWindow*1.5 {
+		} else if rate*2 > config.MaxRequestsPerWindow*3 {
 			delay *= 2
 		}
 
 		return &LimitDecision{
 			Action:     "delay",
-			Reason:     fmt.Sprintf("请求频率过高: %d/%s", rate, l.config.RateLimitWindow),
+			Reason:     fmt.Sprintf("请求频率过高: %d/%s", rate, config.RateLimitWindow),
+			Category:   CategoryRateLimit,
+			Source:     SourceRateLimit,
 			Delay:      delay,
 			StatusCode: 429,
 			Headers: map[string]string{
 				"X-Rate-Limit-Status":    "rate_limited",
-				"X-Rate-Limit-Limit":     fmt.Sprintf("%d", l.config.MaxRequestsPerWindow),
+				"X-Rate-Limit-Limit":     fmt.Sprintf("%d", config.MaxRequestsPerWindow),
 				"X-Rate-Limit-Remaining": "0",
-				"X-Rate-Limit-Reset":     fmt.Sprintf("%d", time.Now().Add(l.config.RateLimitWindow).Unix()),
+				"X-Rate-Limit-Reset":     fmt.Sprintf("%d", l.clock.Now().Add(config.RateLimitWindow).Unix()),
 				"Retry-After":            fmt.Sprintf("%.0f", delay.Seconds()),
 			},
-		}
+		}, nil
 	}
 
+	return nil, nil
+}
+
+// 国家和ASN规则检查，拒绝优先于人机验证
+func (l *Limiter) checkGeoRules(config LimiterConfig, geo *geoip.Info) *LimitDecision {
+	rules := config.Geo
+	if rule := matchGeo(geo, rules.BlockCountries, rules.BlockASNs); rule != "" {
+		return &LimitDecision{
+			Action:     "reject",
+			Reason:     fmt.Sprintf("命中地区拒绝规则: %s", rule),
+			Category:   CategoryGeo,
+			Source:     SourceRules,
+			StatusCode: http.StatusForbidden,
+			Message:    "您所在的地区或网络无法访问",
+			Headers: map[string]string{
+				"X-Rate-Limit-Status": "geo_blocked",
+			},
+		}
+	}
+	if rule := matchGeo(geo, rules.ChallengeCountries, rules.ChallengeASNs); rule != "" {
+		return &LimitDecision{
+			Action:     "challenge",
+			Reason:     fmt.Sprintf("命中地区验证规则: %s", rule),
+			Category:   CategoryGeo,
+			Source:     SourceRules,
+			StatusCode: 429,
+			Headers: map[string]string{
+				"X-Rate-Limit-Status": "challenge_required",
+			},
+			Message: "需要完成人机验证",
+		}
+	}
 	return nil
 }
 
 // 基于分数的限制检查
-func (l *Limiter) checkScoreBasedLimit(fingerprint string, score int) *LimitDecision {
+func (l *Limiter) checkScoreBasedLimit(config LimiterConfig, fingerprint string, score int) *LimitDecision {
 	if score <= 0 {
 		// 分数为0或负数，封禁
-		return l.banUser(fingerprint, "用户分数过低", l.config.BanDuration)
+		return l.banUser(SourceScore, CategoryScore, "用户分数过低", config.BanDuration)
 	}
 
-	if score < l.config.CriticalThreshold {
+	if score < config.CriticalThreshold {
 		// 分数过低，需要人机验证
 		return &LimitDecision{
 			Action:     "challenge",
 			Reason:     fmt.Sprintf("用户分数过低: %d", score),
+			Category:   CategoryScore,
+			Source:     SourceScore,
 			StatusCode: 429,
 			Headers: map[string]string{
 				"X-Rate-Limit-Status": "challenge_required",
@@ -154,12 +422,14 @@ func (l *Limiter) checkScoreBasedLimit(fingerprint string, score int) *LimitDeci
 		}
 	}
 
-	if score < l.config.WarningThreshold {
+	if score < config.WarningThreshold {
 		// 分数较低，限速
-		delay := time.Duration(l.config.DelayResponseMs*2) * time.Millisecond
+		delay := time.Duration(config.DelayResponseMs*2) * time.Millisecond
 		return &LimitDecision{
 			Action:     "delay",
 			Reason:     fmt.Sprintf("用户分数较低: %d", score),
+			Category:   CategoryScore,
+			Source:     SourceScore,
 			Delay:      delay,
 			StatusCode: 200,
 			Headers: map[string]string{
@@ -173,18 +443,20 @@ func (l *Limiter) checkScoreBasedLimit(fingerprint string, score int) *LimitDeci
 }
 
 // 基于行为分析的限制检查
-func (l *Limiter) checkAnalysisBasedLimit(fingerprint string, result *analyzer.AnalysisResult) *LimitDecision {
+func (l *Limiter) checkAnalysisBasedLimit(config LimiterConfig, fingerprint string, result *analyzer.AnalysisResult) *LimitDecision {
 	switch result.RiskLevel {
 	case "critical":
 		// 严重风险，立即封禁
-		duration := l.config.BanDuration * 2 // 加倍封禁时间
-		return l.banUser(fingerprint, fmt.Sprintf("严重风险行为: %.1f", result.RiskScore), duration)
+		duration := config.BanDuration * 2 // 加倍封禁时间
+		return l.banUser(SourceAnalysis, CategoryRisk, fmt.Sprintf("严重风险行为: %.1f", result.RiskScore), duration)
 
 	case "high":
 		// 高风险，需要人机验证
 		return &LimitDecision{
 			Action:     "challenge",
 			Reason:     fmt.Sprintf("高风险行为: %.1f", result.RiskScore),
+			Category:   CategoryRisk,
+			Source:     SourceAnalysis,
 			StatusCode: 429,
 			Headers: map[string]string{
 				"X-Rate-Limit-Status": "high_risk",
@@ -195,10 +467,12 @@ func (l *Limiter) checkAnalysisBasedLimit(fingerprint string, result *analyzer.A
 
 	case "medium":
 		// 中等风险，限速
-		delay := time.Duration(l.config.DelayResponseMs*3) * time.Millisecond
+		delay := time.Duration(config.DelayResponseMs*3) * time.Millisecond
 		return &LimitDecision{
 			Action:     "delay",
 			Reason:     fmt.Sprintf("中等风险行为: %.1f", result.RiskScore),
+			Category:   CategoryRisk,
+			Source:     SourceAnalysis,
 			Delay:      delay,
 			StatusCode: 200,
 			Headers: map[string]string{
@@ -211,29 +485,24 @@ func (l *Limiter) checkAnalysisBasedLimit(fingerprint string, result *analyzer.A
 	// 检查特定行为模式
 	for _, behavior := range result.Behaviors {
 		if behavior.Type == "bot_behavior" && behavior.Confidence > 0.8 {
-			return l.banUser(fingerprint, "检测到机器人行为", l.config.BanDuration)
+			return l.banUser(SourceAnalysis, CategoryBot, "检测到机器人行为", config.BanDuration)
 		}
 
 		if behavior.Type == "scanning_behavior" && behavior.Severity == "danger" {
-			return l.banUser(fingerprint, "检测到恶意扫描", l.config.BanDuration*3)
+			return l.banUser(SourceAnalysis, CategoryScanning, "检测到恶意扫描", config.BanDuration*3)
 		}
 	}
 
 	return nil
 }
 
-// 封禁用户
-func (l *Limiter) banUser(fingerprint, reason string, duration time.Duration) *LimitDecision {
-	// 在Redis中记录封禁
-	err := l.redisClient.BanUser(fingerprint, duration)
-	if err != nil {
-		// 记录错误但继续执行
-		fmt.Printf("封禁用户时出错: %v\n", err)
-	}
-
+// 封禁决策，由CheckLimit在执行时写入存储
+func (l *Limiter) banUser(source, category, reason string, duration time.Duration) *LimitDecision {
 	return &LimitDecision{
 		Action:      "ban",
 		Reason:      reason,
+		Category:    category,
+		Source:      source,
 		BanDuration: duration,
 		StatusCode:  403,
 		Message:     fmt.Sprintf("您已被封禁，原因: %s，时长: %v", reason, duration.Round(time.Minute)),
@@ -275,6 +544,17 @@ func (l *Limiter) ApplyDecision(w http.ResponseWriter, r *http.Request, decision
 		l.writeBanResponse(w, decision)
 		return true // 阻止请求
 
+	case "reject":
+		// 依赖故障、爬虫限流或地区规则拒绝请求
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(decision.StatusCode)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":   "rejected",
+			"message": decision.Message,
+			"reason":  decision.Reason,
+		})
+		return true // 阻止请求
+
 	default:
 		return false
 	}
@@ -295,15 +575,7 @@ func (l *Limiter) writeChallengeResponse(w http.ResponseWriter, decision *LimitD
 	}
 
 	// 这里应该返回实际的验证码页面或API响应
-	fmt.Fprintf(w, `{
-		"error": "challenge_required",
-		"message": "%s",
-		"reason": "%s",
-		"challenge": {
-			"type": "captcha",
-			"url": "/api/v1/challenge"
-		}
-	}`, decision.Message, decision.Reason)
+	json.NewEncoder(w).Encode(response)
 }
 
 // 写入封禁响应
@@ -322,24 +594,40 @@ func (l *Limiter) writeBanResponse(w http.ResponseWriter, decision *LimitDecisio
 
 // 手动封禁用户
 func (l *Limiter) ManualBan(fingerprint, reason string, duration time.Duration) error {
-	return l.redisClient.BanUser(fingerprint, duration)
+	return l.store.BanUser(fingerprint, duration)
 }
 
 // 解除封禁
 func (l *Limiter) Unban(fingerprint string) error {
-	return l.redisClient.UnbanUser(fingerprint)
+	return l.store.UnbanUser(fingerprint)
 }
 
 // 获取封禁状态
 func (l *Limiter) GetBanStatus(fingerprint string) (bool, time.Duration, error) {
-	return l.redisClient.IsUserBanned(fingerprint)
+	return l.store.IsUserBanned(fingerprint)
 }
 
 // 更新配置
 func (l *Limiter) UpdateConfig(config LimiterConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	l.config = config
 }
 
+// 当前影子模式配置
+func (l *Limiter) ShadowConfig() ShadowConfig {
+	return l.snapshot().Shadow
+}
+
+// 更新影子模式配置
+// 来源列表复制一份，调用方之后修改原切片不影响正在进行的检查
+func (l *Limiter) UpdateShadow(shadow ShadowConfig) {
+	shadow.Sources = append([]string(nil), shadow.Sources...)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.config.Shadow = shadow
+}
+
 // 获取限制统计信息
 func (l *Limiter) GetLimitStats() (map[string]interface{}, error) {
 	stats := make(map[string]interface{})
@@ -381,13 +669,13 @@ func (l *Limiter) Middleware() func(http.Handler) http.Handler {
 			}
 
 			// 增加请求计数
-			l.redisClient.IncrementRequestRate(fingerprint)
+			l.store.IncrementRequestRate(fingerprint)
 
 			// 获取用户分数（这里需要与scorer模块集成）
-			userScore, _ := l.redisClient.GetUserScore(fingerprint)
+			userScore, _ := l.store.GetUserScore(fingerprint)
 			
 			// 检查限制
-			decision, err := l.CheckLimit(fingerprint, userScore.Score, nil)
+			decision, err := l.CheckLimit(fingerprint, userScore.Score, nil, nil)
 			if err != nil {
 				// 错误处理
 				http.Error(w, "Internal Server Error", 500)
@@ -416,16 +704,10 @@ func (l *Limiter) VerifyChallenge(fingerprint, challengeResponse string) bool {
 
 // 创建白名单
 func (l *Limiter) AddToWhitelist(fingerprint string, duration time.Duration) error {
-	key := fmt.Sprintf("whitelist:%s", fingerprint)
-	return l.redisClient.client.Set(l.redisClient.ctx, key, "whitelisted", duration).Err()
+	return l.store.AddToWhitelist(fingerprint, duration)
 }
 
 // 检查白名单
 func (l *Limiter) IsWhitelisted(fingerprint string) (bool, error) {
-	key := fmt.Sprintf("whitelist:%s", fingerprint)
-	_, err := l.redisClient.client.Get(l.redisClient.ctx, key).Result()
-	if err != nil {
-		return false, nil // 不在白名单中
-	}
-	return true, nil
+	return l.store.IsWhitelisted(fingerprint)
 }
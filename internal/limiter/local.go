@@ -0,0 +1,130 @@
+package limiter
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 本地限流配置（依赖故障时的降级限流）
+type LocalLimiterConfig struct {
+	Window      time.Duration `yaml:"window"`       // 计数窗口
+	MaxRequests int           `yaml:"max_requests"` // 每个窗口最大请求数
+	MaxEntries  int           `yaml:"max_entries"`  // 最多跟踪的指纹数
+}
+
+// 默认本地限流配置
+var DefaultLocalLimiterConfig = LocalLimiterConfig{
+	Window:      time.Minute,
+	MaxRequests: 60,
+	MaxEntries:  100000,
+}
+
+type localWindow struct {
+	start time.Time
+	count int
+}
+
+// 进程内固定窗口限流器，不依赖Redis
+type LocalLimiter struct {
+	config  LocalLimiterConfig
+	mu      sync.Mutex
+	windows map[string]*localWindow
+}
+
+// 创建本地限流器
+func NewLocalLimiter(config LocalLimiterConfig) *LocalLimiter {
+	if config.Window <= 0 {
+		config.Window = DefaultLocalLimiterConfig.Window
+	}
+	if config.MaxRequests <= 0 {
+		config.MaxRequests = DefaultLocalLimiterConfig.MaxRequests
+	}
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = DefaultLocalLimiterConfig.MaxEntries
+	}
+	return &LocalLimiter{
+		config:  config,
+		windows: make(map[string]*localWindow),
+	}
+}
+
+// 计数并判断是否允许，返回窗口剩余时间
+func (ll *LocalLimiter) Allow(key string) (bool, time.Duration) {
+	now := time.Now()
+
+	ll.mu.Lock()
+	defer ll.mu.Unlock()
+
+	window, ok := ll.windows[key]
+	if !ok || now.Sub(window.start) >= ll.config.Window {
+		if !ok && len(ll.windows) >= ll.config.MaxEntries {
+			ll.evictExpired(now)
+		}
+		window = &localWindow{start: now}
+		ll.windows[key] = window
+	}
+	window.count++
+
+	remaining := ll.config.Window - now.Sub(window.start)
+	return window.count <= ll.config.MaxRequests, remaining
+}
+
+// 清理过期窗口，仍然超出容量时整体重置
+func (ll *LocalLimiter) evictExpired(now time.Time) {
+	for key, window := range ll.windows {
+		if now.Sub(window.start) >= ll.config.Window {
+			delete(ll.windows, key)
+		}
+	}
+	if len(ll.windows) >= ll.config.MaxEntries {
+		ll.windows = make(map[string]*localWindow)
+	}
+}
+
+// 本地限流决策
+func (ll *LocalLimiter) Decide(fingerprint string) *LimitDecision {
+	allowed, remaining := ll.Allow(fingerprint)
+	if allowed {
+		return &LimitDecision{
+			Action:     "allow",
+			Reason:     "降级模式: 本地限流放行",
+			StatusCode: http.StatusOK,
+			Degraded:   true,
+			Category:   CategoryDegraded,
+			Headers: map[string]string{
+				"X-Rate-Limit-Status": "degraded",
+			},
+		}
+	}
+
+	return &LimitDecision{
+		Action:     "reject",
+		Reason:     fmt.Sprintf("降级模式: 本地限流 %d/%s", ll.config.MaxRequests, ll.config.Window),
+		StatusCode: http.StatusTooManyRequests,
+		Message:    "请求过于频繁，请稍后再试",
+		Degraded:   true,
+		Category:   CategoryDegraded,
+		Headers: map[string]string{
+			"X-Rate-Limit-Status": "degraded_rate_limited",
+			"Retry-After":         fmt.Sprintf("%.0f", remaining.Seconds()),
+		},
+	}
+}
+
+// 依赖故障时拒绝请求的决策
+func FailClosedDecision(reason string) *LimitDecision {
+	return &LimitDecision{
+		Action:     "reject",
+		Reason:     "降级模式: " + reason,
+		StatusCode: http.StatusServiceUnavailable,
+		Message:    "服务暂时不可用，请稍后再试",
+		Degraded:   true,
+		Category:   CategoryDegraded,
+		Headers: map[string]string{
+			"X-Rate-Limit-Status": "unavailable",
+			"Retry-After":         "10",
+		},
+	}
+}
@@ -0,0 +1,167 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "firewall"
+
+// 决策管道阶段
+const (
+	StageCollect     = "collect"
+	StageFingerprint = "fingerprint"
+	StageScore       = "score"
+	StageAnalyze     = "analyze"
+	StageLimit       = "limit"
+	StagePersist     = "persist"
+)
+
+// 指标注册表，只包含本服务的指标和Go运行时指标
+var Registry = prometheus.NewRegistry()
+
+var (
+	// 按动作和原因分类统计的决策数
+	Decisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "decisions_total",
+		Help:      "Firewall decisions by action and reason category.",
+	}, []string{"action", "reason"})
+
+	// 依赖故障时按模式统计的降级决策数
+	DegradedDecisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "degraded_decisions_total",
+		Help:      "Decisions made while a dependency was failing, by failure mode.",
+	}, []string{"mode"})
+
+	// 决策管道各阶段耗时
+	StageDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "pipeline_stage_duration_seconds",
+		Help:      "Latency of each decision pipeline stage.",
+		Buckets:   []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"stage"})
+
+	// 决策管道总耗时（不含限速延迟）
+	PipelineDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "pipeline_duration_seconds",
+		Help:      "Total decision pipeline latency, excluding applied delays.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	})
+
+	// 存储调用耗时
+	StorageDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_call_duration_seconds",
+		Help:      "Latency of Redis and MySQL calls.",
+		Buckets:   []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"backend", "operation"})
+
+	// 存储调用错误数
+	StorageErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "storage_call_errors_total",
+		Help:      "Failed Redis and MySQL calls.",
+	}, []string{"backend", "operation"})
+
+	// 当前封禁的指纹数
+	ActiveBans = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_bans",
+		Help:      "Fingerprints currently banned.",
+	})
+
+	// 当前白名单指纹数
+	WhitelistedFingerprints = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "whitelisted_fingerprints",
+		Help:      "Fingerprints currently whitelisted.",
+	})
+
+	// 访问日志写入队列深度
+	WriteQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "access_write_queue_depth",
+		Help:      "Access records waiting to be written to MySQL.",
+	})
+
+	// 事件流丢弃的事件数（redis: 跨实例广播, subscriber: 慢订阅者, siem: SIEM导出）
+	EventsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_dropped_total",
+		Help:      "Decision events dropped by the event bus.",
+	}, []string{"target"})
+
+	// 影子模式下计算出但未执行的决策数
+	ShadowDecisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "shadow_decisions_total",
+		Help:      "Decisions computed in shadow mode but not enforced, by source and would-be action.",
+	}, []string{"source", "action"})
+
+	// 当前事件流订阅者数
+	EventSubscribers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "event_subscribers",
+		Help:      "Active decision event stream subscribers.",
+	})
+
+	// 自称搜索引擎爬虫的请求按验证结果统计
+	BotVerifications = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bot_verifications_total",
+		Help:      "Requests claiming to be a known crawler, by crawler and verification status.",
+	}, []string{"crawler", "status"})
+
+	// 队列满时丢弃的访问记录数
+	WriteQueueDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "access_write_dropped_total",
+		Help:      "Access records dropped because the write queue was full.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		Decisions,
+		DegradedDecisions,
+		StageDuration,
+		PipelineDuration,
+		StorageDuration,
+		StorageErrors,
+		ActiveBans,
+		WhitelistedFingerprints,
+		WriteQueueDepth,
+		WriteQueueDropped,
+		EventsDropped,
+		EventSubscribers,
+		ShadowDecisions,
+		BotVerifications,
+	)
+}
+
+// 指标HTTP处理器
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// 记录阶段耗时
+func ObserveStage(stage string, start time.Time) {
+	StageDuration.WithLabelValues(stage).Observe(time.Since(start).Seconds())
+}
+
+// 记录存储调用耗时和错误
+func ObserveStorage(backend, operation string, start time.Time, err error) {
+	StorageDuration.WithLabelValues(backend, operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		StorageErrors.WithLabelValues(backend, operation).Inc()
+	}
+}
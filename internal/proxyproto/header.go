@@ -0,0 +1,385 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// v2协议签名
+var v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// v1头最大长度（含CRLF）
+const v1MaxLength = 107
+
+var (
+	// 连接开头不是PROXY协议头
+	ErrNoHeader = errors.New("缺少PROXY协议头")
+	// CRC32C校验失败
+	ErrChecksum = errors.New("PROXY协议头CRC32C校验失败")
+)
+
+// 命令
+type Command byte
+
+const (
+	CommandLocal Command = 0x0 // 负载均衡自身发起的连接（如健康检查），没有客户端地址
+	CommandProxy Command = 0x1 // 代理客户端连接
+)
+
+// v2 TLV类型
+const (
+	TypeALPN      byte = 0x01
+	TypeAuthority byte = 0x02
+	TypeCRC32C    byte = 0x03
+	TypeNoop      byte = 0x04
+	TypeUniqueID  byte = 0x05
+	TypeSSL       byte = 0x20
+	TypeNetNS     byte = 0x30
+
+	subTypeSSLVersion byte = 0x21
+	subTypeSSLCN      byte = 0x22
+	subTypeSSLCipher  byte = 0x23
+	subTypeSSLSigAlg  byte = 0x24
+	subTypeSSLKeyAlg  byte = 0x25
+)
+
+// PP2_TYPE_SSL中client字段的标志位
+const (
+	SSLClientSSL      byte = 0x01 // 客户端通过TLS连接
+	SSLClientCertConn byte = 0x02 // 客户端在本连接提供了证书
+	SSLClientCertSess byte = 0x04 // 客户端在会话中提供过证书
+)
+
+// 类型-长度-值扩展字段
+type TLV struct {
+	Type  byte   `json:"type"`
+	Value []byte `json:"value"`
+}
+
+// 负载均衡终结TLS时传递的信息（PP2_TYPE_SSL）
+type TLSInfo struct {
+	Client     byte   `json:"client"`                // 标志位，见SSLClient*
+	Verified   bool   `json:"verified"`              // 客户端证书校验通过（verify字段为0）
+	Version    string `json:"version,omitempty"`     // 如 TLSv1.3
+	CommonName string `json:"common_name,omitempty"` // 客户端证书CN
+	Cipher     string `json:"cipher,omitempty"`
+	SigAlg     string `json:"sig_alg,omitempty"`
+	KeyAlg     string `json:"key_alg,omitempty"`
+}
+
+// 解码后的PROXY协议头
+type Header struct {
+	Version     int      // 1 或 2
+	Command     Command  // v1始终为CommandProxy
+	Protocol    string   // tcp4 / tcp6 / udp4 / udp6 / unix / unknown
+	Source      net.Addr // 客户端地址，LOCAL命令或UNKNOWN协议时为空
+	Destination net.Addr // 负载均衡接收连接的地址
+	TLVs        []TLV    // v2扩展字段（原始内容）
+	Authority   string   // PP2_TYPE_AUTHORITY，通常为TLS SNI
+	ALPN        string   // PP2_TYPE_ALPN
+	UniqueID    []byte   // PP2_TYPE_UNIQUE_ID
+	TLS         *TLSInfo // PP2_TYPE_SSL
+}
+
+// 读取并解析PROXY协议头（v1或v2），reader停在头之后的第一个字节
+func ReadHeader(r *bufio.Reader) (*Header, error) {
+	prefix, err := r.Peek(len(v2Signature))
+	if err == nil && bytes.Equal(prefix, v2Signature) {
+		return readV2(r)
+	}
+	prefix, err = r.Peek(6)
+	if err == nil && string(prefix) == "PROXY " {
+		return readV1(r)
+	}
+	if err != nil && len(prefix) == 0 {
+		return nil, err
+	}
+	return nil, ErrNoHeader
+}
+
+// v1: "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n"
+func readV1(r *bufio.Reader) (*Header, error) {
+	var line []byte
+	for len(line) < v1MaxLength {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("读取v1头失败: %v", err)
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, fmt.Errorf("v1头超过%d字节或缺少CRLF", v1MaxLength)
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("无效的v1头: %q", line)
+	}
+
+	header := &Header{Version: 1, Command: CommandProxy}
+	switch fields[1] {
+	case "UNKNOWN":
+		// 其余字段应被忽略
+		header.Protocol = "unknown"
+		return header, nil
+	case "TCP4", "TCP6":
+		header.Protocol = strings.ToLower(fields[1])
+	default:
+		return nil, fmt.Errorf("不支持的v1协议: %q", fields[1])
+	}
+	if len(fields) != 6 {
+		return nil, fmt.Errorf("无效的v1头: %q", line)
+	}
+
+	source, err := parseV1Addr(fields[2], fields[4], header.Protocol)
+	if err != nil {
+		return nil, err
+	}
+	destination, err := parseV1Addr(fields[3], fields[5], header.Protocol)
+	if err != nil {
+		return nil, err
+	}
+	header.Source, header.Destination = source, destination
+	return header, nil
+}
+
+func parseV1Addr(host, port, protocol string) (*net.TCPAddr, error) {
+	ip := net.ParseIP(host)
+	if ip == nil || (protocol == "tcp6") != strings.Contains(host, ":") {
+		return nil, fmt.Errorf("无效的%s地址: %q", protocol, host)
+	}
+	// 端口为0-65535的十进制数，不允许前导0
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 0 || n > 65535 || (len(port) > 1 && port[0] == '0') {
+		return nil, fmt.Errorf("无效的端口: %q", port)
+	}
+	return &net.TCPAddr{IP: ip, Port: n}, nil
+}
+
+// v2: 16字节固定头 + 地址 + TLV
+func readV2(r *bufio.Reader) (*Header, error) {
+	fixed := make([]byte, 16)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, fmt.Errorf("读取v2头失败: %v", err)
+	}
+
+	if fixed[12]>>4 != 0x2 {
+		return nil, fmt.Errorf("不支持的v2版本: %#x", fixed[12]>>4)
+	}
+	header := &Header{Version: 2, Command: Command(fixed[12] & 0x0f)}
+	if header.Command != CommandLocal && header.Command != CommandProxy {
+		return nil, fmt.Errorf("不支持的v2命令: %#x", fixed[12]&0x0f)
+	}
+
+	length := int(binary.BigEndian.Uint16(fixed[14:16]))
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, fmt.Errorf("读取v2地址失败: %v", err)
+	}
+
+	family, transport := fixed[13]>>4, fixed[13]&0x0f
+	var addrLength int
+	switch family {
+	case 0x0:
+		header.Protocol = "unknown"
+	case 0x1:
+		addrLength = 12
+	case 0x2:
+		addrLength = 36
+	case 0x3:
+		addrLength = 216
+		header.Protocol = "unix"
+	default:
+		return nil, fmt.Errorf("不支持的v2地址族: %#x", family)
+	}
+	if transport > 0x2 {
+		return nil, fmt.Errorf("不支持的v2传输协议: %#x", transport)
+	}
+	if length < addrLength {
+		return nil, fmt.Errorf("v2地址长度不足: %d < %d", length, addrLength)
+	}
+
+	// LOCAL命令必须忽略地址信息
+	if header.Command == CommandProxy {
+		parseV2Addrs(header, family, transport, payload[:addrLength])
+	}
+
+	if err := parseTLVs(header, payload[addrLength:]); err != nil {
+		return nil, err
+	}
+
+	if tlv := header.tlv(TypeCRC32C); tlv != nil {
+		if err := verifyChecksum(fixed, payload, addrLength, tlv); err != nil {
+			return nil, err
+		}
+	}
+	return header, nil
+}
+
+func parseV2Addrs(header *Header, family, transport byte, addrs []byte) {
+	network := map[byte]string{0x1: "tcp", 0x2: "udp"}[transport]
+	switch family {
+	case 0x1, 0x2:
+		size := 4
+		if family == 0x2 {
+			size = 16
+		}
+		if network == "" {
+			header.Protocol = "unknown"
+			return
+		}
+		header.Protocol = network + map[byte]string{0x1: "4", 0x2: "6"}[family]
+		srcIP := net.IP(append([]byte(nil), addrs[:size]...))
+		dstIP := net.IP(append([]byte(nil), addrs[size:2*size]...))
+		srcPort := int(binary.BigEndian.Uint16(addrs[2*size:]))
+		dstPort := int(binary.BigEndian.Uint16(addrs[2*size+2:]))
+		if network == "udp" {
+			header.Source = &net.UDPAddr{IP: srcIP, Port: srcPort}
+			header.Destination = &net.UDPAddr{IP: dstIP, Port: dstPort}
+		} else {
+			header.Source = &net.TCPAddr{IP: srcIP, Port: srcPort}
+			header.Destination = &net.TCPAddr{IP: dstIP, Port: dstPort}
+		}
+	case 0x3:
+		trim := func(b []byte) string {
+			if i := bytes.IndexByte(b, 0); i >= 0 {
+				b = b[:i]
+			}
+			return string(b)
+		}
+		unixNetwork := "unix"
+		if network == "udp" {
+			unixNetwork = "unixgram"
+		}
+		header.Source = &net.UnixAddr{Name: trim(addrs[:108]), Net: unixNetwork}
+		header.Destination = &net.UnixAddr{Name: trim(addrs[108:216]), Net: unixNetwork}
+	}
+}
+
+func parseTLVs(header *Header, data []byte) error {
+	for len(data) > 0 {
+		if len(data) < 3 {
+			return fmt.Errorf("TLV长度不足")
+		}
+		tlvType := data[0]
+		length := int(binary.BigEndian.Uint16(data[1:3]))
+		if len(data) < 3+length {
+			return fmt.Errorf("TLV %#x 长度越界", tlvType)
+		}
+		value := append([]byte(nil), data[3:3+length]...)
+		data = data[3+length:]
+
+		header.TLVs = append(header.TLVs, TLV{Type: tlvType, Value: value})
+		switch tlvType {
+		case TypeALPN:
+			header.ALPN = string(value)
+		case TypeAuthority:
+			header.Authority = string(value)
+		case TypeUniqueID:
+			header.UniqueID = value
+		case TypeSSL:
+			info, err := parseSSL(value)
+			if err != nil {
+				return err
+			}
+			header.TLS = info
+		}
+	}
+	return nil
+}
+
+// PP2_TYPE_SSL: client(1) + verify(4) + 子TLV
+func parseSSL(value []byte) (*TLSInfo, error) {
+	if len(value) < 5 {
+		return nil, fmt.Errorf("SSL TLV长度不足")
+	}
+	info := &TLSInfo{
+		Client:   value[0],
+		Verified: binary.BigEndian.Uint32(value[1:5]) == 0,
+	}
+
+	data := value[5:]
+	for len(data) > 0 {
+		if len(data) < 3 {
+			return nil, fmt.Errorf("SSL子TLV长度不足")
+		}
+		subType := data[0]
+		length := int(binary.BigEndian.Uint16(data[1:3]))
+		if len(data) < 3+length {
+			return nil, fmt.Errorf("SSL子TLV %#x 长度越界", subType)
+		}
+		text := string(data[3 : 3+length])
+		data = data[3+length:]
+
+		switch subType {
+		case subTypeSSLVersion:
+			info.Version = text
+		case subTypeSSLCN:
+			info.CommonName = text
+		case subTypeSSLCipher:
+			info.Cipher = text
+		case subTypeSSLSigAlg:
+			info.SigAlg = text
+		case subTypeSSLKeyAlg:
+			info.KeyAlg = text
+		}
+	}
+	return info, nil
+}
+
+// CRC32C覆盖整个头，计算时校验字段置0
+func verifyChecksum(fixed, payload []byte, addrLength int, tlv *TLV) error {
+	if len(tlv.Value) != 4 {
+		return fmt.Errorf("CRC32C TLV长度应为4")
+	}
+	expected := binary.BigEndian.Uint32(tlv.Value)
+
+	zeroed := append([]byte(nil), payload...)
+	data := zeroed[addrLength:]
+	for len(data) >= 3 {
+		length := int(binary.BigEndian.Uint16(data[1:3]))
+		if data[0] == TypeCRC32C {
+			for i := 3; i < 3+length; i++ {
+				data[i] = 0
+			}
+			break
+		}
+		data = data[3+length:]
+	}
+
+	hash := crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	hash.Write(fixed)
+	hash.Write(zeroed)
+	if hash.Sum32() != expected {
+		return ErrChecksum
+	}
+	return nil
+}
+
+func (h *Header) tlv(tlvType byte) *TLV {
+	for i := range h.TLVs {
+		if h.TLVs[i].Type == tlvType {
+			return &h.TLVs[i]
+		}
+	}
+	return nil
+}
+
+// 按类型查找TLV，不存在时返回nil
+func (h *Header) TLV(tlvType byte) []byte {
+	if tlv := h.tlv(tlvType); tlv != nil {
+		return tlv.Value
+	}
+	return nil
+}
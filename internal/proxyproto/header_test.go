@@ -0,0 +1,275 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 构造TLV
+func tlv(tlvType byte, value []byte) []byte {
+	out := []byte{tlvType, 0, 0}
+	binary.BigEndian.PutUint16(out[1:], uint16(len(value)))
+	return append(out, value...)
+}
+
+// 构造v2头，length<0时按实际负载长度填写
+func v2Header(verCmd, famProto byte, length int, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	if length < 0 {
+		length = len(body)
+	}
+	out := append([]byte(nil), v2Signature...)
+	out = append(out, verCmd, famProto, byte(length>>8), byte(length))
+	return append(out, body...)
+}
+
+// 按规范计算CRC32C：校验值字段置0后对整个头计算，再写回
+func withCRC(header []byte) []byte {
+	out := append([]byte(nil), header...)
+	sum := crc32.Checksum(out, crc32.MakeTable(crc32.Castagnoli))
+	// CRC32C TLV放在最后，校验值是最后4个字节
+	binary.BigEndian.PutUint32(out[len(out)-4:], sum)
+	return out
+}
+
+func ipv4Addrs(src, dst string, srcPort, dstPort uint16) []byte {
+	out := append(net.ParseIP(src).To4(), net.ParseIP(dst).To4()...)
+	return binary.BigEndian.AppendUint16(binary.BigEndian.AppendUint16(out, srcPort), dstPort)
+}
+
+func ipv6Addrs(src, dst string, srcPort, dstPort uint16) []byte {
+	out := append(net.ParseIP(src).To16(), net.ParseIP(dst).To16()...)
+	return binary.BigEndian.AppendUint16(binary.BigEndian.AppendUint16(out, srcPort), dstPort)
+}
+
+func unixAddrs(src, dst string) []byte {
+	out := make([]byte, 216)
+	copy(out, src)
+	copy(out[108:], dst)
+	return out
+}
+
+func TestReadHeader(t *testing.T) {
+	sslValue := append([]byte{SSLClientSSL | SSLClientCertConn, 0, 0, 0, 0},
+		append(tlv(subTypeSSLVersion, []byte("TLSv1.3")), tlv(subTypeSSLCN, []byte("client.example.com"))...)...)
+
+	tests := []struct {
+		name  string
+		input []byte
+		want  *Header
+	}{
+		{
+			name:  "v1 TCP4",
+			input: []byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n"),
+			want: &Header{
+				Version:     1,
+				Command:     CommandProxy,
+				Protocol:    "tcp4",
+				Source:      &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 56324},
+				Destination: &net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: 443},
+			},
+		},
+		{
+			name:  "v1 TCP6",
+			input: []byte("PROXY TCP6 2001:db8::1 2001:db8::2 65535 0\r\n"),
+			want: &Header{
+				Version:     1,
+				Command:     CommandProxy,
+				Protocol:    "tcp6",
+				Source:      &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 65535},
+				Destination: &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 0},
+			},
+		},
+		{
+			name:  "v1 UNKNOWN忽略其余字段",
+			input: []byte("PROXY UNKNOWN ffff::1 ffff::2 1 2\r\n"),
+			want:  &Header{Version: 1, Command: CommandProxy, Protocol: "unknown"},
+		},
+		{
+			name:  "v1最大长度",
+			input: []byte("PROXY TCP6 ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff 65535 65535\r\n"),
+			want: &Header{
+				Version:     1,
+				Command:     CommandProxy,
+				Protocol:    "tcp6",
+				Source:      &net.TCPAddr{IP: net.ParseIP("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"), Port: 65535},
+				Destination: &net.TCPAddr{IP: net.ParseIP("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"), Port: 65535},
+			},
+		},
+		{
+			name:  "v2 TCP4",
+			input: v2Header(0x21, 0x11, -1, ipv4Addrs("192.0.2.1", "198.51.100.1", 56324, 443)),
+			want: &Header{
+				Version:     2,
+				Command:     CommandProxy,
+				Protocol:    "tcp4",
+				Source:      &net.TCPAddr{IP: net.ParseIP("192.0.2.1").To4(), Port: 56324},
+				Destination: &net.TCPAddr{IP: net.ParseIP("198.51.100.1").To4(), Port: 443},
+			},
+		},
+		{
+			name:  "v2 UDP6",
+			input: v2Header(0x21, 0x22, -1, ipv6Addrs("2001:db8::1", "2001:db8::2", 5353, 53)),
+			want: &Header{
+				Version:     2,
+				Command:     CommandProxy,
+				Protocol:    "udp6",
+				Source:      &net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 5353},
+				Destination: &net.UDPAddr{IP: net.ParseIP("2001:db8::2"), Port: 53},
+			},
+		},
+		{
+			name:  "v2 unix",
+			input: v2Header(0x21, 0x31, -1, unixAddrs("/run/src.sock", "/run/dst.sock")),
+			want: &Header{
+				Version:     2,
+				Command:     CommandProxy,
+				Protocol:    "unix",
+				Source:      &net.UnixAddr{Name: "/run/src.sock", Net: "unix"},
+				Destination: &net.UnixAddr{Name: "/run/dst.sock", Net: "unix"},
+			},
+		},
+		{
+			name:  "v2 LOCAL忽略地址",
+			input: v2Header(0x20, 0x11, -1, ipv4Addrs("192.0.2.1", "198.51.100.1", 1, 2)),
+			want:  &Header{Version: 2, Command: CommandLocal},
+		},
+		{
+			name:  "v2 LOCAL无地址",
+			input: v2Header(0x20, 0x00, 0),
+			want:  &Header{Version: 2, Command: CommandLocal, Protocol: "unknown"},
+		},
+		{
+			name: "v2 TLV",
+			input: v2Header(0x21, 0x11, -1,
+				ipv4Addrs("192.0.2.1", "198.51.100.1", 56324, 443),
+				tlv(TypeALPN, []byte("h2")),
+				tlv(TypeAuthority, []byte("example.com")),
+				tlv(TypeUniqueID, []byte{0xde, 0xad}),
+				tlv(TypeNoop, nil),
+				tlv(TypeSSL, sslValue),
+			),
+			want: &Header{
+				Version:     2,
+				Command:     CommandProxy,
+				Protocol:    "tcp4",
+				Source:      &net.TCPAddr{IP: net.ParseIP("192.0.2.1").To4(), Port: 56324},
+				Destination: &net.TCPAddr{IP: net.ParseIP("198.51.100.1").To4(), Port: 443},
+				TLVs: []TLV{
+					{Type: TypeALPN, Value: []byte("h2")},
+					{Type: TypeAuthority, Value: []byte("example.com")},
+					{Type: TypeUniqueID, Value: []byte{0xde, 0xad}},
+					{Type: TypeNoop},
+					{Type: TypeSSL, Value: sslValue},
+				},
+				Authority: "example.com",
+				ALPN:      "h2",
+				UniqueID:  []byte{0xde, 0xad},
+				TLS: &TLSInfo{
+					Client:     SSLClientSSL | SSLClientCertConn,
+					Verified:   true,
+					Version:    "TLSv1.3",
+					CommonName: "client.example.com",
+				},
+			},
+		},
+		{
+			name: "v2 CRC32C校验通过",
+			input: withCRC(v2Header(0x21, 0x11, -1,
+				ipv4Addrs("192.0.2.1", "198.51.100.1", 56324, 443),
+				tlv(TypeAuthority, []byte("example.com")),
+				tlv(TypeCRC32C, make([]byte, 4)),
+			)),
+			want: &Header{
+				Version:     2,
+				Command:     CommandProxy,
+				Protocol:    "tcp4",
+				Source:      &net.TCPAddr{IP: net.ParseIP("192.0.2.1").To4(), Port: 56324},
+				Destination: &net.TCPAddr{IP: net.ParseIP("198.51.100.1").To4(), Port: 443},
+				Authority:   "example.com",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReader(io.MultiReader(bytes.NewReader(tt.input), strings.NewReader("GET / HTTP/1.1\r\n")))
+			got, err := ReadHeader(r)
+			if err != nil {
+				t.Fatalf("ReadHeader() 错误: %v", err)
+			}
+			// TLV原始内容单独比较，CRC32C用例只比较解析出的字段
+			if tt.want.TLVs == nil {
+				got.TLVs = nil
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadHeader() = %+v, 期望 %+v", got, tt.want)
+			}
+			rest, _ := r.ReadString('\n')
+			if rest != "GET / HTTP/1.1\r\n" {
+				t.Errorf("头之后的数据 = %q, 期望停在请求行开头", rest)
+			}
+		})
+	}
+}
+
+func TestReadHeaderErrors(t *testing.T) {
+	addrs := ipv4Addrs("192.0.2.1", "198.51.100.1", 56324, 443)
+	crcHeader := withCRC(v2Header(0x21, 0x11, -1, addrs, tlv(TypeCRC32C, make([]byte, 4))))
+	corrupted := append([]byte(nil), crcHeader...)
+	corrupted[len(v2Signature)+4+3] ^= 0xff // 修改源地址最后一个字节
+
+	tests := []struct {
+		name    string
+		input   []byte
+		wantErr error // 为空时只要求返回错误
+	}{
+		{name: "空连接", input: nil, wantErr: io.EOF},
+		{name: "HTTP请求", input: []byte("GET / HTTP/1.1\r\n\r\n"), wantErr: ErrNoHeader},
+		{name: "v2签名错误", input: append([]byte("\r\n\r\n\x00\r\nQUIX\n"), 0x21, 0x11, 0, 12), wantErr: ErrNoHeader},
+		{name: "v1小写前缀", input: []byte("proxy TCP4 192.0.2.1 198.51.100.1 1 2\r\n"), wantErr: ErrNoHeader},
+		{name: "v1缺少CRLF", input: []byte("PROXY TCP4 192.0.2.1 198.51.100.1 1 2\n")},
+		{name: "v1截断", input: []byte("PROXY TCP4 192.0.2.1 198.51")},
+		{name: "v1超过最大长度", input: []byte("PROXY TCP6 " + strings.Repeat("f", 100) + " ::1 1 2\r\n")},
+		{name: "v1字段数错误", input: []byte("PROXY TCP4 192.0.2.1 198.51.100.1 1\r\n")},
+		{name: "v1不支持的协议", input: []byte("PROXY UDP4 192.0.2.1 198.51.100.1 1 2\r\n")},
+		{name: "v1 TCP4使用IPv6地址", input: []byte("PROXY TCP4 2001:db8::1 198.51.100.1 1 2\r\n")},
+		{name: "v1端口越界", input: []byte("PROXY TCP4 192.0.2.1 198.51.100.1 65536 2\r\n")},
+		{name: "v1端口前导0", input: []byte("PROXY TCP4 192.0.2.1 198.51.100.1 080 2\r\n")},
+		{name: "v2固定头截断", input: append(append([]byte(nil), v2Signature...), 0x21)},
+		{name: "v2长度超过实际数据", input: v2Header(0x21, 0x11, len(addrs)+10, addrs)},
+		{name: "v2长度小于地址长度", input: v2Header(0x21, 0x21, len(addrs), addrs)},
+		{name: "v2 unix地址长度不足", input: v2Header(0x21, 0x31, -1, make([]byte, 108))},
+		{name: "v2版本错误", input: v2Header(0x11, 0x11, -1, addrs)},
+		{name: "v2命令错误", input: v2Header(0x22, 0x11, -1, addrs)},
+		{name: "v2地址族错误", input: v2Header(0x21, 0x41, -1, addrs)},
+		{name: "v2传输协议错误", input: v2Header(0x21, 0x13, -1, addrs)},
+		{name: "TLV头截断", input: v2Header(0x21, 0x11, -1, addrs, []byte{TypeALPN, 0})},
+		{name: "TLV长度越界", input: v2Header(0x21, 0x11, -1, addrs, []byte{TypeALPN, 0, 5, 'h', '2'})},
+		{name: "SSL TLV长度不足", input: v2Header(0x21, 0x11, -1, addrs, tlv(TypeSSL, []byte{1, 0, 0}))},
+		{name: "SSL子TLV长度越界", input: v2Header(0x21, 0x11, -1, addrs, tlv(TypeSSL, []byte{1, 0, 0, 0, 0, subTypeSSLVersion, 0, 9, 'T'}))},
+		{name: "CRC32C TLV长度错误", input: v2Header(0x21, 0x11, -1, addrs, tlv(TypeCRC32C, []byte{1, 2}))},
+		{name: "CRC32C不匹配", input: corrupted, wantErr: ErrChecksum},
+		{name: "CRC32C为0", input: v2Header(0x21, 0x11, -1, addrs, tlv(TypeCRC32C, make([]byte, 4))), wantErr: ErrChecksum},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadHeader(bufio.NewReader(bytes.NewReader(tt.input)))
+			if err == nil {
+				t.Fatalf("ReadHeader() = %+v, 期望返回错误", got)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("ReadHeader() 错误 = %v, 期望 %v", err, tt.wantErr)
+			}
+		})
+	}
+}
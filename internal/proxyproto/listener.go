@@ -0,0 +1,203 @@
+package proxyproto
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// PROXY协议配置
+type Config struct {
+	Enabled        bool          `yaml:"enabled"`
+	TrustedSources []string      `yaml:"trusted_sources"` // 允许发送PROXY协议头的负载均衡地址（IP/CIDR）
+	Optional       bool          `yaml:"optional"`        // 可信来源可以不发送协议头（默认必须发送）
+	HeaderTimeout  time.Duration `yaml:"header_timeout"`  // 读取协议头的超时
+}
+
+// 默认PROXY协议配置
+var DefaultConfig = Config{
+	Enabled:       false,
+	HeaderTimeout: 5 * time.Second,
+}
+
+// 解析PROXY协议头的监听器。只有来自可信来源的连接才解析协议头，
+// 其他连接原样传递，客户端无法通过自行发送协议头伪造地址
+type Listener struct {
+	net.Listener
+	config  Config
+	trusted []*net.IPNet
+}
+
+// 包装监听器
+func NewListener(inner net.Listener, config Config) (*Listener, error) {
+	if config.HeaderTimeout <= 0 {
+		config.HeaderTimeout = DefaultConfig.HeaderTimeout
+	}
+	if len(config.TrustedSources) == 0 {
+		return nil, fmt.Errorf("未配置可信来源(trusted_sources)")
+	}
+
+	l := &Listener{Listener: inner, config: config}
+	for _, source := range config.TrustedSources {
+		network, err := parseCIDR(source)
+		if err != nil {
+			return nil, err
+		}
+		l.trusted = append(l.trusted, network)
+	}
+	return l, nil
+}
+
+func parseCIDR(value string) (*net.IPNet, error) {
+	if _, network, err := net.ParseCIDR(value); err == nil {
+		return network, nil
+	}
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, fmt.Errorf("无效的可信来源: %s", value)
+	}
+	bits := 128
+	if ip.To4() != nil {
+		ip, bits = ip.To4(), 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// 接受连接，协议头在首次读取或获取地址时才解析，不阻塞Accept
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{
+		Conn:    conn,
+		trusted: l.isTrusted(conn.RemoteAddr()),
+		config:  l.config,
+	}, nil
+}
+
+func (l *Listener) isTrusted(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, network := range l.trusted {
+		if network.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// 携带PROXY协议头的连接
+type Conn struct {
+	net.Conn
+	trusted bool
+	config  Config
+
+	once   sync.Once
+	reader *bufio.Reader
+	header *Header
+	err    error
+}
+
+// 读取协议头。net/http在服务连接前先调用RemoteAddr，因此协议头在设置请求超时前读取完毕
+func (c *Conn) init() {
+	c.once.Do(func() {
+		if !c.trusted {
+			return
+		}
+
+		c.reader = bufio.NewReader(c.Conn)
+		c.Conn.SetReadDeadline(time.Now().Add(c.config.HeaderTimeout))
+		c.header, c.err = ReadHeader(c.reader)
+		c.Conn.SetReadDeadline(time.Time{})
+
+		if errors.Is(c.err, ErrNoHeader) && c.config.Optional {
+			c.err = nil
+		}
+		if c.err != nil {
+			log.Printf("PROXY协议头解析失败 (%s): %v", c.Conn.RemoteAddr(), c.err)
+		}
+	})
+}
+
+func (c *Conn) Read(b []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+	if c.reader != nil {
+		return c.reader.Read(b)
+	}
+	return c.Conn.Read(b)
+}
+
+// 客户端地址：协议头中的IP源地址，没有时为TCP对端地址
+func (c *Conn) RemoteAddr() net.Addr {
+	c.init()
+	if c.header != nil {
+		switch c.header.Source.(type) {
+		case *net.TCPAddr, *net.UDPAddr:
+			return c.header.Source
+		}
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// 本地地址：协议头中的目标地址（负载均衡监听地址），没有时为本机地址
+func (c *Conn) LocalAddr() net.Addr {
+	c.init()
+	if c.header != nil && c.header.Destination != nil {
+		return c.header.Destination
+	}
+	return c.Conn.LocalAddr()
+}
+
+// 解码后的协议头，未使用PROXY协议时返回nil
+func (c *Conn) Header() *Header {
+	c.init()
+	return c.header
+}
+
+// TCP连接的对端地址（负载均衡地址）
+func (c *Conn) BalancerAddr() net.Addr {
+	return c.Conn.RemoteAddr()
+}
+
+type contextKey struct{}
+
+// 将连接保存到context，用于http.Server.ConnContext。
+// ConnContext在Accept循环中调用，这里不读取协议头
+func ContextWithConn(ctx context.Context, conn net.Conn) context.Context {
+	for conn != nil {
+		if c, ok := conn.(*Conn); ok {
+			return context.WithValue(ctx, contextKey{}, c)
+		}
+		// 解开tls.Conn等包装
+		wrapper, ok := conn.(interface{ NetConn() net.Conn })
+		if !ok {
+			break
+		}
+		conn = wrapper.NetConn()
+	}
+	return ctx
+}
+
+// 获取请求所在连接的PROXY协议头，未使用PROXY协议时返回nil
+func HeaderFromContext(ctx context.Context) (*Header, net.Addr) {
+	c, ok := ctx.Value(contextKey{}).(*Conn)
+	if !ok {
+		return nil, nil
+	}
+	header := c.Header()
+	if header == nil {
+		return nil, nil
+	}
+	return header, c.BalancerAddr()
+}
@@ -0,0 +1,244 @@
+package replay
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 输入日志格式
+const (
+	FormatAuto      = "auto"       // 按行自动识别
+	FormatCombined  = "combined"   // nginx/apache combined文本格式
+	FormatNginxJSON = "nginx-json" // nginx escape=json日志
+	FormatNDJSON    = "ndjson"     // 本系统 /logs/export?format=ndjson 导出
+)
+
+// combined格式，可选的第9个字段为$http_x_forwarded_for
+var combinedPattern = regexp.MustCompile(`^(\S+) \S+ \S+ \[([^\]]+)\] "([^"]*)" \d{3} \S+ "([^"]*)" "([^"]*)"(?: "([^"]*)")?`)
+
+const combinedTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// 回放的一条访问记录
+type Entry struct {
+	Time          time.Time
+	IP            string
+	Method        string
+	Path          string
+	UserAgent     string
+	Referer       string
+	XForwardedFor string
+	Fingerprint   string // 导出记录中的原始指纹，为空时重新生成
+}
+
+// 逐行读取访问日志
+type Reader struct {
+	format  string
+	scanner *bufio.Scanner
+	closer  io.Closer
+	line    int
+	name    string
+}
+
+// 打开日志文件，"-"表示标准输入，.gz后缀自动解压
+func Open(path, format string) (*Reader, error) {
+	var (
+		input  io.Reader
+		closer io.Closer
+	)
+	if path == "-" {
+		input = os.Stdin
+	} else {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("打开日志文件失败: %v", err)
+		}
+		input, closer = file, file
+		if strings.HasSuffix(path, ".gz") {
+			gz, err := gzip.NewReader(file)
+			if err != nil {
+				file.Close()
+				return nil, fmt.Errorf("解压日志文件失败: %v", err)
+			}
+			input = gz
+		}
+	}
+
+	reader, err := NewReader(input, format)
+	if err != nil {
+		if closer != nil {
+			closer.Close()
+		}
+		return nil, err
+	}
+	reader.closer = closer
+	reader.name = path
+	return reader, nil
+}
+
+// 从任意输入创建读取器
+func NewReader(input io.Reader, format string) (*Reader, error) {
+	switch format {
+	case "":
+		format = FormatAuto
+	case FormatAuto, FormatCombined, FormatNginxJSON, FormatNDJSON:
+	default:
+		return nil, fmt.Errorf("不支持的日志格式: %s", format)
+	}
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	return &Reader{format: format, scanner: scanner}, nil
+}
+
+// 读取下一条记录，结束时返回io.EOF；无法解析的行返回*ParseError，可跳过后继续读取
+func (r *Reader) Next() (*Entry, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := strings.TrimSpace(r.scanner.Text())
+		if line == "" {
+			continue
+		}
+		entry, err := parseLine(line, r.format)
+		if err != nil {
+			return nil, &ParseError{Source: r.name, Line: r.line, Err: err}
+		}
+		return entry, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取日志失败: %v", err)
+	}
+	return nil, io.EOF
+}
+
+func (r *Reader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// 单行解析错误
+type ParseError struct {
+	Source string
+	Line   int
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s:%d: %v", e.Source, e.Line, e.Err)
+}
+
+func parseLine(line, format string) (*Entry, error) {
+	switch format {
+	case FormatCombined:
+		return parseCombined(line)
+	case FormatNginxJSON, FormatNDJSON:
+		return parseJSON(line)
+	}
+	if strings.HasPrefix(line, "{") {
+		return parseJSON(line)
+	}
+	return parseCombined(line)
+}
+
+func parseCombined(line string) (*Entry, error) {
+	m := combinedPattern.FindStringSubmatch(line)
+	if m == nil {
+		return nil, fmt.Errorf("不是combined格式")
+	}
+	ts, err := time.Parse(combinedTimeLayout, m[2])
+	if err != nil {
+		return nil, fmt.Errorf("无效的时间: %v", err)
+	}
+	method, path := splitRequestLine(m[3])
+	return &Entry{
+		Time:          ts,
+		IP:            m[1],
+		Method:        method,
+		Path:          path,
+		Referer:       dashToEmpty(m[4]),
+		UserAgent:     dashToEmpty(m[5]),
+		XForwardedFor: dashToEmpty(m[6]),
+	}, nil
+}
+
+// 兼容本系统导出记录和常见的nginx JSON日志字段
+func parseJSON(line string) (*Entry, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return nil, fmt.Errorf("无效的JSON: %v", err)
+	}
+
+	get := func(keys ...string) string {
+		for _, key := range keys {
+			if value, ok := fields[key].(string); ok && value != "" && value != "-" {
+				return value
+			}
+		}
+		return ""
+	}
+
+	entry := &Entry{
+		IP:            get("ip", "remote_addr", "client_ip"),
+		Method:        get("method", "request_method"),
+		Path:          get("path", "request_uri", "uri"),
+		UserAgent:     get("user_agent", "http_user_agent"),
+		Referer:       get("referer", "http_referer"),
+		XForwardedFor: get("http_x_forwarded_for", "x_forwarded_for"),
+		Fingerprint:   get("fingerprint"),
+	}
+	if entry.Method == "" || entry.Path == "" {
+		if request := get("request"); request != "" {
+			entry.Method, entry.Path = splitRequestLine(request)
+		}
+	}
+	if entry.IP == "" {
+		return nil, fmt.Errorf("缺少客户端IP")
+	}
+
+	raw := get("timestamp", "time_iso8601", "@timestamp", "time_local", "time")
+	if raw == "" {
+		return nil, fmt.Errorf("缺少时间字段")
+	}
+	ts, err := parseTime(raw)
+	if err != nil {
+		return nil, err
+	}
+	entry.Time = ts
+	return entry, nil
+}
+
+func parseTime(raw string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, combinedTimeLayout, "2006-01-02 15:04:05"} {
+		if ts, err := time.Parse(layout, raw); err == nil {
+			return ts, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法识别的时间格式: %s", raw)
+}
+
+// 拆分 "GET /path HTTP/1.1"
+func splitRequestLine(request string) (string, string) {
+	parts := strings.Fields(request)
+	switch len(parts) {
+	case 0:
+		return "", ""
+	case 1:
+		return "", parts[0]
+	default:
+		return parts[0], parts[1]
+	}
+}
+
+func dashToEmpty(value string) string {
+	if value == "-" {
+		return ""
+	}
+	return value
+}
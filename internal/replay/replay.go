@@ -0,0 +1,296 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"securefingerprint/internal/analyzer"
+	"securefingerprint/internal/clock"
+	"securefingerprint/internal/collector"
+	"securefingerprint/internal/fingerprint"
+	"securefingerprint/internal/geoip"
+	"securefingerprint/internal/limiter"
+	"securefingerprint/internal/scorer"
+	"securefingerprint/internal/storage"
+	"securefingerprint/internal/useragent"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 与服务端一致的默认指纹盐值
+var DefaultFingerprintSalt = fingerprint.DefaultSaltConfig.Salt
+
+// 过期数据的清理间隔（模拟时间）
+const sweepInterval = 10 * time.Minute
+
+// 回放使用的决策配置
+type Config struct {
+	Name            string                  `yaml:"-"`
+	Scoring         scorer.ScoringConfig    `yaml:"scoring"`
+	Limiter         limiter.LimiterConfig   `yaml:"limiter"`
+	Analyzer        analyzer.AnalyzerConfig `yaml:"analyzer"`
+	Proxy           collector.ProxyConfig   `yaml:"-"` // 顶层proxy段，决定日志中X-Forwarded-For是否可信
+	GeoIP           geoip.Config            `yaml:"-"` // 顶层geoip段，国家和ASN规则依赖GeoIP查询
+	Fingerprint     fingerprint.Config      `yaml:"-"` // 顶层fingerprint段，指纹权重和按路由的组成
+	UserAgent       useragent.Config        `yaml:"-"` // 顶层user_agent段，UA模式库
+	FingerprintSalt string                  `yaml:"-"`
+}
+
+// 从服务端配置文件读取security段，未配置的项与服务端一样保持零值
+func LoadConfig(filename string) (Config, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return Config{}, fmt.Errorf("读取配置文件失败: %v", err)
+	}
+
+	var file struct {
+		Security    Config                `yaml:"security"`
+		Proxy       collector.ProxyConfig `yaml:"proxy"`
+		GeoIP       geoip.Config          `yaml:"geoip"`
+		Fingerprint fingerprint.Config    `yaml:"fingerprint"`
+		UserAgent   useragent.Config      `yaml:"user_agent"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return Config{}, fmt.Errorf("解析配置文件失败: %v", err)
+	}
+	if _, err := collector.NewProxyDetector(file.Proxy); err != nil {
+		return Config{}, fmt.Errorf("代理配置错误: %v", err)
+	}
+	if _, err := useragent.NewParser(file.UserAgent); err != nil {
+		return Config{}, fmt.Errorf("UA模式库配置错误: %v", err)
+	}
+
+	config := file.Security
+	config.Proxy = file.Proxy
+	config.GeoIP = file.GeoIP
+	config.Fingerprint = file.Fingerprint
+	config.UserAgent = file.UserAgent
+	config.Name = filename
+	if !config.GeoIP.Enabled && config.usesGeo() {
+		log.Printf("%s: 配置了国家/ASN规则或分数调整但未启用GeoIP，回放时这些规则不会生效", filename)
+	}
+	config.FingerprintSalt = file.Fingerprint.Salt
+	if config.FingerprintSalt == "" {
+		config.FingerprintSalt = DefaultFingerprintSalt
+	}
+	return config, nil
+}
+
+// 是否配置了依赖GeoIP的限制规则或分数调整
+func (c Config) usesGeo() bool {
+	rules := c.Limiter.Geo
+	return len(rules.BlockCountries) > 0 || len(rules.BlockASNs) > 0 ||
+		len(rules.ChallengeCountries) > 0 || len(rules.ChallengeASNs) > 0 ||
+		len(c.Scoring.CountryPenalties) > 0 || len(c.Scoring.ASNPenalties) > 0
+}
+
+// 单条记录的回放结果
+type Decision struct {
+	Fingerprint string
+	Score       int
+	RiskLevel   string
+	Action      string
+	Category    string
+	Reason      string
+	Shadows     []*limiter.LimitDecision // 影子模式下未执行的决策，按评估顺序排列
+}
+
+// 回放引擎：与服务端防火墙中间件相同的决策流程，状态保存在隔离的内存存储中，时间由日志时间驱动
+type Engine struct {
+	clock       *clock.Simulated
+	store       *storage.MemoryStore
+	collector   *collector.Collector
+	fingerprint *fingerprint.Generator
+	scorer      *scorer.Scorer
+	analyzer    *analyzer.Analyzer
+	limiter     *limiter.Limiter
+	geo         *geoip.Database
+	report      *Report
+	lastSweep   time.Time
+}
+
+// 创建回放引擎，指纹权重、组成、UA模式库和GeoIP与服务端的初始化方式一致
+func NewEngine(config Config) (*Engine, error) {
+	if config.FingerprintSalt == "" {
+		config.FingerprintSalt = DefaultFingerprintSalt
+	}
+
+	simulated := clock.NewSimulated(time.Time{})
+	store := storage.NewMemoryStore(simulated)
+	// 配置已在LoadConfig中校验，无效时回退为默认代理配置
+	proxy, _ := collector.NewProxyDetector(config.Proxy)
+	agents, err := useragent.NewParser(config.UserAgent)
+	if err != nil {
+		return nil, fmt.Errorf("UA模式库配置错误: %v", err)
+	}
+
+	// 日志中没有TLS握手和浏览器信号，这两个组件不参与（与服务端未开启对应功能时相同）
+	generator := fingerprint.NewGenerator(config.FingerprintSalt)
+	weights := fingerprint.DefaultWeights
+	if config.Fingerprint.Weights != nil {
+		weights = *config.Fingerprint.Weights
+		weights.TLS, weights.Client = 0, 0
+	}
+	generator.SetWeights(weights)
+	if err := generator.SetProfiles(config.Fingerprint.Profiles); err != nil {
+		return nil, fmt.Errorf("指纹组成配置错误: %v", err)
+	}
+
+	e := &Engine{
+		clock:       simulated,
+		store:       store,
+		collector:   collector.NewCollector(proxy),
+		fingerprint: generator,
+		scorer:      scorer.NewScorer(config.Scoring, store),
+		analyzer:    analyzer.NewAnalyzer(config.Analyzer, store),
+		limiter:     limiter.NewLimiter(config.Limiter, store),
+		report:      newReport(config.Name),
+	}
+	e.scorer.SetClock(simulated)
+	e.analyzer.SetClock(simulated)
+	e.limiter.SetClock(simulated)
+	e.collector.SetUserAgentParser(agents)
+	for _, profile := range config.Fingerprint.Profiles {
+		e.collector.AddHeaders(profile.Headers...)
+	}
+
+	if config.GeoIP.Enabled {
+		geo, err := geoip.NewDatabase(config.GeoIP)
+		if err != nil {
+			return nil, fmt.Errorf("初始化GeoIP失败: %v", err)
+		}
+		e.geo = geo
+		e.collector.SetGeoIP(geo)
+	}
+	return e, nil
+}
+
+// 关闭引擎打开的GeoIP数据库
+func (e *Engine) Close() error {
+	if e.geo == nil {
+		return nil
+	}
+	return e.geo.Close(context.Background())
+}
+
+// 回放一条记录
+func (e *Engine) Process(entry *Entry) (*Decision, error) {
+	e.clock.Set(entry.Time)
+	now := e.clock.Now()
+	if now.Sub(e.lastSweep) >= sweepInterval {
+		e.store.Sweep()
+		e.lastSweep = now
+	}
+
+	// 重建访问信息
+	info := e.collector.CollectFromRequest(buildRequest(entry))
+	info.Timestamp = entry.Time
+
+	userFingerprint := entry.Fingerprint
+	if userFingerprint == "" {
+		userFingerprint = e.fingerprint.Generate(info)
+	}
+
+	e.store.IncrementRequestRate(userFingerprint)
+	scoreResult, err := e.scorer.CalculateScore(userFingerprint, info)
+	if err != nil {
+		return nil, err
+	}
+
+	recentAccess, _ := e.store.GetRecentAccess(userFingerprint, 60)
+	analysisResult, _ := e.analyzer.AnalyzeUser(userFingerprint, recentAccess)
+
+	decision, err := e.limiter.CheckLimit(userFingerprint, scoreResult.NewScore, analysisResult, info.Geo)
+	if err != nil {
+		return nil, err
+	}
+
+	e.store.LogAccess(&storage.AccessLog{
+		Fingerprint: userFingerprint,
+		IP:          info.IP,
+		UserAgent:   info.UserAgent,
+		Path:        info.Path,
+		Method:      info.Method,
+		Timestamp:   entry.Time,
+		Score:       scoreResult.NewScore,
+	})
+
+	result := &Decision{
+		Fingerprint: userFingerprint,
+		Score:       scoreResult.NewScore,
+		RiskLevel:   analysisResult.RiskLevel,
+		Action:      decision.Action,
+		Category:    decision.Category,
+		Reason:      decision.Reason,
+		Shadows:     decision.Shadows,
+	}
+	e.report.record(entry, info, result)
+	return result, nil
+}
+
+// 当前统计结果
+func (e *Engine) Report() *Report {
+	return e.report
+}
+
+// 从日志记录构造请求，复用采集器的IP、设备和机器人识别逻辑
+func buildRequest(entry *Entry) *http.Request {
+	method := entry.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	target, err := url.ParseRequestURI(entry.Path)
+	if err != nil {
+		target = &url.URL{Path: entry.Path}
+	}
+
+	r := &http.Request{
+		Method:     method,
+		URL:        target,
+		RequestURI: entry.Path,
+		Header:     make(http.Header),
+		RemoteAddr: entry.IP,
+	}
+	if entry.UserAgent != "" {
+		r.Header.Set("User-Agent", entry.UserAgent)
+	}
+	if entry.Referer != "" {
+		r.Header.Set("Referer", entry.Referer)
+	}
+	if entry.XForwardedFor != "" {
+		r.Header.Set("X-Forwarded-For", entry.XForwardedFor)
+	}
+	return r
+}
+
+// 将日志依次回放到所有引擎，返回跳过的无法解析行数
+func Run(reader *Reader, engines ...*Engine) (int64, error) {
+	var skipped int64
+	for {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			return skipped, nil
+		}
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			skipped++
+			continue
+		}
+		if err != nil {
+			return skipped, err
+		}
+
+		for _, engine := range engines {
+			if _, err := engine.Process(entry); err != nil {
+				return skipped, fmt.Errorf("回放失败: %v", err)
+			}
+		}
+	}
+}
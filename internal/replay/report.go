@@ -0,0 +1,268 @@
+package replay
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"securefingerprint/internal/collector"
+	"securefingerprint/internal/limiter"
+)
+
+// 回放统计
+type Report struct {
+	Config       string                        `json:"config"`
+	Requests     int64                         `json:"requests"`
+	Fingerprints int                           `json:"fingerprints"`
+	Start        time.Time                     `json:"start"`
+	End          time.Time                     `json:"end"`
+	Actions      map[string]int64              `json:"actions"`
+	Categories   map[string]int64              `json:"categories"`
+	Shadow       map[string]int64              `json:"shadow,omitempty"` // 影子决策数，键为"来源/动作"
+	Banned       map[string]*BannedFingerprint `json:"banned"`
+
+	seen map[string]int64
+}
+
+// 会被封禁的指纹
+type BannedFingerprint struct {
+	Fingerprint string    `json:"fingerprint"`
+	IP          string    `json:"ip"`
+	UserAgent   string    `json:"user_agent"`
+	DeviceType  string    `json:"device_type"`
+	IsBot       bool      `json:"is_bot"`
+	FirstBan    time.Time `json:"first_ban"`
+	Category    string    `json:"category"`
+	Reason      string    `json:"reason"`
+	Bans        int       `json:"bans"`             // 新封禁次数（不含封禁期内的请求）
+	Requests    int64     `json:"requests"`         // 该指纹的全部请求数
+	Blocked     int64     `json:"blocked_requests"` // 被ban拒绝的请求数
+}
+
+func newReport(config string) *Report {
+	return &Report{
+		Config:     config,
+		Actions:    make(map[string]int64),
+		Categories: make(map[string]int64),
+		Shadow:     make(map[string]int64),
+		Banned:     make(map[string]*BannedFingerprint),
+		seen:       make(map[string]int64),
+	}
+}
+
+func (r *Report) record(entry *Entry, info *collector.AccessInfo, decision *Decision) {
+	r.Requests++
+	if r.Start.IsZero() || entry.Time.Before(r.Start) {
+		r.Start = entry.Time
+	}
+	if entry.Time.After(r.End) {
+		r.End = entry.Time
+	}
+	r.Actions[decision.Action]++
+	r.Categories[decision.Category]++
+	for _, shadow := range decision.Shadows {
+		r.Shadow[shadow.Source+"/"+shadow.Action]++
+	}
+
+	r.seen[decision.Fingerprint]++
+	r.Fingerprints = len(r.seen)
+
+	banned, ok := r.Banned[decision.Fingerprint]
+	if ok {
+		banned.Requests = r.seen[decision.Fingerprint]
+	}
+	if decision.Action != "ban" {
+		return
+	}
+
+	if !ok {
+		banned = &BannedFingerprint{
+			Fingerprint: decision.Fingerprint,
+			IP:          info.IP,
+			UserAgent:   info.UserAgent,
+			DeviceType:  info.DeviceType,
+			IsBot:       info.IsBot,
+			FirstBan:    entry.Time,
+			Category:    decision.Category,
+			Reason:      decision.Reason,
+			Requests:    r.seen[decision.Fingerprint],
+		}
+		r.Banned[decision.Fingerprint] = banned
+	}
+	banned.Blocked++
+	if decision.Category != limiter.CategoryBanned {
+		banned.Bans++
+	}
+}
+
+// 按首次封禁时间排序的封禁列表
+func (r *Report) BannedList() []*BannedFingerprint {
+	list := make([]*BannedFingerprint, 0, len(r.Banned))
+	for _, banned := range r.Banned {
+		list = append(list, banned)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].FirstBan.Equal(list[j].FirstBan) {
+			return list[i].FirstBan.Before(list[j].FirstBan)
+		}
+		return list[i].Fingerprint < list[j].Fingerprint
+	})
+	return list
+}
+
+// 动作数量变化
+type ActionDelta struct {
+	Action    string `json:"action"`
+	Base      int64  `json:"base"`
+	Candidate int64  `json:"candidate"`
+	Delta     int64  `json:"delta"`
+}
+
+// 两份配置的回放差异
+type Diff struct {
+	Base           string               `json:"base"`
+	Candidate      string               `json:"candidate"`
+	Actions        []ActionDelta        `json:"actions"`
+	Categories     []ActionDelta        `json:"categories"`
+	NewlyBanned    []*BannedFingerprint `json:"newly_banned"`     // 仅在候选配置下被封禁
+	NoLongerBanned []*BannedFingerprint `json:"no_longer_banned"` // 仅在基准配置下被封禁
+	BothBanned     int                  `json:"both_banned"`
+}
+
+// 比较基准配置与候选配置的回放结果
+func Compare(base, candidate *Report) *Diff {
+	diff := &Diff{
+		Base:       base.Config,
+		Candidate:  candidate.Config,
+		Actions:    compareCounts(base.Actions, candidate.Actions),
+		Categories: compareCounts(base.Categories, candidate.Categories),
+	}
+	for _, banned := range candidate.BannedList() {
+		if _, ok := base.Banned[banned.Fingerprint]; ok {
+			diff.BothBanned++
+		} else {
+			diff.NewlyBanned = append(diff.NewlyBanned, banned)
+		}
+	}
+	for _, banned := range base.BannedList() {
+		if _, ok := candidate.Banned[banned.Fingerprint]; !ok {
+			diff.NoLongerBanned = append(diff.NoLongerBanned, banned)
+		}
+	}
+	return diff
+}
+
+func compareCounts(base, candidate map[string]int64) []ActionDelta {
+	keys := make(map[string]struct{})
+	for key := range base {
+		keys[key] = struct{}{}
+	}
+	for key := range candidate {
+		keys[key] = struct{}{}
+	}
+
+	deltas := make([]ActionDelta, 0, len(keys))
+	for key := range keys {
+		deltas = append(deltas, ActionDelta{
+			Action:    key,
+			Base:      base[key],
+			Candidate: candidate[key],
+			Delta:     candidate[key] - base[key],
+		})
+	}
+	sort.Slice(deltas, func(i, j int) bool { return deltas[i].Action < deltas[j].Action })
+	return deltas
+}
+
+// 输出文本报告，limit限制封禁列表条数（0表示不限制）
+func (r *Report) WriteText(w io.Writer, limit int) {
+	fmt.Fprintf(w, "配置: %s\n", r.Config)
+	fmt.Fprintf(w, "时间范围: %s ~ %s\n", r.Start.Format(time.RFC3339), r.End.Format(time.RFC3339))
+	fmt.Fprintf(w, "请求数: %d  指纹数: %d  封禁指纹数: %d\n\n", r.Requests, r.Fingerprints, len(r.Banned))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "动作\t请求数\t占比")
+	for _, key := range sortedKeys(r.Actions) {
+		fmt.Fprintf(tw, "%s\t%d\t%.2f%%\n", key, r.Actions[key], percent(r.Actions[key], r.Requests))
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "原因分类\t请求数\t占比")
+	for _, key := range sortedKeys(r.Categories) {
+		fmt.Fprintf(tw, "%s\t%d\t%.2f%%\n", key, r.Categories[key], percent(r.Categories[key], r.Requests))
+	}
+	if len(r.Shadow) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "影子决策(来源/动作)\t请求数\t占比")
+		for _, key := range sortedKeys(r.Shadow) {
+			fmt.Fprintf(tw, "%s\t%d\t%.2f%%\n", key, r.Shadow[key], percent(r.Shadow[key], r.Requests))
+		}
+	}
+	tw.Flush()
+
+	if len(r.Banned) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	writeBannedTable(w, "会被封禁的指纹", r.BannedList(), limit)
+}
+
+// 输出文本差异
+func (d *Diff) WriteText(w io.Writer, limit int) {
+	fmt.Fprintf(w, "基准: %s\n候选: %s\n\n", d.Base, d.Candidate)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "动作\t基准\t候选\t变化")
+	for _, delta := range d.Actions {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%+d\n", delta.Action, delta.Base, delta.Candidate, delta.Delta)
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "原因分类\t基准\t候选\t变化")
+	for _, delta := range d.Categories {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%+d\n", delta.Action, delta.Base, delta.Candidate, delta.Delta)
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\n两者都封禁: %d  新增封禁: %d  不再封禁: %d\n", d.BothBanned, len(d.NewlyBanned), len(d.NoLongerBanned))
+	if len(d.NewlyBanned) > 0 {
+		fmt.Fprintln(w)
+		writeBannedTable(w, "新增封禁", d.NewlyBanned, limit)
+	}
+	if len(d.NoLongerBanned) > 0 {
+		fmt.Fprintln(w)
+		writeBannedTable(w, "不再封禁", d.NoLongerBanned, limit)
+	}
+}
+
+func writeBannedTable(w io.Writer, title string, list []*BannedFingerprint, limit int) {
+	fmt.Fprintf(w, "%s:\n", title)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "指纹\tIP\t首次封禁\t分类\t原因\t请求数\t拦截数\t机器人")
+	for i, banned := range list {
+		if limit > 0 && i >= limit {
+			fmt.Fprintf(tw, "... 另有%d条\n", len(list)-limit)
+			break
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%d\t%t\n",
+			banned.Fingerprint, banned.IP, banned.FirstBan.Format(time.RFC3339),
+			banned.Category, banned.Reason, banned.Requests, banned.Blocked, banned.IsBot)
+	}
+	tw.Flush()
+}
+
+func sortedKeys(counts map[string]int64) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func percent(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
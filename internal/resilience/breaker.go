@@ -0,0 +1,152 @@
+package resilience
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// 熔断器打开时返回的错误
+var ErrCircuitOpen = errors.New("熔断器已打开，跳过存储调用")
+
+// 熔断器状态
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half_open"
+)
+
+// 熔断器配置
+type BreakerConfig struct {
+	FailureThreshold int           `yaml:"failure_threshold"`   // 连续失败多少次后打开
+	OpenTimeout      time.Duration `yaml:"open_timeout"`        // 打开后多久进入半开状态
+	HalfOpenMaxCalls int           `yaml:"half_open_max_calls"` // 半开状态允许的探测请求数
+}
+
+// 默认熔断器配置
+var DefaultBreakerConfig = BreakerConfig{
+	FailureThreshold: 5,
+	OpenTimeout:      10 * time.Second,
+	HalfOpenMaxCalls: 1,
+}
+
+// 熔断器，连续失败达到阈值后在一段时间内直接拒绝调用
+type CircuitBreaker struct {
+	name     string
+	config   BreakerConfig
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	inFlight int
+	onChange func(name, from, to string)
+}
+
+// 创建熔断器
+func NewCircuitBreaker(name string, config BreakerConfig) *CircuitBreaker {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = DefaultBreakerConfig.FailureThreshold
+	}
+	if config.OpenTimeout <= 0 {
+		config.OpenTimeout = DefaultBreakerConfig.OpenTimeout
+	}
+	if config.HalfOpenMaxCalls <= 0 {
+		config.HalfOpenMaxCalls = DefaultBreakerConfig.HalfOpenMaxCalls
+	}
+	return &CircuitBreaker{
+		name:   name,
+		config: config,
+		state:  StateClosed,
+		onChange: func(name, from, to string) {
+			log.Printf("熔断器%s状态变化: %s -> %s", name, from, to)
+		},
+	}
+}
+
+// 设置状态变化回调
+func (cb *CircuitBreaker) OnStateChange(fn func(name, from, to string)) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.onChange = fn
+}
+
+// 判断是否允许调用，允许时调用方必须随后调用Record
+func (cb *CircuitBreaker) Allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case StateOpen:
+		if time.Since(cb.openedAt) < cb.config.OpenTimeout {
+			return ErrCircuitOpen
+		}
+		cb.setState(StateHalfOpen)
+		cb.inFlight = 0
+		fallthrough
+	case StateHalfOpen:
+		if cb.inFlight >= cb.config.HalfOpenMaxCalls {
+			return ErrCircuitOpen
+		}
+		cb.inFlight++
+	}
+
+	return nil
+}
+
+// 记录调用结果
+func (cb *CircuitBreaker) Record(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == StateHalfOpen && cb.inFlight > 0 {
+		cb.inFlight--
+	}
+
+	if err == nil {
+		cb.failures = 0
+		if cb.state != StateClosed {
+			cb.setState(StateClosed)
+		}
+		return
+	}
+
+	cb.failures++
+	if cb.state == StateHalfOpen || cb.failures >= cb.config.FailureThreshold {
+		cb.openedAt = time.Now()
+		if cb.state != StateOpen {
+			cb.setState(StateOpen)
+		}
+	}
+}
+
+// 当前状态
+func (cb *CircuitBreaker) State() string {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
+}
+
+// 熔断器摘要
+func (cb *CircuitBreaker) Summary() map[string]interface{} {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	summary := map[string]interface{}{
+		"name":                 cb.name,
+		"state":                cb.state,
+		"consecutive_failures": cb.failures,
+	}
+	if cb.state != StateClosed {
+		summary["opened_at"] = cb.openedAt
+	}
+	return summary
+}
+
+func (cb *CircuitBreaker) setState(state string) {
+	from := cb.state
+	cb.state = state
+	if cb.onChange != nil {
+		go cb.onChange(cb.name, from, state)
+	}
+}
@@ -0,0 +1,121 @@
+package resilience
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// 依赖故障时的处理模式
+const (
+	ModeOpen   = "open"   // 放行请求
+	ModeClosed = "closed" // 拒绝请求
+	ModeLocal  = "local"  // 降级为本地内存限流
+)
+
+// 路由级故障策略
+type RoutePolicy struct {
+	PathPrefix string `yaml:"path_prefix" json:"path_prefix"`
+	Mode       string `yaml:"mode" json:"mode"`
+}
+
+// 故障策略配置
+type FailurePolicyConfig struct {
+	DefaultMode string        `yaml:"default_mode" json:"default_mode"`
+	Routes      []RoutePolicy `yaml:"routes" json:"routes"`
+	Breaker     BreakerConfig `yaml:"breaker" json:"breaker"`
+}
+
+// 默认故障策略：全部放行
+var DefaultFailurePolicyConfig = FailurePolicyConfig{
+	DefaultMode: ModeOpen,
+	Breaker:     DefaultBreakerConfig,
+}
+
+// 故障策略
+type FailurePolicy struct {
+	defaultMode string
+	routes      []RoutePolicy
+}
+
+// 创建故障策略，路由按前缀长度降序匹配
+func NewFailurePolicy(config FailurePolicyConfig) (*FailurePolicy, error) {
+	if config.DefaultMode == "" {
+		config.DefaultMode = ModeOpen
+	}
+	if !validMode(config.DefaultMode) {
+		return nil, fmt.Errorf("无效的默认故障模式: %s", config.DefaultMode)
+	}
+
+	routes := make([]RoutePolicy, 0, len(config.Routes))
+	for _, route := range config.Routes {
+		if !validMode(route.Mode) {
+			return nil, fmt.Errorf("路由%s的故障模式无效: %s", route.PathPrefix, route.Mode)
+		}
+		routes = append(routes, route)
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		return len(routes[i].PathPrefix) > len(routes[j].PathPrefix)
+	})
+
+	return &FailurePolicy{
+		defaultMode: config.DefaultMode,
+		routes:      routes,
+	}, nil
+}
+
+func validMode(mode string) bool {
+	return mode == ModeOpen || mode == ModeClosed || mode == ModeLocal
+}
+
+// 获取路径对应的故障模式
+func (p *FailurePolicy) ModeFor(path string) string {
+	for _, route := range p.routes {
+		if strings.HasPrefix(path, route.PathPrefix) {
+			return route.Mode
+		}
+	}
+	return p.defaultMode
+}
+
+// 是否有路由在依赖故障时拒绝请求（此时Redis是就绪的必要条件）
+func (p *FailurePolicy) HasClosedMode() bool {
+	if p.defaultMode == ModeClosed {
+		return true
+	}
+	for _, route := range p.routes {
+		if route.Mode == ModeClosed {
+			return true
+		}
+	}
+	return false
+}
+
+// 降级决策统计
+type DegradedStats struct {
+	open   uint64
+	closed uint64
+	local  uint64
+}
+
+// 记录一次降级决策
+func (s *DegradedStats) Record(mode string) {
+	switch mode {
+	case ModeClosed:
+		atomic.AddUint64(&s.closed, 1)
+	case ModeLocal:
+		atomic.AddUint64(&s.local, 1)
+	default:
+		atomic.AddUint64(&s.open, 1)
+	}
+}
+
+// 统计快照
+func (s *DegradedStats) Snapshot() map[string]uint64 {
+	return map[string]uint64{
+		ModeOpen:   atomic.LoadUint64(&s.open),
+		ModeClosed: atomic.LoadUint64(&s.closed),
+		ModeLocal:  atomic.LoadUint64(&s.local),
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 	"time"
 
+	"securefingerprint/internal/clock"
 	"securefingerprint/internal/collector"
 	"securefingerprint/internal/storage"
 )
@@ -21,6 +22,13 @@ type ScoringConfig struct {
 	ProxyPenalty          int     `yaml:"proxy_penalty"`            // 代理访问扣分
 	PathSpamPenalty       int     `yaml:"path_spam_penalty"`        // 路径垃圾信息扣分
 	NoRefererPenalty      int     `yaml:"no_referer_penalty"`       // 无来源扣分
+	TLSMismatchPenalty    int     `yaml:"tls_mismatch_penalty"`     // UA与TLS握手指纹不一致扣分
+	DeviceReplayPenalty   int     `yaml:"device_replay_penalty"`    // 设备cookie跨多个网段重放扣分
+	ClientHintsMismatchPenalty int `yaml:"client_hints_mismatch_penalty"` // UA与Client Hints矛盾扣分
+	SpoofedBotPenalty     int     `yaml:"spoofed_bot_penalty"`      // 冒充搜索引擎爬虫扣分
+	HostingPenalty        int     `yaml:"hosting_penalty"`          // 来自云服务/IDC网络扣分
+	CountryPenalties      map[string]int `yaml:"country_penalties"` // 按国家代码（大写ISO 3166-1两位）的分数调整，需要启用GeoIP
+	ASNPenalties          map[uint]int   `yaml:"asn_penalties"`     // 按ASN的分数调整（需要启用GeoIP）
 }
 
 // 默认打分配置
@@ -35,6 +43,11 @@ var DefaultScoringConfig = ScoringConfig{
 	ProxyPenalty:          -5,
 	PathSpamPenalty:       -8,
 	NoRefererPenalty:      -2,
+	TLSMismatchPenalty:    -20,
+	DeviceReplayPenalty:   -15,
+	ClientHintsMismatchPenalty: -10,
+	SpoofedBotPenalty:     -50,
+	HostingPenalty:        -5,
 }
 
 // 打分结果
@@ -49,21 +62,28 @@ type ScoreResult struct {
 }
 
 type Scorer struct {
-	config      ScoringConfig
-	redisClient *storage.RedisClient
+	config ScoringConfig
+	store  storage.Store
+	clock  clock.Clock
 }
 
-func NewScorer(config ScoringConfig, redisClient *storage.RedisClient) *Scorer {
+func NewScorer(config ScoringConfig, store storage.Store) *Scorer {
 	return &Scorer{
-		config:      config,
-		redisClient: redisClient,
+		config: config,
+		store:  store,
+		clock:  clock.Real{},
 	}
 }
 
+// 替换时间来源（回放时使用模拟时钟）
+func (s *Scorer) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
 // 计算访问分数
 func (s *Scorer) CalculateScore(fingerprint string, info *collector.AccessInfo) (*ScoreResult, error) {
 	// 获取当前用户分数
-	userScore, err := s.redisClient.GetUserScore(fingerprint)
+	userScore, err := s.store.GetUserScore(fingerprint)
 	if err != nil {
 		return nil, fmt.Errorf("获取用户分数失败: %v", err)
 	}
@@ -92,10 +112,10 @@ func (s *Scorer) CalculateScore(fingerprint string, info *collector.AccessInfo)
 
 	// 更新用户分数
 	userScore.Score = newScore
-	userScore.LastSeen = time.Now()
+	userScore.LastSeen = s.clock.Now()
 	userScore.RequestCount++
 	
-	err = s.redisClient.UpdateUserScore(fingerprint, userScore)
+	err = s.store.UpdateUserScore(fingerprint, userScore)
 	if err != nil {
 		return nil, fmt.Errorf("更新用户分数失败: %v", err)
 	}
@@ -110,7 +130,7 @@ func (s *Scorer) CalculateScore(fingerprint string, info *collector.AccessInfo)
 		Reasons:   reasons,
 		Action:    action,
 		Details:   details,
-		Timestamp: time.Now(),
+		Timestamp: s.clock.Now(),
 	}
 
 	return result, nil
@@ -120,8 +140,14 @@ func (s *Scorer) CalculateScore(fingerprint string, info *collector.AccessInfo)
 func (s *Scorer) analyzeAccess(info *collector.AccessInfo, userScore *storage.UserScore) []ScoreAdjustment {
 	var adjustments []ScoreAdjustment
 
-	// 1. 检查是否为机器人
-	if info.IsBot {
+	// 1. 检查是否为机器人，已验证的搜索引擎爬虫按正常访问处理
+	if info.BotVerification.Verified() {
+		adjustments = append(adjustments, ScoreAdjustment{
+			Points:   s.config.NormalAccessBonus,
+			Reason:   fmt.Sprintf("已验证的爬虫: %s", info.BotVerification.Crawler),
+			Category: "verified_bot",
+		})
+	} else if info.IsBot {
 		adjustments = append(adjustments, ScoreAdjustment{
 			Points:   s.config.BotPenalty,
 			Reason:   "检测到机器人行为",
@@ -136,8 +162,8 @@ func (s *Scorer) analyzeAccess(info *collector.AccessInfo, userScore *storage.Us
 		})
 	}
 
-	// 2. 检查User-Agent可疑性
-	if s.isSuspiciousUserAgent(info.UserAgent) {
+	// 2. 检查User-Agent可疑性（已验证爬虫的UA本身包含bot等字样）
+	if !info.BotVerification.Verified() && s.isSuspiciousUserAgent(info.UserAgent) {
 		adjustments = append(adjustments, ScoreAdjustment{
 			Points:   s.config.SuspiciousUAPenalty,
 			Reason:   "可疑的User-Agent",
@@ -152,6 +178,12 @@ func (s *Scorer) analyzeAccess(info *collector.AccessInfo, userScore *storage.Us
 			Reason:   "通过代理访问",
 			Category: "proxy_access",
 		})
+	} else if info.NetworkType == "hosting" && !info.BotVerification.Verified() {
+		adjustments = append(adjustments, ScoreAdjustment{
+			Points:   s.config.HostingPenalty,
+			Reason:   "来自云服务或IDC网络",
+			Category: "hosting_access",
+		})
 	}
 
 	// 4. 检查访问路径
@@ -172,9 +204,63 @@ func (s *Scorer) analyzeAccess(info *collector.AccessInfo, userScore *storage.Us
 		})
 	}
 
-	// 6. 检查请求频率（需要查询Redis）
-	if s.redisClient != nil {
-		if rate, err := s.redisClient.GetRequestRate(info.IP); err == nil && rate > 50 {
+	// 6. 检查UA与TLS客户端是否一致（浏览器UA配合Go/Python等TLS栈）
+	if info.TLS != nil && info.TLS.Mismatch != nil {
+		adjustments = append(adjustments, ScoreAdjustment{
+			Points:   s.config.TLSMismatchPenalty,
+			Reason:   info.TLS.Mismatch.Reason,
+			Category: "tls_mismatch",
+		})
+	}
+
+	// 7. 检查设备cookie是否在过多网段中出现（cookie被复制给多个客户端）
+	if info.Device != nil && info.Device.Replay {
+		adjustments = append(adjustments, ScoreAdjustment{
+			Points:   s.config.DeviceReplayPenalty,
+			Reason:   fmt.Sprintf("设备cookie在%d个网段中出现", info.Device.Networks),
+			Category: "device_replay",
+		})
+	}
+
+	// 8. 检查UA与Client Hints是否矛盾（修改了UA但未同步修改Sec-CH-UA*请求头）
+	if info.Agent != nil && len(info.Agent.Contradictions) > 0 {
+		adjustments = append(adjustments, ScoreAdjustment{
+			Points:   s.config.ClientHintsMismatchPenalty,
+			Reason:   fmt.Sprintf("UA与Client Hints矛盾: %s", strings.Join(info.Agent.Contradictions, ", ")),
+			Category: "client_hints_mismatch",
+		})
+	}
+
+	// 9. 检查是否冒充搜索引擎爬虫（UA自称爬虫但IP未通过DNS确认）
+	if info.BotVerification.Spoofed() {
+		adjustments = append(adjustments, ScoreAdjustment{
+			Points:   s.config.SpoofedBotPenalty,
+			Reason:   fmt.Sprintf("冒充爬虫: %s", info.BotVerification.Crawler),
+			Category: "spoofed_bot",
+		})
+	}
+
+	// 10. 检查来源国家和ASN（已验证爬虫的来源由DNS确认，不参与）
+	if info.Geo != nil && !info.BotVerification.Verified() {
+		if points, ok := s.config.CountryPenalties[info.Geo.Country]; ok && info.Geo.Country != "" {
+			adjustments = append(adjustments, ScoreAdjustment{
+				Points:   points,
+				Reason:   fmt.Sprintf("来源国家: %s", info.Geo.Country),
+				Category: "geo_country",
+			})
+		}
+		if points, ok := s.config.ASNPenalties[info.Geo.ASN]; ok && info.Geo.ASN != 0 {
+			adjustments = append(adjustments, ScoreAdjustment{
+				Points:   points,
+				Reason:   fmt.Sprintf("来源网络: AS%d %s", info.Geo.ASN, info.Geo.Organization),
+				Category: "geo_asn",
+			})
+		}
+	}
+
+	// 11. 检查请求频率（需要查询Redis）
+	if s.store != nil {
+		if rate, err := s.store.GetRequestRate(info.IP); err == nil && rate > 50 {
 			penalty := s.config.FrequentRequestPenalty
 			// 根据频率调整扣分力度
 			if rate > 100 {
@@ -280,8 +366,8 @@ func (s *Scorer) determineAction(score int, info *collector.AccessInfo) string {
 		return "ban"
 	}
 
-	// 分数较低或检测到机器人，限制
-	if score < 30 || info.IsBot {
+	// 分数较低或检测到机器人（已验证的爬虫除外），限制
+	if score < 30 || (info.IsBot && !info.BotVerification.Verified()) {
 		return "limit"
 	}
 
@@ -299,7 +385,7 @@ func (s *Scorer) GetScoreTrend(fingerprint string, hours int) ([]ScoreTrendPoint
 	// 为简化实现，返回模拟数据
 	var trend []ScoreTrendPoint
 	
-	now := time.Now()
+	now := s.clock.Now()
 	for i := hours; i >= 0; i-- {
 		point := ScoreTrendPoint{
 			Timestamp: now.Add(-time.Duration(i) * time.Hour),
@@ -332,11 +418,11 @@ func (s *Scorer) BatchUpdateScores() error {
 func (s *Scorer) ResetUserScore(fingerprint string) error {
 	userScore := &storage.UserScore{
 		Score:        s.config.InitialScore,
-		LastSeen:     time.Now(),
+		LastSeen:     s.clock.Now(),
 		RequestCount: 0,
 	}
 	
-	return s.redisClient.UpdateUserScore(fingerprint, userScore)
+	return s.store.UpdateUserScore(fingerprint, userScore)
 }
 
 // 获取分数统计信息
@@ -0,0 +1,362 @@
+package siem
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 负载格式
+const (
+	FormatCEF = "cef"
+	FormatECS = "ecs"
+)
+
+const (
+	vendor     = "SecureFingerprint"
+	product    = "FirewallController"
+	ecsVersion = "8.11.0"
+)
+
+// 路径和UA的最大导出字节数，转义后整条消息仍远小于UDP数据报上限
+const maxFieldBytes = 2048
+
+// CEF flexString字段的最大长度
+const maxFlexStringBytes = 1023
+
+// syslog严重级别
+const (
+	severityError   = 3
+	severityWarning = 4
+	severityNotice  = 5
+	severityInfo    = 6
+)
+
+// 决策动作对应的syslog严重级别
+func syslogSeverity(action string) int {
+	switch action {
+	case "ban", "reject":
+		return severityWarning
+	case "delay", "challenge":
+		return severityNotice
+	case "allow":
+		return severityInfo
+	default:
+		return severityError
+	}
+}
+
+// 决策动作对应的CEF严重级别(0-10)
+func cefSeverity(action string) int {
+	switch action {
+	case "ban":
+		return 8
+	case "reject":
+		return 6
+	case "challenge":
+		return 5
+	case "delay":
+		return 4
+	default:
+		return 1
+	}
+}
+
+// RFC 5424 syslog消息：<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD MSG
+func formatSyslog(facility int, hostname, appName string, procID int, record *Record, payload []byte) []byte {
+	pri := facility*8 + syslogSeverity(record.Access.Action)
+	header := fmt.Sprintf("<%d>1 %s %s %s %d decision - ",
+		pri,
+		record.Access.Timestamp.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogField(hostname, 255),
+		syslogField(appName, 48),
+		procID,
+	)
+	return append([]byte(header), payload...)
+}
+
+// syslog头部字段：空值用"-"，只保留可打印ASCII并截断
+func syslogField(value string, maxLen int) string {
+	var b strings.Builder
+	for _, r := range value {
+		if r > 32 && r < 127 {
+			b.WriteRune(r)
+		}
+	}
+	out := b.String()
+	if out == "" {
+		return "-"
+	}
+	if len(out) > maxLen {
+		out = out[:maxLen]
+	}
+	return out
+}
+
+// 按字节截断，不截断多字节字符
+func truncate(value string, maxLen int) string {
+	if len(value) <= maxLen {
+		return value
+	}
+	cut := maxLen
+	for cut > maxLen-utf8.UTFMax && cut > 0 && !utf8.RuneStart(value[cut]) {
+		cut--
+	}
+	return value[:cut]
+}
+
+// CEF负载
+func formatCEF(version string, record *Record) []byte {
+	access := record.Access
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "CEF:0|%s|%s|%s|%s|%s|%d|",
+		cefHeader(vendor),
+		cefHeader(product),
+		cefHeader(version),
+		cefHeader("decision:"+access.Action),
+		cefHeader(record.Reason),
+		cefSeverity(access.Action),
+	)
+
+	ext := []struct{ key, value string }{
+		{"rt", strconv.FormatInt(access.Timestamp.UnixMilli(), 10)},
+		{"act", access.Action},
+		{"src", access.IP},
+		{"requestMethod", access.Method},
+		{"request", truncate(access.Path, maxFieldBytes)},
+		{"requestClientApplication", truncate(access.UserAgent, maxFieldBytes)},
+		{"reason", record.Reason},
+		{"cs1Label", "fingerprint"},
+		{"cs1", access.Fingerprint},
+		{"cs2Label", "category"},
+		{"cs2", record.Category},
+		{"cn1Label", "score"},
+		{"cn1", strconv.Itoa(access.Score)},
+	}
+	if record.Score != nil {
+		ext = append(ext,
+			struct{ key, value string }{"cn2Label", "scoreChange"},
+			struct{ key, value string }{"cn2", strconv.Itoa(record.Score.Change)},
+		)
+	}
+	if record.Analysis != nil {
+		ext = append(ext,
+			struct{ key, value string }{"cs3Label", "riskLevel"},
+			struct{ key, value string }{"cs3", record.Analysis.RiskLevel},
+			struct{ key, value string }{"cfp1Label", "riskScore"},
+			struct{ key, value string }{"cfp1", strconv.FormatFloat(record.Analysis.RiskScore, 'f', 1, 64)},
+		)
+		if behaviors := behaviorTypes(record); len(behaviors) > 0 {
+			ext = append(ext,
+				struct{ key, value string }{"cs4Label", "behaviors"},
+				struct{ key, value string }{"cs4", strings.Join(behaviors, ",")},
+			)
+		}
+	}
+	if record.Degraded {
+		ext = append(ext,
+			struct{ key, value string }{"cs5Label", "degraded"},
+			struct{ key, value string }{"cs5", "true"},
+		)
+	}
+	// 启用GeoIP时的来源国家、城市和自治系统
+	if access.Country != "" {
+		ext = append(ext,
+			struct{ key, value string }{"cs6Label", "country"},
+			struct{ key, value string }{"cs6", access.Country},
+		)
+	}
+	if access.City != "" {
+		ext = append(ext,
+			struct{ key, value string }{"flexString2Label", "city"},
+			struct{ key, value string }{"flexString2", truncate(access.City, maxFlexStringBytes)},
+		)
+	}
+	if access.ASN != 0 {
+		ext = append(ext,
+			struct{ key, value string }{"cn3Label", "asn"},
+			struct{ key, value string }{"cn3", strconv.FormatUint(uint64(access.ASN), 10)},
+		)
+		if access.ASOrg != "" {
+			ext = append(ext,
+				struct{ key, value string }{"flexString1Label", "asOrganization"},
+				struct{ key, value string }{"flexString1", truncate(access.ASOrg, maxFlexStringBytes)},
+			)
+		}
+	}
+
+	first := true
+	for _, field := range ext {
+		if field.value == "" {
+			continue
+		}
+		if !first {
+			b.WriteByte(' ')
+		}
+		first = false
+		b.WriteString(field.key)
+		b.WriteByte('=')
+		b.WriteString(cefExtension(field.value))
+	}
+
+	return []byte(b.String())
+}
+
+// CEF头部转义：反斜杠和竖线
+func cefHeader(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "|", `\|`)
+	value = strings.ReplaceAll(value, "\r", " ")
+	return strings.ReplaceAll(value, "\n", " ")
+}
+
+// CEF扩展值转义：反斜杠、等号和换行
+func cefExtension(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "=", `\=`)
+	value = strings.ReplaceAll(value, "\r", `\r`)
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
+
+// Elastic Common Schema文档
+type ecsDocument struct {
+	Timestamp time.Time `json:"@timestamp"`
+	ECS       struct {
+		Version string `json:"version"`
+	} `json:"ecs"`
+	Event struct {
+		Kind      string   `json:"kind"`
+		Category  []string `json:"category"`
+		Type      []string `json:"type"`
+		Action    string   `json:"action"`
+		Outcome   string   `json:"outcome"`
+		Reason    string   `json:"reason,omitempty"`
+		Severity  int      `json:"severity"`
+		RiskScore float64  `json:"risk_score,omitempty"`
+	} `json:"event"`
+	Observer struct {
+		Vendor  string `json:"vendor"`
+		Product string `json:"product"`
+		Type    string `json:"type"`
+		Version string `json:"version,omitempty"`
+	} `json:"observer"`
+	Source struct {
+		IP  string  `json:"ip,omitempty"`
+		Geo *ecsGeo `json:"geo,omitempty"`
+		AS  *ecsAS  `json:"as,omitempty"`
+	} `json:"source"`
+	HTTP struct {
+		Request struct {
+			Method string `json:"method,omitempty"`
+		} `json:"request"`
+	} `json:"http"`
+	URL struct {
+		Path string `json:"path,omitempty"`
+	} `json:"url"`
+	UserAgent struct {
+		Original string `json:"original,omitempty"`
+	} `json:"user_agent"`
+	Rule struct {
+		Category string `json:"category,omitempty"`
+	} `json:"rule"`
+	Firewall ecsFirewall `json:"firewall"`
+}
+
+// 来源地理位置
+type ecsGeo struct {
+	CountryISOCode string `json:"country_iso_code,omitempty"`
+	CityName       string `json:"city_name,omitempty"`
+}
+
+// 来源自治系统
+type ecsAS struct {
+	Number       uint `json:"number"`
+	Organization struct {
+		Name string `json:"name,omitempty"`
+	} `json:"organization"`
+}
+
+// 自定义字段
+type ecsFirewall struct {
+	Fingerprint string   `json:"fingerprint"`
+	Score       int      `json:"score"`
+	ScoreChange *int     `json:"score_change,omitempty"`
+	ScoreReason []string `json:"score_reasons,omitempty"`
+	RiskLevel   string   `json:"risk_level,omitempty"`
+	Behaviors   []string `json:"behaviors,omitempty"`
+	Degraded    bool     `json:"degraded,omitempty"`
+}
+
+// ECS JSON负载
+func formatECS(version string, record *Record) ([]byte, error) {
+	access := record.Access
+
+	var doc ecsDocument
+	doc.Timestamp = access.Timestamp.UTC()
+	doc.ECS.Version = ecsVersion
+
+	doc.Event.Kind = "event"
+	doc.Event.Category = []string{"network", "web"}
+	doc.Event.Action = access.Action
+	doc.Event.Reason = record.Reason
+	doc.Event.Severity = cefSeverity(access.Action)
+	if access.Action == "allow" {
+		doc.Event.Type = []string{"allowed"}
+		doc.Event.Outcome = "success"
+	} else {
+		doc.Event.Type = []string{"denied"}
+		doc.Event.Outcome = "failure"
+	}
+
+	doc.Observer.Vendor = vendor
+	doc.Observer.Product = product
+	doc.Observer.Type = "firewall"
+	doc.Observer.Version = version
+
+	doc.Source.IP = access.IP
+	if access.Country != "" || access.City != "" {
+		doc.Source.Geo = &ecsGeo{CountryISOCode: access.Country, CityName: access.City}
+	}
+	if access.ASN != 0 {
+		doc.Source.AS = &ecsAS{Number: access.ASN}
+		doc.Source.AS.Organization.Name = access.ASOrg
+	}
+	doc.HTTP.Request.Method = access.Method
+	doc.URL.Path = truncate(access.Path, maxFieldBytes)
+	doc.UserAgent.Original = truncate(access.UserAgent, maxFieldBytes)
+	doc.Rule.Category = record.Category
+
+	doc.Firewall = ecsFirewall{
+		Fingerprint: access.Fingerprint,
+		Score:       access.Score,
+		Degraded:    record.Degraded,
+	}
+	if record.Score != nil {
+		change := record.Score.Change
+		doc.Firewall.ScoreChange = &change
+		doc.Firewall.ScoreReason = record.Score.Reasons
+	}
+	if record.Analysis != nil {
+		doc.Event.RiskScore = record.Analysis.RiskScore
+		doc.Firewall.RiskLevel = record.Analysis.RiskLevel
+		doc.Firewall.Behaviors = behaviorTypes(record)
+	}
+
+	return json.Marshal(doc)
+}
+
+// 检测到的行为类型
+func behaviorTypes(record *Record) []string {
+	if record.Analysis == nil {
+		return nil
+	}
+	types := make([]string, 0, len(record.Analysis.Behaviors))
+	for _, behavior := range record.Analysis.Behaviors {
+		types = append(types, behavior.Type)
+	}
+	return types
+}
@@ -0,0 +1,319 @@
+package siem
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+
+	"securefingerprint/internal/analyzer"
+	"securefingerprint/internal/metrics"
+	"securefingerprint/internal/scorer"
+	"securefingerprint/internal/storage"
+)
+
+// 传输协议
+const (
+	NetworkUDP = "udp"
+	NetworkTCP = "tcp"
+	NetworkTLS = "tls"
+)
+
+// TLS配置
+type TLSConfig struct {
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"` // 客户端证书（双向TLS时使用）
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// SIEM导出配置
+type Config struct {
+	Enabled      bool          `yaml:"enabled"`
+	Network      string        `yaml:"network"`       // udp / tcp / tls
+	Address      string        `yaml:"address"`       // host:port
+	Format       string        `yaml:"format"`        // cef / ecs
+	AllDecisions bool          `yaml:"all_decisions"` // 同时导出allow决策
+	Facility     int           `yaml:"facility"`      // syslog facility，默认16(local0)
+	AppName      string        `yaml:"app_name"`
+	Hostname     string        `yaml:"hostname"` // 为空时使用系统主机名
+	BufferSize   int           `yaml:"buffer_size"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	MaxBackoff   time.Duration `yaml:"max_backoff"` // 重连最大等待时间
+	MaxRetries   int           `yaml:"max_retries"` // 单条记录最多重试次数，超过后丢弃，避免一条记录阻塞队列
+	TLS          TLSConfig     `yaml:"tls"`
+	Version      string        `yaml:"-"` // 产品版本，由构建信息注入
+}
+
+// 默认SIEM导出配置
+var DefaultConfig = Config{
+	Network:      NetworkUDP,
+	Address:      "localhost:514",
+	Format:       FormatCEF,
+	Facility:     16,
+	AppName:      "firewall-controller",
+	BufferSize:   10000,
+	WriteTimeout: 5 * time.Second,
+	MaxBackoff:   30 * time.Second,
+	MaxRetries:   10,
+}
+
+// 导出的决策记录
+type Record struct {
+	Access   *storage.AccessRecord
+	Score    *scorer.ScoreResult      // 依赖故障时为nil
+	Analysis *analyzer.AnalysisResult // 依赖故障时为nil
+	Category string
+	Reason   string
+	Degraded bool
+}
+
+// syslog导出器：缓冲队列 + 后台发送，断线自动重连
+type Exporter struct {
+	config    Config
+	hostname  string
+	procID    int
+	tlsConfig *tls.Config
+	queue     chan *Record
+	conn      net.Conn
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// 创建导出器并启动发送协程
+func NewExporter(config Config) (*Exporter, error) {
+	if config.Network == "" {
+		config.Network = DefaultConfig.Network
+	}
+	if config.Format == "" {
+		config.Format = DefaultConfig.Format
+	}
+	if config.Facility <= 0 || config.Facility > 23 {
+		config.Facility = DefaultConfig.Facility
+	}
+	if config.AppName == "" {
+		config.AppName = DefaultConfig.AppName
+	}
+	if config.BufferSize <= 0 {
+		config.BufferSize = DefaultConfig.BufferSize
+	}
+	if config.WriteTimeout <= 0 {
+		config.WriteTimeout = DefaultConfig.WriteTimeout
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = DefaultConfig.MaxBackoff
+	}
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = DefaultConfig.MaxRetries
+	}
+	if config.Format != FormatCEF && config.Format != FormatECS {
+		return nil, fmt.Errorf("不支持的SIEM格式: %s", config.Format)
+	}
+	if config.Address == "" {
+		return nil, fmt.Errorf("SIEM导出缺少address")
+	}
+
+	hostname := config.Hostname
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+
+	e := &Exporter{
+		config:   config,
+		hostname: hostname,
+		procID:   os.Getpid(),
+		queue:    make(chan *Record, config.BufferSize),
+		done:     make(chan struct{}),
+	}
+
+	switch config.Network {
+	case NetworkUDP, NetworkTCP:
+	case NetworkTLS:
+		tlsConfig, err := buildTLSConfig(config.TLS)
+		if err != nil {
+			return nil, err
+		}
+		e.tlsConfig = tlsConfig
+	default:
+		return nil, fmt.Errorf("不支持的SIEM传输协议: %s", config.Network)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel = cancel
+	go e.run(ctx)
+
+	return e, nil
+}
+
+func buildTLSConfig(config TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         config.ServerName,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取SIEM CA证书失败: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("解析SIEM CA证书失败: %s", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if config.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载SIEM客户端证书失败: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// 导出决策（非阻塞，缓冲区满时丢弃）
+func (e *Exporter) Export(record *Record) {
+	if !e.config.AllDecisions && record.Access.Action == "allow" {
+		return
+	}
+	select {
+	case e.queue <- record:
+	default:
+		metrics.EventsDropped.WithLabelValues("siem").Inc()
+	}
+}
+
+// 当前缓冲的记录数
+func (e *Exporter) QueueDepth() int {
+	return len(e.queue)
+}
+
+// 发送循环，发送失败时按指数退避重连后重试当前消息，
+// 消息本身无法发送（如超过数据报上限）或重试次数用尽时丢弃
+func (e *Exporter) run(ctx context.Context) {
+	defer close(e.done)
+	defer e.disconnect()
+
+	backoff := 500 * time.Millisecond
+	for {
+		var record *Record
+		select {
+		case <-ctx.Done():
+			return
+		case record = <-e.queue:
+		}
+
+		message, err := e.encode(record)
+		if err != nil {
+			log.Printf("SIEM记录编码失败: %v", err)
+			continue
+		}
+
+		for attempt := 1; ; attempt++ {
+			err := e.send(message)
+			if err == nil {
+				backoff = 500 * time.Millisecond
+				break
+			}
+			e.disconnect()
+			if permanentError(err) || attempt > e.config.MaxRetries {
+				log.Printf("SIEM发送失败，丢弃记录（%d字节，已尝试%d次）: %v", len(message), attempt, err)
+				metrics.EventsDropped.WithLabelValues("siem").Inc()
+				break
+			}
+			log.Printf("SIEM发送失败，%v后重连: %v", backoff, err)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > e.config.MaxBackoff {
+				backoff = e.config.MaxBackoff
+			}
+		}
+	}
+}
+
+// 与消息本身有关、重试不会成功的错误
+func permanentError(err error) bool {
+	return errors.Is(err, syscall.EMSGSIZE)
+}
+
+// 编码为带传输分帧的syslog消息
+func (e *Exporter) encode(record *Record) ([]byte, error) {
+	var payload []byte
+	switch e.config.Format {
+	case FormatECS:
+		var err error
+		payload, err = formatECS(e.config.Version, record)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		payload = formatCEF(e.config.Version, record)
+	}
+
+	message := formatSyslog(e.config.Facility, e.hostname, e.config.AppName, e.procID, record, payload)
+	if e.config.Network == NetworkUDP {
+		return message, nil
+	}
+	// TCP/TLS使用RFC 6587 octet-counting分帧
+	return append([]byte(strconv.Itoa(len(message))+" "), message...), nil
+}
+
+func (e *Exporter) send(message []byte) error {
+	if e.conn == nil {
+		conn, err := e.dial()
+		if err != nil {
+			return err
+		}
+		e.conn = conn
+	}
+	e.conn.SetWriteDeadline(time.Now().Add(e.config.WriteTimeout))
+	_, err := e.conn.Write(message)
+	return err
+}
+
+func (e *Exporter) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: e.config.WriteTimeout}
+	switch e.config.Network {
+	case NetworkTLS:
+		return tls.DialWithDialer(dialer, "tcp", e.config.Address, e.tlsConfig)
+	default:
+		return dialer.Dial(e.config.Network, e.config.Address)
+	}
+}
+
+func (e *Exporter) disconnect() {
+	if e.conn != nil {
+		e.conn.Close()
+		e.conn = nil
+	}
+}
+
+// 停止导出，在截止时间前尽量发送完缓冲区
+func (e *Exporter) Close(ctx context.Context) error {
+	for len(e.queue) > 0 {
+		select {
+		case <-ctx.Done():
+			e.cancel()
+			<-e.done
+			return ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	e.cancel()
+	<-e.done
+	return nil
+}
@@ -1,7 +1,7 @@
 package storage
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -15,6 +15,8 @@ type AccessRecordQuery struct {
 	Path        string    `json:"path,omitempty"`
 	Method      string    `json:"method,omitempty"`
 	Action      string    `json:"action,omitempty"`
+	Country     string    `json:"country,omitempty"`
+	ASN         uint      `json:"asn,omitempty"`
 	MinScore    *int      `json:"min_score,omitempty"`
 	MaxScore    *int      `json:"max_score,omitempty"`
 	StartTime   time.Time `json:"start_time,omitempty"`
@@ -23,12 +25,14 @@ type AccessRecordQuery struct {
 	Offset      int       `json:"offset"`
 	OrderBy     string    `json:"order_by"`
 	OrderDir    string    `json:"order_dir"`
+	Cursor      *int64    `json:"cursor,omitempty"` // 游标分页：返回ID小于游标的记录（0表示从最新开始），按ID降序
 }
 
 // 访问记录查询结果
 type AccessRecordResult struct {
 	Records    []AccessRecord `json:"records"`
 	Total      int64          `json:"total"`
+	NextCursor *int64         `json:"next_cursor,omitempty"` // 游标分页的下一页游标，没有更多记录时为空
 	Page       int            `json:"page"`
 	PageSize   int            `json:"page_size"`
 	TotalPages int            `json:"total_pages"`
@@ -44,6 +48,8 @@ type AccessStats struct {
 	PathStats         []PathAccessStat       `json:"path_stats"`
 	UserAgentStats    []UserAgentStat        `json:"user_agent_stats"`
 	ScoreDistribution []ScoreDistributionStat `json:"score_distribution"`
+	CountryStats      []CountryAccessStat    `json:"country_stats"`
+	ASNStats          []ASNAccessStat        `json:"asn_stats"`
 }
 
 type HourlyAccessStat struct {
@@ -66,8 +72,23 @@ type ScoreDistributionStat struct {
 	Count      int64  `json:"count"`
 }
 
+type CountryAccessStat struct {
+	Country string `json:"country"`
+	Count   int64  `json:"count"`
+}
+
+type ASNAccessStat struct {
+	ASN          uint   `json:"asn"`
+	Organization string `json:"organization"`
+	Count        int64  `json:"count"`
+}
+
 // 扩展MySQL客户端功能
 func (m *MySQLClient) QueryAccessRecords(query *AccessRecordQuery) (*AccessRecordResult, error) {
+	if query.Cursor != nil {
+		return m.queryAccessRecordsByCursor(query)
+	}
+
 	// 构建WHERE条件
 	whereClause, args := m.buildWhereClause(query)
 	
@@ -87,7 +108,7 @@ func (m *MySQLClient) QueryAccessRecords(query *AccessRecordQuery) (*AccessRecor
 	
 	// 查询数据
 	dataSQL := fmt.Sprintf(`
-		SELECT id, fingerprint, ip, user_agent, path, method, score, action, timestamp 
+		SELECT id, fingerprint, ip, user_agent, path, method, score, action, timestamp, country, city, asn, as_org 
 		FROM access_logs 
 		WHERE %s 
 		%s 
@@ -105,6 +126,7 @@ func (m *MySQLClient) QueryAccessRecords(query *AccessRecordQuery) (*AccessRecor
 		err := rows.Scan(
 			&record.ID, &record.Fingerprint, &record.IP, &record.UserAgent,
 			&record.Path, &record.Method, &record.Score, &record.Action, &record.Timestamp,
+			&record.Country, &record.City, &record.ASN, &record.ASOrg,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("扫描记录失败: %v", err)
@@ -125,6 +147,89 @@ func (m *MySQLClient) QueryAccessRecords(query *AccessRecordQuery) (*AccessRecor
 	}, nil
 }
 
+// 游标分页查询，不统计总数，深翻页性能不随页数下降
+func (m *MySQLClient) queryAccessRecordsByCursor(query *AccessRecordQuery) (*AccessRecordResult, error) {
+	whereClause, args := m.buildWhereClause(query)
+	if *query.Cursor > 0 {
+		whereClause += " AND id < ?"
+		args = append(args, *query.Cursor)
+	}
+	args = append(args, query.Limit)
+
+	dataSQL := fmt.Sprintf(`
+		SELECT id, fingerprint, ip, user_agent, path, method, score, action, timestamp, country, city, asn, as_org 
+		FROM access_logs 
+		WHERE %s 
+		ORDER BY id DESC 
+		LIMIT ?`, whereClause)
+
+	rows, err := m.db.Query(dataSQL, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询数据失败: %v", err)
+	}
+	defer rows.Close()
+
+	records := []AccessRecord{}
+	for rows.Next() {
+		var record AccessRecord
+		err := rows.Scan(
+			&record.ID, &record.Fingerprint, &record.IP, &record.UserAgent,
+			&record.Path, &record.Method, &record.Score, &record.Action, &record.Timestamp,
+			&record.Country, &record.City, &record.ASN, &record.ASOrg,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("扫描记录失败: %v", err)
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("读取记录失败: %v", err)
+	}
+
+	result := &AccessRecordResult{
+		Records:  records,
+		Total:    -1,
+		PageSize: query.Limit,
+	}
+	if len(records) == query.Limit {
+		next := int64(records[len(records)-1].ID)
+		result.NextCursor = &next
+	}
+	return result, nil
+}
+
+// 按ID升序逐行读取符合条件的记录，不限制条数也不在内存中缓存结果集
+func (m *MySQLClient) StreamAccessRecords(ctx context.Context, query *AccessRecordQuery, fn func(*AccessRecord) error) error {
+	whereClause, args := m.buildWhereClause(query)
+	dataSQL := fmt.Sprintf(`
+		SELECT id, fingerprint, ip, user_agent, path, method, score, action, timestamp, country, city, asn, as_org 
+		FROM access_logs 
+		WHERE %s 
+		ORDER BY id ASC`, whereClause)
+
+	rows, err := m.db.QueryContext(ctx, dataSQL, args...)
+	if err != nil {
+		return fmt.Errorf("查询数据失败: %v", err)
+	}
+	defer rows.Close()
+
+	var record AccessRecord
+	for rows.Next() {
+		err := rows.Scan(
+			&record.ID, &record.Fingerprint, &record.IP, &record.UserAgent,
+			&record.Path, &record.Method, &record.Score, &record.Action, &record.Timestamp,
+			&record.Country, &record.City, &record.ASN, &record.ASOrg,
+		)
+		if err != nil {
+			return fmt.Errorf("扫描记录失败: %v", err)
+		}
+		if err := fn(&record); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
 // 构建WHERE子句
 func (m *MySQLClient) buildWhereClause(query *AccessRecordQuery) (string, []interface{}) {
 	var conditions []string
@@ -163,6 +268,16 @@ func (m *MySQLClient) buildWhereClause(query *AccessRecordQuery) (string, []inte
 		args = append(args, query.Action)
 	}
 
+	if query.Country != "" {
+		conditions = append(conditions, "country = ?")
+		args = append(args, strings.ToUpper(query.Country))
+	}
+
+	if query.ASN != 0 {
+		conditions = append(conditions, "asn = ?")
+		args = append(args, query.ASN)
+	}
+
 	if query.MinScore != nil {
 		conditions = append(conditions, "score >= ?")
 		args = append(args, *query.MinScore)
@@ -194,6 +309,7 @@ func (m *MySQLClient) buildOrderClause(query *AccessRecordQuery) string {
 		validFields := map[string]bool{
 			"id": true, "fingerprint": true, "ip": true, "score": true,
 			"timestamp": true, "action": true, "method": true,
+			"country": true, "asn": true,
 		}
 		if validFields[query.OrderBy] {
 			orderBy = query.OrderBy
@@ -277,6 +393,16 @@ func (m *MySQLClient) GetAccessStats(query *AccessRecordQuery) (*AccessStats, er
 		stats.ScoreDistribution = scoreDistribution
 	}
 
+	// 国家和ASN统计
+	countryStats, err := m.getCountryStats(whereClause, args)
+	if err == nil {
+		stats.CountryStats = countryStats
+	}
+	asnStats, err := m.getASNStats(whereClause, args)
+	if err == nil {
+		stats.ASNStats = asnStats
+	}
+
 	return stats, nil
 }
 
@@ -367,6 +493,62 @@ func (m *MySQLClient) getUserAgentStats(whereClause string, args []interface{})
 	return stats, nil
 }
 
+// 获取国家统计（前10）
+func (m *MySQLClient) getCountryStats(whereClause string, args []interface{}) ([]CountryAccessStat, error) {
+	sql := fmt.Sprintf(`
+		SELECT country, COUNT(*) as count 
+		FROM access_logs 
+		WHERE %s AND country != ''
+		GROUP BY country 
+		ORDER BY count DESC 
+		LIMIT 10`, whereClause)
+
+	rows, err := m.db.Query(sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []CountryAccessStat
+	for rows.Next() {
+		var stat CountryAccessStat
+		if err := rows.Scan(&stat.Country, &stat.Count); err != nil {
+			continue
+		}
+		stats = append(stats, stat)
+	}
+
+	return stats, nil
+}
+
+// 获取ASN统计（前10），同一ASN的组织名称在数据库更新后可能不同，取其中一个
+func (m *MySQLClient) getASNStats(whereClause string, args []interface{}) ([]ASNAccessStat, error) {
+	sql := fmt.Sprintf(`
+		SELECT asn, MAX(as_org) as as_org, COUNT(*) as count 
+		FROM access_logs 
+		WHERE %s AND asn != 0
+		GROUP BY asn 
+		ORDER BY count DESC 
+		LIMIT 10`, whereClause)
+
+	rows, err := m.db.Query(sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []ASNAccessStat
+	for rows.Next() {
+		var stat ASNAccessStat
+		if err := rows.Scan(&stat.ASN, &stat.Organization, &stat.Count); err != nil {
+			continue
+		}
+		stats = append(stats, stat)
+	}
+
+	return stats, nil
+}
+
 // 获取分数分布统计
 func (m *MySQLClient) getScoreDistribution(whereClause string, args []interface{}) ([]ScoreDistributionStat, error) {
 	sql := fmt.Sprintf(`
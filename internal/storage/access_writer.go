@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"securefingerprint/internal/tracing"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 异步写入器配置
+type AccessWriterConfig struct {
+	QueueSize     int           `yaml:"queue_size"`     // 队列容量
+	BatchSize     int           `yaml:"batch_size"`     // 单批写入条数
+	FlushInterval time.Duration `yaml:"flush_interval"` // 最长刷新间隔
+}
+
+// 默认异步写入器配置
+var DefaultAccessWriterConfig = AccessWriterConfig{
+	QueueSize:     10000,
+	BatchSize:     100,
+	FlushInterval: time.Second,
+}
+
+// 访问日志异步写入器，将MySQL写入移出请求路径
+type AccessWriter struct {
+	client  *MySQLClient
+	config  AccessWriterConfig
+	queue   chan *AccessRecord
+	mu      sync.RWMutex
+	closed  bool
+	done    chan struct{}
+	dropped uint64
+}
+
+// 创建异步写入器
+func NewAccessWriter(client *MySQLClient, config AccessWriterConfig) *AccessWriter {
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultAccessWriterConfig.QueueSize
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultAccessWriterConfig.BatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = DefaultAccessWriterConfig.FlushInterval
+	}
+
+	w := &AccessWriter{
+		client: client,
+		config: config,
+		queue:  make(chan *AccessRecord, config.QueueSize),
+		done:   make(chan struct{}),
+	}
+	go w.run()
+
+	return w
+}
+
+// 提交访问记录（非阻塞，队列满时丢弃并返回false）
+func (w *AccessWriter) Write(record *AccessRecord) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		atomic.AddUint64(&w.dropped, 1)
+		return false
+	}
+
+	select {
+	case w.queue <- record:
+		return true
+	default:
+		atomic.AddUint64(&w.dropped, 1)
+		return false
+	}
+}
+
+// 当前队列深度
+func (w *AccessWriter) QueueDepth() int {
+	return len(w.queue)
+}
+
+// 被丢弃的记录数
+func (w *AccessWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+// 停止接收新记录并在截止时间前写完队列
+func (w *AccessWriter) Close(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 后台批量写入循环
+func (w *AccessWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*AccessRecord, 0, w.config.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		_, span := tracing.Tracer().Start(context.Background(), "mysql.log_access_batch",
+			trace.WithLinks(batchLinks(batch)...),
+			trace.WithAttributes(tracing.AttrBatchSize.Int(len(batch))),
+		)
+		if err := w.client.LogAccessBatch(batch); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			log.Printf("批量写入访问日志失败(%d条): %v", len(batch), err)
+		}
+		span.End()
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case record, ok := <-w.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, record)
+			if len(batch) >= w.config.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// 批量写入span链接到各条记录所属的请求span
+func batchLinks(batch []*AccessRecord) []trace.Link {
+	links := make([]trace.Link, 0, len(batch))
+	for _, record := range batch {
+		if record.SpanContext.IsValid() {
+			links = append(links, trace.Link{SpanContext: record.SpanContext})
+		}
+	}
+	return links
+}
@@ -0,0 +1,257 @@
+package storage
+
+import (
+	"sync"
+	"time"
+
+	"securefingerprint/internal/clock"
+)
+
+// 与RedisClient相同的过期时间
+const (
+	memoryScoreTTL     = 24 * time.Hour
+	memoryAccessLogTTL = time.Hour
+	memoryRateTTL      = time.Minute
+)
+
+type memoryEntry struct {
+	expiresAt time.Time
+}
+
+type memoryCounter struct {
+	count     int
+	expiresAt time.Time
+}
+
+type memoryScore struct {
+	score     UserScore
+	expiresAt time.Time
+}
+
+// 进程内状态存储，按注入的时钟判断过期，行为与RedisClient的键过期一致
+type MemoryStore struct {
+	clock clock.Clock
+
+	mu         sync.Mutex
+	scores     map[string]*memoryScore
+	accessLogs map[string][]AccessLog
+	bans       map[string]memoryEntry
+	rates      map[string]*memoryCounter
+	whitelist  map[string]memoryEntry
+}
+
+// 创建内存存储，clock为空时使用系统时钟
+func NewMemoryStore(c clock.Clock) *MemoryStore {
+	if c == nil {
+		c = clock.Real{}
+	}
+	return &MemoryStore{
+		clock:      c,
+		scores:     make(map[string]*memoryScore),
+		accessLogs: make(map[string][]AccessLog),
+		bans:       make(map[string]memoryEntry),
+		rates:      make(map[string]*memoryCounter),
+		whitelist:  make(map[string]memoryEntry),
+	}
+}
+
+// 获取用户分数
+func (m *MemoryStore) GetUserScore(fingerprint string) (*UserScore, error) {
+	now := m.clock.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.scores[fingerprint]
+	if !ok || !now.Before(entry.expiresAt) {
+		return &UserScore{
+			Score:        100,
+			LastSeen:     now,
+			RequestCount: 0,
+		}, nil
+	}
+	score := entry.score
+	return &score, nil
+}
+
+// 更新用户分数
+func (m *MemoryStore) UpdateUserScore(fingerprint string, score *UserScore) error {
+	now := m.clock.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.scores[fingerprint] = &memoryScore{score: *score, expiresAt: now.Add(memoryScoreTTL)}
+	return nil
+}
+
+// 记录访问日志，同一秒内的记录互相覆盖（与Redis键按秒命名一致）
+func (m *MemoryStore) LogAccess(log *AccessLog) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	logs := m.accessLogs[log.Fingerprint]
+	for i := range logs {
+		if logs[i].Timestamp.Unix() == log.Timestamp.Unix() {
+			logs[i] = *log
+			return nil
+		}
+	}
+	m.accessLogs[log.Fingerprint] = append(logs, *log)
+	return nil
+}
+
+// 获取用户最近访问记录，同时清理已过期的记录
+func (m *MemoryStore) GetRecentAccess(fingerprint string, minutes int) ([]AccessLog, error) {
+	now := m.clock.Now()
+	expired := now.Add(-memoryAccessLogTTL)
+	cutoff := now.Add(-time.Duration(minutes) * time.Minute)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	logs := m.accessLogs[fingerprint]
+	kept := logs[:0]
+	var result []AccessLog
+	for _, log := range logs {
+		if !log.Timestamp.After(expired) {
+			continue
+		}
+		kept = append(kept, log)
+		if log.Timestamp.After(cutoff) {
+			result = append(result, log)
+		}
+	}
+	if len(kept) == 0 {
+		delete(m.accessLogs, fingerprint)
+	} else {
+		m.accessLogs[fingerprint] = kept
+	}
+	return result, nil
+}
+
+// 检查用户是否被封禁
+func (m *MemoryStore) IsUserBanned(fingerprint string) (bool, time.Duration, error) {
+	now := m.clock.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.bans[fingerprint]
+	if !ok {
+		return false, 0, nil
+	}
+	ttl := entry.expiresAt.Sub(now)
+	if ttl <= 0 {
+		delete(m.bans, fingerprint)
+		return false, 0, nil
+	}
+	return true, ttl, nil
+}
+
+// 封禁用户
+func (m *MemoryStore) BanUser(fingerprint string, duration time.Duration) error {
+	now := m.clock.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.bans[fingerprint] = memoryEntry{expiresAt: now.Add(duration)}
+	return nil
+}
+
+// 解除封禁
+func (m *MemoryStore) UnbanUser(fingerprint string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.bans, fingerprint)
+	return nil
+}
+
+// 获取访问频率
+func (m *MemoryStore) GetRequestRate(fingerprint string) (int, error) {
+	now := m.clock.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	counter, ok := m.rates[fingerprint]
+	if !ok || !now.Before(counter.expiresAt) {
+		return 0, nil
+	}
+	return counter.count, nil
+}
+
+// 增加请求计数，每次计数都会刷新过期时间（与INCR+EXPIRE一致）
+func (m *MemoryStore) IncrementRequestRate(fingerprint string) error {
+	now := m.clock.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	counter, ok := m.rates[fingerprint]
+	if !ok || !now.Before(counter.expiresAt) {
+		counter = &memoryCounter{}
+		m.rates[fingerprint] = counter
+	}
+	counter.count++
+	counter.expiresAt = now.Add(memoryRateTTL)
+	return nil
+}
+
+// 添加白名单
+func (m *MemoryStore) AddToWhitelist(fingerprint string, duration time.Duration) error {
+	now := m.clock.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.whitelist[fingerprint] = memoryEntry{expiresAt: now.Add(duration)}
+	return nil
+}
+
+// 检查白名单
+func (m *MemoryStore) IsWhitelisted(fingerprint string) (bool, error) {
+	now := m.clock.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.whitelist[fingerprint]
+	if !ok {
+		return false, nil
+	}
+	if !now.Before(entry.expiresAt) {
+		delete(m.whitelist, fingerprint)
+		return false, nil
+	}
+	return true, nil
+}
+
+// 清理过期的分数和计数，长时间回放时控制内存占用
+func (m *MemoryStore) Sweep() {
+	now := m.clock.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, entry := range m.scores {
+		if !now.Before(entry.expiresAt) {
+			delete(m.scores, key)
+		}
+	}
+	for key, counter := range m.rates {
+		if !now.Before(counter.expiresAt) {
+			delete(m.rates, key)
+		}
+	}
+	expired := now.Add(-memoryAccessLogTTL)
+	for key, logs := range m.accessLogs {
+		if len(logs) > 0 && !logs[len(logs)-1].Timestamp.After(expired) {
+			delete(m.accessLogs, key)
+		}
+	}
+}
+
+var _ Store = (*MemoryStore)(nil)
@@ -1,11 +1,14 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type MySQLClient struct {
@@ -22,6 +25,13 @@ type AccessRecord struct {
 	Score       int       `json:"score"`
 	Action      string    `json:"action"` // "allow", "limit", "ban"
 	Timestamp   time.Time `json:"timestamp"`
+	Country     string    `json:"country,omitempty"` // GeoIP国家代码
+	City        string    `json:"city,omitempty"`
+	ASN         uint      `json:"asn,omitempty"`
+	ASOrg       string    `json:"as_org,omitempty"` // 自治系统所属组织
+
+	Shadows     []*ShadowRecord   `json:"shadows,omitempty"` // 影子模式下未执行的决策，按评估顺序排列
+	SpanContext trace.SpanContext `json:"-"`                // 产生该记录的请求span，用于关联异步写入
 }
 
 type UserStats struct {
@@ -67,9 +77,15 @@ func (m *MySQLClient) initTables() error {
 			score INT DEFAULT 100,
 			action VARCHAR(20) DEFAULT 'allow',
 			timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			country CHAR(2) NOT NULL DEFAULT '',
+			city VARCHAR(100) NOT NULL DEFAULT '',
+			asn INT UNSIGNED NOT NULL DEFAULT 0,
+			as_org VARCHAR(255) NOT NULL DEFAULT '',
 			INDEX idx_fingerprint (fingerprint),
 			INDEX idx_timestamp (timestamp),
-			INDEX idx_ip (ip)
+			INDEX idx_ip (ip),
+			INDEX idx_country (country),
+			INDEX idx_asn (asn)
 		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
 
 		`CREATE TABLE IF NOT EXISTS user_stats (
@@ -91,6 +107,24 @@ func (m *MySQLClient) initTables() error {
 			INDEX idx_fingerprint (fingerprint),
 			INDEX idx_banned_at (banned_at)
 		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+		`CREATE TABLE IF NOT EXISTS shadow_decisions (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			fingerprint VARCHAR(64) NOT NULL,
+			ip VARCHAR(45) NOT NULL,
+			path VARCHAR(500),
+			method VARCHAR(10),
+			score INT,
+			source VARCHAR(20) NOT NULL,
+			category VARCHAR(20),
+			shadow_action VARCHAR(20) NOT NULL,
+			enforced_action VARCHAR(20) NOT NULL,
+			reason VARCHAR(200),
+			position TINYINT UNSIGNED NOT NULL DEFAULT 0,
+			timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			INDEX idx_timestamp (timestamp),
+			INDEX idx_fingerprint (fingerprint)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
 	}
 
 	for _, query := range queries {
@@ -99,25 +133,125 @@ func (m *MySQLClient) initTables() error {
 		}
 	}
 
+	return m.migrateColumns()
+}
+
+// 旧版本创建的表缺少的列
+var columnMigrations = []struct {
+	table      string
+	name       string
+	definition string
+}{
+	{"access_logs", "country", "ADD COLUMN country CHAR(2) NOT NULL DEFAULT '', ADD INDEX idx_country (country)"},
+	{"access_logs", "city", "ADD COLUMN city VARCHAR(100) NOT NULL DEFAULT ''"},
+	{"access_logs", "asn", "ADD COLUMN asn INT UNSIGNED NOT NULL DEFAULT 0, ADD INDEX idx_asn (asn)"},
+	{"access_logs", "as_org", "ADD COLUMN as_org VARCHAR(255) NOT NULL DEFAULT ''"},
+	{"shadow_decisions", "position", "ADD COLUMN position TINYINT UNSIGNED NOT NULL DEFAULT 0"},
+}
+
+// 为已存在的表补充新增的列
+func (m *MySQLClient) migrateColumns() error {
+	for _, column := range columnMigrations {
+		var count int
+		err := m.db.QueryRow(`SELECT COUNT(*) FROM information_schema.COLUMNS
+			WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?`, column.table, column.name).Scan(&count)
+		if err != nil {
+			return fmt.Errorf("检查%s表结构失败: %v", column.table, err)
+		}
+		if count > 0 {
+			continue
+		}
+		if _, err := m.db.Exec("ALTER TABLE " + column.table + " " + column.definition); err != nil {
+			return fmt.Errorf("为%s表添加%s列失败: %v", column.table, column.name, err)
+		}
+	}
 	return nil
 }
 
 // 记录访问日志
-func (m *MySQLClient) LogAccess(record *AccessRecord) error {
-	query := `INSERT INTO access_logs (fingerprint, ip, user_agent, path, method, score, action, timestamp) 
-			  VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+func (m *MySQLClient) LogAccess(record *AccessRecord) (err error) {
+	defer observeMySQL("log_access", time.Now(), &err)
+	query := `INSERT INTO access_logs (fingerprint, ip, user_agent, path, method, score, action, timestamp, country, city, asn, as_org) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 	
-	_, err := m.db.Exec(query, record.Fingerprint, record.IP, record.UserAgent, 
-		record.Path, record.Method, record.Score, record.Action, record.Timestamp)
+	_, err = m.db.Exec(query, record.Fingerprint, record.IP, record.UserAgent, 
+		record.Path, record.Method, record.Score, record.Action, record.Timestamp,
+		record.Country, record.City, record.ASN, record.ASOrg)
 	
 	if err != nil {
 		return err
 	}
 
+	if err := insertShadowRecords(m.db, []*AccessRecord{record}); err != nil {
+		return err
+	}
+
 	// 更新用户统计
 	return m.updateUserStats(record.Fingerprint, record.Score)
 }
 
+// 批量记录访问日志
+func (m *MySQLClient) LogAccessBatch(records []*AccessRecord) (err error) {
+	if len(records) == 0 {
+		return nil
+	}
+	defer observeMySQL("log_access_batch", time.Now(), &err)
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	placeholders := make([]string, 0, len(records))
+	args := make([]interface{}, 0, len(records)*12)
+	for _, record := range records {
+		placeholders = append(placeholders, "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+		args = append(args, record.Fingerprint, record.IP, record.UserAgent,
+			record.Path, record.Method, record.Score, record.Action, record.Timestamp,
+			record.Country, record.City, record.ASN, record.ASOrg)
+	}
+
+	query := `INSERT INTO access_logs (fingerprint, ip, user_agent, path, method, score, action, timestamp, country, city, asn, as_org)
+			  VALUES ` + strings.Join(placeholders, ", ")
+	if _, err := tx.Exec(query, args...); err != nil {
+		return err
+	}
+	if err := insertShadowRecords(tx, records); err != nil {
+		return err
+	}
+
+	// 按指纹聚合后更新用户统计
+	type statDelta struct {
+		count int
+		score int
+	}
+	deltas := make(map[string]*statDelta)
+	for _, record := range records {
+		delta, ok := deltas[record.Fingerprint]
+		if !ok {
+			delta = &statDelta{}
+			deltas[record.Fingerprint] = delta
+		}
+		delta.count++
+		delta.score = record.Score
+	}
+
+	statsQuery := `INSERT INTO user_stats (fingerprint, total_requests, current_score, first_seen, last_seen)
+			  VALUES (?, ?, ?, NOW(), NOW())
+			  ON DUPLICATE KEY UPDATE
+			  total_requests = total_requests + ?,
+			  current_score = ?,
+			  last_seen = NOW()`
+	for fingerprint, delta := range deltas {
+		if _, err := tx.Exec(statsQuery, fingerprint, delta.count, delta.score, delta.count, delta.score); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
 // 更新用户统计信息
 func (m *MySQLClient) updateUserStats(fingerprint string, score int) error {
 	query := `INSERT INTO user_stats (fingerprint, total_requests, current_score, first_seen, last_seen) 
@@ -132,12 +266,13 @@ func (m *MySQLClient) updateUserStats(fingerprint string, score int) error {
 }
 
 // 获取用户统计信息
-func (m *MySQLClient) GetUserStats(fingerprint string) (*UserStats, error) {
+func (m *MySQLClient) GetUserStats(fingerprint string) (_ *UserStats, err error) {
+	defer observeMySQL("get_user_stats", time.Now(), &err)
 	query := `SELECT fingerprint, total_requests, current_score, first_seen, last_seen, ban_count 
 			  FROM user_stats WHERE fingerprint = ?`
 	
 	var stats UserStats
-	err := m.db.QueryRow(query, fingerprint).Scan(
+	err = m.db.QueryRow(query, fingerprint).Scan(
 		&stats.Fingerprint, &stats.TotalRequests, &stats.CurrentScore,
 		&stats.FirstSeen, &stats.LastSeen, &stats.BanCount,
 	)
@@ -150,7 +285,8 @@ func (m *MySQLClient) GetUserStats(fingerprint string) (*UserStats, error) {
 }
 
 // 获取访问日志（支持分页和筛选）
-func (m *MySQLClient) GetAccessLogs(fingerprint string, limit, offset int, startTime, endTime time.Time) ([]AccessRecord, error) {
+func (m *MySQLClient) GetAccessLogs(fingerprint string, limit, offset int, startTime, endTime time.Time) (_ []AccessRecord, err error) {
+	defer observeMySQL("get_access_logs", time.Now(), &err)
 	query := `SELECT id, fingerprint, ip, user_agent, path, method, score, action, timestamp 
 			  FROM access_logs WHERE 1=1`
 	args := []interface{}{}
@@ -195,11 +331,12 @@ func (m *MySQLClient) GetAccessLogs(fingerprint string, limit, offset int, start
 }
 
 // 记录封禁历史
-func (m *MySQLClient) LogBan(fingerprint, reason string, durationSeconds int) error {
+func (m *MySQLClient) LogBan(fingerprint, reason string, durationSeconds int) (err error) {
+	defer observeMySQL("log_ban", time.Now(), &err)
 	query := `INSERT INTO ban_history (fingerprint, reason, banned_at, duration_seconds) 
 			  VALUES (?, ?, NOW(), ?)`
 	
-	_, err := m.db.Exec(query, fingerprint, reason, durationSeconds)
+	_, err = m.db.Exec(query, fingerprint, reason, durationSeconds)
 	if err != nil {
 		return err
 	}
@@ -211,12 +348,13 @@ func (m *MySQLClient) LogBan(fingerprint, reason string, durationSeconds int) er
 }
 
 // 获取系统统计信息
-func (m *MySQLClient) GetSystemStats() (map[string]interface{}, error) {
+func (m *MySQLClient) GetSystemStats() (_ map[string]interface{}, err error) {
+	defer observeMySQL("get_system_stats", time.Now(), &err)
 	stats := make(map[string]interface{})
 
 	// 总访问次数
 	var totalAccess int
-	err := m.db.QueryRow("SELECT COUNT(*) FROM access_logs").Scan(&totalAccess)
+	err = m.db.QueryRow("SELECT COUNT(*) FROM access_logs").Scan(&totalAccess)
 	if err != nil {
 		return nil, err
 	}
@@ -249,6 +387,24 @@ func (m *MySQLClient) GetSystemStats() (map[string]interface{}, error) {
 	return stats, nil
 }
 
+// 带超时的连通性检查
+func (m *MySQLClient) Ping(ctx context.Context) error {
+	return m.db.PingContext(ctx)
+}
+
+// 连接池统计
+func (m *MySQLClient) PoolStats() map[string]interface{} {
+	stats := m.db.Stats()
+	return map[string]interface{}{
+		"max_open_conns":   stats.MaxOpenConnections,
+		"open_conns":       stats.OpenConnections,
+		"in_use":           stats.InUse,
+		"idle":             stats.Idle,
+		"wait_count":       stats.WaitCount,
+		"wait_duration_ms": stats.WaitDuration.Milliseconds(),
+	}
+}
+
 func (m *MySQLClient) Close() error {
 	return m.db.Close()
 }
@@ -0,0 +1,12 @@
+package storage
+
+import (
+	"time"
+
+	"securefingerprint/internal/metrics"
+)
+
+// 记录MySQL调用耗时与错误，配合命名返回值使用: defer observeMySQL("op", time.Now(), &err)
+func observeMySQL(operation string, start time.Time, err *error) {
+	metrics.ObserveStorage("mysql", operation, start, *err)
+}
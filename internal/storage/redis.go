@@ -6,12 +6,15 @@ import (
 	"fmt"
 	"time"
 
+	"securefingerprint/internal/resilience"
+
 	"github.com/redis/go-redis/v9"
 )
 
 type RedisClient struct {
-	client *redis.Client
-	ctx    context.Context
+	client  *redis.Client
+	ctx     context.Context
+	breaker *resilience.CircuitBreaker
 }
 
 type UserScore struct {
@@ -21,21 +24,30 @@ type UserScore struct {
 }
 
 type AccessLog struct {
-	Fingerprint string    `json:"fingerprint"`
-	IP          string    `json:"ip"`
-	UserAgent   string    `json:"user_agent"`
-	Path        string    `json:"path"`
-	Timestamp   time.Time `json:"timestamp"`
-	Score       int       `json:"score"`
+	Fingerprint    string    `json:"fingerprint"`
+	IP             string    `json:"ip"`
+	UserAgent      string    `json:"user_agent"`
+	Path           string    `json:"path"`
+	Method         string    `json:"method"`
+	Timestamp      time.Time `json:"timestamp"`
+	Score          int       `json:"score"`
+	HeaderOrder    string    `json:"header_order,omitempty"`    // 原始请求头顺序签名
+	HeaderMismatch bool      `json:"header_mismatch,omitempty"` // 头顺序与UA声明的浏览器不一致
+	ClientEvidence []string  `json:"client_evidence,omitempty"` // 浏览器信号中的自动化工具证据
+	DeviceReplay   bool      `json:"device_replay,omitempty"`   // 设备cookie在过多网段中出现
 }
 
-func NewRedisClient(addr, password string, db int, poolSize int) (*RedisClient, error) {
+func NewRedisClient(addr, password string, db int, poolSize int, dialTimeout, readTimeout, writeTimeout time.Duration) (*RedisClient, error) {
 	rdb := redis.NewClient(&redis.Options{
-		Addr:     addr,
-		Password: password,
-		DB:       db,
-		PoolSize: poolSize,
+		Addr:         addr,
+		Password:     password,
+		DB:           db,
+		PoolSize:     poolSize,
+		DialTimeout:  dialTimeout,
+		ReadTimeout:  readTimeout,
+		WriteTimeout: writeTimeout,
 	})
+	rdb.AddHook(metricsHook{})
 
 	ctx := context.Background()
 	_, err := rdb.Ping(ctx).Result()
@@ -167,6 +179,177 @@ func (r *RedisClient) IncrementRequestRate(fingerprint string) error {
 	return err
 }
 
+// 添加白名单
+func (r *RedisClient) AddToWhitelist(fingerprint string, duration time.Duration) error {
+	key := fmt.Sprintf("whitelist:%s", fingerprint)
+	return r.client.Set(r.ctx, key, "whitelisted", duration).Err()
+}
+
+// 检查白名单
+func (r *RedisClient) IsWhitelisted(fingerprint string) (bool, error) {
+	key := fmt.Sprintf("whitelist:%s", fingerprint)
+	n, err := r.client.Exists(r.ctx, key).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// 把旧指纹的分数、封禁和白名单带到新指纹（指纹盐值轮换时使用），保留剩余有效期，新指纹已有的状态不覆盖。
+// 每个新指纹在ttl内只迁移一次，返回是否执行了迁移
+func (r *RedisClient) MigrateFingerprint(from, to string, ttl time.Duration) (bool, error) {
+	claimed, err := r.client.SetNX(r.ctx, fmt.Sprintf("fp_migrated:%s", to), from, ttl).Result()
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	for _, prefix := range []string{"user_score", "banned", "whitelist"} {
+		source := fmt.Sprintf("%s:%s", prefix, from)
+		pipe := r.client.Pipeline()
+		value := pipe.Get(r.ctx, source)
+		remaining := pipe.PTTL(r.ctx, source)
+		if _, err := pipe.Exec(r.ctx); err == redis.Nil {
+			continue
+		} else if err != nil {
+			return true, err
+		}
+
+		expiration := remaining.Val()
+		if expiration < 0 {
+			expiration = 0
+		}
+		target := fmt.Sprintf("%s:%s", prefix, to)
+		if err := r.client.SetNX(r.ctx, target, value.Val(), expiration).Err(); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// 关联设备ID与指纹，设备ID已有关联时返回已关联的指纹，并刷新保留时间
+func (r *RedisClient) LinkDevice(deviceID, fingerprint string, ttl time.Duration) (string, error) {
+	key := fmt.Sprintf("device:%s", deviceID)
+	pipe := r.client.TxPipeline()
+	pipe.SetNX(r.ctx, key, fingerprint, ttl)
+	pipe.Expire(r.ctx, key, ttl)
+	linked := pipe.Get(r.ctx, key)
+	if _, err := pipe.Exec(r.ctx); err != nil {
+		return "", err
+	}
+	return linked.Val(), nil
+}
+
+// 记录设备ID出现的网段，返回时间窗口内的不同网段数
+func (r *RedisClient) TrackDeviceNetwork(deviceID, network string, window time.Duration) (int, error) {
+	key := fmt.Sprintf("device_networks:%s", deviceID)
+	now := time.Now()
+	pipe := r.client.TxPipeline()
+	pipe.ZAdd(r.ctx, key, redis.Z{Score: float64(now.UnixMilli()), Member: network})
+	pipe.ZRemRangeByScore(r.ctx, key, "-inf", fmt.Sprintf("%d", now.Add(-window).UnixMilli()))
+	count := pipe.ZCard(r.ctx, key)
+	pipe.Expire(r.ctx, key, window)
+	if _, err := pipe.Exec(r.ctx); err != nil {
+		return 0, err
+	}
+	return int(count.Val()), nil
+}
+
+// 带超时的连通性检查
+func (r *RedisClient) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+// 连接池统计
+func (r *RedisClient) PoolStats() map[string]interface{} {
+	stats := r.client.PoolStats()
+	return map[string]interface{}{
+		"hits":        stats.Hits,
+		"misses":      stats.Misses,
+		"timeouts":    stats.Timeouts,
+		"total_conns": stats.TotalConns,
+		"idle_conns":  stats.IdleConns,
+		"stale_conns": stats.StaleConns,
+	}
+}
+
 func (r *RedisClient) Close() error {
 	return r.client.Close()
 }
+
+// 分配序号、写入历史列表并发布，三步在同一脚本中执行，各订阅者看到的序号与历史列表一致
+var publishSequencedScript = redis.NewScript(`
+local seq = redis.call('INCR', KEYS[1])
+local message = seq .. ':' .. ARGV[1]
+redis.call('LPUSH', KEYS[2], message)
+redis.call('LTRIM', KEYS[2], 0, tonumber(ARGV[2]) - 1)
+redis.call('PUBLISH', ARGV[3], message)
+return seq
+`)
+
+// 发布带全局序号的消息，消息格式为 <序号>:<payload>，历史列表最多保留maxLen条
+func (r *RedisClient) PublishSequenced(ctx context.Context, channel, seqKey, historyKey string, payload []byte, maxLen int) (uint64, error) {
+	seq, err := publishSequencedScript.Run(ctx, r.client, []string{seqKey, historyKey}, payload, maxLen, channel).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("发布事件失败: %v", err)
+	}
+	return uint64(seq), nil
+}
+
+// 订阅频道，断线后自动重连，调用返回的函数取消订阅
+func (r *RedisClient) Subscribe(ctx context.Context, channel string) (<-chan []byte, func() error) {
+	pubsub := r.client.Subscribe(ctx, channel)
+	messages := make(chan []byte)
+	go func() {
+		defer close(messages)
+		for msg := range pubsub.Channel() {
+			select {
+			case messages <- []byte(msg.Payload):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return messages, pubsub.Close
+}
+
+// 获取分布式去重标记，返回true表示首次获得
+func (r *RedisClient) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, key, "1", ttl).Result()
+}
+
+// 写入列表头部并截断到最大长度
+func (r *RedisClient) PushCapped(ctx context.Context, key string, payload []byte, maxLen int) error {
+	pipe := r.client.TxPipeline()
+	pipe.LPush(ctx, key, payload)
+	pipe.LTrim(ctx, key, 0, int64(maxLen-1))
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// 读取列表前limit项
+func (r *RedisClient) ListRange(ctx context.Context, key string, limit int) ([][]byte, error) {
+	values, err := r.client.LRange(ctx, key, 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+	result := make([][]byte, 0, len(values))
+	for _, value := range values {
+		result = append(result, []byte(value))
+	}
+	return result, nil
+}
+
+// 原子取出并删除整个列表
+func (r *RedisClient) DrainList(ctx context.Context, key string) ([][]byte, error) {
+	pipe := r.client.TxPipeline()
+	lrange := pipe.LRange(ctx, key, 0, -1)
+	pipe.Del(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	result := make([][]byte, 0, len(lrange.Val()))
+	for _, value := range lrange.Val() {
+		result = append(result, []byte(value))
+	}
+	return result, nil
+}
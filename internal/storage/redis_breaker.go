@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"securefingerprint/internal/resilience"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis命令熔断钩子
+type breakerHook struct {
+	breaker *resilience.CircuitBreaker
+}
+
+// 为Redis客户端启用熔断器，PING命令不经过熔断器以便健康检查反映真实状态
+func (r *RedisClient) UseCircuitBreaker(breaker *resilience.CircuitBreaker) {
+	r.breaker = breaker
+	r.client.AddHook(&breakerHook{breaker: breaker})
+}
+
+// 熔断器状态摘要
+func (r *RedisClient) BreakerSummary() map[string]interface{} {
+	if r.breaker == nil {
+		return nil
+	}
+	return r.breaker.Summary()
+}
+
+func (h *breakerHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (h *breakerHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if cmd.Name() == "ping" {
+			return next(ctx, cmd)
+		}
+		if err := h.breaker.Allow(); err != nil {
+			cmd.SetErr(err)
+			return err
+		}
+		err := next(ctx, cmd)
+		h.breaker.Record(storageFailure(err))
+		return err
+	}
+}
+
+func (h *breakerHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if err := h.breaker.Allow(); err != nil {
+			for _, cmd := range cmds {
+				cmd.SetErr(err)
+			}
+			return err
+		}
+		err := next(ctx, cmds)
+		h.breaker.Record(storageFailure(err))
+		return err
+	}
+}
+
+// 只有连接或超时类错误才计入熔断，redis.Nil等业务结果不算失败
+func storageFailure(err error) error {
+	if err == nil || errors.Is(err, redis.Nil) {
+		return nil
+	}
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) {
+		return nil
+	}
+	return err
+}
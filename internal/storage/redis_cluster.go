@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 保存指纹的组件向量
+func (r *RedisClient) SaveComponents(fingerprint string, components map[string]string, ttl time.Duration) error {
+	key := fmt.Sprintf("fp_components:%s", fingerprint)
+	values := make(map[string]interface{}, len(components))
+	for name, value := range components {
+		values[name] = value
+	}
+	pipe := r.client.TxPipeline()
+	pipe.HSet(r.ctx, key, values)
+	pipe.Expire(r.ctx, key, ttl)
+	_, err := pipe.Exec(r.ctx)
+	return err
+}
+
+// 读取指纹的组件向量，不存在时返回空
+func (r *RedisClient) GetComponents(fingerprint string) (map[string]string, error) {
+	return r.client.HGetAll(r.ctx, fmt.Sprintf("fp_components:%s", fingerprint)).Result()
+}
+
+// 把指纹加入组件值索引
+func (r *RedisClient) IndexComponent(component, value, fingerprint string, ttl time.Duration) error {
+	key := fmt.Sprintf("fp_index:%s:%s", component, value)
+	pipe := r.client.TxPipeline()
+	pipe.SAdd(r.ctx, key, fingerprint)
+	pipe.Expire(r.ctx, key, ttl)
+	_, err := pipe.Exec(r.ctx)
+	return err
+}
+
+// 随机取出最多limit个组件值相同的指纹
+func (r *RedisClient) FindByComponent(component, value string, limit int) ([]string, error) {
+	key := fmt.Sprintf("fp_index:%s:%s", component, value)
+	return r.client.SRandMemberN(r.ctx, key, int64(limit)).Result()
+}
+
+// 查询指纹所属身份，未聚类时返回空
+func (r *RedisClient) GetCluster(fingerprint string) (string, error) {
+	cluster, err := r.client.Get(r.ctx, fmt.Sprintf("fp_cluster:%s", fingerprint)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return cluster, err
+}
+
+// 把指纹加入身份，并刷新身份的保留时间
+func (r *RedisClient) JoinCluster(fingerprint, cluster string, ttl time.Duration) error {
+	members := fmt.Sprintf("cluster_members:%s", cluster)
+	pipe := r.client.TxPipeline()
+	pipe.Set(r.ctx, fmt.Sprintf("fp_cluster:%s", fingerprint), cluster, ttl)
+	pipe.SAdd(r.ctx, members, fingerprint)
+	pipe.Expire(r.ctx, members, ttl)
+	_, err := pipe.Exec(r.ctx)
+	return err
+}
+
+// 身份中的全部指纹
+func (r *RedisClient) ClusterMembers(cluster string) ([]string, error) {
+	return r.client.SMembers(r.ctx, fmt.Sprintf("cluster_members:%s", cluster)).Result()
+}
+
+// 记录身份封禁
+func (r *RedisClient) BanCluster(cluster string, duration time.Duration) error {
+	return r.client.Set(r.ctx, fmt.Sprintf("banned_cluster:%s", cluster), "banned", duration).Err()
+}
+
+// 身份封禁剩余时间，未封禁时返回0
+func (r *RedisClient) ClusterBanTTL(cluster string) (time.Duration, error) {
+	ttl, err := r.client.TTL(r.ctx, fmt.Sprintf("banned_cluster:%s", cluster)).Result()
+	if err != nil || ttl <= 0 {
+		return 0, err
+	}
+	return ttl, nil
+}
+
+// 解除身份封禁
+func (r *RedisClient) UnbanCluster(cluster string) error {
+	return r.client.Del(r.ctx, fmt.Sprintf("banned_cluster:%s", cluster)).Err()
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"securefingerprint/internal/metrics"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis命令耗时与错误统计钩子
+type metricsHook struct{}
+
+func (metricsHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		start := time.Now()
+		conn, err := next(ctx, network, addr)
+		metrics.ObserveStorage("redis", "dial", start, err)
+		return conn, err
+	}
+}
+
+func (metricsHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		metrics.ObserveStorage("redis", cmd.Name(), start, storageFailure(err))
+		return err
+	}
+}
+
+func (metricsHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		metrics.ObserveStorage("redis", "pipeline", start, storageFailure(err))
+		return err
+	}
+}
+
+// 统计匹配模式的键数量（SCAN，不阻塞Redis）
+func (r *RedisClient) CountKeys(ctx context.Context, pattern string) (int, error) {
+	count := 0
+	iter := r.client.Scan(ctx, 0, pattern, 1000).Iterator()
+	for iter.Next(ctx) {
+		count++
+	}
+	return count, iter.Err()
+}
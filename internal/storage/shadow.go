@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 影子模式下计算出但未执行的决策
+type ShadowRecord struct {
+	Source   string `json:"source"`   // 决策来源
+	Category string `json:"category"` // 原因分类
+	Action   string `json:"action"`   // 本应执行的动作
+	Reason   string `json:"reason"`
+}
+
+// 影子决策与实际执行结果的对比
+type ShadowOutcome struct {
+	Source         string `json:"source"`
+	ShadowAction   string `json:"shadow_action"`
+	EnforcedAction string `json:"enforced_action"`
+	Count          int64  `json:"count"`
+	Fingerprints   int64  `json:"fingerprints"`
+}
+
+// 影子决策最多的指纹
+type ShadowFingerprint struct {
+	Fingerprint string `json:"fingerprint"`
+	Count       int64  `json:"count"`
+	Sources     string `json:"sources"`
+}
+
+// 影子模式报告
+type ShadowReport struct {
+	StartTime            time.Time           `json:"start_time"`
+	EndTime              time.Time           `json:"end_time"`
+	TotalRequests        int64               `json:"total_requests"`
+	ShadowDecisions      int64               `json:"shadow_decisions"`
+	AffectedFingerprints int64               `json:"affected_fingerprints"`
+	WouldBlock           int64               `json:"would_block"` // 关闭影子模式后会被拦截但实际放行的请求数
+	Outcomes             []ShadowOutcome     `json:"outcomes"`
+	TopFingerprints      []ShadowFingerprint `json:"top_fingerprints"`
+}
+
+// 会拦截请求的动作
+var blockingActions = []string{"challenge", "ban", "reject"}
+
+// *sql.DB与*sql.Tx共有的执行接口
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// 写入记录中的影子决策，每个决策一行，position为评估顺序（0是关闭影子模式后实际会执行的决策）
+// 批量写入时与访问日志在同一事务中
+func insertShadowRecords(db execer, records []*AccessRecord) error {
+	placeholders := make([]string, 0, len(records))
+	args := make([]interface{}, 0, len(records)*12)
+	for _, record := range records {
+		for position, shadow := range record.Shadows {
+			placeholders = append(placeholders, "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+			args = append(args, record.Fingerprint, record.IP, record.Path, record.Method, record.Score,
+				shadow.Source, shadow.Category, shadow.Action, record.Action,
+				shadow.Reason, position, record.Timestamp)
+		}
+	}
+	if len(placeholders) == 0 {
+		return nil
+	}
+
+	query := `INSERT INTO shadow_decisions (fingerprint, ip, path, method, score, source, category, shadow_action, enforced_action, reason, position, timestamp)
+			  VALUES ` + strings.Join(placeholders, ", ")
+	_, err := db.Exec(query, args...)
+	return err
+}
+
+// 统计时间范围内影子决策与实际执行结果
+func (m *MySQLClient) GetShadowReport(startTime, endTime time.Time) (_ *ShadowReport, err error) {
+	defer observeMySQL("get_shadow_report", time.Now(), &err)
+
+	report := &ShadowReport{
+		StartTime:       startTime,
+		EndTime:         endTime,
+		Outcomes:        []ShadowOutcome{},
+		TopFingerprints: []ShadowFingerprint{},
+	}
+	rangeArgs := []interface{}{startTime, endTime}
+
+	err = m.db.QueryRow("SELECT COUNT(*) FROM access_logs WHERE timestamp >= ? AND timestamp < ?", rangeArgs...).
+		Scan(&report.TotalRequests)
+	if err != nil {
+		return nil, fmt.Errorf("查询请求总数失败: %v", err)
+	}
+
+	blocking := "'" + strings.Join(blockingActions, "','") + "'"
+	summarySQL := fmt.Sprintf(`
+		SELECT COUNT(*), COUNT(DISTINCT fingerprint),
+			COALESCE(SUM(position = 0 AND shadow_action IN (%s) AND enforced_action NOT IN (%s)), 0)
+		FROM shadow_decisions
+		WHERE timestamp >= ? AND timestamp < ?`, blocking, blocking)
+	err = m.db.QueryRow(summarySQL, rangeArgs...).
+		Scan(&report.ShadowDecisions, &report.AffectedFingerprints, &report.WouldBlock)
+	if err != nil {
+		return nil, fmt.Errorf("查询影子决策统计失败: %v", err)
+	}
+
+	rows, err := m.db.Query(`
+		SELECT source, shadow_action, enforced_action, COUNT(*), COUNT(DISTINCT fingerprint)
+		FROM shadow_decisions
+		WHERE timestamp >= ? AND timestamp < ?
+		GROUP BY source, shadow_action, enforced_action
+		ORDER BY source, COUNT(*) DESC`, rangeArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("查询影子决策对比失败: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var outcome ShadowOutcome
+		if err := rows.Scan(&outcome.Source, &outcome.ShadowAction, &outcome.EnforcedAction,
+			&outcome.Count, &outcome.Fingerprints); err != nil {
+			return nil, err
+		}
+		report.Outcomes = append(report.Outcomes, outcome)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	topRows, err := m.db.Query(`
+		SELECT fingerprint, COUNT(*), GROUP_CONCAT(DISTINCT source)
+		FROM shadow_decisions
+		WHERE timestamp >= ? AND timestamp < ?
+		GROUP BY fingerprint
+		ORDER BY COUNT(*) DESC
+		LIMIT 20`, rangeArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("查询影子决策指纹失败: %v", err)
+	}
+	defer topRows.Close()
+
+	for topRows.Next() {
+		var fp ShadowFingerprint
+		if err := topRows.Scan(&fp.Fingerprint, &fp.Count, &fp.Sources); err != nil {
+			return nil, err
+		}
+		report.TopFingerprints = append(report.TopFingerprints, fp)
+	}
+	return report, topRows.Err()
+}
@@ -0,0 +1,20 @@
+package storage
+
+import "time"
+
+// 决策流程使用的状态存储，RedisClient为生产实现，MemoryStore用于离线回放
+type Store interface {
+	GetUserScore(fingerprint string) (*UserScore, error)
+	UpdateUserScore(fingerprint string, score *UserScore) error
+	LogAccess(log *AccessLog) error
+	GetRecentAccess(fingerprint string, minutes int) ([]AccessLog, error)
+	IsUserBanned(fingerprint string) (bool, time.Duration, error)
+	BanUser(fingerprint string, duration time.Duration) error
+	UnbanUser(fingerprint string) error
+	GetRequestRate(fingerprint string) (int, error)
+	IncrementRequestRate(fingerprint string) error
+	AddToWhitelist(fingerprint string, duration time.Duration) error
+	IsWhitelisted(fingerprint string) (bool, error)
+}
+
+var _ Store = (*RedisClient)(nil)
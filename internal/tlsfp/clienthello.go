@@ -0,0 +1,275 @@
+package tlsfp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// TLS扩展类型
+const (
+	extServerName           uint16 = 0x0000
+	extSupportedGroups      uint16 = 0x000a
+	extPointFormats         uint16 = 0x000b
+	extSignatureAlgorithms  uint16 = 0x000d
+	extALPN                 uint16 = 0x0010
+	extStatusRequestV2      uint16 = 0x0011
+	extSCT                  uint16 = 0x0012
+	extEncryptThenMAC       uint16 = 0x0016
+	extCompressCertificate  uint16 = 0x001b
+	extRecordSizeLimit      uint16 = 0x001c
+	extDelegatedCredentials uint16 = 0x0022
+	extSupportedVersions    uint16 = 0x002b
+	extALPS                 uint16 = 0x4469
+	extALPSNew              uint16 = 0x44cd
+)
+
+const (
+	recordTypeHandshake   = 22
+	handshakeTypeClientHi = 1
+	maxClientHelloSize    = 64 * 1024
+	recordHeaderLen       = 5
+	handshakeHeaderLen    = 4
+)
+
+// 数据不足，需要继续读取
+var errShortHello = errors.New("ClientHello不完整")
+
+// 解析后的ClientHello，保留扩展等字段的原始顺序
+type ClientHello struct {
+	Version             uint16   // ClientHello中的legacy_version
+	SupportedVersions   []uint16 // supported_versions扩展
+	CipherSuites        []uint16
+	Extensions          []uint16
+	Curves              []uint16 // supported_groups扩展
+	PointFormats        []uint8
+	SignatureAlgorithms []uint16
+	ALPN                []string
+	ServerName          string
+}
+
+// GREASE值（RFC 8701）形如0x?a?a，计算指纹时忽略
+func isGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+// 是否包含GREASE值，主流浏览器中只有Chromium和Safari会发送
+func (h *ClientHello) HasGREASE() bool {
+	for _, v := range h.CipherSuites {
+		if isGREASE(v) {
+			return true
+		}
+	}
+	for _, v := range h.Extensions {
+		if isGREASE(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// 是否发送了指定扩展
+func (h *ClientHello) hasExtension(ext uint16) bool {
+	for _, v := range h.Extensions {
+		if v == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// 客户端支持的最高TLS版本，优先取supported_versions扩展
+func (h *ClientHello) MaxVersion() uint16 {
+	var max uint16
+	for _, v := range h.SupportedVersions {
+		if !isGREASE(v) && v > max {
+			max = v
+		}
+	}
+	if max == 0 {
+		return h.Version
+	}
+	return max
+}
+
+// 从TLS记录流中提取ClientHello握手消息。数据不足时返回errShortHello
+func readHandshakeMessage(data []byte) ([]byte, error) {
+	var message []byte
+	for len(data) > 0 {
+		if len(data) < recordHeaderLen {
+			return nil, errShortHello
+		}
+		if data[0] != recordTypeHandshake {
+			return nil, fmt.Errorf("不是TLS握手记录: 类型%d", data[0])
+		}
+		length := int(binary.BigEndian.Uint16(data[3:5]))
+		if len(data) < recordHeaderLen+length {
+			return nil, errShortHello
+		}
+		message = append(message, data[recordHeaderLen:recordHeaderLen+length]...)
+		data = data[recordHeaderLen+length:]
+
+		// 握手消息可能跨多个记录
+		if len(message) >= handshakeHeaderLen {
+			if message[0] != handshakeTypeClientHi {
+				return nil, fmt.Errorf("首个握手消息不是ClientHello: 类型%d", message[0])
+			}
+			size := int(message[1])<<16 | int(message[2])<<8 | int(message[3])
+			if size > maxClientHelloSize {
+				return nil, fmt.Errorf("ClientHello过大: %d字节", size)
+			}
+			if len(message) >= handshakeHeaderLen+size {
+				return message[handshakeHeaderLen : handshakeHeaderLen+size], nil
+			}
+		}
+	}
+	return nil, errShortHello
+}
+
+// 解析ClientHello消息体（不含握手头）
+func ParseClientHello(body []byte) (*ClientHello, error) {
+	r := &reader{data: body}
+	h := &ClientHello{}
+
+	h.Version = r.uint16()
+	r.skip(32) // random
+	r.skip(int(r.uint8()))
+	suites := r.vector16()
+	for suites.len() >= 2 {
+		h.CipherSuites = append(h.CipherSuites, suites.uint16())
+	}
+	r.skip(int(r.uint8())) // compression_methods
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.len() == 0 {
+		return h, nil
+	}
+
+	extensions := r.vector16()
+	for extensions.len() > 0 && extensions.err == nil {
+		typ := extensions.uint16()
+		data := extensions.vector16()
+		if extensions.err != nil {
+			break
+		}
+		h.Extensions = append(h.Extensions, typ)
+		if err := h.parseExtension(typ, data); err != nil {
+			return nil, fmt.Errorf("扩展0x%04x: %v", typ, err)
+		}
+	}
+	if err := firstError(r.err, extensions.err); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *ClientHello) parseExtension(typ uint16, data *reader) error {
+	switch typ {
+	case extServerName:
+		list := data.vector16()
+		for list.len() > 0 && list.err == nil {
+			nameType := list.uint8()
+			name := list.vector16()
+			if nameType == 0 && list.err == nil {
+				h.ServerName = string(name.data)
+			}
+		}
+		return firstError(data.err, list.err)
+	case extSupportedGroups:
+		list := data.vector16()
+		for list.len() >= 2 {
+			h.Curves = append(h.Curves, list.uint16())
+		}
+		return data.err
+	case extPointFormats:
+		list := data.vector8()
+		h.PointFormats = append(h.PointFormats, list.data...)
+		return data.err
+	case extSignatureAlgorithms:
+		list := data.vector16()
+		for list.len() >= 2 {
+			h.SignatureAlgorithms = append(h.SignatureAlgorithms, list.uint16())
+		}
+		return data.err
+	case extALPN:
+		list := data.vector16()
+		for list.len() > 0 && list.err == nil {
+			proto := list.vector8()
+			if list.err == nil {
+				h.ALPN = append(h.ALPN, string(proto.data))
+			}
+		}
+		return firstError(data.err, list.err)
+	case extSupportedVersions:
+		list := data.vector8()
+		for list.len() >= 2 {
+			h.SupportedVersions = append(h.SupportedVersions, list.uint16())
+		}
+		return data.err
+	}
+	return nil
+}
+
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 按网络字节序读取的游标，越界时记录错误并返回零值
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) len() int {
+	return len(r.data)
+}
+
+func (r *reader) take(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data) {
+		r.err = errors.New("ClientHello长度字段越界")
+		r.data = nil
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *reader) skip(n int) {
+	r.take(n)
+}
+
+func (r *reader) uint8() uint8 {
+	b := r.take(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *reader) uint16() uint16 {
+	b := r.take(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (r *reader) vector8() *reader {
+	n := int(r.uint8())
+	return &reader{data: r.take(n), err: r.err}
+}
+
+func (r *reader) vector16() *reader {
+	n := int(r.uint16())
+	return &reader{data: r.take(n), err: r.err}
+}
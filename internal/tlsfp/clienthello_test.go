@@ -0,0 +1,295 @@
+package tlsfp
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// curl 7.88.1 / OpenSSL 3.0.17 访问 https://localhost 时发送的ClientHello（单个TLS记录），
+// 末尾是180字节的padding扩展
+var curlClientHello = "" +
+	"1603010200010001fc0303b0e348dc7f2717d5159cbfa03094436c92a7d8f0d0" +
+	"df2af218b8baca83219c6a208a6b517cc1936a53fe6080fad774a190e2cbdab6" +
+	"f73faf7a38da9251440832ba003e130213031301c02cc030009fcca9cca8ccaa" +
+	"c02bc02f009ec024c028006bc023c0270067c00ac0140039c009c0130033009d" +
+	"009c003d003c0035002f00ff010001750000000e000c0000096c6f63616c686f" +
+	"7374000b000403000102000a00160014001d0017001e00190018010001010102" +
+	"010301040010000e000c02683208687474702f312e3100160000001700000031" +
+	"0000000d002a0028040305030603080708080809080a080b0804080508060401" +
+	"05010601030303010302040205020602002b0009080304030303020301002d00" +
+	"020101003300260024001d00206001d45b74d7801754e7d87b7a17ab0e493791" +
+	"12b212e66e311af4687b29ff76001500b4" + strings.Repeat("00", 180)
+
+// The Illustrated TLS 1.3 Connection（tls13.xargs.org）中curl发送的ClientHello
+var ulfheimClientHello = "" +
+	"16030100f8010000f40303000102030405060708090a0b0c0d0e0f1011121314" +
+	"15161718191a1b1c1d1e1f20e0e1e2e3e4e5e6e7e8e9eaebecedeeeff0f1f2f3" +
+	"f4f5f6f7f8f9fafbfcfdfeff000813021303130100ff010000a3000000180016" +
+	"0000136578616d706c652e756c666865696d2e6e6574000b000403000102000a" +
+	"00160014001d0017001e00190018010001010102010301040023000000160000" +
+	"00170000000d001e001c040305030603080708080809080a080b080408050806" +
+	"040105010601002b0003020304002d00020101003300260024001d0020358072" +
+	"d6365880d1aeea329adf9121383851ed21a28e3b75e965d0d2cd166254"
+
+func decodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("无效的测试数据: %v", err)
+	}
+	return data
+}
+
+// 从完整的TLS记录中取出ClientHello消息体
+func helloBody(t *testing.T, s string) []byte {
+	t.Helper()
+	body, err := readHandshakeMessage(decodeHex(t, s))
+	if err != nil {
+		t.Fatalf("readHandshakeMessage() 错误: %v", err)
+	}
+	return body
+}
+
+func TestFingerprints(t *testing.T) {
+	tests := []struct {
+		name       string
+		record     string
+		serverName string
+		alpn       []string
+		maxVersion uint16
+		ja3String  string
+		ja3        string
+		ja4        string
+	}{
+		{
+			name:       "curl OpenSSL 3",
+			record:     curlClientHello,
+			serverName: "localhost",
+			alpn:       []string{"h2", "http/1.1"},
+			maxVersion: 0x0304,
+			ja3String: "771,4866-4867-4865-49196-49200-159-52393-52392-52394-49195-49199-158-49188-49192-107-49187-49191-103-49162-49172-57-49161-49171-51-157-156-61-60-53-47-255," +
+				"0-11-10-16-22-23-49-13-43-45-51-21,29-23-30-25-24-256-257-258-259-260,0-1-2",
+			ja3: "0149f47eabf9a20d0893e2a44e5a6323",
+			ja4: "t13d3112h2_e8f1e7e78f70_b26ce05bbdd6",
+		},
+		{
+			name:       "Illustrated TLS 1.3",
+			record:     ulfheimClientHello,
+			serverName: "example.ulfheim.net",
+			maxVersion: 0x0304,
+			ja3String:  "771,4866-4867-4865-255,0-11-10-35-22-23-13-43-45-51,29-23-30-25-24-256-257-258-259-260,0-1-2",
+			ja3:        "f146948b4a599d4d7ddf071b74696983",
+			ja4:        "t13d041000_16476d049b0b_78f1d400d464",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hello, err := ParseClientHello(helloBody(t, tt.record))
+			if err != nil {
+				t.Fatalf("ParseClientHello() 错误: %v", err)
+			}
+			if hello.ServerName != tt.serverName {
+				t.Errorf("ServerName = %q, 期望 %q", hello.ServerName, tt.serverName)
+			}
+			if !reflect.DeepEqual(hello.ALPN, tt.alpn) {
+				t.Errorf("ALPN = %v, 期望 %v", hello.ALPN, tt.alpn)
+			}
+			if got := hello.MaxVersion(); got != tt.maxVersion {
+				t.Errorf("MaxVersion() = %#04x, 期望 %#04x", got, tt.maxVersion)
+			}
+			if hello.HasGREASE() {
+				t.Error("HasGREASE() = true, 期望 false")
+			}
+			if got := hello.JA3String(); got != tt.ja3String {
+				t.Errorf("JA3String() = %s, 期望 %s", got, tt.ja3String)
+			}
+			if got := hello.JA3(); got != tt.ja3 {
+				t.Errorf("JA3() = %s, 期望 %s", got, tt.ja3)
+			}
+			if got := hello.JA4(); got != tt.ja4 {
+				t.Errorf("JA4() = %s, 期望 %s", got, tt.ja4)
+			}
+		})
+	}
+}
+
+// 构造扩展
+func extension(typ uint16, data []byte) []byte {
+	out := binary.BigEndian.AppendUint16(nil, typ)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(data)))
+	return append(out, data...)
+}
+
+func uint16List(lengthBytes int, values ...uint16) []byte {
+	var out []byte
+	for _, v := range values {
+		out = binary.BigEndian.AppendUint16(out, v)
+	}
+	if lengthBytes == 1 {
+		return append([]byte{byte(len(out))}, out...)
+	}
+	return append(binary.BigEndian.AppendUint16(nil, uint16(len(out))), out...)
+}
+
+// 构造ClientHello消息体
+func buildHello(ciphers []uint16, extensions ...[]byte) []byte {
+	out := []byte{0x03, 0x03}
+	out = append(out, make([]byte, 32)...) // random
+	out = append(out, 0)                   // session_id
+	out = append(out, uint16List(2, ciphers...)...)
+	out = append(out, 1, 0) // compression_methods
+	ext := []byte{}
+	for _, e := range extensions {
+		ext = append(ext, e...)
+	}
+	out = binary.BigEndian.AppendUint16(out, uint16(len(ext)))
+	return append(out, ext...)
+}
+
+func TestFingerprintGREASE(t *testing.T) {
+	alpn := []byte{0, 3, 2, 'h', '2'}
+	withGREASE := buildHello([]uint16{0x0a0a, 0x1301, 0x1302},
+		extension(0x1a1a, nil),
+		extension(extALPN, alpn),
+		extension(extSupportedGroups, uint16List(2, 0x2a2a, 0x001d)),
+		extension(extSupportedVersions, uint16List(1, 0x3a3a, 0x0304, 0x0303)),
+		extension(0x4a4a, []byte{0}),
+	)
+	without := buildHello([]uint16{0x1301, 0x1302},
+		extension(extALPN, alpn),
+		extension(extSupportedGroups, uint16List(2, 0x001d)),
+		extension(extSupportedVersions, uint16List(1, 0x0304, 0x0303)),
+	)
+
+	a, err := ParseClientHello(withGREASE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ParseClientHello(without)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !a.HasGREASE() || b.HasGREASE() {
+		t.Errorf("HasGREASE() = %v/%v, 期望 true/false", a.HasGREASE(), b.HasGREASE())
+	}
+	if a.JA3String() != b.JA3String() || a.JA3String() != "771,4865-4866,16-10-43,29," {
+		t.Errorf("JA3String() = %q / %q, 期望忽略GREASE后相同", a.JA3String(), b.JA3String())
+	}
+	if a.JA4() != b.JA4() || !strings.HasPrefix(a.JA4(), "t13i0203h2_") {
+		t.Errorf("JA4() = %q / %q, 期望忽略GREASE后相同", a.JA4(), b.JA4())
+	}
+	if a.MaxVersion() != 0x0304 {
+		t.Errorf("MaxVersion() = %#04x, 期望忽略GREASE版本", a.MaxVersion())
+	}
+}
+
+func TestReadHandshakeMessage(t *testing.T) {
+	record := decodeHex(t, ulfheimClientHello)
+	body := record[recordHeaderLen+handshakeHeaderLen:]
+
+	// 同一握手消息拆分到两个记录
+	split := append([]byte{22, 3, 1, 0, 100}, record[recordHeaderLen:recordHeaderLen+100]...)
+	rest := record[recordHeaderLen+100:]
+	split = append(split, 22, 3, 1, byte(len(rest)>>8), byte(len(rest)))
+	split = append(split, rest...)
+
+	oversized := append([]byte(nil), record...)
+	oversized[recordHeaderLen+1] = 0x01 // 握手长度改为0x0100f4
+
+	notHello := append([]byte(nil), record...)
+	notHello[recordHeaderLen] = 2 // ServerHello
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []byte
+		wantErr error // 为空且want为空时只要求返回错误
+	}{
+		{name: "单个记录", data: record, want: body},
+		{name: "跨两个记录", data: split, want: body},
+		{name: "后面跟着其他数据", data: append(append([]byte(nil), record...), 23, 3, 3, 0, 1, 0), want: body},
+		{name: "空数据", data: nil, wantErr: errShortHello},
+		{name: "记录头不完整", data: record[:3], wantErr: errShortHello},
+		{name: "记录体不完整", data: record[:100], wantErr: errShortHello},
+		{name: "只有第一个记录", data: split[:recordHeaderLen+100], wantErr: errShortHello},
+		{name: "不是握手记录", data: append([]byte{23}, record[1:]...)},
+		{name: "不是ClientHello", data: notHello},
+		{name: "握手长度过大", data: oversized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readHandshakeMessage(tt.data)
+			if tt.want != nil {
+				if err != nil {
+					t.Fatalf("readHandshakeMessage() 错误: %v", err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("readHandshakeMessage() 返回%d字节, 期望%d字节", len(got), len(tt.want))
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("readHandshakeMessage() 期望返回错误")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("readHandshakeMessage() 错误 = %v, 期望 %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && errors.Is(err, errShortHello) {
+				t.Errorf("readHandshakeMessage() 错误 = %v, 期望格式错误而不是继续等待数据", err)
+			}
+		})
+	}
+}
+
+func TestParseClientHelloTruncated(t *testing.T) {
+	body := helloBody(t, curlClientHello)
+	// legacy_version(2) + random(32) + session_id(1+32) + cipher_suites(2+62) + compression_methods(2)
+	extensionsStart := 2 + 32 + 1 + 32 + 2 + 62 + 2
+
+	// 任意位置截断都不能panic；截断在扩展之前或扩展内部时必须返回错误，
+	// 恰好在compression_methods之后截断是合法的无扩展ClientHello
+	for n := 0; n < len(body); n++ {
+		hello, err := ParseClientHello(body[:n])
+		if n == extensionsStart {
+			if err != nil || len(hello.Extensions) != 0 {
+				t.Errorf("截断到%d字节: 期望无扩展的ClientHello, 得到 %v, %v", n, hello, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("截断到%d字节: 期望返回错误", n)
+		}
+	}
+
+	overlong := buildHello([]uint16{0x1301}, extension(extEncryptThenMAC, nil))
+	binary.BigEndian.PutUint16(overlong[len(overlong)-6:], 0x10)
+
+	tests := []struct {
+		name string
+		body []byte
+	}{
+		{name: "扩展总长度越界", body: overlong},
+		{name: "扩展长度越界", body: buildHello([]uint16{0x1301}, []byte{0x00, 0x17, 0x00, 0x05, 0x00})},
+		{name: "SNI列表长度越界", body: buildHello([]uint16{0x1301}, extension(extServerName, []byte{0x00, 0x20, 0x00, 0x00, 0x01, 'a'}))},
+		{name: "SNI名称长度越界", body: buildHello([]uint16{0x1301}, extension(extServerName, []byte{0x00, 0x04, 0x00, 0x00, 0x09, 'a'}))},
+		{name: "ALPN协议长度越界", body: buildHello([]uint16{0x1301}, extension(extALPN, []byte{0x00, 0x03, 0x05, 'h', '2'}))},
+		{name: "supported_groups长度越界", body: buildHello([]uint16{0x1301}, extension(extSupportedGroups, []byte{0x00, 0x08, 0x00, 0x1d}))},
+		{name: "supported_versions长度越界", body: buildHello([]uint16{0x1301}, extension(extSupportedVersions, []byte{0x06, 0x03, 0x04}))},
+		{name: "point_formats长度越界", body: buildHello([]uint16{0x1301}, extension(extPointFormats, []byte{0x03, 0x00}))},
+		{name: "signature_algorithms长度越界", body: buildHello([]uint16{0x1301}, extension(extSignatureAlgorithms, []byte{0x00, 0x04, 0x04, 0x03}))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if hello, err := ParseClientHello(tt.body); err == nil {
+				t.Errorf("ParseClientHello() = %+v, 期望返回错误", hello)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package tlsfp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 客户端TLS栈
+const (
+	StackChromium = "chromium"
+	StackFirefox  = "firefox"
+	StackSafari   = "safari"
+	StackGo       = "go"
+	StackOpenSSL  = "openssl" // Python、curl、PHP、Ruby等基于OpenSSL的客户端
+	StackJava     = "java"
+	StackUnknown  = "unknown"
+)
+
+// 浏览器TLS栈，非浏览器栈配合浏览器UA时视为伪装
+var browserStacks = map[string]bool{
+	StackChromium: true,
+	StackFirefox:  true,
+	StackSafari:   true,
+}
+
+// UA声明的浏览器与TLS栈不一致
+type Mismatch struct {
+	Claimed  string `json:"claimed"`  // UA声明的浏览器TLS栈
+	Observed string `json:"observed"` // ClientHello识别出的TLS栈
+	Reason   string `json:"reason"`
+}
+
+// TLS栈识别与UA一致性检测
+type Detector struct {
+	signatures map[string]string // JA4 -> TLS栈
+}
+
+// 创建检测器，signatures为已知JA4到TLS栈的映射
+func NewDetector(signatures map[string]string) *Detector {
+	d := &Detector{signatures: make(map[string]string)}
+	for ja4, stack := range signatures {
+		d.signatures[ja4] = strings.ToLower(stack)
+	}
+	return d
+}
+
+// 识别ClientHello所属的TLS栈。先查已知签名，再按各实现的扩展特征判断
+func (d *Detector) Stack(h *ClientHello) string {
+	if h == nil {
+		return StackUnknown
+	}
+	if stack, ok := d.signatures[h.JA4()]; ok {
+		return stack
+	}
+
+	if h.HasGREASE() {
+		// ALPS只有Chromium实现
+		if h.hasExtension(extALPS) || h.hasExtension(extALPSNew) {
+			return StackChromium
+		}
+		return StackSafari
+	}
+	switch {
+	case h.hasExtension(extRecordSizeLimit) && h.hasExtension(extDelegatedCredentials):
+		return StackFirefox
+	case h.hasExtension(extEncryptThenMAC):
+		// crypto/tls、NSS和BoringSSL均不发送encrypt_then_mac
+		return StackOpenSSL
+	case h.hasExtension(extStatusRequestV2):
+		return StackJava
+	case h.hasExtension(extSCT) && !h.hasExtension(extCompressCertificate):
+		return StackGo
+	}
+	return StackUnknown
+}
+
+// 检查UA声明的浏览器与TLS栈是否一致，一致或无法判断时返回nil
+func (d *Detector) Check(userAgent string, h *ClientHello) *Mismatch {
+	claimed := ClaimedBrowser(userAgent)
+	if claimed == "" || h == nil {
+		return nil
+	}
+	observed := d.Stack(h)
+	if observed == StackUnknown || observed == claimed {
+		return nil
+	}
+
+	reason := fmt.Sprintf("UA声明%s浏览器，TLS握手来自%s", claimed, observed)
+	if !browserStacks[observed] {
+		reason = fmt.Sprintf("UA声明%s浏览器，TLS握手来自%s客户端库", claimed, observed)
+	}
+	return &Mismatch{Claimed: claimed, Observed: observed, Reason: reason}
+}
+
+// UA声明的浏览器所使用的网络栈（chromium/firefox/safari），非浏览器UA返回空
+func ClaimedBrowser(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	if !strings.HasPrefix(ua, "mozilla/") {
+		return ""
+	}
+	switch {
+	// iOS上的所有浏览器都使用系统WebKit网络栈
+	case strings.Contains(ua, "crios/") || strings.Contains(ua, "fxios/") || strings.Contains(ua, "edgios/"):
+		return StackSafari
+	case strings.Contains(ua, "firefox/"):
+		return StackFirefox
+	case strings.Contains(ua, "chrome/") || strings.Contains(ua, "chromium/"):
+		return StackChromium
+	case strings.Contains(ua, "safari/") && strings.Contains(ua, "version/"):
+		return StackSafari
+	}
+	return ""
+}
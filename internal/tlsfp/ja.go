@@ -0,0 +1,157 @@
+package tlsfp
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JA3原始字符串: 版本,加密套件,扩展,椭圆曲线,点格式，均为十进制并忽略GREASE
+func (h *ClientHello) JA3String() string {
+	return strings.Join([]string{
+		strconv.Itoa(int(h.Version)),
+		joinDecimal(h.CipherSuites),
+		joinDecimal(h.Extensions),
+		joinDecimal(h.Curves),
+		joinDecimal(pointFormats(h.PointFormats)),
+	}, ",")
+}
+
+// JA3指纹（JA3字符串的MD5）
+func (h *ClientHello) JA3() string {
+	sum := md5.Sum([]byte(h.JA3String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// JA4指纹（TCP），格式为 t13d1516h2_<套件哈希>_<扩展哈希>。
+// 套件和扩展排序后再哈希，不受Chrome扩展顺序随机化影响
+func (h *ClientHello) JA4() string {
+	sni := "i"
+	if h.ServerName != "" {
+		sni = "d"
+	}
+
+	var ciphers, extensions []string
+	for _, v := range h.CipherSuites {
+		if !isGREASE(v) {
+			ciphers = append(ciphers, fmt.Sprintf("%04x", v))
+		}
+	}
+	extensionCount := 0
+	for _, v := range h.Extensions {
+		if isGREASE(v) {
+			continue
+		}
+		extensionCount++
+		// SNI和ALPN已体现在第一段，不参与扩展哈希
+		if v != extServerName && v != extALPN {
+			extensions = append(extensions, fmt.Sprintf("%04x", v))
+		}
+	}
+
+	prefix := fmt.Sprintf("t%s%s%02d%02d%s",
+		ja4Version(h.MaxVersion()), sni, min(len(ciphers), 99), min(extensionCount, 99), ja4ALPN(h.ALPN))
+
+	sort.Strings(ciphers)
+	sort.Strings(extensions)
+	extensionPart := strings.Join(extensions, ",")
+	if len(h.SignatureAlgorithms) > 0 {
+		var algorithms []string
+		for _, v := range h.SignatureAlgorithms {
+			algorithms = append(algorithms, fmt.Sprintf("%04x", v))
+		}
+		extensionPart += "_" + strings.Join(algorithms, ",")
+	}
+
+	return prefix + "_" + truncatedHash(strings.Join(ciphers, ","), len(ciphers) == 0) +
+		"_" + truncatedHash(extensionPart, len(extensions) == 0)
+}
+
+func ja4Version(v uint16) string {
+	switch v {
+	case 0x0304:
+		return "13"
+	case 0x0303:
+		return "12"
+	case 0x0302:
+		return "11"
+	case 0x0301:
+		return "10"
+	case 0x0300:
+		return "s3"
+	case 0x0002:
+		return "s2"
+	case 0xfeff:
+		return "d1"
+	case 0xfefd:
+		return "d2"
+	case 0xfefc:
+		return "d3"
+	}
+	return "00"
+}
+
+// 第一个ALPN值的首尾字符，非字母数字时取首字节高4位和末字节低4位的十六进制
+func ja4ALPN(protocols []string) string {
+	if len(protocols) == 0 || protocols[0] == "" {
+		return "00"
+	}
+	first, last := protocols[0][0], protocols[0][len(protocols[0])-1]
+	if isAlphanumeric(first) && isAlphanumeric(last) {
+		return string([]byte{first, last})
+	}
+	const digits = "0123456789abcdef"
+	return string([]byte{digits[first>>4], digits[last&0x0f]})
+}
+
+func isAlphanumeric(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// SHA256前12位，列表为空时为全0
+func truncatedHash(value string, empty bool) string {
+	if empty {
+		return "000000000000"
+	}
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+func joinDecimal(values []uint16) string {
+	var parts []string
+	for _, v := range values {
+		if !isGREASE(v) {
+			parts = append(parts, strconv.Itoa(int(v)))
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+func pointFormats(formats []uint8) []uint16 {
+	values := make([]uint16, len(formats))
+	for i, f := range formats {
+		values[i] = uint16(f)
+	}
+	return values
+}
+
+// 可读的TLS版本名
+func VersionName(v uint16) string {
+	switch v {
+	case 0x0304:
+		return "TLS1.3"
+	case 0x0303:
+		return "TLS1.2"
+	case 0x0302:
+		return "TLS1.1"
+	case 0x0301:
+		return "TLS1.0"
+	case 0x0300:
+		return "SSL3.0"
+	}
+	return fmt.Sprintf("0x%04x", v)
+}
@@ -0,0 +1,142 @@
+package tlsfp
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// TLS服务配置
+type Config struct {
+	Enabled           bool              `yaml:"enabled"`
+	CertFile          string            `yaml:"cert_file"`
+	KeyFile           string            `yaml:"key_file"`
+	ReloadInterval    time.Duration     `yaml:"reload_interval"`    // 检查证书文件变化的间隔
+	FingerprintWeight float64           `yaml:"fingerprint_weight"` // JA4在用户指纹中的权重，0表示不参与
+	Signatures        map[string]string `yaml:"signatures"`         // 已知JA4到客户端TLS栈的映射，优先于内置规则
+}
+
+// 默认TLS配置
+var DefaultConfig = Config{
+	Enabled:        false,
+	ReloadInterval: 30 * time.Second,
+}
+
+// 根据证书加载器创建服务端TLS配置
+func NewServerConfig(certs *CertReloader) *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: certs.GetCertificate,
+	}
+}
+
+// 在TLS握手前记录客户端发送的ClientHello的监听器，需位于tls.NewListener之下
+type Listener struct {
+	net.Listener
+}
+
+// 包装监听器
+func NewListener(inner net.Listener) *Listener {
+	return &Listener{Listener: inner}
+}
+
+// 接受连接
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{Conn: conn}, nil
+}
+
+// 记录ClientHello的连接。握手期间缓存读到的字节，解析出ClientHello后停止缓存
+type Conn struct {
+	net.Conn
+
+	mu       sync.Mutex
+	done     bool
+	buffer   []byte
+	hello    *ClientHello
+	helloErr error
+}
+
+func (c *Conn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.capture(b[:n])
+	}
+	return n, err
+}
+
+func (c *Conn) capture(data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.done {
+		return
+	}
+
+	c.buffer = append(c.buffer, data...)
+	body, err := readHandshakeMessage(c.buffer)
+	if errors.Is(err, errShortHello) {
+		if len(c.buffer) <= maxClientHelloSize+recordHeaderLen*16 {
+			return
+		}
+		err = fmt.Errorf("ClientHello过大")
+	}
+	if err == nil {
+		c.hello, err = ParseClientHello(body)
+	}
+	c.helloErr = err
+	c.done = true
+	c.buffer = nil
+}
+
+// 客户端的ClientHello，握手尚未完成或解析失败时返回nil
+func (c *Conn) ClientHello() *ClientHello {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hello
+}
+
+// 解析ClientHello时的错误
+func (c *Conn) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.helloErr
+}
+
+// 被包装的连接，供其他包继续解开包装（如PROXY协议连接）
+func (c *Conn) NetConn() net.Conn {
+	return c.Conn
+}
+
+type contextKey struct{}
+
+// 将连接保存到context，用于http.Server.ConnContext。
+// ConnContext在握手前调用，ClientHello在请求到达时才读取
+func ContextWithConn(ctx context.Context, conn net.Conn) context.Context {
+	for conn != nil {
+		if c, ok := conn.(*Conn); ok {
+			return context.WithValue(ctx, contextKey{}, c)
+		}
+		wrapper, ok := conn.(interface{ NetConn() net.Conn })
+		if !ok {
+			break
+		}
+		conn = wrapper.NetConn()
+	}
+	return ctx
+}
+
+// 获取请求所在连接的ClientHello，非TLS连接返回nil
+func FromContext(ctx context.Context) *ClientHello {
+	c, ok := ctx.Value(contextKey{}).(*Conn)
+	if !ok {
+		return nil
+	}
+	return c.ClientHello()
+}
@@ -0,0 +1,144 @@
+package tracing
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "securefingerprint"
+
+// 导出器类型
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// span属性键
+const (
+	AttrFingerprint = attribute.Key("firewall.fingerprint_hash")
+	AttrScore       = attribute.Key("firewall.score")
+	AttrRiskLevel   = attribute.Key("firewall.risk_level")
+	AttrAction      = attribute.Key("firewall.action")
+	AttrReason      = attribute.Key("firewall.reason")
+	AttrDelayMs     = attribute.Key("firewall.delay_ms")
+	AttrBatchSize   = attribute.Key("firewall.batch_size")
+	AttrShadow      = attribute.Key("firewall.shadow_action")
+)
+
+// 链路追踪配置
+type Config struct {
+	Enabled     bool    `yaml:"enabled"`
+	Exporter    string  `yaml:"exporter"`     // otlp 或 stdout
+	Endpoint    string  `yaml:"endpoint"`     // OTLP/HTTP地址，如 localhost:4318
+	Insecure    bool    `yaml:"insecure"`     // 不使用TLS连接OTLP端点
+	ServiceName string  `yaml:"service_name"` // 上报的服务名
+	SampleRatio float64 `yaml:"sample_ratio"` // 根span采样率，0~1
+}
+
+// 默认链路追踪配置
+var DefaultConfig = Config{
+	Exporter:    ExporterOTLP,
+	Endpoint:    "localhost:4318",
+	ServiceName: "firewall-controller",
+	SampleRatio: 1,
+}
+
+// 初始化全局TracerProvider与W3C传播器，返回关闭函数
+func Init(config Config) (func(ctx context.Context) error, error) {
+	// 无论是否启用导出都传播trace context，保证上下游链路不断
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if !config.Enabled {
+		return func(ctx context.Context) error { return nil }, nil
+	}
+	if config.ServiceName == "" {
+		config.ServiceName = DefaultConfig.ServiceName
+	}
+
+	exporter, err := newExporter(config)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(config.ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("创建追踪资源失败: %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+func newExporter(config Config) (sdktrace.SpanExporter, error) {
+	switch config.Exporter {
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return nil, fmt.Errorf("创建stdout导出器失败: %v", err)
+		}
+		return exporter, nil
+	case ExporterOTLP, "":
+		endpoint := config.Endpoint
+		if endpoint == "" {
+			endpoint = DefaultConfig.Endpoint
+		}
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint)}
+		if config.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(context.Background(), opts...)
+		if err != nil {
+			return nil, fmt.Errorf("创建OTLP导出器失败: %v", err)
+		}
+		return exporter, nil
+	default:
+		return nil, fmt.Errorf("不支持的追踪导出器: %s", config.Exporter)
+	}
+}
+
+// 获取Tracer
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// 从请求头提取上游trace context
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// 将trace context写入请求头，供下游处理器或转发的上游服务使用
+func Inject(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// 指纹哈希，避免在链路数据中暴露原始指纹
+func HashFingerprint(fingerprint string) string {
+	sum := sha256.Sum256([]byte(fingerprint))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,143 @@
+package useragent
+
+import (
+	"net/http"
+	"strings"
+)
+
+// 浏览器品牌和版本
+type Brand struct {
+	Brand   string `json:"brand"`
+	Version string `json:"version"`
+}
+
+// User-Agent Client Hints（Sec-CH-UA*请求头）
+type ClientHints struct {
+	Brands          []Brand `json:"brands,omitempty"`
+	FullVersionList []Brand `json:"full_version_list,omitempty"`
+	Mobile          *bool   `json:"mobile,omitempty"`
+	Platform        string  `json:"platform,omitempty"`
+	PlatformVersion string  `json:"platform_version,omitempty"`
+	Model           string  `json:"model,omitempty"`
+}
+
+// UA与Client Hints的矛盾类型
+const (
+	ContradictionNonChromium  = "hints_from_non_chromium" // 非Chromium内核的UA发送了Client Hints
+	ContradictionBrandVersion = "brand_version_mismatch"  // Chromium品牌版本与UA版本不一致
+	ContradictionBrand        = "brand_mismatch"          // 品牌与UA浏览器不一致
+	ContradictionPlatform     = "platform_mismatch"       // 平台与UA操作系统不一致
+	ContradictionMobile       = "mobile_mismatch"         // 移动端标记与UA设备类型不一致
+)
+
+// Client Hints品牌对应的UA浏览器名称
+var brandBrowsers = map[string]string{
+	"Google Chrome":  "Chrome",
+	"Microsoft Edge": "Edge",
+	"Opera":          "Opera",
+}
+
+// Client Hints平台对应的UA操作系统名称
+var platformOS = map[string]string{
+	"Windows":     "Windows",
+	"macOS":       "macOS",
+	"Linux":       "Linux",
+	"Android":     "Android",
+	"iOS":         "iOS",
+	"Chrome OS":   "Chrome OS",
+	"Chromium OS": "Chrome OS",
+}
+
+// 解析请求中的Client Hints，没有任何Sec-CH-UA*请求头时返回nil
+func ParseClientHints(h http.Header) *ClientHints {
+	hints := &ClientHints{
+		Brands:          parseBrands(h.Get("Sec-CH-UA")),
+		FullVersionList: parseBrands(h.Get("Sec-CH-UA-Full-Version-List")),
+		Platform:        unquote(h.Get("Sec-CH-UA-Platform")),
+		PlatformVersion: unquote(h.Get("Sec-CH-UA-Platform-Version")),
+		Model:           unquote(h.Get("Sec-CH-UA-Model")),
+	}
+	switch strings.TrimSpace(h.Get("Sec-CH-UA-Mobile")) {
+	case "?1":
+		mobile := true
+		hints.Mobile = &mobile
+	case "?0":
+		mobile := false
+		hints.Mobile = &mobile
+	}
+	if hints.Brands == nil && hints.FullVersionList == nil && hints.Mobile == nil &&
+		hints.Platform == "" && hints.PlatformVersion == "" && hints.Model == "" {
+		return nil
+	}
+	return hints
+}
+
+// 解析品牌列表，如 "Chromium";v="124", "Not-A.Brand";v="99"，忽略GREASE品牌
+func parseBrands(value string) []Brand {
+	var brands []Brand
+	for _, item := range strings.Split(value, ",") {
+		parts := strings.Split(item, ";")
+		brand := Brand{Brand: unquote(parts[0])}
+		if brand.Brand == "" || isGrease(brand.Brand) {
+			continue
+		}
+		for _, param := range parts[1:] {
+			if key, val, ok := strings.Cut(strings.TrimSpace(param), "="); ok && key == "v" {
+				brand.Version = unquote(val)
+			}
+		}
+		brands = append(brands, brand)
+	}
+	return brands
+}
+
+// GREASE品牌用于防止服务端硬编码品牌列表，如 "Not?A_Brand"
+func isGrease(brand string) bool {
+	return strings.Contains(brand, "Not") && strings.Contains(brand, "Brand")
+}
+
+func unquote(value string) string {
+	return strings.Trim(strings.TrimSpace(value), `"`)
+}
+
+// 品牌版本，未找到时返回空字符串
+func (h *ClientHints) Version(brand string) string {
+	for _, list := range [][]Brand{h.FullVersionList, h.Brands} {
+		for _, b := range list {
+			if b.Brand == brand {
+				return b.Version
+			}
+		}
+	}
+	return ""
+}
+
+// 检查UA与Client Hints是否矛盾
+func (a *Agent) contradictions() []string {
+	var found []string
+	hints := a.Hints
+	if len(hints.Brands) > 0 && a.Engine.Name != "Blink" {
+		// 只有Chromium内核支持Client Hints，其余检查没有意义
+		return append(found, ContradictionNonChromium)
+	}
+
+	if version := hints.Version("Chromium"); version != "" && a.Engine.Major > 0 && major(version) != a.Engine.Major {
+		found = append(found, ContradictionBrandVersion)
+	}
+
+	for _, b := range hints.Brands {
+		if browser, ok := brandBrowsers[b.Brand]; ok && a.Browser.Name != browser {
+			found = append(found, ContradictionBrand)
+			break
+		}
+	}
+
+	if os, ok := platformOS[hints.Platform]; ok && a.OS.Name != "" && a.OS.Name != os {
+		found = append(found, ContradictionPlatform)
+	}
+
+	if hints.Mobile != nil && ((*hints.Mobile && a.Device == "desktop") || (!*hints.Mobile && a.Device == "mobile")) {
+		found = append(found, ContradictionMobile)
+	}
+	return found
+}
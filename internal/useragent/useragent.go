@@ -0,0 +1,235 @@
+package useragent
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed patterns.yaml
+var defaultPatterns []byte
+
+var (
+	defaultParser     *Parser
+	defaultParserOnce sync.Once
+)
+
+// UA解析配置
+type Config struct {
+	PatternFile string   `yaml:"pattern_file"` // 模式库文件，为空时使用内置模式库
+	AcceptCH    []string `yaml:"accept_ch"`    // 通过Accept-CH响应头请求的高熵Client Hints
+}
+
+// 解析结果中的名称和版本
+type Component struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	Major   int    `json:"major,omitempty"`
+}
+
+// 识别出的机器人或HTTP库
+type Bot struct {
+	Name     string `json:"name"`
+	Category string `json:"category"` // search_engine/social/monitoring/tool/library/headless/crawler/unknown
+}
+
+// 结构化的UA解析结果
+type Agent struct {
+	Browser        Component    `json:"browser"`
+	OS             Component    `json:"os"`
+	Engine         Component    `json:"engine"`
+	Device         string       `json:"device"` // desktop/mobile/tablet/tv/console/bot
+	Bot            *Bot         `json:"bot,omitempty"`
+	Hints          *ClientHints `json:"client_hints,omitempty"`
+	Contradictions []string     `json:"contradictions,omitempty"` // UA与Client Hints的矛盾
+}
+
+// 模式库中的规则
+type rule struct {
+	Name     string            `yaml:"name"`
+	Pattern  string            `yaml:"pattern"`
+	Exclude  string            `yaml:"exclude"`
+	Version  *int              `yaml:"version"`  // 版本号所在的捕获组，默认1（没有捕获组时为0），0表示不提取
+	Category string            `yaml:"category"` // 机器人分类
+	Versions map[string]string `yaml:"versions"` // 版本号到显示名称的映射（如Windows NT 6.1为7）
+
+	pattern *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// 模式库
+type database struct {
+	Version  int     `yaml:"version"`
+	Bots     []*rule `yaml:"bots"`
+	Browsers []*rule `yaml:"browsers"`
+	OS       []*rule `yaml:"os"`
+	Engines  []*rule `yaml:"engines"`
+	Devices  []*rule `yaml:"devices"`
+}
+
+// UA解析器
+type Parser struct {
+	db *database
+}
+
+// 创建解析器，配置了模式库文件时从文件加载，否则使用内置模式库
+func NewParser(config Config) (*Parser, error) {
+	if config.PatternFile == "" {
+		return Default(), nil
+	}
+	data, err := os.ReadFile(config.PatternFile)
+	if err != nil {
+		return nil, fmt.Errorf("读取UA模式库失败: %v", err)
+	}
+	return Parse(data)
+}
+
+// 从YAML数据创建解析器
+func Parse(data []byte) (*Parser, error) {
+	var db database
+	if err := yaml.Unmarshal(data, &db); err != nil {
+		return nil, fmt.Errorf("解析UA模式库失败: %v", err)
+	}
+	for section, rules := range map[string][]*rule{
+		"bots": db.Bots, "browsers": db.Browsers, "os": db.OS, "engines": db.Engines, "devices": db.Devices,
+	} {
+		for i, r := range rules {
+			if err := r.compile(); err != nil {
+				return nil, fmt.Errorf("UA模式库%s第%d条规则无效: %v", section, i+1, err)
+			}
+		}
+	}
+	return &Parser{db: &db}, nil
+}
+
+// 内置模式库的解析器，内置模式库无效时panic
+func Default() *Parser {
+	defaultParserOnce.Do(func() {
+		parser, err := Parse(defaultPatterns)
+		if err != nil {
+			panic(err)
+		}
+		defaultParser = parser
+	})
+	return defaultParser
+}
+
+// 模式库版本
+func (p *Parser) Version() int {
+	return p.db.Version
+}
+
+func (r *rule) compile() error {
+	if r.Pattern == "" {
+		return fmt.Errorf("缺少pattern")
+	}
+	var err error
+	if r.pattern, err = regexp.Compile(r.Pattern); err != nil {
+		return err
+	}
+	if r.Exclude != "" {
+		if r.exclude, err = regexp.Compile(r.Exclude); err != nil {
+			return err
+		}
+	}
+	group := r.group()
+	if group > r.pattern.NumSubexp() {
+		return fmt.Errorf("版本捕获组%d不存在", group)
+	}
+	if r.Name == "" && r.pattern.NumSubexp() == 0 {
+		return fmt.Errorf("未配置name且没有捕获组")
+	}
+	return nil
+}
+
+func (r *rule) group() int {
+	if r.Version == nil {
+		return min(1, r.pattern.NumSubexp())
+	}
+	return *r.Version
+}
+
+// 按规则匹配，返回名称和版本
+func (r *rule) match(ua string) (Component, bool) {
+	groups := r.pattern.FindStringSubmatch(ua)
+	if groups == nil || (r.exclude != nil && r.exclude.MatchString(ua)) {
+		return Component{}, false
+	}
+	component := Component{Name: r.Name}
+	if component.Name == "" {
+		component.Name = groups[1]
+	}
+	if group := r.group(); group > 0 {
+		component.Version = strings.ReplaceAll(groups[group], "_", ".")
+		if name, ok := r.Versions[component.Version]; ok {
+			component.Version = name
+		}
+		component.Major = major(component.Version)
+	}
+	return component, true
+}
+
+// 依次匹配规则，返回第一个匹配的规则
+func first(rules []*rule, ua string) (Component, *rule) {
+	for _, r := range rules {
+		if component, ok := r.match(ua); ok {
+			return component, r
+		}
+	}
+	return Component{}, nil
+}
+
+// 版本号的主版本
+func major(version string) int {
+	n := 0
+	for _, c := range version {
+		if c < '0' || c > '9' {
+			break
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n
+}
+
+// 解析UA字符串
+func (p *Parser) Parse(userAgent string) *Agent {
+	agent := &Agent{}
+	agent.Browser, _ = first(p.db.Browsers, userAgent)
+	agent.OS, _ = first(p.db.OS, userAgent)
+	agent.Engine, _ = first(p.db.Engines, userAgent)
+
+	if userAgent == "" {
+		agent.Bot = &Bot{Name: "empty", Category: "unknown"}
+	} else if bot, r := first(p.db.Bots, userAgent); r != nil {
+		agent.Bot = &Bot{Name: bot.Name, Category: r.Category}
+	} else if len(userAgent) < 10 && agent.Browser.Name == "" {
+		// 过短且不含浏览器标识
+		agent.Bot = &Bot{Name: userAgent, Category: "unknown"}
+	}
+
+	switch device, r := first(p.db.Devices, userAgent); {
+	case agent.Bot != nil:
+		agent.Device = "bot"
+	case r != nil:
+		agent.Device = device.Name
+	default:
+		agent.Device = "desktop"
+	}
+	return agent
+}
+
+// 解析请求的UA和Client Hints，并检查两者是否矛盾
+func (p *Parser) ParseRequest(r *http.Request) *Agent {
+	agent := p.Parse(r.UserAgent())
+	agent.Hints = ParseClientHints(r.Header)
+	if agent.Hints != nil {
+		agent.Contradictions = agent.contradictions()
+	}
+	return agent
+}
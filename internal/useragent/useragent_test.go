@@ -0,0 +1,533 @@
+package useragent
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		ua      string
+		browser Component
+		os      Component
+		engine  string
+		device  string
+		bot     *Bot
+	}{
+		{
+			name:    "Windows Chrome",
+			ua:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			browser: Component{Name: "Chrome", Version: "124.0.0.0", Major: 124},
+			os:      Component{Name: "Windows", Version: "10", Major: 10},
+			engine:  "Blink",
+			device:  "desktop",
+		},
+		{
+			name:    "Windows 7 Chrome",
+			ua:      "Mozilla/5.0 (Windows NT 6.1; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/109.0.0.0 Safari/537.36",
+			browser: Component{Name: "Chrome", Version: "109.0.0.0", Major: 109},
+			os:      Component{Name: "Windows", Version: "7", Major: 7},
+			engine:  "Blink",
+			device:  "desktop",
+		},
+		{
+			name:    "Edge",
+			ua:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.2478.51",
+			browser: Component{Name: "Edge", Version: "124.0.2478.51", Major: 124},
+			os:      Component{Name: "Windows", Version: "10", Major: 10},
+			engine:  "Blink",
+			device:  "desktop",
+		},
+		{
+			name:    "Edge Legacy",
+			ua:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/70.0.3538.102 Safari/537.36 Edge/18.19045",
+			browser: Component{Name: "Edge Legacy", Version: "18.19045", Major: 18},
+			os:      Component{Name: "Windows", Version: "10", Major: 10},
+			engine:  "EdgeHTML",
+			device:  "desktop",
+		},
+		{
+			name:    "Android Edge",
+			ua:      "Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36 EdgA/124.0.2478.50",
+			browser: Component{Name: "Edge", Version: "124.0.2478.50", Major: 124},
+			os:      Component{Name: "Android", Version: "10", Major: 10},
+			engine:  "Blink",
+			device:  "mobile",
+		},
+		{
+			name:    "Opera",
+			ua:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/123.0.0.0 Safari/537.36 OPR/109.0.0.0",
+			browser: Component{Name: "Opera", Version: "109.0.0.0", Major: 109},
+			os:      Component{Name: "Windows", Version: "10", Major: 10},
+			engine:  "Blink",
+			device:  "desktop",
+		},
+		{
+			name:    "macOS Safari",
+			ua:      "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4.1 Safari/605.1.15",
+			browser: Component{Name: "Safari", Version: "17.4.1", Major: 17},
+			os:      Component{Name: "macOS", Version: "10.15.7", Major: 10},
+			engine:  "WebKit",
+			device:  "desktop",
+		},
+		{
+			name:    "macOS Firefox",
+			ua:      "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:125.0) Gecko/20100101 Firefox/125.0",
+			browser: Component{Name: "Firefox", Version: "125.0", Major: 125},
+			os:      Component{Name: "macOS", Version: "10.15", Major: 10},
+			engine:  "Gecko",
+			device:  "desktop",
+		},
+		{
+			name:    "Linux Firefox",
+			ua:      "Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0",
+			browser: Component{Name: "Firefox", Version: "125.0", Major: 125},
+			os:      Component{Name: "Linux"},
+			engine:  "Gecko",
+			device:  "desktop",
+		},
+		{
+			name:    "iPhone Safari在macOS之前识别为iOS",
+			ua:      "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4.1 Mobile/15E148 Safari/604.1",
+			browser: Component{Name: "Safari", Version: "17.4.1", Major: 17},
+			os:      Component{Name: "iOS", Version: "17.4.1", Major: 17},
+			engine:  "WebKit",
+			device:  "mobile",
+		},
+		{
+			name:    "iPhone CriOS识别为Chrome",
+			ua:      "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/124.0.6367.71 Mobile/15E148 Safari/604.1",
+			browser: Component{Name: "Chrome", Version: "124.0.6367.71", Major: 124},
+			os:      Component{Name: "iOS", Version: "17.4", Major: 17},
+			engine:  "WebKit",
+			device:  "mobile",
+		},
+		{
+			name:    "iPhone FxiOS识别为Firefox",
+			ua:      "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) FxiOS/125.0 Mobile/15E148 Safari/605.1.15",
+			browser: Component{Name: "Firefox", Version: "125.0", Major: 125},
+			os:      Component{Name: "iOS", Version: "17.4", Major: 17},
+			engine:  "WebKit",
+			device:  "mobile",
+		},
+		{
+			name:    "iPad",
+			ua:      "Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.6 Mobile/15E148 Safari/604.1",
+			browser: Component{Name: "Safari", Version: "16.6", Major: 16},
+			os:      Component{Name: "iOS", Version: "16.6", Major: 16},
+			engine:  "WebKit",
+			device:  "tablet",
+		},
+		{
+			name:    "Android手机",
+			ua:      "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.6367.82 Mobile Safari/537.36",
+			browser: Component{Name: "Chrome", Version: "124.0.6367.82", Major: 124},
+			os:      Component{Name: "Android", Version: "14", Major: 14},
+			engine:  "Blink",
+			device:  "mobile",
+		},
+		{
+			name:    "不含Mobile的Android平板",
+			ua:      "Mozilla/5.0 (Linux; Android 13; Pixel Tablet) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			browser: Component{Name: "Chrome", Version: "124.0.0.0", Major: 124},
+			os:      Component{Name: "Android", Version: "13", Major: 13},
+			engine:  "Blink",
+			device:  "tablet",
+		},
+		{
+			name:    "三星平板",
+			ua:      "Mozilla/5.0 (Linux; Android 13; SM-X710) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/24.0 Chrome/117.0.0.0 Safari/537.36",
+			browser: Component{Name: "Samsung Internet", Version: "24.0", Major: 24},
+			os:      Component{Name: "Android", Version: "13", Major: 13},
+			engine:  "Blink",
+			device:  "tablet",
+		},
+		{
+			name:    "微信",
+			ua:      "Mozilla/5.0 (Linux; Android 12; V2148A Build/SP1A.210812.003; wv) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/101.0.4951.74 Mobile Safari/537.36 MicroMessenger/8.0.47.2560(0x28002F35) WeChat/arm64 Weixin NetType/WIFI Language/zh_CN ABI/arm64",
+			browser: Component{Name: "WeChat", Version: "8.0.47.2560", Major: 8},
+			os:      Component{Name: "Android", Version: "12", Major: 12},
+			engine:  "Blink",
+			device:  "mobile",
+		},
+		{
+			name:    "鸿蒙",
+			ua:      "Mozilla/5.0 (Phone; OpenHarmony 4.0; HarmonyOS 4.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/114.0.0.0 Safari/537.36 ArkWeb/4.1.6.1 Mobile",
+			browser: Component{Name: "Chrome", Version: "114.0.0.0", Major: 114},
+			os:      Component{Name: "HarmonyOS", Version: "4.0", Major: 4},
+			engine:  "Blink",
+			device:  "mobile",
+		},
+		{
+			name:    "Chrome OS",
+			ua:      "Mozilla/5.0 (X11; CrOS x86_64 14541.0.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			browser: Component{Name: "Chrome", Version: "124.0.0.0", Major: 124},
+			os:      Component{Name: "Chrome OS", Version: "14541.0.0", Major: 14541},
+			engine:  "Blink",
+			device:  "desktop",
+		},
+		{
+			name:    "IE11",
+			ua:      "Mozilla/5.0 (Windows NT 6.1; WOW64; Trident/7.0; rv:11.0) like Gecko",
+			browser: Component{Name: "IE", Version: "11.0", Major: 11},
+			os:      Component{Name: "Windows", Version: "7", Major: 7},
+			engine:  "Trident",
+			device:  "desktop",
+		},
+		{
+			name:   "智能电视",
+			ua:     "Mozilla/5.0 (SMART-TV; Linux; Tizen 6.0) AppleWebKit/537.36 (KHTML, like Gecko) 85.0.4183.93/6.0 TV Safari/537.36",
+			os:     Component{Name: "Linux"},
+			engine: "WebKit",
+			device: "tv",
+		},
+		{
+			name:   "Googlebot",
+			ua:     "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			device: "bot",
+			bot:    &Bot{Name: "Googlebot", Category: "search_engine"},
+		},
+		{
+			name:    "移动版Googlebot",
+			ua:      "Mozilla/5.0 (Linux; Android 6.0.1; Nexus 5X Build/MMB29P) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.6367.82 Mobile Safari/537.36 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			browser: Component{Name: "Chrome", Version: "124.0.6367.82", Major: 124},
+			os:      Component{Name: "Android", Version: "6.0.1", Major: 6},
+			engine:  "Blink",
+			device:  "bot",
+			bot:     &Bot{Name: "Googlebot", Category: "search_engine"},
+		},
+		{
+			name:   "社交预览",
+			ua:     "facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)",
+			device: "bot",
+			bot:    &Bot{Name: "facebookexternalhit", Category: "social"},
+		},
+		{
+			name:   "curl",
+			ua:     "curl/8.5.0",
+			device: "bot",
+			bot:    &Bot{Name: "curl", Category: "library"},
+		},
+		{
+			name:   "Go-http-client",
+			ua:     "Go-http-client/1.1",
+			device: "bot",
+			bot:    &Bot{Name: "Go-http-client", Category: "library"},
+		},
+		{
+			name:   "python-requests",
+			ua:     "python-requests/2.31.0",
+			device: "bot",
+			bot:    &Bot{Name: "python-requests", Category: "library"},
+		},
+		{
+			name:   "Postman",
+			ua:     "PostmanRuntime/7.37.3",
+			device: "bot",
+			bot:    &Bot{Name: "PostmanRuntime", Category: "tool"},
+		},
+		{
+			name:    "无头Chrome",
+			ua:      "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/124.0.6367.60 Safari/537.36",
+			browser: Component{Name: "HeadlessChrome", Version: "124.0.6367.60", Major: 124},
+			os:      Component{Name: "Linux"},
+			engine:  "Blink",
+			device:  "bot",
+			bot:     &Bot{Name: "HeadlessChrome", Category: "headless"},
+		},
+		{
+			name:   "通用爬虫",
+			ua:     "Mozilla/5.0 (compatible; AhrefsBot/7.0; +http://ahrefs.com/robot/)",
+			device: "bot",
+			bot:    &Bot{Name: "AhrefsBot", Category: "crawler"},
+		},
+		{
+			name:   "附带联系地址",
+			ua:     "Mozilla/5.0 (compatible; SomeFetcher/1.0; +https://example.com/about)",
+			device: "bot",
+			bot:    &Bot{Name: "unknown", Category: "crawler"},
+		},
+		{
+			name:    "浏览器UA中的java子串不算HTTP库",
+			ua:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 JavaApp/1.0",
+			browser: Component{Name: "Chrome", Version: "124.0.0.0", Major: 124},
+			os:      Component{Name: "Windows", Version: "10", Major: 10},
+			engine:  "Blink",
+			device:  "desktop",
+		},
+		{
+			name:   "空UA",
+			ua:     "",
+			device: "bot",
+			bot:    &Bot{Name: "empty", Category: "unknown"},
+		},
+		{
+			name:   "过短的UA",
+			ua:     "foo",
+			device: "bot",
+			bot:    &Bot{Name: "foo", Category: "unknown"},
+		},
+	}
+
+	parser := Default()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent := parser.Parse(tt.ua)
+			if agent.Browser != tt.browser {
+				t.Errorf("Browser = %+v, 期望 %+v", agent.Browser, tt.browser)
+			}
+			if agent.OS != tt.os {
+				t.Errorf("OS = %+v, 期望 %+v", agent.OS, tt.os)
+			}
+			if agent.Engine.Name != tt.engine {
+				t.Errorf("Engine = %q, 期望 %q", agent.Engine.Name, tt.engine)
+			}
+			if agent.Device != tt.device {
+				t.Errorf("Device = %q, 期望 %q", agent.Device, tt.device)
+			}
+			if !reflect.DeepEqual(agent.Bot, tt.bot) {
+				t.Errorf("Bot = %+v, 期望 %+v", agent.Bot, tt.bot)
+			}
+		})
+	}
+}
+
+func TestParsePatterns(t *testing.T) {
+	parser, err := Parse([]byte(`
+version: 7
+browsers:
+  - name: Custom
+    pattern: 'Custom/(\d+)'
+os:
+  - pattern: '(Plan9)'
+devices:
+  - name: mobile
+    pattern: 'Phone'
+`))
+	if err != nil {
+		t.Fatalf("Parse() 错误: %v", err)
+	}
+	if parser.Version() != 7 {
+		t.Errorf("Version() = %d, 期望 7", parser.Version())
+	}
+	agent := parser.Parse("Mozilla/5.0 (Plan9; Phone) Custom/3")
+	if agent.Browser != (Component{Name: "Custom", Version: "3", Major: 3}) || agent.OS.Name != "Plan9" || agent.Device != "mobile" {
+		t.Errorf("Parse() = %+v, 期望使用自定义模式库", agent)
+	}
+
+	invalid := []struct {
+		name string
+		data string
+	}{
+		{name: "YAML格式错误", data: "browsers: ["},
+		{name: "缺少pattern", data: "browsers:\n  - name: A\n"},
+		{name: "正则无效", data: "browsers:\n  - name: A\n    pattern: '('\n"},
+		{name: "exclude无效", data: "devices:\n  - name: A\n    pattern: 'a'\n    exclude: '['\n"},
+		{name: "版本捕获组不存在", data: "browsers:\n  - name: A\n    pattern: 'A/(\\d+)'\n    version: 2\n"},
+		{name: "没有名称也没有捕获组", data: "os:\n  - pattern: 'Plan9'\n"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.data)); err == nil {
+				t.Error("Parse() 期望返回错误")
+			}
+		})
+	}
+
+	if _, err := NewParser(Config{PatternFile: "/nonexistent/patterns.yaml"}); err == nil {
+		t.Error("NewParser() 模式库文件不存在时期望返回错误")
+	}
+}
+
+func TestParseClientHints(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    *ClientHints
+	}{
+		{name: "没有Client Hints", headers: map[string]string{"Accept": "*/*"}},
+		{
+			name: "低熵Client Hints",
+			headers: map[string]string{
+				"Sec-CH-UA":          `"Chromium";v="124", "Google Chrome";v="124", "Not-A.Brand";v="99"`,
+				"Sec-CH-UA-Mobile":   "?0",
+				"Sec-CH-UA-Platform": `"Windows"`,
+			},
+			want: &ClientHints{
+				Brands:   []Brand{{Brand: "Chromium", Version: "124"}, {Brand: "Google Chrome", Version: "124"}},
+				Mobile:   &no,
+				Platform: "Windows",
+			},
+		},
+		{
+			name: "高熵Client Hints",
+			headers: map[string]string{
+				"Sec-CH-UA":                   `"Not/A)Brand";v="8", "Chromium";v="126", "Microsoft Edge";v="126"`,
+				"Sec-CH-UA-Full-Version-List": `"Not/A)Brand";v="8.0.0.0", "Chromium";v="126.0.6478.127", "Microsoft Edge";v="126.0.2592.87"`,
+				"Sec-CH-UA-Mobile":            "?1",
+				"Sec-CH-UA-Platform":          `"Android"`,
+				"Sec-CH-UA-Platform-Version":  `"14.0.0"`,
+				"Sec-CH-UA-Model":             `"Pixel 8"`,
+			},
+			want: &ClientHints{
+				Brands:          []Brand{{Brand: "Chromium", Version: "126"}, {Brand: "Microsoft Edge", Version: "126"}},
+				FullVersionList: []Brand{{Brand: "Chromium", Version: "126.0.6478.127"}, {Brand: "Microsoft Edge", Version: "126.0.2592.87"}},
+				Mobile:          &yes,
+				Platform:        "Android",
+				PlatformVersion: "14.0.0",
+				Model:           "Pixel 8",
+			},
+		},
+		{
+			name:    "只有平台",
+			headers: map[string]string{"Sec-CH-UA-Platform": `"macOS"`},
+			want:    &ClientHints{Platform: "macOS"},
+		},
+		{
+			name:    "无效的移动端标记被忽略",
+			headers: map[string]string{"Sec-CH-UA-Mobile": "1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tt.headers {
+				h.Set(k, v)
+			}
+			if got := ParseClientHints(h); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseClientHints() = %+v, 期望 %+v", got, tt.want)
+			}
+		})
+	}
+
+	hints := &ClientHints{
+		Brands:          []Brand{{Brand: "Chromium", Version: "126"}},
+		FullVersionList: []Brand{{Brand: "Chromium", Version: "126.0.6478.127"}},
+	}
+	if v := hints.Version("Chromium"); v != "126.0.6478.127" {
+		t.Errorf("Version(Chromium) = %q, 期望优先使用完整版本", v)
+	}
+	if v := hints.Version("Google Chrome"); v != "" {
+		t.Errorf("Version(Google Chrome) = %q, 期望为空", v)
+	}
+}
+
+func TestContradictions(t *testing.T) {
+	const (
+		chromeWindows = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+		edgeWindows   = chromeWindows + " Edg/124.0.2478.51"
+		chromeAndroid = "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.6367.82 Mobile Safari/537.36"
+		firefox       = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:125.0) Gecko/20100101 Firefox/125.0"
+		chromeBrands  = `"Chromium";v="124", "Google Chrome";v="124", "Not-A.Brand";v="99"`
+	)
+
+	tests := []struct {
+		name    string
+		ua      string
+		headers map[string]string
+		want    []string
+	}{
+		{
+			name: "一致的Chrome",
+			ua:   chromeWindows,
+			headers: map[string]string{
+				"Sec-CH-UA": chromeBrands, "Sec-CH-UA-Mobile": "?0", "Sec-CH-UA-Platform": `"Windows"`,
+			},
+		},
+		{
+			name: "一致的Edge",
+			ua:   edgeWindows,
+			headers: map[string]string{
+				"Sec-CH-UA": `"Chromium";v="124", "Microsoft Edge";v="124", "Not-A.Brand";v="99"`, "Sec-CH-UA-Platform": `"Windows"`,
+			},
+		},
+		{
+			name: "一致的Android",
+			ua:   chromeAndroid,
+			headers: map[string]string{
+				"Sec-CH-UA": chromeBrands, "Sec-CH-UA-Mobile": "?1", "Sec-CH-UA-Platform": `"Android"`,
+			},
+		},
+		{
+			name:    "没有Client Hints不检查",
+			ua:      firefox,
+			headers: nil,
+		},
+		{
+			name:    "Firefox发送了Client Hints",
+			ua:      firefox,
+			headers: map[string]string{"Sec-CH-UA": chromeBrands, "Sec-CH-UA-Platform": `"Linux"`},
+			want:    []string{ContradictionNonChromium},
+		},
+		{
+			name:    "Chromium版本不一致",
+			ua:      chromeWindows,
+			headers: map[string]string{"Sec-CH-UA": `"Chromium";v="120", "Google Chrome";v="120"`},
+			want:    []string{ContradictionBrandVersion},
+		},
+		{
+			name:    "完整版本列表优先",
+			ua:      chromeWindows,
+			headers: map[string]string{"Sec-CH-UA": chromeBrands, "Sec-CH-UA-Full-Version-List": `"Chromium";v="123.0.6312.122"`},
+			want:    []string{ContradictionBrandVersion},
+		},
+		{
+			name:    "Chrome的UA发送Edge品牌",
+			ua:      chromeWindows,
+			headers: map[string]string{"Sec-CH-UA": `"Chromium";v="124", "Microsoft Edge";v="124"`},
+			want:    []string{ContradictionBrand},
+		},
+		{
+			name:    "Windows的UA发送macOS平台",
+			ua:      chromeWindows,
+			headers: map[string]string{"Sec-CH-UA": chromeBrands, "Sec-CH-UA-Platform": `"macOS"`},
+			want:    []string{ContradictionPlatform},
+		},
+		{
+			name:    "未知平台不检查",
+			ua:      chromeWindows,
+			headers: map[string]string{"Sec-CH-UA": chromeBrands, "Sec-CH-UA-Platform": `"Unknown"`},
+		},
+		{
+			name:    "桌面UA声明为移动端",
+			ua:      chromeWindows,
+			headers: map[string]string{"Sec-CH-UA": chromeBrands, "Sec-CH-UA-Mobile": "?1"},
+			want:    []string{ContradictionMobile},
+		},
+		{
+			name:    "移动UA声明为非移动端",
+			ua:      chromeAndroid,
+			headers: map[string]string{"Sec-CH-UA": chromeBrands, "Sec-CH-UA-Mobile": "?0", "Sec-CH-UA-Platform": `"Android"`},
+			want:    []string{ContradictionMobile},
+		},
+		{
+			name: "多个矛盾",
+			ua:   chromeAndroid,
+			headers: map[string]string{
+				"Sec-CH-UA": `"Chromium";v="120", "Microsoft Edge";v="120"`, "Sec-CH-UA-Mobile": "?0", "Sec-CH-UA-Platform": `"Windows"`,
+			},
+			want: []string{ContradictionBrandVersion, ContradictionBrand, ContradictionPlatform, ContradictionMobile},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest("GET", "/", nil)
+			r.Header.Set("User-Agent", tt.ua)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			agent := Default().ParseRequest(r)
+			if !reflect.DeepEqual(agent.Contradictions, tt.want) {
+				t.Errorf("Contradictions = %v, 期望 %v", agent.Contradictions, tt.want)
+			}
+			if (agent.Hints != nil) != (len(tt.headers) > 0) {
+				t.Errorf("Hints = %+v, 期望有Client Hints时才解析", agent.Hints)
+			}
+		})
+	}
+}